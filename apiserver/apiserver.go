@@ -3,6 +3,7 @@ package apiserver
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"time"
@@ -31,6 +32,10 @@ func (o *Options) validate() error {
 	if o.Addr == "" {
 		return errors.New("addr must not be empty")
 	}
+
+	if err := o.Toggles.Validate(); err != nil {
+		return fmt.Errorf("invalid toggles: %w", err)
+	}
 	return nil
 }
 
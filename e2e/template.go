@@ -176,7 +176,7 @@ func baseTemplate(location string) *datamodel.NodeBootstrappingConfiguration {
 					}{
 						PublicKeys: []datamodel.PublicKey{
 							{
-								KeyData: "dummysshkey",
+								KeyData: "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIB+hjmlQ6cmdGOuGL3hWX5jWxj8L3fl5ES+lD5PxYYoG",
 							},
 						},
 					},
@@ -6,16 +6,26 @@ package agent
 import (
 	"archive/zip"
 	"bytes"
+	"crypto/tls"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"path"
 	"reflect"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/Azure/agentbaker/parts"
 	"github.com/Azure/agentbaker/pkg/agent/common"
 	"github.com/Azure/agentbaker/pkg/agent/datamodel"
+	"github.com/Azure/agentbaker/pkg/agent/vhd/cache"
 	"github.com/Azure/go-autorest/autorest/to"
 )
 
@@ -40,6 +50,56 @@ func (t *TemplateGenerator) getNodeBootstrappingPayload(config *datamodel.NodeBo
 	return base64.StdEncoding.EncodeToString([]byte(customData))
 }
 
+/*
+EstimateCustomDataSize approximates the size of the base64 encoded custom data without rendering it. It sums the
+compressed size of the files embedded into the custom data and the size of the config dependent content, so the
+result is close to, but not exactly, the size of the rendered custom data.
+*/
+func EstimateCustomDataSize(config *datamodel.NodeBootstrappingConfiguration) (int, error) {
+	if config == nil || config.ContainerService == nil || config.AgentPoolProfile == nil {
+		return 0, fmt.Errorf("container service and agent pool profile are required to estimate the custom data size")
+	}
+	var size int
+	if config.AgentPoolProfile.IsWindows() {
+		b, err := parts.Templates.ReadFile(kubernetesWindowsAgentCustomDataPS1)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read %s: %w", kubernetesWindowsAgentCustomDataPS1, err)
+		}
+		size = len(b)
+	} else {
+		b, err := parts.Templates.ReadFile(kubernetesNodeCustomDataYaml)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read %s: %w", kubernetesNodeCustomDataYaml, err)
+		}
+		size = len(removeComments(b))
+		for _, file := range getLinuxCloudInitFiles(config) {
+			b, err = parts.Templates.ReadFile(file)
+			if err != nil {
+				return 0, fmt.Errorf("failed to read %s: %w", file, err)
+			}
+			size += len(getBase64EncodedGzippedCustomScriptFromStr(string(removeComments(b))))
+		}
+		if IsKubeletConfigFileEnabled(config.ContainerService, config.AgentPoolProfile, config.EnableKubeletConfigFile) {
+			kubeletConfigFile := GetKubeletConfigFileContent(config.KubeletConfig, config.AgentPoolProfile.CustomKubeletConfig)
+			size += base64.StdEncoding.EncodedLen(len(kubeletConfigFile))
+		}
+	}
+	// the custom data is base64 encoded once more as the node bootstrapping payload.
+	return base64.StdEncoding.EncodedLen(size), nil
+}
+
+// validateCustomDataSize returns a CustomDataTooLargeError if the custom data exceeds the limit of the OS of the node.
+func validateCustomDataSize(config *datamodel.NodeBootstrappingConfiguration, nodeBootstrapping *datamodel.NodeBootstrapping) error {
+	osType, maxSize := datamodel.Linux, datamodel.MaxLinuxCustomDataSize
+	if config.AgentPoolProfile.IsWindows() {
+		osType, maxSize = datamodel.Windows, datamodel.MaxWindowsCustomDataSize
+	}
+	if size := nodeBootstrapping.CustomDataSize(); size > maxSize {
+		return &datamodel.CustomDataTooLargeError{OSType: osType, Size: size, MaxSize: maxSize}
+	}
+	return nil
+}
+
 // GetLinuxNodeCustomDataJSONObject returns Linux customData JSON object in the form.
 // { "customData": "<customData string>" }.
 func (t *TemplateGenerator) getLinuxNodeCustomDataJSONObject(config *datamodel.NodeBootstrappingConfiguration) string {
@@ -84,6 +144,9 @@ func (t *TemplateGenerator) getWindowsNodeCustomDataJSONObject(config *datamodel
 // GetNodeBootstrappingCmd get node bootstrapping cmd.
 // This function only can be called after the validation of the input NodeBootstrappingConfiguration.
 func (t *TemplateGenerator) getNodeBootstrappingCmd(config *datamodel.NodeBootstrappingConfiguration) string {
+	if config.IsArcEnabled() {
+		return getArcNodeCSECommand(config)
+	}
 	if config.AgentPoolProfile.IsWindows() {
 		return t.getWindowsNodeCSECommand(config)
 	}
@@ -112,6 +175,31 @@ func (t *TemplateGenerator) getLinuxNodeCSECommand(config *datamodel.NodeBootstr
 	return strings.ReplaceAll(str, "\n", " ")
 }
 
+/*
+getArcNodeCSECommand returns the CSE command of Arc-connected Linux nodes. Instead of the kubelet bootstrap,
+it installs the Azure Connected Machine agent and connects the machine to Azure Arc with the service principal
+of the cluster, tagged with the connected cluster the node joins.
+*/
+func getArcNodeCSECommand(config *datamodel.NodeBootstrappingConfiguration) string {
+	arcConfig := config.ArcConfig
+	servicePrincipal := config.ContainerService.Properties.ServicePrincipalProfile
+	args := []string{
+		"--service-principal-id", servicePrincipal.ClientID,
+		"--service-principal-secret", servicePrincipal.Secret,
+		"--tenant-id", arcConfig.TenantID,
+		"--subscription-id", config.SubscriptionID,
+		"--resource-group", arcConfig.ResourceGroup,
+		"--location", config.ContainerService.Location,
+		"--tags", "ConnectedCluster=" + arcConfig.ClusterName,
+	}
+	// Only the values, at the odd indices, are quoted.
+	for i := 1; i < len(args); i += 2 {
+		args[i] = "'" + strings.ReplaceAll(args[i], "'", `'\''`) + "'"
+	}
+	return fmt.Sprintf("curl -fsSL -o %s %s && bash %s && azcmagent connect %s", arcAgentInstallScriptFilepath,
+		arcAgentInstallScriptURL, arcAgentInstallScriptFilepath, strings.Join(args, " "))
+}
+
 // getWindowsNodeCSECommand returns Windows node custom script extension execution command.
 func (t *TemplateGenerator) getWindowsNodeCSECommand(config *datamodel.NodeBootstrappingConfiguration) string {
 	// get parameters
@@ -256,80 +344,2371 @@ func normalizeResourceGroupNameForLabel(resourceGroupName string) string {
 		truncated = truncated[0:maxKubernetesLabelLength]
 	}
 
-	if strings.HasSuffix(truncated, "-") ||
-		strings.HasSuffix(truncated, "_") ||
-		strings.HasSuffix(truncated, ".") {
-		if len(truncated) > maxKubernetesLabelLength-1 {
-			return truncated[0:len(truncated)-1] + "z"
-		}
-		return truncated + "z"
+	if strings.HasSuffix(truncated, "-") ||
+		strings.HasSuffix(truncated, "_") ||
+		strings.HasSuffix(truncated, ".") {
+		if len(truncated) > maxKubernetesLabelLength-1 {
+			return truncated[0:len(truncated)-1] + "z"
+		}
+		return truncated + "z"
+	}
+	return truncated
+}
+
+func validateAndSetContainerdSnapshotter(config *datamodel.NodeBootstrappingConfiguration, onVHD *cache.OnVHD) error {
+	switch config.ContainerdSnapshotter {
+	case "":
+		config.ContainerdSnapshotter = datamodel.ContainerdSnapshotterOverlayfs
+	case datamodel.ContainerdSnapshotterOverlayfs:
+	case datamodel.ContainerdSnapshotterStargz:
+		// teleport, artifact streaming and kata configure their own snapshotters.
+		if config.EnableACRTeleportPlugin || config.EnableArtifactStreaming ||
+			(config.AgentPoolProfile != nil && config.AgentPoolProfile.Distro.IsKataDistro()) {
+			return fmt.Errorf("containerd snapshotter %s can not be combined with teleport, artifact streaming or kata",
+				config.ContainerdSnapshotter)
+		}
+		if !onVHD.HasDownloadedFile(stargzSnapshotterComponentName) {
+			return fmt.Errorf("containerd snapshotter %s requires the %s plugin, which is not cached on the VHD",
+				config.ContainerdSnapshotter, stargzSnapshotterComponentName)
+		}
+	default:
+		return fmt.Errorf("unknown containerd snapshotter %q, must be one of %s or %s", config.ContainerdSnapshotter,
+			datamodel.ContainerdSnapshotterOverlayfs, datamodel.ContainerdSnapshotterStargz)
+	}
+	return nil
+}
+
+// validatePackageVersionOverrides validates the package versions the CSE installs instead of the defaults are cached on the VHD.
+func validatePackageVersionOverrides(config *datamodel.NodeBootstrappingConfiguration, onVHD *cache.OnVHD) error {
+	names := make([]string, 0, len(config.PackageVersionOverrides))
+	for name := range config.PackageVersionOverrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if version := config.PackageVersionOverrides[name]; !onVHD.HasDownloadedFileVersion(name, version) {
+			return fmt.Errorf("package %s version %s is not cached on the VHD", name, version)
+		}
+	}
+	return nil
+}
+
+// getPackageVersionOverridesContent returns the package version overrides as sorted, comma-separated name=version pairs.
+func getPackageVersionOverridesContent(overrides map[string]string) string {
+	pairs := make([]string, 0, len(overrides))
+	for name, version := range overrides {
+		pairs = append(pairs, name+"="+version)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// credentialProviderComponentNames maps credential provider binaries to the downloaded file components they are
+// cached in on the VHD, other binaries are cached in a component of their own name.
+//
+//nolint:gochecknoglobals
+var credentialProviderComponentNames = map[string]string{"acr-credential-provider": cloudProviderAzureComponentName}
+
+/*
+validateAndSetCredentialProviders validates the kubelet image credential providers, fills in their default API version
+and points the kubelet flags to the rendered credential provider config.
+*/
+func validateAndSetCredentialProviders(config *datamodel.NodeBootstrappingConfiguration, onVHD *cache.OnVHD) error {
+	if len(config.CredentialProviders) == 0 {
+		return nil
+	}
+	var orchestratorVersion string
+	if orchestratorProfile := config.ContainerService.Properties.OrchestratorProfile; orchestratorProfile != nil {
+		orchestratorVersion = orchestratorProfile.OrchestratorVersion
+	}
+	if !IsKubernetesVersionGe(orchestratorVersion, credentialProvidersMinKubernetesVersion) {
+		return fmt.Errorf("credential providers require kubernetes %s or later, got %s", credentialProvidersMinKubernetesVersion, orchestratorVersion)
+	}
+	if config.K8sComponents != nil && config.K8sComponents.LinuxCredentialProviderURL != "" {
+		return fmt.Errorf("credential providers cannot be used with the out-of-tree credential provider of K8sComponents")
+	}
+	if path, ok := config.KubeletConfig["--image-credential-provider-config"]; ok && path != credentialProviderConfigFilepath {
+		return fmt.Errorf("credential providers cannot be used with kubelet flag --image-credential-provider-config")
+	}
+	nameRegex := regexp.MustCompile(`^[A-Za-z0-9][-A-Za-z0-9_.]*$`)
+	seen := make(map[string]bool, len(config.CredentialProviders))
+	for i := range config.CredentialProviders {
+		provider := &config.CredentialProviders[i]
+		if !nameRegex.MatchString(provider.Name) {
+			return fmt.Errorf("invalid credential provider name %q, it must be the file name of the plugin binary", provider.Name)
+		}
+		if seen[provider.Name] {
+			return fmt.Errorf("duplicate credential provider %s", provider.Name)
+		}
+		seen[provider.Name] = true
+		if len(provider.MatchImages) == 0 {
+			return fmt.Errorf("credential provider %s must match at least one image", provider.Name)
+		}
+		if provider.DefaultCacheDuration == "" {
+			provider.DefaultCacheDuration = datamodel.DefaultCredentialProviderCacheDuration
+		}
+		cacheDuration, err := time.ParseDuration(string(provider.DefaultCacheDuration))
+		if err != nil {
+			return fmt.Errorf("invalid default cache duration of credential provider %s: %w", provider.Name, err)
+		}
+		if cacheDuration <= 0 {
+			return fmt.Errorf("default cache duration of credential provider %s must be positive, got %s", provider.Name, provider.DefaultCacheDuration)
+		}
+		switch provider.APIVersion {
+		case "":
+			provider.APIVersion = datamodel.DefaultCredentialProviderAPIVersion
+		case datamodel.DefaultCredentialProviderAPIVersion, datamodel.CredentialProviderAPIVersionV1Beta1:
+		default:
+			return fmt.Errorf("unsupported API version %q of credential provider %s, must be %s or %s", provider.APIVersion, provider.Name,
+				datamodel.DefaultCredentialProviderAPIVersion, datamodel.CredentialProviderAPIVersionV1Beta1)
+		}
+		componentName := credentialProviderComponentNames[provider.Name]
+		if componentName == "" {
+			componentName = provider.Name
+		}
+		if !onVHD.HasDownloadedFile(componentName) {
+			return fmt.Errorf("credential provider %s is not cached on the VHD", provider.Name)
+		}
+	}
+	if config.KubeletConfig == nil {
+		config.KubeletConfig = make(map[string]string)
+	}
+	config.KubeletConfig["--image-credential-provider-config"] = credentialProviderConfigFilepath
+	config.KubeletConfig["--image-credential-provider-bin-dir"] = credentialProviderBinDir
+	return nil
+}
+
+// getCredentialProviderConfigContent returns the kubelet CredentialProviderConfig of the credential providers, as JSON.
+func getCredentialProviderConfigContent(providers []datamodel.CredentialProviderConfig) (string, error) {
+	content, err := json.MarshalIndent(map[string]interface{}{
+		"apiVersion": "kubelet.config.k8s.io/v1",
+		"kind":       "CredentialProviderConfig",
+		"providers":  providers,
+	}, "", "  ")
+	return string(content), err
+}
+
+func validateContainerdOOMScore(config *datamodel.NodeBootstrappingConfiguration) error {
+	// the range of the systemd OOMScoreAdjust setting.
+	const minOOMScoreAdjust, maxOOMScoreAdjust = -1000, 1000
+	if score := config.ContainerdOOMScore; score != nil && (*score < minOOMScoreAdjust || *score > maxOOMScoreAdjust) {
+		return fmt.Errorf("containerd OOM score must be between %d and %d, got %d", minOOMScoreAdjust, maxOOMScoreAdjust, *score)
+	}
+	return nil
+}
+
+// validateContainerdMaxConcurrentDownloads validates the number of image layers containerd downloads in parallel.
+func validateContainerdMaxConcurrentDownloads(config *datamodel.NodeBootstrappingConfiguration) error {
+	// more parallel layer downloads than that only contend for the bandwidth of the node.
+	const minConcurrentDownloads, maxConcurrentDownloads = 1, 32
+	if downloads := config.ContainerdMaxConcurrentDownloads; downloads != nil &&
+		(*downloads < minConcurrentDownloads || *downloads > maxConcurrentDownloads) {
+		return fmt.Errorf("containerd max concurrent downloads must be between %d and %d, got %d",
+			minConcurrentDownloads, maxConcurrentDownloads, *downloads)
+	}
+	return nil
+}
+
+// validateAndSetSeccompDefault enables seccomp by default in kubelet, if requested.
+func validateAndSetSeccompDefault(config *datamodel.NodeBootstrappingConfiguration) error {
+	if !config.SeccompDefault {
+		return nil
+	}
+	var orchestratorVersion string
+	if orchestratorProfile := config.ContainerService.Properties.OrchestratorProfile; orchestratorProfile != nil {
+		orchestratorVersion = orchestratorProfile.OrchestratorVersion
+	}
+	if !IsKubernetesVersionGe(orchestratorVersion, seccompDefaultMinKubernetesVersion) {
+		return fmt.Errorf("seccomp by default requires kubernetes %s or later, got %s", seccompDefaultMinKubernetesVersion, orchestratorVersion)
+	}
+	if config.KubeletConfig == nil {
+		config.KubeletConfig = make(map[string]string)
+	}
+	config.KubeletConfig["--seccomp-default"] = "true"
+	return nil
+}
+
+/*
+validateAndSetGracefulNodeShutdown validates the graceful node shutdown of kubelet and renders the grace periods into
+the kubelet config file. The critical pod grace period is part of the shutdown grace period, so it must not be longer.
+*/
+func validateAndSetGracefulNodeShutdown(config *datamodel.NodeBootstrappingConfiguration) error {
+	if !isGracefulNodeShutdownEnabled(config) {
+		return nil
+	}
+	shutdown := config.GracefulNodeShutdown
+	gracePeriod, err := time.ParseDuration(string(shutdown.ShutdownGracePeriod))
+	if err != nil {
+		return fmt.Errorf("invalid shutdown grace period %q: %w", shutdown.ShutdownGracePeriod, err)
+	}
+	if gracePeriod <= 0 {
+		return fmt.Errorf("shutdown grace period must be a positive duration, got %s", shutdown.ShutdownGracePeriod)
+	}
+	criticalPodGracePeriod := time.Duration(0)
+	if shutdown.CriticalPodGracePeriod != "" {
+		criticalPodGracePeriod, err = time.ParseDuration(string(shutdown.CriticalPodGracePeriod))
+		if err != nil {
+			return fmt.Errorf("invalid critical pod grace period %q: %w", shutdown.CriticalPodGracePeriod, err)
+		}
+	}
+	if criticalPodGracePeriod < 0 || criticalPodGracePeriod > gracePeriod {
+		return fmt.Errorf("critical pod grace period must be between 0s and the shutdown grace period %s, got %s",
+			shutdown.ShutdownGracePeriod, shutdown.CriticalPodGracePeriod)
+	}
+	cs := config.ContainerService
+	var orchestratorVersion string
+	if cs.Properties.OrchestratorProfile != nil {
+		orchestratorVersion = cs.Properties.OrchestratorProfile.OrchestratorVersion
+	}
+	if !IsKubernetesVersionGe(orchestratorVersion, gracefulNodeShutdownMinKubernetesVersion) {
+		return fmt.Errorf("graceful node shutdown requires kubernetes %s or later, got %s",
+			gracefulNodeShutdownMinKubernetesVersion, orchestratorVersion)
+	}
+	if config.AgentPoolProfile == nil || !IsKubeletConfigFileEnabled(cs, config.AgentPoolProfile, config.EnableKubeletConfigFile) {
+		return fmt.Errorf("graceful node shutdown requires the kubelet config file")
+	}
+	if config.KubeletConfig == nil {
+		config.KubeletConfig = make(map[string]string)
+	}
+	config.KubeletConfig["--shutdown-grace-period"] = string(shutdown.ShutdownGracePeriod)
+	config.KubeletConfig["--shutdown-grace-period-critical-pods"] = criticalPodGracePeriod.String()
+	return nil
+}
+
+// isGracefulNodeShutdownEnabled returns whether kubelet delays the node shutdown to terminate the pods gracefully.
+func isGracefulNodeShutdownEnabled(config *datamodel.NodeBootstrappingConfiguration) bool {
+	return config.GracefulNodeShutdown != nil && config.GracefulNodeShutdown.Enabled
+}
+
+/*
+getGracefulNodeShutdownDropinContent returns the systemd-logind drop-in which allows kubelet to hold its inhibitor
+lock for the whole shutdown grace period, logind releases delay locks after InhibitDelayMaxSec, 5 seconds by default.
+*/
+func getGracefulNodeShutdownDropinContent(config *datamodel.NodeBootstrappingConfiguration) string {
+	if !isGracefulNodeShutdownEnabled(config) {
+		return ""
+	}
+	gracePeriod, err := time.ParseDuration(string(config.GracefulNodeShutdown.ShutdownGracePeriod))
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("[Login]\nInhibitDelayMaxSec=%d\n", int64(math.Ceil(gracePeriod.Seconds())))
+}
+
+// validateNodeProblemDetectorConfig validates the custom node-problem-detector config and that node-problem-detector is cached on the VHD.
+func validateNodeProblemDetectorConfig(config *datamodel.NodeBootstrappingConfiguration, onVHD *cache.OnVHD) error {
+	npdConfig := config.NodeProblemDetectorConfig
+	if npdConfig == nil {
+		return nil
+	}
+	if !npdConfig.Enabled {
+		if len(npdConfig.CustomConfig) > 0 {
+			return fmt.Errorf("a custom node-problem-detector config requires node-problem-detector to be enabled")
+		}
+		return nil
+	}
+	if !onVHD.HasDownloadedFile(npdComponentName) {
+		return fmt.Errorf("node-problem-detector is not cached on the VHD")
+	}
+	if len(npdConfig.CustomConfig) == 0 {
+		return nil
+	}
+	var monitorConfig struct {
+		Plugin string            `json:"plugin"`
+		Source string            `json:"source"`
+		Rules  []json.RawMessage `json:"rules"`
+	}
+	if err := json.Unmarshal(npdConfig.CustomConfig, &monitorConfig); err != nil {
+		return fmt.Errorf("invalid custom node-problem-detector config: %w", err)
+	}
+	if monitorConfig.Plugin == "" || monitorConfig.Source == "" {
+		return fmt.Errorf("invalid custom node-problem-detector config: the plugin and the source of the system log monitor are required")
+	}
+	if len(monitorConfig.Rules) == 0 {
+		return fmt.Errorf("invalid custom node-problem-detector config: at least one rule is required")
+	}
+	return nil
+}
+
+/*
+getNodeProblemDetectorServiceContent returns the systemd service running node-problem-detector with its system log
+monitor configs. It reports the node problems to the API server with the kubeconfig of kubelet.
+*/
+func getNodeProblemDetectorServiceContent(npdConfig *datamodel.NodeProblemDetectorConfig, endpoint string) string {
+	monitorConfigs := npdKernelMonitorConfigFilepath
+	if len(npdConfig.CustomConfig) > 0 {
+		monitorConfigs += "," + npdCustomConfigFilepath
+	}
+	return fmt.Sprintf(`[Unit]
+Description=Node Problem Detector
+After=kubelet.service
+
+[Service]
+ExecStart=%s --apiserver-override=https://%s:443?inClusterConfig=false&auth=%s --config.system-log-monitor=%s
+Restart=always
+RestartSec=10
+
+[Install]
+WantedBy=multi-user.target
+`, npdBinaryFilepath, endpoint, kubeletKubeconfigFilepath, monitorConfigs)
+}
+
+// getContainerdOOMScoreDropinContent returns the systemd drop-in setting the OOMScoreAdjust of the containerd service.
+func getContainerdOOMScoreDropinContent(score int) string {
+	return fmt.Sprintf("[Service]\nOOMScoreAdjust=%d\n", score)
+}
+
+// validateAndSetContainerdDirs validates the custom root and state directories of containerd.
+func validateAndSetContainerdDirs(config *datamodel.NodeBootstrappingConfiguration) error {
+	for _, dir := range []struct {
+		name  string
+		value *string
+	}{
+		{"root", &config.ContainerdRootDir},
+		{"state", &config.ContainerdStateDir},
+	} {
+		if *dir.value == "" {
+			continue
+		}
+		if !path.IsAbs(*dir.value) {
+			return fmt.Errorf("containerd %s dir must be an absolute path, got %q", dir.name, *dir.value)
+		}
+		if strings.ContainsAny(*dir.value, " \t\r\n\"") {
+			return fmt.Errorf("containerd %s dir must not contain whitespace or quotes, got %q", dir.name, *dir.value)
+		}
+		*dir.value = path.Clean(*dir.value)
+	}
+	rootDir, stateDir := getContainerdRootDir(config), getContainerdStateDir(config)
+	if strings.HasPrefix(rootDir+"/", stateDir+"/") || strings.HasPrefix(stateDir+"/", rootDir+"/") {
+		return fmt.Errorf("containerd root dir %s and state dir %s must not contain each other", rootDir, stateDir)
+	}
+	return nil
+}
+
+// getContainerdRootDir returns ContainerdRootDir or the default root directory of containerd, ignoring the data dir.
+func getContainerdRootDir(config *datamodel.NodeBootstrappingConfiguration) string {
+	if config.ContainerdRootDir != "" {
+		return config.ContainerdRootDir
+	}
+	return datamodel.DefaultContainerdRootDir
+}
+
+// getContainerdStateDir returns the state directory of containerd.
+func getContainerdStateDir(config *datamodel.NodeBootstrappingConfiguration) string {
+	if config.ContainerdStateDir != "" {
+		return config.ContainerdStateDir
+	}
+	return datamodel.DefaultContainerdStateDir
+}
+
+/*
+getContainerdDirsDropinContent returns the systemd drop-in of the containerd service which creates its custom
+directories and waits for the file systems they are on, e.g. a data disk, to be mounted before containerd starts.
+*/
+func getContainerdDirsDropinContent(config *datamodel.NodeBootstrappingConfiguration) string {
+	var dirs []string
+	if config.ContainerdRootDir != "" {
+		dirs = append(dirs, config.ContainerdRootDir)
+	}
+	if config.ContainerdStateDir != "" {
+		dirs = append(dirs, config.ContainerdStateDir)
+	}
+	if len(dirs) == 0 {
+		return ""
+	}
+	joined := strings.Join(dirs, " ")
+	return fmt.Sprintf("[Unit]\nRequiresMountsFor=%s\n\n[Service]\nExecStartPre=/bin/mkdir -p %s\n", joined, joined)
+}
+
+// getContainerdRuntimeHandlers returns the runtime handlers configured by the containerd config template of the node.
+func getContainerdRuntimeHandlers(config *datamodel.NodeBootstrappingConfiguration) []string {
+	handlers := []string{containerdRuntimeUntrusted}
+	if config.EnableNvidia {
+		handlers = append(handlers, containerdRuntimeNvidia)
+	} else {
+		handlers = append(handlers, containerdRuntimeRunc)
+	}
+	if isNvidiaRuntimeEnabled(config) {
+		handlers = append(handlers, containerdRuntimeNvidiaHandler)
+	}
+	if profile := config.AgentPoolProfile; profile != nil {
+		if strings.EqualFold(string(profile.WorkloadRuntime), string(datamodel.WasmWasi)) {
+			handlers = append(handlers, containerdKrustletRuntimes...)
+		}
+		if profile.Distro.IsKataDistro() {
+			handlers = append(handlers, containerdKataRuntimes...)
+		}
+	}
+	return handlers
+}
+
+// validateDefaultContainerdRuntime validates that the default containerd runtime is configured by the containerd config template.
+func validateDefaultContainerdRuntime(config *datamodel.NodeBootstrappingConfiguration) error {
+	if config.DefaultContainerdRuntime == "" {
+		return nil
+	}
+	handlers := getContainerdRuntimeHandlers(config)
+	for _, handler := range handlers {
+		if handler == config.DefaultContainerdRuntime {
+			return nil
+		}
+	}
+	return fmt.Errorf("default containerd runtime %q is not configured, must be one of %s",
+		config.DefaultContainerdRuntime, strings.Join(handlers, ", "))
+}
+
+// getContainerdDefaultRuntime returns the default runtime of a containerd config template, whose default is baseRuntime.
+func getContainerdDefaultRuntime(config *datamodel.NodeBootstrappingConfiguration, baseRuntime string) string {
+	switch config.DefaultContainerdRuntime {
+	// runc and the nvidia runtime are exchanged by the GPU and non-GPU templates, the CSE picks one of them on the node.
+	case "", containerdRuntimeRunc, containerdRuntimeNvidia:
+		return baseRuntime
+	default:
+		return config.DefaultContainerdRuntime
+	}
+}
+
+/*
+validateAndSetMIGProfile validates the MIG partition profile of the node, which the CSE applies as GPUInstanceProfile.
+A GPUInstanceProfile set without MIGProfile is passed to the CSE as is.
+*/
+func validateAndSetMIGProfile(config *datamodel.NodeBootstrappingConfiguration) error {
+	profile := config.MIGProfile
+	if profile == "" {
+		return nil
+	}
+	if config.GPUInstanceProfile != "" && config.GPUInstanceProfile != profile {
+		return fmt.Errorf("MIG profile %s conflicts with GPU instance profile %s", profile, config.GPUInstanceProfile)
+	}
+	if !datamodel.IsValidMIGProfile(profile) {
+		return fmt.Errorf("unknown MIG profile %q", profile)
+	}
+	if config.AgentPoolProfile == nil || !datamodel.IsMIGCapableSKU(config.AgentPoolProfile.VMSize) {
+		var vmSize string
+		if config.AgentPoolProfile != nil {
+			vmSize = config.AgentPoolProfile.VMSize
+		}
+		return fmt.Errorf("MIG profile %s can't be applied to VM size %q, which does not support MIG", profile, vmSize)
+	}
+	config.GPUInstanceProfile = profile
+	return nil
+}
+
+func isNvidiaRuntimeEnabled(config *datamodel.NodeBootstrappingConfiguration) bool {
+	return config.NvidiaRuntimeConfig != nil && config.NvidiaRuntimeConfig.Enabled
+}
+
+/*
+validateAndSetNvidiaRuntimeConfig validates the nvidia runtime handler is only enabled on GPU SKUs with
+nvidia-container-runtime cached on the VHD, and makes it the default containerd runtime if requested.
+*/
+func validateAndSetNvidiaRuntimeConfig(config *datamodel.NodeBootstrappingConfiguration, onVHD *cache.OnVHD) error {
+	runtimeConfig := config.NvidiaRuntimeConfig
+	if runtimeConfig == nil {
+		return nil
+	}
+	if !runtimeConfig.Enabled {
+		if runtimeConfig.DefaultRuntime {
+			return fmt.Errorf("the nvidia runtime must be enabled to be the default containerd runtime")
+		}
+		return nil
+	}
+	if !config.IsGPUNode() {
+		var vmSize string
+		if config.AgentPoolProfile != nil {
+			vmSize = config.AgentPoolProfile.VMSize
+		}
+		return fmt.Errorf("the nvidia runtime can't be enabled on VM size %q, which is not an NVIDIA GPU SKU", vmSize)
+	}
+	if !onVHD.HasManifestDependency(nvidiaContainerRuntimeDependencyName) {
+		return fmt.Errorf("the nvidia runtime requires %s, which is not cached on the VHD", nvidiaContainerRuntimeDependencyName)
+	}
+	if runtimeConfig.DefaultRuntime {
+		if config.DefaultContainerdRuntime != "" && config.DefaultContainerdRuntime != containerdRuntimeNvidiaHandler {
+			return fmt.Errorf("the nvidia runtime conflicts with default containerd runtime %s", config.DefaultContainerdRuntime)
+		}
+		config.DefaultContainerdRuntime = containerdRuntimeNvidiaHandler
+	}
+	return nil
+}
+
+func validateAndSetLinuxNodeBootstrappingConfiguration(config *datamodel.NodeBootstrappingConfiguration) error {
+	// If using kubelet config file, disable DynamicKubeletConfig feature gate and remove dynamic-config-dir
+	// we should only allow users to configure from API (20201101 and later)
+	dockerShimFlags := []string{
+		"--cni-bin-dir",
+		"--cni-cache-dir",
+		"--cni-conf-dir",
+		"--docker-endpoint",
+		"--image-pull-progress-deadline",
+		"--network-plugin",
+		"--network-plugin-mtu",
+	}
+	profile := config.AgentPoolProfile
+	if _, err := getContainerdConfigTemplate(config.ContainerdConfigTemplateVersion, false); err != nil {
+		return err
+	}
+	if config.CNIPluginVersion != "" && !cache.GetOnVHD().HasDownloadedFileVersion(cniPluginsComponentName, config.CNIPluginVersion) {
+		return fmt.Errorf("CNI plugin version %s is not cached on the VHD", config.CNIPluginVersion)
+	}
+	if err := validatePackageVersionOverrides(config, cache.GetOnVHD()); err != nil {
+		return err
+	}
+	if err := validateAndSetContainerdSnapshotter(config, cache.GetOnVHD()); err != nil {
+		return err
+	}
+	if err := validateContainerdOOMScore(config); err != nil {
+		return err
+	}
+	if err := validateContainerdMaxConcurrentDownloads(config); err != nil {
+		return err
+	}
+	if err := validateAndSetContainerdDirs(config); err != nil {
+		return err
+	}
+	if err := validateAndSetCredentialProviders(config, cache.GetOnVHD()); err != nil {
+		return err
+	}
+	if len(config.ContainerdBaseRuntimeSpec) > 0 {
+		if err := datamodel.ValidateOCIRuntimeSpec(config.ContainerdBaseRuntimeSpec); err != nil {
+			return fmt.Errorf("invalid containerd base runtime spec: %w", err)
+		}
+	}
+	if err := validateAndSetSeccompDefault(config); err != nil {
+		return err
+	}
+	if err := validateAndSetGracefulNodeShutdown(config); err != nil {
+		return err
+	}
+	if err := validateAndSetMIGProfile(config); err != nil {
+		return err
+	}
+	if err := validateAndSetNvidiaRuntimeConfig(config, cache.GetOnVHD()); err != nil {
+		return err
+	}
+	if err := validateNodeProblemDetectorConfig(config, cache.GetOnVHD()); err != nil {
+		return err
+	}
+	if err := validateDefaultContainerdRuntime(config); err != nil {
+		return err
+	}
+	if profile != nil {
+		// overlay the distro defaults, user provided kubelet flags take precedence.
+		if config.KubeletConfig == nil {
+			config.KubeletConfig = make(map[string]string)
+		}
+		for flag, value := range profile.Distro.DefaultKubeletFlags() {
+			if _, ok := config.KubeletConfig[flag]; !ok {
+				config.KubeletConfig[flag] = value
+			}
+		}
+	}
+	if config.KubeletConfig != nil {
+		kubeletFlags := config.KubeletConfig
+		delete(kubeletFlags, "--dynamic-config-dir")
+		delete(kubeletFlags, "--non-masquerade-cidr")
+		if profile != nil && profile.KubernetesConfig != nil &&
+			profile.KubernetesConfig.ContainerRuntime != "" &&
+			profile.KubernetesConfig.ContainerRuntime == "containerd" {
+			for _, flag := range dockerShimFlags {
+				delete(kubeletFlags, flag)
+			}
+		}
+		/* ContainerInsights depends on GPU accelerator Usage metrics from Kubelet cAdvisor endpoint but
+		deprecation of this feature moved to beta which breaks the ContainerInsights customers with K8s
+		 version 1.20 or higher */
+		/* Until Container Insights move to new API the DisableAcceleratorUsageMetrics default feature gate keeps
+		the GPU metrics working */
+		/* Reference -
+		https://github.com/kubernetes/enhancements/tree/master/keps/sig-node/1867-disable-accelerator-usage-metrics */
+		setDefaultFeatureGates(config, false)
+	}
+	return validateAndSetCommonNodeBootstrappingConfiguration(config)
+}
+
+func validateAndSetWindowsNodeBootstrappingConfiguration(config *datamodel.NodeBootstrappingConfiguration) error {
+	if IsTLSBootstrappingEnabledWithHardCodedToken(config.KubeletClientTLSBootstrapToken) {
+		// backfill proper flags for Windows agent node TLS bootstrapping
+		if config.KubeletConfig == nil {
+			config.KubeletConfig = make(map[string]string)
+		}
+
+		config.KubeletConfig["--bootstrap-kubeconfig"] = "c:\\k\\bootstrap-config"
+		config.KubeletConfig["--cert-dir"] = "c:\\k\\pki"
+	}
+	if config.KubeletConfig != nil {
+		kubeletFlags := config.KubeletConfig
+		delete(kubeletFlags, "--dynamic-config-dir")
+		setDefaultFeatureGates(config, true)
+	}
+	if err := validateWindowsIsolationMode(config); err != nil {
+		return err
+	}
+	return validateAndSetCommonNodeBootstrappingConfiguration(config)
+}
+
+/*
+validateWindowsIsolationMode validates that the default sandbox isolation of Windows containers is supported by the
+config. Hyper-V isolation needs the Hyper-V runtime handlers, which are created for the configured Windows build numbers.
+*/
+func validateWindowsIsolationMode(config *datamodel.NodeBootstrappingConfiguration) error {
+	windowsProfile := config.ContainerService.Properties.WindowsProfile
+	if windowsProfile == nil || windowsProfile.ContainerdWindowsRuntimes == nil {
+		return nil
+	}
+	runtimes := windowsProfile.ContainerdWindowsRuntimes
+	for _, handler := range runtimes.RuntimeHandlers {
+		if _, err := strconv.ParseUint(handler.BuildNumber, 10, 32); err != nil {
+			return fmt.Errorf("invalid Windows runtime handler build number %q, must be a Windows build number such as 20348",
+				handler.BuildNumber)
+		}
+	}
+	switch mode := windowsProfile.GetDefaultContainerdWindowsSandboxIsolation(); mode {
+	case datamodel.WindowsIsolationModeProcess:
+		return nil
+	case datamodel.WindowsIsolationModeHyperV:
+		if len(runtimes.RuntimeHandlers) == 0 {
+			return fmt.Errorf("windows isolation mode %s requires the Hyper-V runtime handlers, at least one runtime handler build number must be set",
+				mode)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown windows isolation mode %q, must be one of %s", mode, strings.Join(datamodel.WindowsIsolationModes(), ", "))
+	}
+}
+
+/*
+setDefaultFeatureGates forces the version default feature gates into the --feature-gates kubelet flag, overriding
+the value the user set for them, and drops DynamicKubeletConfig from 1.24 on, where it is removed. The other user
+feature gates are kept as is.
+*/
+func setDefaultFeatureGates(config *datamodel.NodeBootstrappingConfiguration, isWindows bool) {
+	kubeletFlags := config.KubeletConfig
+	orchestratorProfile := config.ContainerService.Properties.OrchestratorProfile
+	if IsKubernetesVersionGe(orchestratorProfile.OrchestratorVersion, "1.24.0") {
+		kubeletFlags["--feature-gates"] = removeFeatureGateString(kubeletFlags["--feature-gates"], "DynamicKubeletConfig")
+	}
+	for name, value := range datamodel.DefaultFeatureGates(orchestratorProfile, isWindows) {
+		kubeletFlags["--feature-gates"] = addFeatureGateString(kubeletFlags["--feature-gates"], name, value)
+	}
+}
+
+// validateAndSetCommonNodeBootstrappingConfiguration runs the validations shared by Linux and Windows nodes.
+func validateAndSetCommonNodeBootstrappingConfiguration(config *datamodel.NodeBootstrappingConfiguration) error {
+	for _, validateAndSet := range []func(*datamodel.NodeBootstrappingConfiguration) error{
+		validateArcConfig,
+		validateGUIDs,
+		validateKubernetesVersion,
+		validateDistroKubernetesVersion,
+		validateAndSetSandboxImage,
+		validateNetworkPolicy,
+		validateOSSKU,
+		validateAndSetWindowsKubeletConfig,
+		validateAndSetCIDRs,
+		validateAndSetKubeProxyMode,
+		validateAndSetNTPServers,
+		validateAndSetKubeletTLSCipherSuites,
+		validateAndSetContainerLogConfig,
+		validateJournaldConfig,
+		validateAndSetRuntimeRequestTimeout,
+		validateAndSetHousekeepingInterval,
+		validateAndSetVerboseProvisioning,
+		validateAndSetVolumePluginDir,
+		validateAndSetImagePulls,
+		validateAndSetNodeIP,
+		validateAndSetKubeletClientCACert,
+		validateCertificateProfile,
+		validateAndSetDownloadRetryConfig,
+		validateAndSetDownloadConcurrency,
+		validateAndSetImageGCThresholds,
+		validateAndSetEvictionThresholds,
+		validateAndSetMaxPods,
+		validateAndSetPodMaxPids,
+		validateAndSetRegisterWithTaints,
+		validateProvisionCompleteMarker,
+		validatePrePullImages,
+		validateAPTSources,
+		validateResolvConfMode,
+		validateAdminUsername,
+		validateSSHPublicKeys,
+		validateUdevRules,
+		validateAndSetKernelModules,
+		validateAndSetDisableSystemdUnits,
+		validateEnvironmentVariables,
+		validateExtraHostsEntries,
+		validateBootCmds,
+		validateAndSetExternalCloudProvider,
+		validateAndSetWorkloadIdentityConfig,
+		validateUserAssignedIdentityIDs,
+		validateAndSetControlPlaneReadyWait,
+		validateKubeletStartupRetries,
+		validateTrustedLaunch,
+		validateAndSetOSDiskType,
+		validateHostnamePattern,
+	} {
+		if err := validateAndSet(config); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateGUIDs validates the tenant, subscription and identity IDs which are set are GUIDs, naming the malformed one.
+func validateGUIDs(config *datamodel.NodeBootstrappingConfiguration) error {
+	type guidField struct {
+		name  string
+		value string
+	}
+	fields := []guidField{
+		{"tenant ID", config.TenantID},
+		{"subscription ID", config.SubscriptionID},
+		{"user-assigned identity client ID", config.UserAssignedIdentityClientID},
+	}
+	if config.IsArcEnabled() {
+		fields = append(fields, guidField{"arc tenant ID", config.ArcConfig.TenantID})
+	}
+	for _, field := range fields {
+		if field.value == "" {
+			continue
+		}
+		if err := datamodel.ValidateGUID(field.value); err != nil {
+			return fmt.Errorf("invalid %s: %w", field.name, err)
+		}
+	}
+	return nil
+}
+
+/*
+validateArcConfig validates the Azure Arc settings of Arc-connected nodes, and the subscription, location and
+service principal the Azure Connected Machine agent connects them with.
+*/
+func validateArcConfig(config *datamodel.NodeBootstrappingConfiguration) error {
+	if !config.IsArcEnabled() {
+		return nil
+	}
+	if config.AgentPoolProfile != nil && (config.AgentPoolProfile.IsWindows() || config.AgentPoolProfile.Distro.IsWindowsDistro()) {
+		return fmt.Errorf("arc-connected Windows nodes are not supported")
+	}
+	arcConfig := config.ArcConfig
+	if arcConfig.TenantID == "" {
+		return fmt.Errorf("arc tenant ID is required")
+	}
+	if arcConfig.ResourceGroup == "" {
+		return fmt.Errorf("arc resource group is required")
+	}
+	if arcConfig.ClusterName == "" {
+		return fmt.Errorf("arc cluster name is required")
+	}
+	if config.KubeletClientTLSBootstrapToken != nil || config.EnableSecureTLSBootstrapping {
+		return fmt.Errorf("TLS bootstrapping is not supported for Arc-connected nodes")
+	}
+	if config.SubscriptionID == "" || config.ContainerService == nil || config.ContainerService.Location == "" {
+		return fmt.Errorf("arc-connected nodes require the subscription ID and location")
+	}
+	if config.ContainerService.Properties == nil || config.ContainerService.Properties.ServicePrincipalProfile == nil ||
+		config.ContainerService.Properties.ServicePrincipalProfile.ClientID == "" ||
+		config.ContainerService.Properties.ServicePrincipalProfile.Secret == "" {
+		return fmt.Errorf("arc-connected nodes require the service principal of the cluster")
+	}
+	return nil
+}
+
+// validateKubernetesVersion validates the format of the cluster's Kubernetes version and that AgentBaker supports it.
+func validateKubernetesVersion(config *datamodel.NodeBootstrappingConfiguration) error {
+	orchestratorProfile := config.ContainerService.Properties.OrchestratorProfile
+	if orchestratorProfile == nil || orchestratorProfile.OrchestratorVersion == "" {
+		return nil
+	}
+	return datamodel.ValidateKubernetesVersion(orchestratorProfile.OrchestratorVersion)
+}
+
+// validateDistroKubernetesVersion validates that the node's distro supports the cluster's Kubernetes version.
+func validateDistroKubernetesVersion(config *datamodel.NodeBootstrappingConfiguration) error {
+	orchestratorProfile := config.ContainerService.Properties.OrchestratorProfile
+	if config.AgentPoolProfile == nil || orchestratorProfile == nil || orchestratorProfile.OrchestratorVersion == "" {
+		return nil
+	}
+	distro := config.AgentPoolProfile.Distro
+	version := orchestratorProfile.OrchestratorVersion
+	if distro.SupportsKubernetesVersion(version) {
+		return nil
+	}
+	minVersion, maxVersion := distro.SupportedKubernetesVersions()
+	if minVersion == "" {
+		minVersion = "any"
+	}
+	if maxVersion == "" {
+		maxVersion = "latest"
+	}
+	return fmt.Errorf("distro %s does not support kubernetes version %s, supported versions are %s to %s",
+		distro, version, minVersion, maxVersion)
+}
+
+// validateNetworkPolicy validates that the network policy engine is compatible with the Kubernetes version and the network plugin.
+func validateNetworkPolicy(config *datamodel.NodeBootstrappingConfiguration) error {
+	orchestratorProfile := config.ContainerService.Properties.OrchestratorProfile
+	if orchestratorProfile == nil || orchestratorProfile.KubernetesConfig == nil {
+		return nil
+	}
+	kubernetesConfig := orchestratorProfile.KubernetesConfig
+	return datamodel.ValidateNetworkPolicy(kubernetesConfig.NetworkPolicy, orchestratorProfile.OrchestratorVersion, kubernetesConfig.NetworkPlugin)
+}
+
+/*
+validateAndSetSandboxImage selects the pause image matching the Kubernetes version as the sandbox image of Linux nodes
+when neither SandboxImage nor K8sComponents.PodInfraContainerImageURL pins one. Windows nodes use WindowsPauseImageURL.
+*/
+func validateAndSetSandboxImage(config *datamodel.NodeBootstrappingConfiguration) error {
+	if config.AgentPoolProfile != nil && (config.AgentPoolProfile.IsWindows() || config.AgentPoolProfile.Distro.IsWindowsDistro()) {
+		if config.SandboxImage != "" {
+			return fmt.Errorf("sandbox image is not supported on Windows nodes, use the Windows pause image URL instead")
+		}
+		return nil
+	}
+	if config.SandboxImage != "" || (config.K8sComponents != nil && config.K8sComponents.PodInfraContainerImageURL != "") {
+		return nil
+	}
+	orchestratorProfile := config.ContainerService.Properties.OrchestratorProfile
+	if orchestratorProfile == nil || orchestratorProfile.OrchestratorVersion == "" {
+		return nil
+	}
+	sandboxImage, err := datamodel.SandboxImageForVersion(orchestratorProfile.OrchestratorVersion)
+	if err != nil {
+		return err
+	}
+	config.SandboxImage = sandboxImage
+	return nil
+}
+
+// getSandboxImage returns the sandbox image of the node, SandboxImage overrides the pod infra container image.
+func getSandboxImage(config *datamodel.NodeBootstrappingConfiguration) string {
+	if config.SandboxImage != "" {
+		return config.SandboxImage
+	}
+	if config.K8sComponents == nil {
+		return ""
+	}
+	return config.K8sComponents.PodInfraContainerImageURL
+}
+
+// validateOSSKU validates that the OS SKU, if set, selects the OS family of the distro.
+func validateOSSKU(config *datamodel.NodeBootstrappingConfiguration) error {
+	if config.AgentPoolProfile == nil || config.OSSKU == "" {
+		return nil
+	}
+	distro := config.AgentPoolProfile.Distro
+	osSKUFamily := datamodel.OSSKUFamily(config.OSSKU)
+	distroFamily := distro.Family()
+	if osSKUFamily == datamodel.DistroFamilyUnknown || distroFamily == datamodel.DistroFamilyUnknown || osSKUFamily == distroFamily {
+		return nil
+	}
+	return fmt.Errorf("OS SKU %s selects the %s OS family, but distro %s is of the %s OS family",
+		config.OSSKU, osSKUFamily, distro, distroFamily)
+}
+
+/*
+validateAndSetCIDRs validates the service, pod and node CIDRs of the cluster. On dual-stack nodes the service and pod
+CIDRs must be IPv4 and IPv6 pairs in the same family order, and the pod CIDR pair is rendered into the kube-proxy
+--cluster-cidr flag.
+*/
+func validateAndSetCIDRs(config *datamodel.NodeBootstrappingConfiguration) error {
+	properties := config.ContainerService.Properties
+	if properties.OrchestratorProfile == nil || properties.OrchestratorProfile.KubernetesConfig == nil {
+		return nil
+	}
+	kubernetesConfig := properties.OrchestratorProfile.KubernetesConfig
+	podCIDR := kubernetesConfig.ClusterSubnet
+	// with Azure CNI in non-overlay mode, pods get their IPs from the node subnet.
+	if properties.OrchestratorProfile.IsAzureCNI() && !kubernetesConfig.IsUsingNetworkPluginMode("overlay") {
+		podCIDR = ""
+	}
+	var nodeCIDR string
+	if properties.HostedMasterProfile != nil {
+		nodeCIDR = properties.HostedMasterProfile.Subnet
+	}
+	if err := datamodel.ValidateCIDRs(kubernetesConfig.ServiceCIDR, podCIDR, nodeCIDR); err != nil {
+		return err
+	}
+	if !config.IsDualStack() {
+		return nil
+	}
+	if err := datamodel.ValidateDualStackCIDRs(kubernetesConfig.ServiceCIDR, podCIDR); err != nil {
+		return err
+	}
+	if podCIDR != "" {
+		if config.KubeproxyConfig == nil {
+			config.KubeproxyConfig = make(map[string]string)
+		}
+		if _, ok := config.KubeproxyConfig["--cluster-cidr"]; !ok {
+			config.KubeproxyConfig["--cluster-cidr"] = strings.ReplaceAll(podCIDR, " ", "")
+		}
+	}
+	return nil
+}
+
+// validateAndSetKubeProxyMode validates the kube-proxy mode and IPVS scheduler and sets them in the kube-proxy config.
+func validateAndSetKubeProxyMode(config *datamodel.NodeBootstrappingConfiguration) error {
+	if config.KubeProxyMode == "" {
+		if config.KubeProxyIPVSScheduler != "" {
+			return fmt.Errorf("kube-proxy IPVS scheduler requires kube-proxy mode %s", datamodel.KubeProxyModeIPVS)
+		}
+		return nil
+	}
+	if config.AgentPoolProfile != nil && (config.AgentPoolProfile.IsWindows() || config.AgentPoolProfile.Distro.IsWindowsDistro()) {
+		return fmt.Errorf("kube-proxy mode is not supported on Windows nodes")
+	}
+	switch config.KubeProxyMode {
+	case datamodel.KubeProxyModeIPTables, datamodel.KubeProxyModeIPVS:
+	case datamodel.KubeProxyModeNFTables:
+		var orchestratorVersion string
+		if config.ContainerService != nil && config.ContainerService.Properties != nil &&
+			config.ContainerService.Properties.OrchestratorProfile != nil {
+			orchestratorVersion = config.ContainerService.Properties.OrchestratorProfile.OrchestratorVersion
+		}
+		if !IsKubernetesVersionGe(orchestratorVersion, kubeProxyNFTablesMinKubernetesVersion) {
+			return fmt.Errorf("kube-proxy mode %s requires kubernetes %s or later, got %s", config.KubeProxyMode,
+				kubeProxyNFTablesMinKubernetesVersion, orchestratorVersion)
+		}
+	default:
+		return fmt.Errorf("unknown kube-proxy mode %q, must be one of %s, %s or %s", config.KubeProxyMode,
+			datamodel.KubeProxyModeIPTables, datamodel.KubeProxyModeIPVS, datamodel.KubeProxyModeNFTables)
+	}
+	if config.KubeProxyIPVSScheduler != "" {
+		if config.KubeProxyMode != datamodel.KubeProxyModeIPVS {
+			return fmt.Errorf("kube-proxy IPVS scheduler requires kube-proxy mode %s, got %s", datamodel.KubeProxyModeIPVS, config.KubeProxyMode)
+		}
+		known := false
+		for _, scheduler := range kubeProxyIPVSSchedulers {
+			known = known || scheduler == config.KubeProxyIPVSScheduler
+		}
+		if !known {
+			return fmt.Errorf("unknown kube-proxy IPVS scheduler %q, must be one of %s", config.KubeProxyIPVSScheduler,
+				strings.Join(kubeProxyIPVSSchedulers, ", "))
+		}
+	}
+	if config.KubeproxyConfig == nil {
+		config.KubeproxyConfig = make(map[string]string)
+	}
+	if mode, ok := config.KubeproxyConfig["--proxy-mode"]; ok && mode != string(config.KubeProxyMode) {
+		return fmt.Errorf("kube-proxy mode %s conflicts with the --proxy-mode kube-proxy flag %s", config.KubeProxyMode, mode)
+	}
+	config.KubeproxyConfig["--proxy-mode"] = string(config.KubeProxyMode)
+	if config.KubeProxyIPVSScheduler != "" {
+		config.KubeproxyConfig["--ipvs-scheduler"] = config.KubeProxyIPVSScheduler
+	}
+	return nil
+}
+
+// validateAndSetNTPServers validates and de-duplicates the NTP servers, falling back to the Azure time server when none is set.
+func validateAndSetNTPServers(config *datamodel.NodeBootstrappingConfiguration) error {
+	seen := map[string]bool{}
+	ntpServers := []string{}
+	for _, server := range config.NTPServers {
+		server = strings.TrimSpace(server)
+		if err := datamodel.ValidateHostnameOrIP(server); err != nil {
+			return fmt.Errorf("invalid NTP server: %w", err)
+		}
+		if seen[strings.ToLower(server)] {
+			continue
+		}
+		seen[strings.ToLower(server)] = true
+		ntpServers = append(ntpServers, server)
+	}
+	if len(ntpServers) == 0 {
+		ntpServers = []string{datamodel.DefaultNTPServer}
+	}
+	config.NTPServers = ntpServers
+	return nil
+}
+
+// validateAndSetKubeletTLSCipherSuites validates the kubelet TLS cipher suites against the suites supported by Go
+// and renders them into the kubelet flags. Insecure suites are rejected unless explicitly allowed.
+func validateAndSetKubeletTLSCipherSuites(config *datamodel.NodeBootstrappingConfiguration) error {
+	if len(config.KubeletTLSCipherSuites) == 0 {
+		return nil
+	}
+	secureSuites := map[string]bool{}
+	for _, suite := range tls.CipherSuites() {
+		secureSuites[suite.Name] = true
+	}
+	insecureSuites := map[string]bool{}
+	for _, suite := range tls.InsecureCipherSuites() {
+		insecureSuites[suite.Name] = true
+	}
+	for _, suite := range config.KubeletTLSCipherSuites {
+		switch {
+		case secureSuites[suite]:
+		case insecureSuites[suite]:
+			if !config.AllowInsecureKubeletTLSCipherSuites {
+				return fmt.Errorf("kubelet TLS cipher suite %s is insecure", suite)
+			}
+		default:
+			return fmt.Errorf("unknown kubelet TLS cipher suite %s", suite)
+		}
+	}
+
+	if config.KubeletConfig == nil {
+		config.KubeletConfig = make(map[string]string)
+	}
+	config.KubeletConfig["--tls-cipher-suites"] = strings.Join(config.KubeletTLSCipherSuites, ",")
+	return nil
+}
+
+// validateAndSetContainerLogConfig validates the container log rotation settings, fills in defaults
+// for unset values and renders them into the kubelet flags.
+func validateAndSetContainerLogConfig(config *datamodel.NodeBootstrappingConfiguration) error {
+	logConfig := config.ContainerLogConfig
+	if logConfig == nil {
+		return nil
+	}
+	if logConfig.MaxSizeMB < 0 {
+		return fmt.Errorf("container log max size must be a positive number of MB, got %d", logConfig.MaxSizeMB)
+	}
+	if logConfig.MaxFiles < 0 {
+		return fmt.Errorf("container log max files must be a positive number, got %d", logConfig.MaxFiles)
+	}
+	if logConfig.MaxSizeMB == 0 {
+		logConfig.MaxSizeMB = datamodel.DefaultContainerLogMaxSizeMB
+	}
+	if logConfig.MaxFiles == 0 {
+		logConfig.MaxFiles = datamodel.DefaultContainerLogMaxFiles
+	}
+	// kubelet needs at least 2 files to be able to rotate the log.
+	const minContainerLogMaxFiles = 2
+	if logConfig.MaxFiles < minContainerLogMaxFiles {
+		return fmt.Errorf("container log max files must be at least %d, got %d", minContainerLogMaxFiles, logConfig.MaxFiles)
+	}
+
+	if config.KubeletConfig == nil {
+		config.KubeletConfig = make(map[string]string)
+	}
+	config.KubeletConfig["--container-log-max-size"] = fmt.Sprintf("%dMi", logConfig.MaxSizeMB)
+	config.KubeletConfig["--container-log-max-files"] = strconv.Itoa(logConfig.MaxFiles)
+	return nil
+}
+
+// getServiceCIDR returns the service CIDR of the cluster, a comma-separated pair on dual-stack clusters.
+func getServiceCIDR(config *datamodel.NodeBootstrappingConfiguration) string {
+	properties := config.ContainerService.Properties
+	if properties.OrchestratorProfile == nil || properties.OrchestratorProfile.KubernetesConfig == nil {
+		return ""
+	}
+	return properties.OrchestratorProfile.KubernetesConfig.ServiceCIDR
+}
+
+/*
+validateAndSetNodeIP validates the node IP and renders it into the kubelet --node-ip flag. Dual-stack clusters can
+set an IPv4 and an IPv6 address, separated by a comma.
+*/
+func validateAndSetNodeIP(config *datamodel.NodeBootstrappingConfiguration) error {
+	if config.NodeIP == "" {
+		return nil
+	}
+	const maxDualStackNodeIPs = 2
+	addresses := strings.Split(config.NodeIP, ",")
+	if len(addresses) > maxDualStackNodeIPs || (len(addresses) == maxDualStackNodeIPs && !config.IsDualStack()) {
+		return fmt.Errorf("invalid node IP %q, only dual-stack clusters can set an IPv4 and an IPv6 address", config.NodeIP)
+	}
+	var ipv4Count int
+	for _, address := range addresses {
+		ip := net.ParseIP(strings.TrimSpace(address))
+		if ip == nil {
+			return fmt.Errorf("invalid node IP %q, %q is not an IP address", config.NodeIP, address)
+		}
+		if ip.To4() != nil {
+			ipv4Count++
+		}
+	}
+	if len(addresses) == maxDualStackNodeIPs {
+		if ipv4Count != 1 {
+			return fmt.Errorf("invalid node IP %q, a dual-stack node IP must be an IPv4 and an IPv6 address", config.NodeIP)
+		}
+		// the primary IP family of the node must match the one of the service CIDR.
+		if serviceCIDR := getServiceCIDR(config); strings.Contains(serviceCIDR, ",") {
+			primaryIP := net.ParseIP(strings.TrimSpace(addresses[0]))
+			primaryCIDRIP, _, err := net.ParseCIDR(strings.TrimSpace(strings.Split(serviceCIDR, ",")[0]))
+			if err == nil && (primaryIP.To4() != nil) != (primaryCIDRIP.To4() != nil) {
+				return fmt.Errorf("invalid node IP %q, it lists the IP families in a different order than the service CIDR %q",
+					config.NodeIP, serviceCIDR)
+			}
+		}
+	}
+	if config.KubeletConfig == nil {
+		config.KubeletConfig = make(map[string]string)
+	}
+	config.KubeletConfig["--node-ip"] = strings.ReplaceAll(config.NodeIP, " ", "")
+	return nil
+}
+
+// validateAndSetKubeletClientCACert validates the kubelet client CA bundle and points the kubelet --client-ca-file flag to it.
+func validateAndSetKubeletClientCACert(config *datamodel.NodeBootstrappingConfiguration) error {
+	if len(config.KubeletClientCACert) == 0 {
+		return nil
+	}
+	if config.AgentPoolProfile != nil && (config.AgentPoolProfile.IsWindows() || config.AgentPoolProfile.Distro.IsWindowsDistro()) {
+		return fmt.Errorf("kubelet client CA certificate is not supported on Windows nodes")
+	}
+	if err := datamodel.ValidateCABundle(config.KubeletClientCACert); err != nil {
+		return fmt.Errorf("invalid kubelet client CA certificate: %w", err)
+	}
+	if config.KubeletConfig == nil {
+		config.KubeletConfig = make(map[string]string)
+	}
+	config.KubeletConfig["--client-ca-file"] = kubeletClientCACertFilepath
+	return nil
+}
+
+/*
+getKubeletClientCABundle returns the kubelet client CA bundle with the cluster CA appended, unless the bundle already
+includes it, so that the API server and the cluster's other clients keep authenticating to kubelet.
+*/
+func getKubeletClientCABundle(config *datamodel.NodeBootstrappingConfiguration) string {
+	bundle := strings.TrimRight(string(config.KubeletClientCACert), "\n") + "\n"
+	cs := config.ContainerService
+	if cs == nil || cs.Properties == nil || cs.Properties.CertificateProfile == nil {
+		return bundle
+	}
+	clusterCA := strings.TrimSpace(cs.Properties.CertificateProfile.CaCertificate)
+	if clusterCA == "" || strings.Contains(bundle, clusterCA) {
+		return bundle
+	}
+	return bundle + clusterCA + "\n"
+}
+
+/*
+validateCertificateProfile validates the cluster CA certificate and that the API server certificate, if any, is signed
+by it, since kubelet can't TLS bootstrap with a malformed or mismatched cluster CA.
+*/
+func validateCertificateProfile(config *datamodel.NodeBootstrappingConfiguration) error {
+	cs := config.ContainerService
+	if cs == nil || cs.Properties == nil || cs.Properties.CertificateProfile == nil {
+		return nil
+	}
+	certificateProfile := cs.Properties.CertificateProfile
+	if certificateProfile.CaCertificate == "" {
+		if certificateProfile.APIServerCertificate != "" {
+			return fmt.Errorf("API server certificate requires the cluster CA certificate")
+		}
+		return nil
+	}
+	if err := datamodel.ValidateCABundle([]byte(certificateProfile.CaCertificate)); err != nil {
+		return fmt.Errorf("invalid cluster CA certificate: %w", err)
+	}
+	if certificateProfile.APIServerCertificate == "" {
+		return nil
+	}
+	if err := datamodel.ValidateCertificateChain([]byte(certificateProfile.APIServerCertificate),
+		[]byte(certificateProfile.CaCertificate)); err != nil {
+		return fmt.Errorf("invalid API server certificate: %w", err)
+	}
+	return nil
+}
+
+// validateJournaldConfig validates the journald storage mode and limits.
+func validateJournaldConfig(config *datamodel.NodeBootstrappingConfiguration) error {
+	journaldConfig := config.JournaldConfig
+	if journaldConfig == nil {
+		return nil
+	}
+	if config.AgentPoolProfile != nil && (config.AgentPoolProfile.IsWindows() || config.AgentPoolProfile.Distro.IsWindowsDistro()) {
+		return fmt.Errorf("journald config is not supported on Windows nodes")
+	}
+	switch journaldConfig.StorageMode {
+	case "", datamodel.JournaldStorageVolatile, datamodel.JournaldStoragePersistent, datamodel.JournaldStorageAuto,
+		datamodel.JournaldStorageNone:
+	default:
+		return fmt.Errorf("unknown journald storage mode %q, must be one of %s, %s, %s or %s", journaldConfig.StorageMode,
+			datamodel.JournaldStorageVolatile, datamodel.JournaldStoragePersistent, datamodel.JournaldStorageAuto,
+			datamodel.JournaldStorageNone)
+	}
+	if journaldConfig.MaxUseMB < 0 {
+		return fmt.Errorf("journald max use must be a positive number of MB, got %d", journaldConfig.MaxUseMB)
+	}
+	if journaldConfig.MaxRetentionDays < 0 {
+		return fmt.Errorf("journald max retention must be a positive number of days, got %d", journaldConfig.MaxRetentionDays)
+	}
+	return nil
+}
+
+// getJournaldConfigDropinContent returns the journald.conf drop-in with the set journald settings.
+func getJournaldConfigDropinContent(journaldConfig *datamodel.JournaldConfig) string {
+	var sb strings.Builder
+	sb.WriteString("[Journal]\n")
+	if journaldConfig.StorageMode != "" {
+		sb.WriteString(fmt.Sprintf("Storage=%s\n", journaldConfig.StorageMode))
+	}
+	if journaldConfig.MaxUseMB > 0 {
+		sb.WriteString(fmt.Sprintf("SystemMaxUse=%dM\n", journaldConfig.MaxUseMB))
+	}
+	if journaldConfig.MaxRetentionDays > 0 {
+		sb.WriteString(fmt.Sprintf("MaxRetentionSec=%dday\n", journaldConfig.MaxRetentionDays))
+	}
+	return sb.String()
+}
+
+// windowsKubeletConfigFlags are the kubelet flags which can be tuned on Windows nodes. Unlike TranslatedKubeletConfigFlags
+// it leaves out Linux only kubelet features, e.g. cgroups, CPU and topology managers, sysctls and graceful node shutdown,
+// and the authentication and TLS files provisioned by the CSE.
+//
+//nolint:gochecknoglobals
+var windowsKubeletConfigFlags = map[string]bool{
+	"--cluster-dns":                       true,
+	"--cluster-domain":                    true,
+	"--max-pods":                          true,
+	"--eviction-hard":                     true,
+	"--node-status-update-frequency":      true,
+	"--node-status-report-frequency":      true,
+	"--image-gc-high-threshold":           true,
+	"--image-gc-low-threshold":            true,
+	"--event-qps":                         true,
+	"--streaming-connection-idle-timeout": true,
+	"--runtime-request-timeout":           true,
+	"--rotate-certificates":               true,
+	"--read-only-port":                    true,
+	"--feature-gates":                     true,
+	"--system-reserved":                   true,
+	"--kube-reserved":                     true,
+	"--tls-cipher-suites":                 true,
+	"--container-log-max-size":            true,
+	"--container-log-max-files":           true,
+	"--serialize-image-pulls":             true,
+	"--max-parallel-image-pulls":          true,
+}
+
+/*
+validateAndSetWindowsKubeletConfig validates the Windows kubelet flags against windowsKubeletConfigFlags, and merges them
+into the kubelet config, so they override the default flags.
+*/
+func validateAndSetWindowsKubeletConfig(config *datamodel.NodeBootstrappingConfiguration) error {
+	if len(config.WindowsKubeletConfig) == 0 {
+		return nil
+	}
+	if config.AgentPoolProfile == nil || !(config.AgentPoolProfile.IsWindows() || config.AgentPoolProfile.Distro.IsWindowsDistro()) {
+		return fmt.Errorf("windows kubelet config is only supported on Windows nodes")
+	}
+	flags := make([]string, 0, len(config.WindowsKubeletConfig))
+	for flag := range config.WindowsKubeletConfig {
+		flags = append(flags, flag)
+	}
+	sort.Strings(flags)
+	for _, flag := range flags {
+		if !windowsKubeletConfigFlags[flag] {
+			return fmt.Errorf("kubelet flag %s is not allowed in the windows kubelet config", flag)
+		}
+		if config.WindowsKubeletConfig[flag] == "" {
+			return fmt.Errorf("kubelet flag %s in the windows kubelet config must have a value", flag)
+		}
+	}
+	if config.KubeletConfig == nil {
+		config.KubeletConfig = make(map[string]string)
+	}
+	for _, flag := range flags {
+		config.KubeletConfig[flag] = config.WindowsKubeletConfig[flag]
+	}
+	return nil
+}
+
+/*
+validateAndSetRuntimeRequestTimeout validates the kubelet runtime request timeout and renders it into the kubelet
+config. The kubelet flags are kept when RuntimeRequestTimeout is not set, kubelet then defaults to
+DefaultRuntimeRequestTimeout.
+*/
+func validateAndSetRuntimeRequestTimeout(config *datamodel.NodeBootstrappingConfiguration) error {
+	timeout := config.RuntimeRequestTimeout
+	if timeout == "" {
+		return nil
+	}
+	duration, err := time.ParseDuration(string(timeout))
+	if err != nil {
+		return fmt.Errorf("invalid runtime request timeout %q: %w", timeout, err)
+	}
+	if duration <= 0 {
+		return fmt.Errorf("runtime request timeout must be a positive duration, got %s", timeout)
+	}
+	if config.KubeletConfig == nil {
+		config.KubeletConfig = make(map[string]string)
+	}
+	config.KubeletConfig["--runtime-request-timeout"] = string(timeout)
+	return nil
+}
+
+/*
+validateAndSetHousekeepingInterval validates the kubelet housekeeping interval and renders it into the kubelet config
+of Linux nodes. The kubelet flags are kept when HousekeepingInterval is not set, kubelet only registers the cAdvisor
+flags on Linux.
+*/
+func validateAndSetHousekeepingInterval(config *datamodel.NodeBootstrappingConfiguration) error {
+	interval := config.HousekeepingInterval
+	if interval == "" {
+		return nil
+	}
+	if config.AgentPoolProfile != nil && (config.AgentPoolProfile.IsWindows() || config.AgentPoolProfile.Distro.IsWindowsDistro()) {
+		return fmt.Errorf("housekeeping interval is not supported on Windows nodes")
+	}
+	duration, err := time.ParseDuration(string(interval))
+	if err != nil {
+		return fmt.Errorf("invalid housekeeping interval %q: %w", interval, err)
+	}
+	if duration <= 0 {
+		return fmt.Errorf("housekeeping interval must be a positive duration, got %s", interval)
+	}
+	if config.KubeletConfig == nil {
+		config.KubeletConfig = make(map[string]string)
+	}
+	config.KubeletConfig["--housekeeping-interval"] = string(interval)
+	return nil
+}
+
+// validateAndSetVerboseProvisioning raises the kubelet log verbosity of nodes with verbose provisioning.
+func validateAndSetVerboseProvisioning(config *datamodel.NodeBootstrappingConfiguration) error {
+	if !config.VerboseProvisioning {
+		return nil
+	}
+	if v, ok := config.KubeletConfig["--v"]; ok {
+		level, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid kubelet log verbosity %q: %w", v, err)
+		}
+		if level >= verboseKubeletLogLevel {
+			return nil
+		}
+	}
+	if config.KubeletConfig == nil {
+		config.KubeletConfig = make(map[string]string)
+	}
+	config.KubeletConfig["--v"] = strconv.Itoa(verboseKubeletLogLevel)
+	return nil
+}
+
+// validateAndSetVolumePluginDir validates the kubelet volume plugin directory of Linux nodes and renders it into the kubelet config.
+func validateAndSetVolumePluginDir(config *datamodel.NodeBootstrappingConfiguration) error {
+	if config.AgentPoolProfile != nil && (config.AgentPoolProfile.IsWindows() || config.AgentPoolProfile.Distro.IsWindowsDistro()) {
+		if config.VolumePluginDir != "" {
+			return fmt.Errorf("volume plugin dir is not supported on Windows nodes")
+		}
+		return nil
+	}
+	dir := config.VolumePluginDir
+	if dir == "" {
+		dir = config.KubeletConfig["--volume-plugin-dir"]
+	}
+	if dir == "" {
+		dir = datamodel.DefaultVolumePluginDir
+	}
+	if !path.IsAbs(dir) {
+		return fmt.Errorf("volume plugin dir must be an absolute path, got %q", dir)
+	}
+	if strings.ContainsAny(dir, " \t\r\n") {
+		return fmt.Errorf("volume plugin dir must not contain whitespace, got %q", dir)
+	}
+	config.VolumePluginDir = path.Clean(dir)
+	if config.KubeletConfig == nil {
+		config.KubeletConfig = make(map[string]string)
+	}
+	config.KubeletConfig["--volume-plugin-dir"] = config.VolumePluginDir
+	return nil
+}
+
+/*
+validateAndSetImagePulls validates the kubelet image pull settings and renders them into the kubelet config.
+Parallel pulls can only be limited when they are not serialized, and the limit is only read from the kubelet config file.
+*/
+func validateAndSetImagePulls(config *datamodel.NodeBootstrappingConfiguration) error {
+	if config.SerializeImagePulls == nil && config.MaxParallelImagePulls == nil {
+		return nil
+	}
+	if config.KubeletConfig == nil {
+		config.KubeletConfig = make(map[string]string)
+	}
+	if config.SerializeImagePulls != nil {
+		config.KubeletConfig["--serialize-image-pulls"] = strconv.FormatBool(*config.SerializeImagePulls)
+	}
+	if config.MaxParallelImagePulls == nil {
+		return nil
+	}
+	if config.SerializeImagePulls == nil || *config.SerializeImagePulls {
+		return fmt.Errorf("max parallel image pulls requires serialize image pulls to be false")
+	}
+	if *config.MaxParallelImagePulls < 1 {
+		return fmt.Errorf("max parallel image pulls must be a positive number, got %d", *config.MaxParallelImagePulls)
+	}
+	cs := config.ContainerService
+	var orchestratorVersion string
+	if cs.Properties.OrchestratorProfile != nil {
+		orchestratorVersion = cs.Properties.OrchestratorProfile.OrchestratorVersion
+	}
+	if !IsKubernetesVersionGe(orchestratorVersion, maxParallelImagePullsMinKubernetesVersion) {
+		return fmt.Errorf("max parallel image pulls requires kubernetes %s or later, got %s",
+			maxParallelImagePullsMinKubernetesVersion, orchestratorVersion)
+	}
+	if config.AgentPoolProfile == nil || !IsKubeletConfigFileEnabled(cs, config.AgentPoolProfile, config.EnableKubeletConfigFile) {
+		return fmt.Errorf("max parallel image pulls requires the kubelet config file")
+	}
+	config.KubeletConfig["--max-parallel-image-pulls"] = strconv.Itoa(*config.MaxParallelImagePulls)
+	return nil
+}
+
+// validateAndSetDownloadRetryConfig validates the component download retry settings and fills in the defaults.
+func validateAndSetDownloadRetryConfig(config *datamodel.NodeBootstrappingConfiguration) error {
+	if config.DownloadRetryConfig == nil {
+		config.DownloadRetryConfig = &datamodel.DownloadRetryConfig{}
+	}
+	retryConfig := config.DownloadRetryConfig
+	if retryConfig.MaxRetries < 0 {
+		return fmt.Errorf("download max retries must be a positive number, got %d", retryConfig.MaxRetries)
+	}
+	if retryConfig.BackoffSeconds < 0 {
+		return fmt.Errorf("download backoff must be a positive number of seconds, got %d", retryConfig.BackoffSeconds)
+	}
+	if retryConfig.MaxRetries == 0 {
+		retryConfig.MaxRetries = datamodel.DefaultDownloadMaxRetries
+	}
+	if retryConfig.BackoffSeconds == 0 {
+		retryConfig.BackoffSeconds = datamodel.DefaultDownloadBackoffSeconds
+	}
+	return nil
+}
+
+// validateAndSetDownloadConcurrency validates the number of parallel component downloads and fills in the default.
+func validateAndSetDownloadConcurrency(config *datamodel.NodeBootstrappingConfiguration) error {
+	if config.DownloadConcurrency == 0 {
+		config.DownloadConcurrency = datamodel.DefaultDownloadConcurrency
+	}
+	if config.DownloadConcurrency < 1 || config.DownloadConcurrency > datamodel.MaxDownloadConcurrency {
+		return fmt.Errorf("download concurrency must be between 1 and %d, got %d", datamodel.MaxDownloadConcurrency, config.DownloadConcurrency)
+	}
+	return nil
+}
+
+// validateAndSetImageGCThresholds renders the image GC thresholds into the kubelet flags and validates
+// the resulting thresholds, so that kubelet doesn't refuse to start.
+func validateAndSetImageGCThresholds(config *datamodel.NodeBootstrappingConfiguration) error {
+	const maxPercent = 100
+	for _, threshold := range []struct {
+		flag    string
+		percent *int
+	}{
+		{"--image-gc-high-threshold", config.ImageGCHighThresholdPercent},
+		{"--image-gc-low-threshold", config.ImageGCLowThresholdPercent},
+	} {
+		if threshold.percent == nil {
+			continue
+		}
+		if *threshold.percent < 0 || *threshold.percent > maxPercent {
+			return fmt.Errorf("%s must be between 0 and 100, got %d", threshold.flag, *threshold.percent)
+		}
+		if config.KubeletConfig == nil {
+			config.KubeletConfig = make(map[string]string)
+		}
+		config.KubeletConfig[threshold.flag] = strconv.Itoa(*threshold.percent)
+	}
+
+	high, hasHigh := config.KubeletConfig["--image-gc-high-threshold"]
+	low, hasLow := config.KubeletConfig["--image-gc-low-threshold"]
+	if !hasHigh || !hasLow {
+		return nil
+	}
+	highPercent, err := strconv.Atoi(high)
+	if err != nil {
+		return fmt.Errorf("invalid --image-gc-high-threshold %q: %w", high, err)
+	}
+	lowPercent, err := strconv.Atoi(low)
+	if err != nil {
+		return fmt.Errorf("invalid --image-gc-low-threshold %q: %w", low, err)
+	}
+	if highPercent <= lowPercent {
+		return fmt.Errorf("--image-gc-high-threshold (%d) must be greater than --image-gc-low-threshold (%d)", highPercent, lowPercent)
+	}
+	return nil
+}
+
+/*
+validateAndSetEvictionThresholds validates the hard and soft eviction thresholds and renders them into the kubelet flags.
+Every soft threshold needs a positive grace period and every grace period needs a soft threshold.
+*/
+func validateAndSetEvictionThresholds(config *datamodel.NodeBootstrappingConfiguration) error {
+	for _, thresholds := range []struct {
+		flag       string
+		thresholds map[string]string
+	}{
+		{"--eviction-hard", config.EvictionHard},
+		{"--eviction-soft", config.EvictionSoft},
+	} {
+		for signal, threshold := range thresholds.thresholds {
+			if err := datamodel.ValidateEvictionThreshold(signal, threshold); err != nil {
+				return fmt.Errorf("invalid %s: %w", thresholds.flag, err)
+			}
+		}
+	}
+	for signal, gracePeriod := range config.EvictionSoftGracePeriod {
+		if _, ok := config.EvictionSoft[signal]; !ok {
+			return fmt.Errorf("eviction soft grace period is set for signal %s which has no soft threshold", signal)
+		}
+		if d, err := time.ParseDuration(gracePeriod); err != nil || d <= 0 {
+			return fmt.Errorf("eviction soft grace period %q of signal %s must be a positive duration", gracePeriod, signal)
+		}
+	}
+	for signal := range config.EvictionSoft {
+		if _, ok := config.EvictionSoftGracePeriod[signal]; !ok {
+			return fmt.Errorf("eviction soft threshold of signal %s has no grace period", signal)
+		}
+	}
+
+	for _, flag := range []struct {
+		name      string
+		values    map[string]string
+		separator string
+	}{
+		{"--eviction-hard", config.EvictionHard, "<"},
+		{"--eviction-soft", config.EvictionSoft, "<"},
+		{"--eviction-soft-grace-period", config.EvictionSoftGracePeriod, "="},
+	} {
+		if len(flag.values) == 0 {
+			continue
+		}
+		signals := make([]string, 0, len(flag.values))
+		for signal := range flag.values {
+			signals = append(signals, signal)
+		}
+		sort.Strings(signals)
+		pairs := make([]string, 0, len(signals))
+		for _, signal := range signals {
+			pairs = append(pairs, signal+flag.separator+flag.values[signal])
+		}
+		if config.KubeletConfig == nil {
+			config.KubeletConfig = make(map[string]string)
+		}
+		config.KubeletConfig[flag.name] = strings.Join(pairs, ",")
+	}
+	return nil
+}
+
+// validateProvisionCompleteMarker validates that the provision complete marker, if set, is a single non-blank line.
+func validateProvisionCompleteMarker(config *datamodel.NodeBootstrappingConfiguration) error {
+	marker := config.ProvisionCompleteMarker
+	if marker == "" {
+		return nil
+	}
+	if strings.TrimSpace(marker) == "" {
+		return fmt.Errorf("provision complete marker must not be blank")
+	}
+	if len(marker) > maxProvisionCompleteMarkerLength {
+		return fmt.Errorf("provision complete marker must be at most %d characters, got %d", maxProvisionCompleteMarkerLength, len(marker))
+	}
+	if strings.ContainsAny(marker, "\r\n") {
+		return fmt.Errorf("provision complete marker must be a single line")
+	}
+	return nil
+}
+
+// validatePrePullImages validates the image references and the auth of the images to pre-pull.
+func validatePrePullImages(config *datamodel.NodeBootstrappingConfiguration) error {
+	for _, image := range config.PrePullImages {
+		if err := datamodel.ValidateImageReference(image.Image); err != nil {
+			return fmt.Errorf("invalid pre-pull image: %w", err)
+		}
+		if image.Auth == "" {
+			continue
+		}
+		if err := datamodel.ValidateDockerConfigJSON(image.Auth); err != nil {
+			return fmt.Errorf("invalid auth of pre-pull image %s: %w", image.Image, err)
+		}
+	}
+	return nil
+}
+
+// validateAdminUsername validates the admin username of the node's OS, if set.
+func validateAdminUsername(config *datamodel.NodeBootstrappingConfiguration) error {
+	properties := config.ContainerService.Properties
+	if config.AgentPoolProfile != nil && config.AgentPoolProfile.IsWindows() {
+		if properties.WindowsProfile == nil || properties.WindowsProfile.AdminUsername == "" {
+			return nil
+		}
+		return datamodel.IsValidAdminUsername(properties.WindowsProfile.AdminUsername, datamodel.Windows)
+	}
+	if properties.LinuxProfile == nil || properties.LinuxProfile.AdminUsername == "" {
+		return nil
+	}
+	return datamodel.IsValidAdminUsername(properties.LinuxProfile.AdminUsername, datamodel.Linux)
+}
+
+// validateSSHPublicKeys validates the authorized SSH public keys of the node, so a malformed key fails the bootstrap.
+func validateSSHPublicKeys(config *datamodel.NodeBootstrappingConfiguration) error {
+	properties := config.ContainerService.Properties
+	if properties.LinuxProfile == nil {
+		return nil
+	}
+	// Windows nodes only authorize the keys when SSH is enabled.
+	if config.AgentPoolProfile != nil && (config.AgentPoolProfile.IsWindows() || config.AgentPoolProfile.Distro.IsWindowsDistro()) &&
+		properties.WindowsProfile != nil && !properties.WindowsProfile.GetSSHEnabled() {
+		return nil
+	}
+	for i, publicKey := range properties.LinuxProfile.SSH.PublicKeys {
+		if err := datamodel.ValidateSSHPublicKey(publicKey.KeyData); err != nil {
+			return fmt.Errorf("invalid SSH public key at index %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// validateUdevRules validates the names and content of the udev rules files written to the Linux node.
+func validateUdevRules(config *datamodel.NodeBootstrappingConfiguration) error {
+	if len(config.UdevRules) == 0 {
+		return nil
+	}
+	if config.AgentPoolProfile != nil && (config.AgentPoolProfile.IsWindows() || config.AgentPoolProfile.Distro.IsWindowsDistro()) {
+		return fmt.Errorf("udev rules are not supported on Windows nodes")
+	}
+	for name, content := range config.UdevRules {
+		if !strings.HasSuffix(name, ".rules") || name == ".rules" || strings.ContainsAny(name, "/\\ \t\n") {
+			return fmt.Errorf("invalid udev rules file name %q, must be a file name ending in .rules", name)
+		}
+		if strings.TrimSpace(content) == "" {
+			return fmt.Errorf("udev rules file %s must not be empty", name)
+		}
+	}
+	return nil
+}
+
+// validateAndSetKernelModules validates the names of the kernel modules to load at boot and removes duplicates.
+func validateAndSetKernelModules(config *datamodel.NodeBootstrappingConfiguration) error {
+	if len(config.KernelModules) == 0 {
+		return nil
+	}
+	if config.AgentPoolProfile != nil && (config.AgentPoolProfile.IsWindows() || config.AgentPoolProfile.Distro.IsWindowsDistro()) {
+		return fmt.Errorf("kernel modules are not supported on Windows nodes")
+	}
+	// the kernel limits module names to 55 characters.
+	const maxKernelModuleNameLength = 55
+	nameRegex := regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	seen := make(map[string]bool, len(config.KernelModules))
+	modules := make([]string, 0, len(config.KernelModules))
+	for _, module := range config.KernelModules {
+		if len(module) > maxKernelModuleNameLength || !nameRegex.MatchString(module) {
+			return fmt.Errorf("invalid kernel module name %q, must be at most %d letters, digits, underscores, and hyphens",
+				module, maxKernelModuleNameLength)
+		}
+		// modprobe treats hyphens and underscores in module names the same.
+		normalized := strings.ReplaceAll(module, "-", "_")
+		if seen[normalized] {
+			continue
+		}
+		seen[normalized] = true
+		modules = append(modules, module)
+	}
+	config.KernelModules = modules
+	return nil
+}
+
+// managedSystemdUnits are the systemd units created or configured by the AgentBaker templates.
+//
+//nolint:gochecknoglobals
+var managedSystemdUnits = []string{
+	"kubelet.service", "containerd.service", "kms.service", "bind-mount.service", "reconcile-private-hosts.service",
+	"snapshot-update.service", "snapshot-update.timer", "package-update.service", "package-update.timer",
+	"mig-partition.service", "dhcpv6.service", "ensure-no-dup.service", "cc-proxy.service", "cc-proxy.socket",
+	"node-problem-detector.service",
+}
+
+/*
+ManagedSystemdUnits returns the sorted names of the systemd units AgentBaker creates or configures on Linux nodes.
+User provided systemd configuration must not collide with them.
+*/
+func ManagedSystemdUnits() []string {
+	units := make([]string, len(managedSystemdUnits))
+	copy(units, managedSystemdUnits)
+	sort.Strings(units)
+	return units
+}
+
+func isManagedSystemdUnit(unit string) bool {
+	for _, managed := range managedSystemdUnits {
+		if unit == managed {
+			return true
+		}
+	}
+	return false
+}
+
+// distroSystemdUnits are the optional systemd units present on the VHDs of each distro family.
+//
+//nolint:gochecknoglobals
+var distroSystemdUnits = map[datamodel.DistroFamily][]string{
+	datamodel.DistroFamilyUbuntu: {
+		"apt-daily.service", "apt-daily.timer", "apt-daily-upgrade.service", "apt-daily-upgrade.timer", "motd-news.service",
+		"motd-news.timer", "snapd.service", "snapd.socket", "unattended-upgrades.service", "ubuntu-advantage.service",
+		"multipathd.service", "multipathd.socket", "ModemManager.service", "rsyslog.service", "chrony.service",
+	},
+	datamodel.DistroFamilyAzureLinux: {
+		"dnf-automatic.service", "dnf-automatic.timer", "dnf-automatic-install.service", "dnf-automatic-install.timer",
+		"rsyslog.service", "chronyd.service", "auditd.service",
+	},
+}
+
+// validateAndSetDisableSystemdUnits validates the names of the systemd units to disable, adds the service suffix
+// to names without a unit type suffix and removes duplicates.
+func validateAndSetDisableSystemdUnits(config *datamodel.NodeBootstrappingConfiguration) error {
+	if len(config.DisableSystemdUnits) == 0 {
+		return nil
+	}
+	if config.AgentPoolProfile != nil && (config.AgentPoolProfile.IsWindows() || config.AgentPoolProfile.Distro.IsWindowsDistro()) {
+		return fmt.Errorf("disabling systemd units is not supported on Windows nodes")
+	}
+	// systemd limits unit names to 255 characters.
+	const maxSystemdUnitNameLength = 255
+	nameRegex := regexp.MustCompile(`^[A-Za-z0-9:_.\\@-]+$`)
+	unitTypeRegex := regexp.MustCompile(`\.(service|socket|timer|path|mount|automount|swap|target|slice|scope|device)$`)
+	seen := make(map[string]bool, len(config.DisableSystemdUnits))
+	units := make([]string, 0, len(config.DisableSystemdUnits))
+	for _, unit := range config.DisableSystemdUnits {
+		if !unitTypeRegex.MatchString(unit) {
+			unit += ".service"
+		}
+		if len(unit) > maxSystemdUnitNameLength || !nameRegex.MatchString(unit) || strings.HasPrefix(unit, ".") {
+			return fmt.Errorf("invalid systemd unit name %q", unit)
+		}
+		if isManagedSystemdUnit(unit) {
+			return fmt.Errorf("systemd unit %s is managed by AgentBaker and can not be disabled", unit)
+		}
+		if seen[unit] {
+			continue
+		}
+		seen[unit] = true
+		units = append(units, unit)
+	}
+	config.DisableSystemdUnits = units
+	return nil
+}
+
+// getDisableSystemdUnitsWarnings returns a warning for each unit to disable which is not known to be on the VHD of the distro.
+func getDisableSystemdUnitsWarnings(config *datamodel.NodeBootstrappingConfiguration) []string {
+	if len(config.DisableSystemdUnits) == 0 || config.AgentPoolProfile == nil {
+		return nil
+	}
+	distro := config.AgentPoolProfile.Distro
+	knownUnits, ok := distroSystemdUnits[distro.Family()]
+	if !ok {
+		return nil
+	}
+	known := make(map[string]bool, len(knownUnits))
+	for _, unit := range knownUnits {
+		known[unit] = true
+	}
+	var warnings []string
+	for _, unit := range config.DisableSystemdUnits {
+		if !known[unit] {
+			warnings = append(warnings, fmt.Sprintf("systemd unit %s to disable is not known to be present on distro %s", unit, distro))
+		}
+	}
+	return warnings
+}
+
+// proxyEnvironmentVariables are the environment variables HTTPProxyConfig writes to /etc/environment.
+//
+//nolint:gochecknoglobals
+var proxyEnvironmentVariables = map[string]bool{"HTTP_PROXY": true, "HTTPS_PROXY": true, "NO_PROXY": true}
+
+func validateEnvironmentVariables(config *datamodel.NodeBootstrappingConfiguration) error {
+	if len(config.EnvironmentVariables) == 0 {
+		return nil
+	}
+	if config.AgentPoolProfile != nil && (config.AgentPoolProfile.IsWindows() || config.AgentPoolProfile.Distro.IsWindowsDistro()) {
+		return fmt.Errorf("environment variables are not supported on Windows nodes")
+	}
+	nameRegex := regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	for name, value := range config.EnvironmentVariables {
+		if !nameRegex.MatchString(name) {
+			return fmt.Errorf("invalid environment variable name %q, must be a valid shell identifier", name)
+		}
+		if proxyEnvironmentVariables[strings.ToUpper(name)] {
+			return fmt.Errorf("environment variable %s must be configured through HTTPProxyConfig", name)
+		}
+		// values are written double quoted, one variable per line.
+		if strings.ContainsAny(value, "\r\n\"") {
+			return fmt.Errorf("value of environment variable %s must not contain newlines or double quotes", name)
+		}
+	}
+	return nil
+}
+
+// getEnvironmentVariablesContent returns the lines appended to /etc/environment, sorted by variable name.
+func getEnvironmentVariablesContent(variables map[string]string) string {
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var content strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&content, "%s=\"%s\"\n", name, variables[name])
+	}
+	return content.String()
+}
+
+// getKernelModulesLoadContent returns the modules-load.d file loading the kernel modules at boot.
+func getKernelModulesLoadContent(modules []string) string {
+	if len(modules) == 0 {
+		return ""
+	}
+	return strings.Join(modules, "\n") + "\n"
+}
+
+/*
+validateAndSetMaxPods validates the --max-pods kubelet flag. When it is unset on a Linux Azure CNI node, it is set to
+the max pods of the cluster, or defaulted by VM size. The kubelet default is kept for other nodes.
+*/
+func validateAndSetMaxPods(config *datamodel.NodeBootstrappingConfiguration) error {
+	if value := config.KubeletConfig["--max-pods"]; value != "" {
+		if maxPods, err := strconv.Atoi(value); err != nil || maxPods <= 0 {
+			return fmt.Errorf("invalid --max-pods %q, must be a positive integer", value)
+		}
+		return nil
+	}
+	if config.AgentPoolProfile == nil || config.AgentPoolProfile.IsWindows() || config.AgentPoolProfile.Distro.IsWindowsDistro() {
+		return nil
+	}
+	properties := config.ContainerService.Properties
+	if properties.OrchestratorProfile == nil || properties.OrchestratorProfile.KubernetesConfig == nil {
+		return nil
+	}
+	networkPlugin := properties.OrchestratorProfile.KubernetesConfig.NetworkPlugin
+	if !strings.EqualFold(networkPlugin, datamodel.NetworkPluginAzure) {
+		return nil
+	}
+	maxPods := properties.OrchestratorProfile.KubernetesConfig.MaxPods
+	if maxPods <= 0 {
+		maxPods = datamodel.DefaultMaxPods(config.AgentPoolProfile.VMSize, networkPlugin)
+	}
+	if config.KubeletConfig == nil {
+		config.KubeletConfig = make(map[string]string)
+	}
+	config.KubeletConfig["--max-pods"] = strconv.Itoa(maxPods)
+	return nil
+}
+
+// validateAndSetPodMaxPids validates the PID limit of pods and renders it into the kubelet config.
+func validateAndSetPodMaxPids(config *datamodel.NodeBootstrappingConfiguration) error {
+	if config.PodMaxPids == nil {
+		return nil
+	}
+	podMaxPids := *config.PodMaxPids
+	if podMaxPids < 0 {
+		return fmt.Errorf("pod max pids must not be negative, got %d", podMaxPids)
+	}
+	// the custom kubelet config overrides the kubelet config file, so a different PID limit there would win silently.
+	if config.AgentPoolProfile != nil && config.AgentPoolProfile.CustomKubeletConfig != nil {
+		if customPodMaxPids := config.AgentPoolProfile.CustomKubeletConfig.PodMaxPids; customPodMaxPids != nil && int(*customPodMaxPids) != podMaxPids {
+			return fmt.Errorf("pod max pids %d conflicts with the pod max pids %d of the custom kubelet config", podMaxPids, *customPodMaxPids)
+		}
+	}
+	if config.KubeletConfig == nil {
+		config.KubeletConfig = make(map[string]string)
+	}
+	config.KubeletConfig["--pod-max-pids"] = strconv.Itoa(podMaxPids)
+	return nil
+}
+
+// validateAndSetExternalCloudProvider sets the kubelet flags of the out-of-tree cloud provider when enabled,
+// and checks the cloud provider in use is supported by the kubernetes version.
+func validateAndSetExternalCloudProvider(config *datamodel.NodeBootstrappingConfiguration) error {
+	var orchestratorVersion string
+	if orchestratorProfile := config.ContainerService.Properties.OrchestratorProfile; orchestratorProfile != nil {
+		orchestratorVersion = orchestratorProfile.OrchestratorVersion
+	}
+	if !config.ExternalCloudProvider {
+		if orchestratorVersion != "" && config.KubeletConfig["--cloud-provider"] == "azure" &&
+			IsKubernetesVersionGe(orchestratorVersion, inTreeCloudProviderRemovedKubernetesVersion) {
+			return fmt.Errorf("the in-tree azure cloud provider was removed in kubernetes %s, an external cloud provider is required for version %s",
+				inTreeCloudProviderRemovedKubernetesVersion, orchestratorVersion)
+		}
+		return nil
+	}
+	if orchestratorVersion != "" && !IsKubernetesVersionGe(orchestratorVersion, externalCloudProviderMinKubernetesVersion) {
+		return fmt.Errorf("an external cloud provider requires kubernetes %s or later, got %s",
+			externalCloudProviderMinKubernetesVersion, orchestratorVersion)
+	}
+	if config.KubeletConfig == nil {
+		config.KubeletConfig = make(map[string]string)
+	}
+	config.KubeletConfig["--cloud-provider"] = "external"
+	// the cloud config is only read by the in-tree cloud provider.
+	delete(config.KubeletConfig, "--cloud-config")
+	return nil
+}
+
+/*
+validateUserAssignedIdentityIDs validates the ARM resource IDs of the user-assigned identities of the cluster,
+i.e. of the addon identities and of the kubelet identity when it is given as a resource ID instead of a name.
+*/
+func validateUserAssignedIdentityIDs(config *datamodel.NodeBootstrappingConfiguration) error {
+	properties := config.ContainerService.Properties
+	if properties.OrchestratorProfile != nil && properties.OrchestratorProfile.KubernetesConfig != nil {
+		kubernetesConfig := properties.OrchestratorProfile.KubernetesConfig
+		if kubernetesConfig.UserAssignedIDEnabled() && strings.HasPrefix(kubernetesConfig.UserAssignedID, "/") {
+			if err := datamodel.ValidateUserAssignedIdentityID(kubernetesConfig.UserAssignedID); err != nil {
+				return fmt.Errorf("invalid kubelet identity: %w", err)
+			}
+		}
+	}
+	addonNames := make([]string, 0, len(properties.AddonProfiles))
+	for name := range properties.AddonProfiles {
+		addonNames = append(addonNames, name)
+	}
+	sort.Strings(addonNames)
+	for _, name := range addonNames {
+		identity := properties.AddonProfiles[name].Identity
+		if identity == nil || identity.ResourceID == "" {
+			continue
+		}
+		if err := datamodel.ValidateUserAssignedIdentityID(identity.ResourceID); err != nil {
+			return fmt.Errorf("invalid identity of addon %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func validateAndSetWorkloadIdentityConfig(config *datamodel.NodeBootstrappingConfiguration) error {
+	workloadIdentityConfig := config.WorkloadIdentityConfig
+	if workloadIdentityConfig == nil {
+		return nil
+	}
+	issuer, err := url.Parse(workloadIdentityConfig.Issuer)
+	if err != nil || issuer.Scheme != "https" || issuer.Host == "" || issuer.RawQuery != "" || issuer.Fragment != "" {
+		return fmt.Errorf("invalid workload identity issuer %q, must be an https URL without query or fragment", workloadIdentityConfig.Issuer)
+	}
+	if orchestratorProfile := config.ContainerService.Properties.OrchestratorProfile; orchestratorProfile != nil &&
+		orchestratorProfile.KubernetesConfig != nil && orchestratorProfile.KubernetesConfig.IsAADPodIdentityEnabled() {
+		return fmt.Errorf("workload identity can not be combined with the %s addon", datamodel.AADPodIdentityAddonName)
+	}
+	if strings.TrimSpace(workloadIdentityConfig.Audience) == "" {
+		workloadIdentityConfig.Audience = datamodel.DefaultWorkloadIdentityAudience
+	}
+	return nil
+}
+
+// validateAndSetControlPlaneReadyWait validates the wait for the API server before kubelet starts and fills in the default timeout.
+func validateAndSetControlPlaneReadyWait(config *datamodel.NodeBootstrappingConfiguration) error {
+	if !config.WaitForControlPlaneReady {
+		if config.ControlPlaneReadyTimeoutSeconds != 0 {
+			return fmt.Errorf("control plane ready timeout requires waiting for the control plane to be ready")
+		}
+		return nil
+	}
+	if config.AgentPoolProfile != nil && (config.AgentPoolProfile.IsWindows() || config.AgentPoolProfile.Distro.IsWindowsDistro()) {
+		return fmt.Errorf("waiting for the control plane to be ready is not supported on Windows nodes")
+	}
+	if config.KubeletClientTLSBootstrapToken == nil && !config.EnableSecureTLSBootstrapping {
+		return fmt.Errorf("waiting for the control plane to be ready requires TLS bootstrapping")
+	}
+	if getKubernetesEndpoint(config.ContainerService) == "" {
+		return fmt.Errorf("waiting for the control plane to be ready requires the API server FQDN or IP address")
+	}
+	timeout := config.ControlPlaneReadyTimeoutSeconds
+	if timeout < 0 || timeout > datamodel.MaxControlPlaneReadyTimeoutSeconds {
+		return fmt.Errorf("control plane ready timeout must be between 1 and %d seconds, got %d",
+			datamodel.MaxControlPlaneReadyTimeoutSeconds, timeout)
+	}
+	if timeout == 0 {
+		config.ControlPlaneReadyTimeoutSeconds = datamodel.DefaultControlPlaneReadyTimeoutSeconds
+	}
+	return nil
+}
+
+// validateTrustedLaunch validates that the distro and the VM size of a trusted launch node support trusted launch.
+func validateTrustedLaunch(config *datamodel.NodeBootstrappingConfiguration) error {
+	if config.TrustedLaunch == nil || config.AgentPoolProfile == nil {
+		return nil
+	}
+	profile := config.AgentPoolProfile
+	if !profile.Distro.SupportsTrustedLaunch() {
+		return fmt.Errorf("distro %s does not support trusted launch, a Gen 2 x86 distro is required", profile.Distro)
+	}
+	if !datamodel.IsTrustedLaunchSupportedSKU(profile.VMSize) {
+		return fmt.Errorf("VM size %s does not support trusted launch", profile.VMSize)
+	}
+	return nil
+}
+
+/*
+validateAndSetOSDiskType validates the VM size of a node with an ephemeral OS disk has a local disk to place it on,
+and moves the kubelet root dir of Linux nodes to the ephemeral mount.
+*/
+func validateAndSetOSDiskType(config *datamodel.NodeBootstrappingConfiguration) error {
+	switch config.OSDiskType {
+	case "":
+		config.OSDiskType = datamodel.OSDiskTypeManaged
+		return nil
+	case datamodel.OSDiskTypeManaged:
+		return nil
+	case datamodel.OSDiskTypeEphemeral:
+	default:
+		return fmt.Errorf("unknown OS disk type %q, must be %s or %s", config.OSDiskType, datamodel.OSDiskTypeManaged, datamodel.OSDiskTypeEphemeral)
+	}
+	profile := config.AgentPoolProfile
+	if profile == nil {
+		return nil
+	}
+	if !datamodel.IsEphemeralOSDiskSupportedSKU(profile.VMSize) {
+		return fmt.Errorf("VM size %s does not support ephemeral OS disks, it has no local disk", profile.VMSize)
+	}
+	if profile.KubeletDiskType == datamodel.TempDisk {
+		return fmt.Errorf("kubelet disk type %s can not be combined with an ephemeral OS disk", datamodel.TempDisk)
+	}
+	if profile.IsWindows() {
+		return nil
+	}
+	if config.KubeletConfig == nil {
+		config.KubeletConfig = make(map[string]string)
+	}
+	if _, ok := config.KubeletConfig["--root-dir"]; !ok {
+		config.KubeletConfig["--root-dir"] = datamodel.EphemeralOSDiskKubeletRootDir
+	}
+	return nil
+}
+
+// validateHostnamePattern validates the hostname pattern renders a valid hostname for the node.
+func validateHostnamePattern(config *datamodel.NodeBootstrappingConfiguration) error {
+	if config.HostnamePattern == "" {
+		return nil
+	}
+	_, err := config.ExpectedNodeName()
+	return err
+}
+
+/*
+getSetHostnameCommand returns the command the CSE sets the hostname of the node with. VMSS instances share the custom
+data of the scale set, so the instance ID is resolved on the node from the VM name IMDS returns, <scale set>_<instance ID>.
+*/
+func getSetHostnameCommand(config *datamodel.NodeBootstrappingConfiguration) string {
+	if config.AgentPoolProfile == nil || !config.AgentPoolProfile.IsVirtualMachineScaleSets() {
+		// the hostname pattern is validated, so the error is always nil.
+		hostname, _ := config.ExpectedNodeName()
+		return fmt.Sprintf("hostnamectl set-hostname %s", hostname)
+	}
+	hostname, _ := config.ExpandHostnamePattern("${instance_id}")
+	return fmt.Sprintf("vm_name=$(curl -sf -H Metadata:true --noproxy '*' '%s') && instance_id=${vm_name##*_} && hostnamectl set-hostname \"%s\"",
+		imdsComputeNameURL, hostname)
+}
+
+// getControlPlaneReadyWaitCommand returns the command the CSE runs before starting kubelet, it polls the readyz endpoint
+// of the API server until it is ready, and fails when the API server is not ready within the timeout.
+func getControlPlaneReadyWaitCommand(endpoint string, timeoutSeconds int) string {
+	return fmt.Sprintf("timeout %d sh -c 'until curl -sf --max-time 10 --cacert %s https://%s:443/readyz >/dev/null; do sleep 5; done'",
+		timeoutSeconds, kubernetesCACertFilepath, endpoint)
+}
+
+// validateKubeletStartupRetries validates the number of times the CSE retries starting kubelet.
+func validateKubeletStartupRetries(config *datamodel.NodeBootstrappingConfiguration) error {
+	if config.KubeletStartupRetries == 0 {
+		return nil
+	}
+	if config.AgentPoolProfile != nil && (config.AgentPoolProfile.IsWindows() || config.AgentPoolProfile.Distro.IsWindowsDistro()) {
+		return fmt.Errorf("kubelet startup retries are not supported on Windows nodes")
+	}
+	if config.KubeletStartupRetries < 0 || config.KubeletStartupRetries > datamodel.MaxKubeletStartupRetries {
+		return fmt.Errorf("kubelet startup retries must be between 0 and %d, got %d",
+			datamodel.MaxKubeletStartupRetries, config.KubeletStartupRetries)
+	}
+	return nil
+}
+
+/*
+getKubeletStartCommand returns the command the CSE starts kubelet with. A start is failed when kubelet is not active
+KubeletStartupSettleSeconds after it, and is retried up to retries times. The start rate limit of the unit is reset
+before each retry and the wait grows by KubeletStartupBackoffSeconds. The CSE exits with ERR_KUBELET_START_FAIL when
+all attempts fail.
+*/
+func getKubeletStartCommand(retries int) string {
+	if retries <= 0 {
+		return "systemctl start kubelet"
+	}
+	attempts := retries + 1
+	return fmt.Sprintf("for i in $(seq 1 %d); do systemctl start kubelet && sleep %d && systemctl is-active --quiet kubelet && break; "+
+		"if [ $i -eq %d ]; then exit $ERR_KUBELET_START_FAIL; fi; systemctl reset-failed kubelet; sleep $((i * %d)); done",
+		attempts, datamodel.KubeletStartupSettleSeconds, attempts, datamodel.KubeletStartupBackoffSeconds)
+}
+
+// getKubernetesEndpoint returns the IP address of the API server, or its FQDN if the IP address is unknown.
+func getKubernetesEndpoint(cs *datamodel.ContainerService) string {
+	if cs.Properties.HostedMasterProfile == nil {
+		return ""
+	}
+	if cs.Properties.HostedMasterProfile.IPAddress != "" {
+		return cs.Properties.HostedMasterProfile.IPAddress
+	}
+	return cs.Properties.HostedMasterProfile.FQDN
+}
+
+/*
+validateAndSetRegisterWithTaints validates the taints kubelet registers the node with and renders them, sorted, into
+the --register-with-taints flag. A key can only be tainted once per effect.
+*/
+func validateAndSetRegisterWithTaints(config *datamodel.NodeBootstrappingConfiguration) error {
+	if len(config.RegisterWithTaints) == 0 {
+		return nil
+	}
+	taints := append([]datamodel.Taint{}, config.RegisterWithTaints...)
+	// sort the taints so the rendered flag does not depend on the order they were specified in.
+	sort.Slice(taints, func(i, j int) bool {
+		if taints[i].Key != taints[j].Key {
+			return taints[i].Key < taints[j].Key
+		}
+		if taints[i].Effect != taints[j].Effect {
+			return taints[i].Effect < taints[j].Effect
+		}
+		return taints[i].Value < taints[j].Value
+	})
+	rendered := make([]string, 0, len(taints))
+	for i, taint := range taints {
+		if err := datamodel.ValidateTaint(taint); err != nil {
+			return fmt.Errorf("invalid --register-with-taints: %w", err)
+		}
+		if i > 0 && taints[i-1].Key == taint.Key && taints[i-1].Effect == taint.Effect {
+			return fmt.Errorf("invalid --register-with-taints: duplicate taint with key %s and effect %s", taint.Key, taint.Effect)
+		}
+		rendered = append(rendered, taint.String())
+	}
+	if config.KubeletConfig == nil {
+		config.KubeletConfig = make(map[string]string)
+	}
+	config.KubeletConfig["--register-with-taints"] = strings.Join(rendered, ",")
+	return nil
+}
+
+// defaultHostsHostnames are the hostnames of the default /etc/hosts entries of the Linux VHDs.
+//
+//nolint:gochecknoglobals
+var defaultHostsHostnames = map[string]bool{
+	"localhost": true, "localhost.localdomain": true, "ip6-localhost": true, "ip6-loopback": true,
+	"ip6-localnet": true, "ip6-mcastprefix": true, "ip6-allnodes": true, "ip6-allrouters": true,
+}
+
+func validateExtraHostsEntries(config *datamodel.NodeBootstrappingConfiguration) error {
+	if len(config.ExtraHostsEntries) == 0 {
+		return nil
+	}
+	if config.AgentPoolProfile != nil && (config.AgentPoolProfile.IsWindows() || config.AgentPoolProfile.Distro.IsWindowsDistro()) {
+		return fmt.Errorf("extra hosts entries are not supported on Windows nodes")
+	}
+	for _, entry := range config.ExtraHostsEntries {
+		ip := net.ParseIP(entry.IP)
+		if ip == nil {
+			return fmt.Errorf("invalid IP %q of hosts entry", entry.IP)
+		}
+		// the default localhost entries must keep resolving as they do on the VHD.
+		if ip.IsLoopback() {
+			return fmt.Errorf("hosts entry IP %s must not be a loopback address", entry.IP)
+		}
+		if len(entry.Hostnames) == 0 {
+			return fmt.Errorf("hosts entry of IP %s must have at least one hostname", entry.IP)
+		}
+		for _, hostname := range entry.Hostnames {
+			if net.ParseIP(hostname) != nil || datamodel.ValidateHostnameOrIP(hostname) != nil {
+				return fmt.Errorf("invalid hostname %q of hosts entry %s", hostname, entry.IP)
+			}
+			if defaultHostsHostnames[strings.ToLower(hostname)] {
+				return fmt.Errorf("hosts entry %s must not override the default hostname %s", entry.IP, hostname)
+			}
+		}
+	}
+	return nil
+}
+
+// getExtraHostsEntriesContent returns the lines appended to /etc/hosts for the extra hosts entries.
+func getExtraHostsEntriesContent(entries []datamodel.HostEntry) string {
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		buf.WriteString(strings.Join(append([]string{entry.IP}, entry.Hostnames...), " "))
+		buf.WriteString("\n")
+	}
+	return buf.String()
+}
+
+func validateBootCmds(config *datamodel.NodeBootstrappingConfiguration) error {
+	if len(config.BootCmds) == 0 {
+		return nil
+	}
+	if config.AgentPoolProfile != nil && (config.AgentPoolProfile.IsWindows() || config.AgentPoolProfile.Distro.IsWindowsDistro()) {
+		return fmt.Errorf("boot commands are not supported on Windows nodes")
+	}
+	for i, cmd := range config.BootCmds {
+		if strings.TrimSpace(cmd) == "" {
+			return fmt.Errorf("boot command %d is empty", i)
+		}
+	}
+	return nil
+}
+
+/*
+getBootCmdsWarnings returns a warning for each boot command which conflicts with the provisioning of AgentBaker: boot
+commands touching a file AgentBaker writes are overwritten by write_files, and boot commands controlling a systemd
+unit AgentBaker manages run before the unit is configured.
+*/
+func getBootCmdsWarnings(config *datamodel.NodeBootstrappingConfiguration) []string {
+	if len(config.BootCmds) == 0 || config.ContainerService == nil || config.ContainerService.Properties == nil {
+		return nil
+	}
+	managedFiles := getManagedFiles(config)
+	var warnings []string
+	for _, cmd := range config.BootCmds {
+		for _, file := range managedFiles {
+			if strings.Contains(cmd, file.Path) {
+				warnings = append(warnings, fmt.Sprintf("boot command %q touches %s, which AgentBaker writes after the boot commands run", cmd, file.Path))
+			}
+		}
+		fields := strings.Fields(cmd)
+		if !containsString(fields, "systemctl") {
+			continue
+		}
+		for _, unit := range managedSystemdUnits {
+			if containsString(fields, unit) || containsString(fields, strings.TrimSuffix(unit, ".service")) {
+				warnings = append(warnings, fmt.Sprintf("boot command %q controls systemd unit %s, which is managed by AgentBaker", cmd, unit))
+			}
+		}
+	}
+	return warnings
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// getBootCmdsContent returns the cloud-init bootcmd section of the boot commands.
+func getBootCmdsContent(cmds []string) string {
+	var buf bytes.Buffer
+	buf.WriteString("bootcmd:\n")
+	for _, cmd := range cmds {
+		// a double quoted Go string is a valid double quoted YAML string.
+		buf.WriteString(fmt.Sprintf("  - %s\n", strconv.Quote(cmd)))
 	}
-	return truncated
+	return buf.String()
 }
 
-func validateAndSetLinuxNodeBootstrappingConfiguration(config *datamodel.NodeBootstrappingConfiguration) {
-	// If using kubelet config file, disable DynamicKubeletConfig feature gate and remove dynamic-config-dir
-	// we should only allow users to configure from API (20201101 and later)
-	dockerShimFlags := []string{
-		"--cni-bin-dir",
-		"--cni-cache-dir",
-		"--cni-conf-dir",
-		"--docker-endpoint",
-		"--image-pull-progress-deadline",
-		"--network-plugin",
-		"--network-plugin-mtu",
+// validateResolvConfMode validates the resolv.conf mode and that the node's distro supports it.
+func validateResolvConfMode(config *datamodel.NodeBootstrappingConfiguration) error {
+	switch config.ResolvConfMode {
+	case "":
+		return nil
+	case datamodel.ResolvConfModeSystemdResolved, datamodel.ResolvConfModeDirect:
+	default:
+		return fmt.Errorf("invalid resolv.conf mode %q, must be one of %s or %s", config.ResolvConfMode,
+			datamodel.ResolvConfModeSystemdResolved, datamodel.ResolvConfModeDirect)
 	}
-	profile := config.AgentPoolProfile
-	if config.KubeletConfig != nil {
-		kubeletFlags := config.KubeletConfig
-		delete(kubeletFlags, "--dynamic-config-dir")
-		delete(kubeletFlags, "--non-masquerade-cidr")
-		if profile != nil && profile.KubernetesConfig != nil &&
-			profile.KubernetesConfig.ContainerRuntime != "" &&
-			profile.KubernetesConfig.ContainerRuntime == "containerd" {
-			for _, flag := range dockerShimFlags {
-				delete(kubeletFlags, flag)
+	if config.AgentPoolProfile == nil {
+		return nil
+	}
+	if config.AgentPoolProfile.IsWindows() || config.AgentPoolProfile.Distro.IsWindowsDistro() {
+		return fmt.Errorf("resolv.conf mode %s is not supported on Windows nodes", config.ResolvConfMode)
+	}
+	// Ubuntu 16.04 does not run systemd-resolved, its resolv.conf always points at the upstream DNS servers.
+	if config.ResolvConfMode == datamodel.ResolvConfModeSystemdResolved && config.AgentPoolProfile.Distro == datamodel.AKSUbuntu1604 {
+		return fmt.Errorf("resolv.conf mode %s is not supported on distro %s", config.ResolvConfMode, config.AgentPoolProfile.Distro)
+	}
+	return nil
+}
+
+// validateAPTSources validates the additional APT repositories, which are only supported on Ubuntu nodes.
+func validateAPTSources(config *datamodel.NodeBootstrappingConfiguration) error {
+	if len(config.APTSources) == 0 {
+		return nil
+	}
+	if config.AgentPoolProfile == nil ||
+		!(config.AgentPoolProfile.Distro.IsUbuntuDistro() || strings.EqualFold(config.OSSKU, datamodel.OSSKUUbuntu)) {
+		return fmt.Errorf("APT sources are only supported on Ubuntu nodes")
+	}
+	for _, source := range config.APTSources {
+		uri, err := url.Parse(source.URI)
+		if err != nil {
+			return fmt.Errorf("invalid APT source URI %q: %w", source.URI, err)
+		}
+		switch uri.Scheme {
+		case "http", "https":
+			if uri.Host == "" {
+				return fmt.Errorf("invalid APT source URI %q: host is required", source.URI)
+			}
+		case "file":
+		default:
+			return fmt.Errorf("invalid APT source URI %q: scheme must be one of http, https or file", source.URI)
+		}
+		if source.Suite == "" || strings.ContainsAny(source.Suite, " \t\n") {
+			return fmt.Errorf("invalid suite %q of APT source %s", source.Suite, source.URI)
+		}
+		// flat repositories, whose suite ends with "/", have no components.
+		if strings.HasSuffix(source.Suite, "/") != (len(source.Components) == 0) {
+			return fmt.Errorf("APT source %s must have components unless its suite is a flat repository ending with /", source.URI)
+		}
+		for _, component := range source.Components {
+			if component == "" || strings.ContainsAny(component, " \t\n") {
+				return fmt.Errorf("invalid component %q of APT source %s", component, source.URI)
 			}
 		}
-		if IsKubernetesVersionGe(config.ContainerService.Properties.OrchestratorProfile.OrchestratorVersion, "1.24.0") {
-			kubeletFlags["--feature-gates"] = removeFeatureGateString(kubeletFlags["--feature-gates"], "DynamicKubeletConfig")
-		} else if IsKubernetesVersionGe(config.ContainerService.Properties.OrchestratorProfile.OrchestratorVersion, "1.11.0") {
-			kubeletFlags["--feature-gates"] = addFeatureGateString(kubeletFlags["--feature-gates"], "DynamicKubeletConfig", false)
+		if source.SigningKey == "" {
+			continue
+		}
+		if err = datamodel.ValidateArmoredGPGPublicKey(source.SigningKey); err != nil {
+			return fmt.Errorf("invalid signing key of APT source %s: %w", source.URI, err)
 		}
+	}
+	return nil
+}
 
-		/* ContainerInsights depends on GPU accelerator Usage metrics from Kubelet cAdvisor endpoint but
-		deprecation of this feature moved to beta which breaks the ContainerInsights customers with K8s
-		 version 1.20 or higher */
-		/* Until Container Insights move to new API adding this feature gate to get the GPU metrics
-		continue to work */
-		/* Reference -
-		https://github.com/kubernetes/enhancements/tree/master/keps/sig-node/1867-disable-accelerator-usage-metrics */
-		if IsKubernetesVersionGe(config.ContainerService.Properties.OrchestratorProfile.OrchestratorVersion, "1.20.0") &&
-			!IsKubernetesVersionGe(config.ContainerService.Properties.OrchestratorProfile.OrchestratorVersion, "1.25.0") {
-			kubeletFlags["--feature-gates"] = addFeatureGateString(kubeletFlags["--feature-gates"], "DisableAcceleratorUsageMetrics", false)
+// getAPTSourcesListContent returns the one-line-style APT sources list of the additional APT repositories.
+func getAPTSourcesListContent(sources []datamodel.APTSource) string {
+	var buf bytes.Buffer
+	for i, source := range sources {
+		buf.WriteString("deb ")
+		if source.SigningKey != "" {
+			buf.WriteString(fmt.Sprintf("[signed-by=%s] ", fmt.Sprintf(aptSourceSigningKeyFilepathFormat, i)))
 		}
+		buf.WriteString(strings.Join(append([]string{source.URI, source.Suite}, source.Components...), " "))
+		buf.WriteString("\n")
 	}
+	return buf.String()
 }
 
-func validateAndSetWindowsNodeBootstrappingConfiguration(config *datamodel.NodeBootstrappingConfiguration) {
-	if IsTLSBootstrappingEnabledWithHardCodedToken(config.KubeletClientTLSBootstrapToken) {
-		// backfill proper flags for Windows agent node TLS bootstrapping
-		if config.KubeletConfig == nil {
-			config.KubeletConfig = make(map[string]string)
+// getPrePullImagesNotOnVHD returns the images to pre-pull which are not already cached on the VHD.
+func getPrePullImagesNotOnVHD(images []datamodel.PrePullImage, onVHD *cache.OnVHD) []datamodel.PrePullImage {
+	notOnVHD := []datamodel.PrePullImage{}
+	for _, image := range images {
+		if !onVHD.HasContainerImage(image.Image) {
+			notOnVHD = append(notOnVHD, image)
 		}
+	}
+	return notOnVHD
+}
 
-		config.KubeletConfig["--bootstrap-kubeconfig"] = "c:\\k\\bootstrap-config"
-		config.KubeletConfig["--cert-dir"] = "c:\\k\\pki"
+// getProvisioningManifest summarizes the versions and configuration resolved for the node bootstrapping.
+func getProvisioningManifest(config *datamodel.NodeBootstrappingConfiguration,
+	nodeBootstrapping *datamodel.NodeBootstrapping) *datamodel.ProvisioningManifest {
+	manifest := &datamodel.ProvisioningManifest{
+		Distro:            config.AgentPoolProfile.Distro,
+		KubernetesVersion: config.ContainerService.Properties.OrchestratorProfile.OrchestratorVersion,
+		ComponentVersions: map[string]string{},
+		OSImageConfig:     nodeBootstrapping.OSImageConfig,
+		SigImageConfig:    nodeBootstrapping.SigImageConfig,
 	}
-	if config.KubeletConfig != nil {
-		kubeletFlags := config.KubeletConfig
-		delete(kubeletFlags, "--dynamic-config-dir")
-		if IsKubernetesVersionGe(config.ContainerService.Properties.OrchestratorProfile.OrchestratorVersion, "1.24.0") {
-			kubeletFlags["--feature-gates"] = removeFeatureGateString(kubeletFlags["--feature-gates"], "DynamicKubeletConfig")
-		} else if IsKubernetesVersionGe(config.ContainerService.Properties.OrchestratorProfile.OrchestratorVersion, "1.11.0") {
-			kubeletFlags["--feature-gates"] = addFeatureGateString(kubeletFlags["--feature-gates"], "DynamicKubeletConfig", false)
+	manifest.ComponentVersions["kubernetes"] = manifest.KubernetesVersion
+	if config.ContainerdVersion != "" {
+		manifest.ComponentVersions["containerd"] = config.ContainerdVersion
+	}
+	if config.RuncVersion != "" {
+		manifest.ComponentVersions["runc"] = config.RuncVersion
+	}
+	if len(config.KubeletConfig) > 0 {
+		manifest.KubeletFlags = make(map[string]string, len(config.KubeletConfig))
+		for flag, value := range config.KubeletConfig {
+			manifest.KubeletFlags[flag] = value
 		}
 	}
+	for _, image := range getPrePullImagesNotOnVHD(config.PrePullImages, cache.GetOnVHD()) {
+		manifest.PrePullImages = append(manifest.PrePullImages, image.Image)
+	}
+	return manifest
 }
 
 // getContainerServiceFuncMap returns all functions used in template generation.
@@ -400,7 +2779,7 @@ func getContainerServiceFuncMap(config *datamodel.NodeBootstrappingConfiguration
 			return config.GetOrderedKubeproxyConfigStringForPowershell()
 		},
 		"IsCgroupV2": func() bool {
-			return profile.Is2204VHDDistro() || profile.IsAzureLinuxCgroupV2VHDDistro()
+			return profile.Distro.IsCgroupV2Distro()
 		},
 		"GetKubeProxyFeatureGatesPsh": func() string {
 			return cs.Properties.GetKubeProxyFeatureGatesWindowsArguments()
@@ -464,13 +2843,7 @@ func getContainerServiceFuncMap(config *datamodel.NodeBootstrappingConfiguration
 			return cs.Properties.OrchestratorProfile.IsKubernetes()
 		},
 		"GetKubernetesEndpoint": func() string {
-			if cs.Properties.HostedMasterProfile == nil {
-				return ""
-			}
-			if cs.Properties.HostedMasterProfile.IPAddress != "" {
-				return cs.Properties.HostedMasterProfile.IPAddress
-			}
-			return cs.Properties.HostedMasterProfile.FQDN
+			return getKubernetesEndpoint(cs)
 		},
 		"IsAzureCNI": func() bool {
 			return cs.Properties.OrchestratorProfile.IsAzureCNI()
@@ -615,7 +2988,7 @@ func getContainerServiceFuncMap(config *datamodel.NodeBootstrappingConfiguration
 			return datamodel.AzureADIdentitySystem
 		},
 		"GetPodInfraContainerSpec": func() string {
-			return config.K8sComponents.PodInfraContainerImageURL
+			return getSandboxImage(config)
 		},
 		"IsKubenet": func() bool {
 			return cs.Properties.OrchestratorProfile.KubernetesConfig.NetworkPlugin == NetworkPluginKubenet
@@ -642,17 +3015,23 @@ func getContainerServiceFuncMap(config *datamodel.NodeBootstrappingConfiguration
 			return cs.Properties.OrchestratorProfile.KubernetesConfig.RequiresDocker()
 		},
 		"HasDataDir": func() bool {
+			if config.ContainerdRootDir != "" {
+				return true
+			}
 			if profile != nil && profile.KubernetesConfig != nil && profile.KubernetesConfig.ContainerRuntimeConfig != nil &&
 				profile.KubernetesConfig.ContainerRuntimeConfig[datamodel.ContainerDataDirKey] != "" {
 				return true
 			}
-			if profile.KubeletDiskType == datamodel.TempDisk {
+			if profile.KubeletDiskType == datamodel.TempDisk || config.OSDiskType == datamodel.OSDiskTypeEphemeral {
 				return true
 			}
 			return cs.Properties.OrchestratorProfile.KubernetesConfig.ContainerRuntimeConfig != nil &&
 				cs.Properties.OrchestratorProfile.KubernetesConfig.ContainerRuntimeConfig[datamodel.ContainerDataDirKey] != ""
 		},
 		"GetDataDir": func() string {
+			if config.ContainerdRootDir != "" {
+				return config.ContainerdRootDir
+			}
 			if profile != nil && profile.KubernetesConfig != nil &&
 				profile.KubernetesConfig.ContainerRuntimeConfig != nil &&
 				profile.KubernetesConfig.ContainerRuntimeConfig[datamodel.ContainerDataDirKey] != "" {
@@ -661,6 +3040,9 @@ func getContainerServiceFuncMap(config *datamodel.NodeBootstrappingConfiguration
 			if profile.KubeletDiskType == datamodel.TempDisk {
 				return datamodel.TempDiskContainerDataDir
 			}
+			if config.OSDiskType == datamodel.OSDiskTypeEphemeral {
+				return datamodel.EphemeralOSDiskContainerDataDir
+			}
 			return cs.Properties.OrchestratorProfile.KubernetesConfig.ContainerRuntimeConfig[datamodel.ContainerDataDirKey]
 		},
 		"HasKubeletDiskType": func() bool {
@@ -686,14 +3068,22 @@ func getContainerServiceFuncMap(config *datamodel.NodeBootstrappingConfiguration
 			return base64.StdEncoding.EncodeToString([]byte(kubenetCniTemplate))
 		},
 		"GetContainerdConfigContent": func() string {
-			output, err := containerdConfigFromTemplate(config, profile, containerdConfigTemplateString)
+			tmpl, err := getContainerdConfigTemplate(config.ContainerdConfigTemplateVersion, true)
+			if err != nil {
+				panic(err)
+			}
+			output, err := containerdConfigFromTemplate(config, profile, tmpl)
 			if err != nil {
 				panic(err)
 			}
 			return output
 		},
 		"GetContainerdConfigNoGPUContent": func() string {
-			output, err := containerdConfigFromTemplate(config, profile, containerdConfigNoGpuTemplateString)
+			tmpl, err := getContainerdConfigTemplate(config.ContainerdConfigTemplateVersion, false)
+			if err != nil {
+				panic(err)
+			}
+			output, err := containerdConfigFromTemplate(config, profile, tmpl)
 			if err != nil {
 				panic(err)
 			}
@@ -712,10 +3102,7 @@ func getContainerServiceFuncMap(config *datamodel.NodeBootstrappingConfiguration
 			return config.K8sComponents.LinuxPrivatePackageURL
 		},
 		"GetTargetEnvironment": func() string {
-			if cs.IsAKSCustomCloud() {
-				return cs.Properties.CustomCloudEnv.Name
-			}
-			return GetCloudTargetEnv(cs.Location)
+			return getTargetEnvironment(cs)
 		},
 		"IsAKSCustomCloud": func() bool {
 			return cs.IsAKSCustomCloud()
@@ -969,6 +3356,343 @@ func getContainerServiceFuncMap(config *datamodel.NodeBootstrappingConfiguration
 		"InsertIMDSRestrictionRuleToMangleTable": func() bool {
 			return config.InsertIMDSRestrictionRuleToMangleTable
 		},
+		"GetNTPServers": func() string {
+			return strings.Join(config.NTPServers, " ")
+		},
+		"IsArcEnabled": func() bool {
+			return config.IsArcEnabled()
+		},
+		"GetArcTenantID": func() string {
+			if config.ArcConfig == nil {
+				return ""
+			}
+			return config.ArcConfig.TenantID
+		},
+		"GetArcResourceGroup": func() string {
+			if config.ArcConfig == nil {
+				return ""
+			}
+			return config.ArcConfig.ResourceGroup
+		},
+		"GetArcClusterName": func() string {
+			if config.ArcConfig == nil {
+				return ""
+			}
+			return config.ArcConfig.ClusterName
+		},
+		"ShouldWriteProvisionCompleteMarker": func() bool {
+			return config.ProvisionCompleteMarker != ""
+		},
+		"GetProvisionCompleteMarker": func() string {
+			return config.ProvisionCompleteMarker
+		},
+		"GetProvisionCompleteMarkerFilepath": func() string {
+			if profile.IsWindows() {
+				return provisionCompleteMarkerWindowsFilepath
+			}
+			return provisionCompleteMarkerFilepath
+		},
+		"GetPrePullImages": func() []datamodel.PrePullImage {
+			// only Linux VHDs have their cached container images tracked.
+			if profile.IsWindows() {
+				return config.PrePullImages
+			}
+			return getPrePullImagesNotOnVHD(config.PrePullImages, cache.GetOnVHD())
+		},
+		"GetResolvConfMode": func() string {
+			return string(config.ResolvConfMode)
+		},
+		"IsResolvConfModeDirect": func() bool {
+			return config.ResolvConfMode == datamodel.ResolvConfModeDirect
+		},
+		"GetResolvedNodeLabels": func() string {
+			labels, _ := ResolveNodeLabels(config)
+			keys := make([]string, 0, len(labels))
+			for key := range labels {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			pairs := make([]string, 0, len(keys))
+			for _, key := range keys {
+				pairs = append(pairs, fmt.Sprintf("%s=%s", key, labels[key]))
+			}
+			return strings.Join(pairs, ",")
+		},
+		"ShouldConfigureAPTSources": func() bool {
+			return len(config.APTSources) > 0
+		},
+		"GetAPTSources": func() []datamodel.APTSource {
+			return config.APTSources
+		},
+		"GetAPTSourcesListContent": func() string {
+			return getAPTSourcesListContent(config.APTSources)
+		},
+		"GetAPTSourcesListFilepath": func() string {
+			return aptSourcesListFilepath
+		},
+		"GetAPTSourceSigningKeyFilepath": func(index int) string {
+			return fmt.Sprintf(aptSourceSigningKeyFilepathFormat, index)
+		},
+		"GetDownloadMaxRetries": func() int {
+			if config.DownloadRetryConfig == nil {
+				return datamodel.DefaultDownloadMaxRetries
+			}
+			return config.DownloadRetryConfig.MaxRetries
+		},
+		"GetDownloadBackoffSeconds": func() int {
+			if config.DownloadRetryConfig == nil {
+				return datamodel.DefaultDownloadBackoffSeconds
+			}
+			return config.DownloadRetryConfig.BackoffSeconds
+		},
+		"GetDownloadConcurrency": func() int {
+			if config.DownloadConcurrency == 0 {
+				return datamodel.DefaultDownloadConcurrency
+			}
+			return config.DownloadConcurrency
+		},
+		"ShouldConfigureUdevRules": func() bool {
+			return len(config.UdevRules) > 0
+		},
+		"GetUdevRules": func() map[string]string {
+			return config.UdevRules
+		},
+		"GetUdevRuleFilepath": func(name string) string {
+			return path.Join(udevRulesDirectory, name)
+		},
+		"GetCNIPluginVersion": func() string {
+			return config.CNIPluginVersion
+		},
+		"ShouldConfigureExtraHostsEntries": func() bool {
+			return len(config.ExtraHostsEntries) > 0
+		},
+		"GetExtraHostsEntriesContent": func() string {
+			return getExtraHostsEntriesContent(config.ExtraHostsEntries)
+		},
+		"HasBootCmds": func() bool {
+			return len(config.BootCmds) > 0
+		},
+		"GetBootCmdsContent": func() string {
+			return getBootCmdsContent(config.BootCmds)
+		},
+		"IsExternalCloudProvider": func() bool {
+			return config.ExternalCloudProvider
+		},
+		"GetAzureCloudConfig": func() (string, error) {
+			cloudConfig, err := RenderAzureCloudConfig(config)
+			return string(cloudConfig), err
+		},
+		"GetBootstrapKubeconfig": func() (string, error) {
+			kubeconfig, err := RenderBootstrapKubeconfig(config)
+			return string(kubeconfig), err
+		},
+		"IsWorkloadIdentityEnabled": func() bool {
+			return config.WorkloadIdentityConfig != nil
+		},
+		"GetWorkloadIdentityIssuer": func() string {
+			if config.WorkloadIdentityConfig == nil {
+				return ""
+			}
+			return config.WorkloadIdentityConfig.Issuer
+		},
+		"GetWorkloadIdentityAudience": func() string {
+			if config.WorkloadIdentityConfig == nil {
+				return ""
+			}
+			return config.WorkloadIdentityConfig.Audience
+		},
+		"GetWorkloadIdentityTokenFilepath": func() string {
+			return workloadIdentityTokenFilepath
+		},
+		"GetContainerdSnapshotter": func() string {
+			return config.ContainerdSnapshotter
+		},
+		"IsStargzSnapshotterEnabled": func() bool {
+			return config.ContainerdSnapshotter == datamodel.ContainerdSnapshotterStargz
+		},
+		"ShouldConfigureKernelModules": func() bool {
+			return len(config.KernelModules) > 0
+		},
+		"GetKernelModulesLoadFilepath": func() string {
+			return kernelModulesLoadFilepath
+		},
+		"GetKernelModulesLoadContent": func() string {
+			return getKernelModulesLoadContent(config.KernelModules)
+		},
+		"GetKernelModulesModprobeCommand": func() string {
+			return "modprobe -a " + strings.Join(config.KernelModules, " ")
+		},
+		"ShouldConfigureContainerdOOMScore": func() bool {
+			return config.ContainerdOOMScore != nil
+		},
+		"GetContainerdOOMScoreDropinFilepath": func() string {
+			return containerdOOMScoreDropinFilepath
+		},
+		"HasContainerdStateDir": func() bool {
+			return config.ContainerdStateDir != ""
+		},
+		"GetContainerdStateDir": func() string {
+			return getContainerdStateDir(config)
+		},
+		"ShouldConfigureContainerdDirs": func() bool {
+			return config.ContainerdRootDir != "" || config.ContainerdStateDir != ""
+		},
+		"GetContainerdDirsDropinFilepath": func() string {
+			return containerdDirsDropinFilepath
+		},
+		"GetContainerdDirsDropinContent": func() string {
+			return getContainerdDirsDropinContent(config)
+		},
+		"GetContainerdOOMScoreDropinContent": func() string {
+			if config.ContainerdOOMScore == nil {
+				return ""
+			}
+			return getContainerdOOMScoreDropinContent(*config.ContainerdOOMScore)
+		},
+		"ShouldConfigureEnvironmentVariables": func() bool {
+			return len(config.EnvironmentVariables) > 0
+		},
+		"GetEtcEnvironmentFilepath": func() string {
+			return etcEnvironmentFilepath
+		},
+		"GetEnvironmentVariablesContent": func() string {
+			return getEnvironmentVariablesContent(config.EnvironmentVariables)
+		},
+		"GetContainerdDefaultRuntime": func(baseRuntime string) string {
+			return getContainerdDefaultRuntime(config, baseRuntime)
+		},
+		"ShouldWaitForControlPlaneReady": func() bool {
+			return config.WaitForControlPlaneReady
+		},
+		"GetControlPlaneReadyWaitCommand": func() string {
+			return getControlPlaneReadyWaitCommand(getKubernetesEndpoint(cs), config.ControlPlaneReadyTimeoutSeconds)
+		},
+		"GetKubeletStartCommand": func() string {
+			return getKubeletStartCommand(config.KubeletStartupRetries)
+		},
+		"ShouldDisableSystemdUnits": func() bool {
+			return len(config.DisableSystemdUnits) > 0
+		},
+		"GetDisableSystemdUnitsCommand": func() string {
+			return "systemctl mask --now " + strings.Join(config.DisableSystemdUnits, " ")
+		},
+		"IsTrustedLaunch": func() bool {
+			return config.TrustedLaunch != nil
+		},
+		"IsSecureBootEnabled": func() bool {
+			return config.IsSecureBootEnabled()
+		},
+		"IsVTPMEnabled": func() bool {
+			return config.TrustedLaunch != nil && config.TrustedLaunch.VTPM
+		},
+		"ShouldSetHostname": func() bool {
+			return config.HostnamePattern != ""
+		},
+		"GetSetHostnameCommand": func() string {
+			return getSetHostnameCommand(config)
+		},
+		"ShouldConfigureJournald": func() bool {
+			return config.JournaldConfig != nil
+		},
+		"GetJournaldConfigDropinFilepath": func() string {
+			return journaldConfigDropinFilepath
+		},
+		"GetJournaldConfigDropinContent": func() string {
+			if config.JournaldConfig == nil {
+				return ""
+			}
+			return getJournaldConfigDropinContent(config.JournaldConfig)
+		},
+		"GetVolumePluginDir": func() string {
+			return config.VolumePluginDir
+		},
+		"IsVerboseProvisioning": func() bool {
+			return config.VerboseProvisioning
+		},
+		"IsNodeProblemDetectorEnabled": func() bool {
+			return config.NodeProblemDetectorConfig != nil && config.NodeProblemDetectorConfig.Enabled
+		},
+		"GetNodeProblemDetectorServiceFilepath": func() string {
+			return npdSystemdServiceFilepath
+		},
+		"GetNodeProblemDetectorServiceContent": func() string {
+			if config.NodeProblemDetectorConfig == nil {
+				return ""
+			}
+			return getNodeProblemDetectorServiceContent(config.NodeProblemDetectorConfig, getKubernetesEndpoint(cs))
+		},
+		"HasNodeProblemDetectorCustomConfig": func() bool {
+			return config.NodeProblemDetectorConfig != nil && len(config.NodeProblemDetectorConfig.CustomConfig) > 0
+		},
+		"GetNodeProblemDetectorCustomConfigFilepath": func() string {
+			return npdCustomConfigFilepath
+		},
+		"GetNodeProblemDetectorCustomConfigContent": func() string {
+			if config.NodeProblemDetectorConfig == nil {
+				return ""
+			}
+			return string(config.NodeProblemDetectorConfig.CustomConfig)
+		},
+		"IsGracefulNodeShutdownEnabled": func() bool {
+			return isGracefulNodeShutdownEnabled(config)
+		},
+		"GetGracefulNodeShutdownDropinFilepath": func() string {
+			return gracefulNodeShutdownDropinFilepath
+		},
+		"GetGracefulNodeShutdownDropinContent": func() string {
+			return getGracefulNodeShutdownDropinContent(config)
+		},
+		"HasContainerdBaseRuntimeSpec": func() bool {
+			return len(config.ContainerdBaseRuntimeSpec) > 0
+		},
+		"GetContainerdBaseRuntimeSpecFilepath": func() string {
+			return containerdBaseRuntimeSpecFilepath
+		},
+		"GetContainerdBaseRuntimeSpecContent": func() string {
+			return string(config.ContainerdBaseRuntimeSpec)
+		},
+		"HasKubeletClientCACert": func() bool {
+			return len(config.KubeletClientCACert) > 0
+		},
+		"GetKubeletClientCACertFilepath": func() string {
+			return kubeletClientCACertFilepath
+		},
+		"GetKubeletClientCACertContent": func() string {
+			return getKubeletClientCABundle(config)
+		},
+		"GetManagedFiles": func() []datamodel.ManagedFile {
+			return getManagedFiles(config)
+		},
+		"HasCredentialProviders": func() bool {
+			return len(config.CredentialProviders) > 0
+		},
+		"GetCredentialProviderConfigFilepath": func() string {
+			return credentialProviderConfigFilepath
+		},
+		"GetCredentialProviderConfigContent": func() (string, error) {
+			return getCredentialProviderConfigContent(config.CredentialProviders)
+		},
+		"IsNvidiaRuntimeEnabled": func() bool {
+			return isNvidiaRuntimeEnabled(config)
+		},
+		"IsEphemeralOSDisk": func() bool {
+			return config.OSDiskType == datamodel.OSDiskTypeEphemeral
+		},
+		"GetPackageVersionOverrides": func() string {
+			return getPackageVersionOverridesContent(config.PackageVersionOverrides)
+		},
+		"GetKubeProxyMode": func() string {
+			return string(config.KubeProxyMode)
+		},
+		"HasContainerdMaxConcurrentDownloads": func() bool {
+			return config.ContainerdMaxConcurrentDownloads != nil
+		},
+		"GetContainerdMaxConcurrentDownloads": func() int {
+			if config.ContainerdMaxConcurrentDownloads == nil {
+				return 0
+			}
+			return *config.ContainerdMaxConcurrentDownloads
+		},
 	}
 }
 
@@ -1145,9 +3869,13 @@ const kubenetCniTemplate = `
 
 const containerdConfigTemplateString = `version = 2
 oom_score = 0{{if HasDataDir }}
-root = "{{GetDataDir}}"{{- end}}
+root = "{{GetDataDir}}"{{- end}}{{if HasContainerdStateDir }}
+state = "{{GetContainerdStateDir}}"{{- end}}
 [plugins."io.containerd.grpc.v1.cri"]
   sandbox_image = "{{GetPodInfraContainerSpec}}"
+  {{- if HasContainerdMaxConcurrentDownloads }}
+  max_concurrent_downloads = {{GetContainerdMaxConcurrentDownloads}}
+  {{- end}}
   [plugins."io.containerd.grpc.v1.cri".containerd]
     {{- if TeleportEnabled }}
     snapshotter = "teleportd"
@@ -1161,10 +3889,17 @@ root = "{{GetDataDir}}"{{- end}}
     snapshotter = "overlaybd"
     disable_snapshot_annotations = false
     {{- end}}
+    {{- if IsStargzSnapshotterEnabled }}
+    snapshotter = "stargz"
+    disable_snapshot_annotations = false
+    {{- end}}
     {{- if IsNSeriesSKU }}
-    default_runtime_name = "nvidia-container-runtime"
+    default_runtime_name = "{{GetContainerdDefaultRuntime "nvidia-container-runtime"}}"
     [plugins."io.containerd.grpc.v1.cri".containerd.runtimes.nvidia-container-runtime]
       runtime_type = "io.containerd.runc.v2"
+      {{- if HasContainerdBaseRuntimeSpec }}
+      base_runtime_spec = "{{GetContainerdBaseRuntimeSpecFilepath}}"
+      {{- end}}
     [plugins."io.containerd.grpc.v1.cri".containerd.runtimes.nvidia-container-runtime.options]
       BinaryName = "/usr/bin/nvidia-container-runtime"
       {{- if IsCgroupV2 }}
@@ -1175,9 +3910,12 @@ root = "{{GetDataDir}}"{{- end}}
     [plugins."io.containerd.grpc.v1.cri".containerd.runtimes.untrusted.options]
       BinaryName = "/usr/bin/nvidia-container-runtime"
     {{- else}}
-    default_runtime_name = "runc"
+    default_runtime_name = "{{GetContainerdDefaultRuntime "runc"}}"
     [plugins."io.containerd.grpc.v1.cri".containerd.runtimes.runc]
       runtime_type = "io.containerd.runc.v2"
+      {{- if HasContainerdBaseRuntimeSpec }}
+      base_runtime_spec = "{{GetContainerdBaseRuntimeSpecFilepath}}"
+      {{- end}}
     [plugins."io.containerd.grpc.v1.cri".containerd.runtimes.runc.options]
       BinaryName = "/usr/bin/runc"
       {{- if IsCgroupV2 }}
@@ -1188,6 +3926,18 @@ root = "{{GetDataDir}}"{{- end}}
     [plugins."io.containerd.grpc.v1.cri".containerd.runtimes.untrusted.options]
       BinaryName = "/usr/bin/runc"
     {{- end}}
+    {{- if IsNvidiaRuntimeEnabled }}
+    [plugins."io.containerd.grpc.v1.cri".containerd.runtimes.nvidia]
+      runtime_type = "io.containerd.runc.v2"
+      {{- if HasContainerdBaseRuntimeSpec }}
+      base_runtime_spec = "{{GetContainerdBaseRuntimeSpecFilepath}}"
+      {{- end}}
+    [plugins."io.containerd.grpc.v1.cri".containerd.runtimes.nvidia.options]
+      BinaryName = "/usr/bin/nvidia-container-runtime"
+      {{- if IsCgroupV2 }}
+      SystemdCgroup = true
+      {{- end}}
+    {{- end}}
     {{- if IsKrustlet }}
     [plugins."io.containerd.grpc.v1.cri".containerd.runtimes.spin]
       runtime_type = "io.containerd.spin-v0-3-0.v1"
@@ -1234,6 +3984,12 @@ root = "{{GetDataDir}}"{{- end}}
     type = "snapshot"
     address = "/run/overlaybd-snapshotter/overlaybd.sock"
 {{- end}}
+{{- if IsStargzSnapshotterEnabled }}
+[proxy_plugins]
+  [proxy_plugins.stargz]
+    type = "snapshot"
+    address = "/run/containerd-stargz-grpc/containerd-stargz-grpc.sock"
+{{- end}}
 {{- if IsKata }}
 [plugins."io.containerd.grpc.v1.cri".containerd.runtimes.kata]
   runtime_type = "io.containerd.kata.v2"
@@ -1272,9 +4028,13 @@ root = "{{GetDataDir}}"{{- end}}
 // they're identical except for GPU runtime class.
 const containerdConfigNoGpuTemplateString = `version = 2
 oom_score = 0{{if HasDataDir }}
-root = "{{GetDataDir}}"{{- end}}
+root = "{{GetDataDir}}"{{- end}}{{if HasContainerdStateDir }}
+state = "{{GetContainerdStateDir}}"{{- end}}
 [plugins."io.containerd.grpc.v1.cri"]
   sandbox_image = "{{GetPodInfraContainerSpec}}"
+  {{- if HasContainerdMaxConcurrentDownloads }}
+  max_concurrent_downloads = {{GetContainerdMaxConcurrentDownloads}}
+  {{- end}}
   [plugins."io.containerd.grpc.v1.cri".containerd]
     {{- if TeleportEnabled }}
     snapshotter = "teleportd"
@@ -1288,9 +4048,16 @@ root = "{{GetDataDir}}"{{- end}}
     snapshotter = "overlaybd"
     disable_snapshot_annotations = false
     {{- end}}
-    default_runtime_name = "runc"
+    {{- if IsStargzSnapshotterEnabled }}
+    snapshotter = "stargz"
+    disable_snapshot_annotations = false
+    {{- end}}
+    default_runtime_name = "{{GetContainerdDefaultRuntime "runc"}}"
     [plugins."io.containerd.grpc.v1.cri".containerd.runtimes.runc]
       runtime_type = "io.containerd.runc.v2"
+      {{- if HasContainerdBaseRuntimeSpec }}
+      base_runtime_spec = "{{GetContainerdBaseRuntimeSpecFilepath}}"
+      {{- end}}
     [plugins."io.containerd.grpc.v1.cri".containerd.runtimes.runc.options]
       BinaryName = "/usr/bin/runc"
       {{- if IsCgroupV2 }}
@@ -1300,6 +4067,18 @@ root = "{{GetDataDir}}"{{- end}}
       runtime_type = "io.containerd.runc.v2"
     [plugins."io.containerd.grpc.v1.cri".containerd.runtimes.untrusted.options]
       BinaryName = "/usr/bin/runc"
+    {{- if IsNvidiaRuntimeEnabled }}
+    [plugins."io.containerd.grpc.v1.cri".containerd.runtimes.nvidia]
+      runtime_type = "io.containerd.runc.v2"
+      {{- if HasContainerdBaseRuntimeSpec }}
+      base_runtime_spec = "{{GetContainerdBaseRuntimeSpecFilepath}}"
+      {{- end}}
+    [plugins."io.containerd.grpc.v1.cri".containerd.runtimes.nvidia.options]
+      BinaryName = "/usr/bin/nvidia-container-runtime"
+      {{- if IsCgroupV2 }}
+      SystemdCgroup = true
+      {{- end}}
+    {{- end}}
     {{- if IsKrustlet }}
     [plugins."io.containerd.grpc.v1.cri".containerd.runtimes.spin]
       runtime_type = "io.containerd.spin-v0-3-0.v1"
@@ -1346,6 +4125,12 @@ root = "{{GetDataDir}}"{{- end}}
     type = "snapshot"
     address = "/run/overlaybd-snapshotter/overlaybd.sock"
 {{- end}}
+{{- if IsStargzSnapshotterEnabled }}
+[proxy_plugins]
+  [proxy_plugins.stargz]
+    type = "snapshot"
+    address = "/run/containerd-stargz-grpc/containerd-stargz-grpc.sock"
+{{- end}}
 {{- if IsKata }}
 [plugins."io.containerd.grpc.v1.cri".containerd.runtimes.kata]
   runtime_type = "io.containerd.kata.v2"
@@ -1375,18 +4160,81 @@ root = "{{GetDataDir}}"{{- end}}
 {{- end}}
 `
 
+// containerdConfigTemplates holds the containerd config templates by version, with and without GPU support.
+//
+//nolint:gochecknoglobals
+var containerdConfigTemplates = map[string]struct {
+	gpu   string
+	noGPU string
+}{
+	containerdConfigTemplateVersionV1: {
+		gpu:   containerdConfigTemplateString,
+		noGPU: containerdConfigNoGpuTemplateString,
+	},
+}
+
+// getContainerdConfigTemplate returns the containerd config template of the specified version,
+// the default version is used when version is empty.
+func getContainerdConfigTemplate(version string, gpu bool) (string, error) {
+	if version == "" {
+		version = defaultContainerdConfigTemplateVersion
+	}
+	templates, ok := containerdConfigTemplates[version]
+	if !ok {
+		return "", fmt.Errorf("unknown containerd config template version %q", version)
+	}
+	if gpu {
+		return templates.gpu, nil
+	}
+	return templates.noGPU, nil
+}
+
 func containerdConfigFromTemplate(
 	config *datamodel.NodeBootstrappingConfiguration,
 	profile *datamodel.AgentPoolProfile,
 	tmpl string,
+) (string, error) {
+	output, err := renderContainerdConfigTemplate(config, profile, tmpl)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString([]byte(output)), nil
+}
+
+func renderContainerdConfigTemplate(
+	config *datamodel.NodeBootstrappingConfiguration,
+	profile *datamodel.AgentPoolProfile,
+	tmpl string,
 ) (string, error) {
 	parameters := getParameters(config)
 	variables := getCustomDataVariables(config)
 	bakerFuncMap := getBakerFuncMap(config, parameters, variables)
-	containerdConfigTemplate := template.Must(template.New("kubenet").Funcs(bakerFuncMap).Parse(tmpl))
+	containerdConfigTemplate, err := template.New("containerdconfig").Funcs(bakerFuncMap).Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse containerd config template: %w", err)
+	}
 	var b bytes.Buffer
-	if err := containerdConfigTemplate.Execute(&b, profile); err != nil {
-		return "", fmt.Errorf("failed to execute sysctl template: %w", err)
+	if err = containerdConfigTemplate.Execute(&b, profile); err != nil {
+		return "", fmt.Errorf("failed to execute containerd config template: %w", err)
+	}
+	return b.String(), nil
+}
+
+// RenderContainerdConfig returns the containerd config.toml AgentBaker generates for the node,
+// without rendering the rest of the bootstrap payload.
+func RenderContainerdConfig(config *datamodel.NodeBootstrappingConfiguration) (string, error) {
+	if config == nil || config.AgentPoolProfile == nil {
+		return "", fmt.Errorf("agent pool profile is required to render the containerd config")
+	}
+	if config.AgentPoolProfile.IsWindows() {
+		return "", fmt.Errorf("containerd config rendering is not supported for Windows nodes")
+	}
+	if err := validateAndSetLinuxNodeBootstrappingConfiguration(config); err != nil {
+		return "", err
+	}
+	tmpl, err := getContainerdConfigTemplate(config.ContainerdConfigTemplateVersion, config.EnableNvidia)
+	if err != nil {
+		return "", err
 	}
-	return base64.StdEncoding.EncodeToString(b.Bytes()), nil
+	return renderContainerdConfigTemplate(config, config.AgentPoolProfile, tmpl)
 }
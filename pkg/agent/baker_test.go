@@ -4,19 +4,27 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/base64"
 	"encoding/json"
 	"encoding/pem"
 	"errors"
 	"fmt"
 	"io"
+	"math/big"
 	"os"
 	"os/exec"
 	"regexp"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/Azure/agentbaker/pkg/agent/datamodel"
+	"github.com/Azure/agentbaker/pkg/agent/vhd/cache"
 	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/barkimedes/go-deepcopy"
 	. "github.com/onsi/ginkgo"
@@ -36,7 +44,24 @@ func generateTestData() bool {
 - KEY="VALUE WITH WHITSPACE". */
 const cseRegexString = `([^=\s]+)=(\"[^\"]*\"|[^\s]*)`
 
+// testSSHPublicKey is the authorized SSH public key of the test nodes.
+const testSSHPublicKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIB+hjmlQ6cmdGOuGL3hWX5jWxj8L3fl5ES+lD5PxYYoG"
+
 // test certificate.
+// testClusterCACert is a static self-signed cluster CA certificate, so that the generated custom data is stable.
+const testClusterCACert = `-----BEGIN CERTIFICATE-----
+MIIBlzCCAT2gAwIBAgIUK2Opf+YjS/IcMhXrtsKxhe+8+tEwCgYIKoZIzj0EAwIw
+GDEWMBQGA1UEAwwNa3ViZXJuZXRlcy1jYTAgFw0yNjEwMTUxMDEzMjVaGA8yMTI2
+MDkyMTEwMTMyNVowGDEWMBQGA1UEAwwNa3ViZXJuZXRlcy1jYTBZMBMGByqGSM49
+AgEGCCqGSM49AwEHA0IABElt1I8PCX6+xNvMhI1Y09abUEV/2wSZwSuwdsT3pgvh
+aken76+re0PTA0zhstwdO0haheZG0u7rtKURwqV8uM6jYzBhMB0GA1UdDgQWBBS2
+X14OBKWsmq+5Z9K0ZmDo3CPKwDAfBgNVHSMEGDAWgBS2X14OBKWsmq+5Z9K0ZmDo
+3CPKwDAPBgNVHRMBAf8EBTADAQH/MA4GA1UdDwEB/wQEAwIBBjAKBggqhkjOPQQD
+AgNIADBFAiAbkaopl7WKgw6fxK0oeRnPFULddbvO2gNwY7dPmDOZZgIhAKyIX2Xu
+FEh4jRdubz38gcc4L9MT9mlZ53DiT50f/t1S
+-----END CERTIFICATE-----
+`
+
 const encodedTestCert = "LS0tLS1CRUdJTiBDRVJUSUZJQ0FURS0tLS0tCk1JSUgvVENDQmVXZ0F3SUJBZ0lRYUJZRTMvTTA4WEhZQ25OVm1jRkJjakFOQmdrcWhraUc5dzBCQVFzRkFEQnkKTVFzd0NRWURWUVFHRXdKVlV6RU9NQXdHQTFVRUNBd0ZWR1Y0WVhNeEVEQU9CZ05WQkFjTUIwaHZkWE4wYjI0eApFVEFQQmdOVkJBb01DRk5UVENCRGIzSndNUzR3TEFZRFZRUUREQ1ZUVTB3dVkyOXRJRVZXSUZOVFRDQkpiblJsCmNtMWxaR2xoZEdVZ1EwRWdVbE5CSUZJek1CNFhEVEl3TURRd01UQXdOVGd6TTFvWERUSXhNRGN4TmpBd05UZ3oKTTFvd2diMHhDekFKQmdOVkJBWVRBbFZUTVE0d0RBWURWUVFJREFWVVpYaGhjekVRTUE0R0ExVUVCd3dIU0c5MQpjM1J2YmpFUk1BOEdBMVVFQ2d3SVUxTk1JRU52Y25BeEZqQVVCZ05WQkFVVERVNVdNakF3T0RFMk1UUXlORE14CkZEQVNCZ05WQkFNTUMzZDNkeTV6YzJ3dVkyOXRNUjB3R3dZRFZRUVBEQlJRY21sMllYUmxJRTl5WjJGdWFYcGgKZEdsdmJqRVhNQlVHQ3lzR0FRUUJnamM4QWdFQ0RBWk9aWFpoWkdFeEV6QVJCZ3NyQmdFRUFZSTNQQUlCQXhNQwpWVk13Z2dFaU1BMEdDU3FHU0liM0RRRUJBUVVBQTRJQkR3QXdnZ0VLQW9JQkFRREhoZVJrYmIxRkNjN3hSS3N0CndLMEpJR2FLWTh0N0piUzJiUTJiNllJSkRnbkh1SVlIcUJyQ1VWNzlvZWxpa2tva1JrRnZjdnBhS2luRkhEUUgKVXBXRUk2UlVFUlltU0NnM084V2k0MnVPY1YyQjVaYWJtWENrd2R4WTVFY2w1MUJiTThVbkdkb0FHYmRObWlSbQpTbVRqY3MrbGhNeGc0ZkZZNmxCcGlFVkZpR1VqR1JSKzYxUjY3THo2VTRLSmVMTmNDbTA3UXdGWUtCbXBpMDhnCmR5Z1N2UmRVdzU1Sm9wcmVkaitWR3RqVWtCNGhGVDRHUVgvZ2h0NjlSbHF6Lys4dTBkRVFraHVVdXVjcnFhbG0KU0d5NDNIUndCZkRLRndZZVdNN0NQTWQ1ZS9kTyt0MDh0OFBianpWVFR2NWhRRENzRVlJVjJUN0FGSTlTY054TQpraDcvQWdNQkFBR2pnZ05CTUlJRFBUQWZCZ05WSFNNRUdEQVdnQlMvd1ZxSC95ajZRVDM5dDAva0hhK2dZVmdwCnZUQi9CZ2dyQmdFRkJRY0JBUVJ6TUhFd1RRWUlLd1lCQlFVSE1BS0dRV2gwZEhBNkx5OTNkM2N1YzNOc0xtTnYKYlM5eVpYQnZjMmwwYjNKNUwxTlRUR052YlMxVGRXSkRRUzFGVmkxVFUwd3RVbE5CTFRRd09UWXRVak11WTNKMApNQ0FHQ0NzR0FRVUZCekFCaGhSb2RIUndPaTh2YjJOemNITXVjM05zTG1OdmJUQWZCZ05WSFJFRUdEQVdnZ3QzCmQzY3VjM05zTG1OdmJZSUhjM05zTG1OdmJUQmZCZ05WSFNBRVdEQldNQWNHQldlQkRBRUJNQTBHQ3lxRWFBR0cKOW5jQ0JRRUJNRHdHRENzR0FRUUJncWt3QVFNQkJEQXNNQ29HQ0NzR0FRVUZCd0lCRmg1b2RIUndjem92TDNkMwpkeTV6YzJ3dVkyOXRMM0psY0c5emFYUnZjbmt3SFFZRFZSMGxCQll3RkFZSUt3WUJCUVVIQXdJR0NDc0dBUVVGCkJ3TUJNRWdHQTFVZEh3UkJNRDh3UGFBN29EbUdOMmgwZEhBNkx5OWpjbXh6TG5OemJDNWpiMjB2VTFOTVkyOXQKTFZOMVlrTkJMVVZXTFZOVFRDMVNVMEV0TkRBNU5pMVNNeTVqY213d0hRWURWUjBPQkJZRUZBREFGVUlhenc1cgpaSUhhcG5SeElVbnB3K0dMTUE0R0ExVWREd0VCL3dRRUF3SUZvRENDQVgwR0Npc0dBUVFCMW5rQ0JBSUVnZ0Z0CkJJSUJhUUZuQUhjQTlseVVMOUYzTUNJVVZCZ0lNSlJXanVOTkV4a3p2OThNTHlBTHpFN3haT01BQUFGeE0waG8KYndBQUJBTUFTREJHQWlFQTZ4ZWxpTlI4R2svNjNwWWRuUy92T3gvQ2pwdEVNRXY4OVdXaDEvdXJXSUVDSVFEeQpCcmVIVTI1RHp3dWtRYVJRandXNjU1WkxrcUNueGJ4UVdSaU9lbWo5SkFCMUFKUWd2QjZPMVkxc2lITWZnb3NpCkxBM1IyazFlYkUrVVBXSGJUaTlZVGFMQ0FBQUJjVE5JYU53QUFBUURBRVl3UkFJZ0dSRTR3emFiTlJkRDhrcS8KdkZQM3RRZTJobTB4NW5YdWxvd2g0SWJ3M2xrQ0lGWWIvM2xTRHBsUzdBY1I0citYcFd0RUtTVEZXSm1OQ1JiYwpYSnVyMlJHQkFIVUE3c0NWN28xeVpBK1M0OE81RzhjU28ybHFDWHRMYWhvVU9PWkhzc3Z0eGZrQUFBRnhNMGhvCjh3QUFCQU1BUmpCRUFpQjZJdmJvV3NzM1I0SXRWd2plYmw3RDN5b0ZhWDBORGgyZFdoaGd3Q3hySHdJZ0NmcTcKb2NNQzV0KzFqaTVNNXhhTG1QQzRJK1dYM0kvQVJrV1N5aU83SVFjd0RRWUpLb1pJaHZjTkFRRUxCUUFEZ2dJQgpBQ2V1dXI0UW51anFtZ3VTckhVM21oZitjSm9kelRRTnFvNHRkZStQRDEvZUZkWUFFTHU4eEYrMEF0N3hKaVBZCmk1Ukt3aWx5UDU2diszaVkyVDlsdzdTOFRKMDQxVkxoYUlLcDE0TXpTVXpSeWVvT0FzSjdRQURNQ2xIS1VEbEgKVVUycE51bzg4WTZpZ292VDNic253Sk5pRVFOcXltU1NZaGt0dzB0YWR1b3FqcVhuMDZnc1Zpb1dUVkRYeXNkNQpxRXg0dDZzSWdJY01tMjZZSDF2SnBDUUVoS3BjMnkwN2dSa2tsQlpSdE1qVGh2NGNYeXlNWDd1VGNkVDdBSkJQCnVlaWZDb1YyNUp4WHVvOGQ1MTM5Z3dQMUJBZTdJQlZQeDJ1N0tOL1V5T1hkWm13TWYvVG1GR3dEZENmc3lIZi8KWnNCMndMSG96VFlvQVZtUTlGb1UxSkxnY1ZpdnFKK3ZObEJoSFhobHhNZE4wajgwUjlOejZFSWdsUWplSzNPOApJL2NGR20vQjgrNDJoT2xDSWQ5WmR0bmRKY1JKVmppMHdEMHF3ZXZDYWZBOWpKbEh2L2pzRStJOVV6NmNwQ3loCnN3K2xyRmR4VWdxVTU4YXhxZUs4OUZSK05vNHEwSUlPK0ppMXJKS3I5bmtTQjBCcVhvelZuRTFZQi9LTHZkSXMKdVlaSnVxYjJwS2t1K3p6VDZnVXdIVVRadkJpTk90WEw0Tnh3Yy9LVDdXek9TZDJ3UDEwUUk4REtnNHZmaU5EcwpIV21CMWM0S2ppNmdPZ0E1dVNVemFHbXEvdjRWbmNLNVVyK245TGJmbmZMYzI4SjVmdC9Hb3Rpbk15RGszaWFyCkYxMFlscWNPbWVYMXVGbUtiZGkvWG9yR2xrQ29NRjNURHg4cm1wOURCaUIvCi0tLS0tRU5EIENFUlRJRklDQVRFLS0tLS0=" //nolint:lll
 
 type nodeBootstrappingOutput struct {
@@ -96,7 +121,7 @@ var _ = Describe("Assert generated customData and cseCmd", func() {
 			},
 		}
 		cs.Properties.LinuxProfile.SSH.PublicKeys = []datamodel.PublicKey{{
-			KeyData: string("testsshkey"),
+			KeyData: testSSHPublicKey,
 		}}
 
 		// AKS always pass in te customHyperKubeImage to aks-e, so we don't really rely on
@@ -168,10 +193,10 @@ var _ = Describe("Assert generated customData and cseCmd", func() {
 			CloudSpecConfig:               datamodel.AzurePublicCloudSpecForTest,
 			K8sComponents:                 k8sComponents,
 			AgentPoolProfile:              agentPool,
-			TenantID:                      "tenantID",
-			SubscriptionID:                "subID",
+			TenantID:                      "72f988bf-86f1-41af-91ab-2d7cd011db47",
+			SubscriptionID:                "8ecadfc9-d1a3-4ea4-b844-0d9f87e4d7c8",
 			ResourceGroupName:             "resourceGroupName",
-			UserAssignedIdentityClientID:  "userAssignedID",
+			UserAssignedIdentityClientID:  "f5c1b1b3-4a7e-4a1b-9c1e-6d2f8d3e9a10",
 			ConfigGPUDriverIfNeeded:       true,
 			EnableGPUDevicePluginIfNeeded: false,
 			EnableKubeletConfigFile:       false,
@@ -663,7 +688,7 @@ var _ = Describe("Assert generated customData and cseCmd", func() {
 			func(config *datamodel.NodeBootstrappingConfiguration) {
 				config.KubeletClientTLSBootstrapToken = to.StringPtr("07401b.f395accd246ae52d")
 				config.ContainerService.Properties.CertificateProfile = &datamodel.CertificateProfile{
-					CaCertificate: "fooBarBaz",
+					CaCertificate: testClusterCACert,
 				}
 			}, func(o *nodeBootstrappingOutput) {
 				// Please see #2815 for more details
@@ -742,6 +767,7 @@ var _ = Describe("Assert generated customData and cseCmd", func() {
 
 		Entry("Mariner v2 with custom cloud", "MarinerV2+CustomCloud", "1.23.8", func(config *datamodel.NodeBootstrappingConfiguration) {
 			config.OSSKU = "Mariner"
+			config.ContainerService.Properties.AgentPoolProfiles[0].Distro = datamodel.AKSCBLMarinerV2
 			config.ContainerService.Properties.AgentPoolProfiles[0].KubernetesConfig = &datamodel.KubernetesConfig{
 				ContainerRuntime: datamodel.Containerd,
 			}
@@ -929,7 +955,7 @@ var _ = Describe("Assert generated customData and cseCmd", func() {
 				ContainerRuntime: datamodel.Containerd,
 			}
 			config.ContainerService.Properties.CertificateProfile = &datamodel.CertificateProfile{
-				CaCertificate: "fooBarBaz",
+				CaCertificate: testClusterCACert,
 			}
 			config.KubeletClientTLSBootstrapToken = to.StringPtr("07401b.f395accd246ae52d")
 		},
@@ -1420,7 +1446,7 @@ var _ = Describe("Assert generated customData and cseCmd for Windows", func() {
 			},
 		}
 		cs.Properties.LinuxProfile.SSH.PublicKeys = []datamodel.PublicKey{{
-			KeyData: string("testsshkey"),
+			KeyData: testSSHPublicKey,
 		}}
 
 		// AKS always pass in te customHyperKubeImage to aks-e, so we don't really rely on
@@ -1498,10 +1524,10 @@ var _ = Describe("Assert generated customData and cseCmd for Windows", func() {
 			CloudSpecConfig:               datamodel.AzurePublicCloudSpecForTest,
 			K8sComponents:                 k8sComponents,
 			AgentPoolProfile:              agentPool,
-			TenantID:                      "tenantID",
-			SubscriptionID:                "subID",
+			TenantID:                      "72f988bf-86f1-41af-91ab-2d7cd011db47",
+			SubscriptionID:                "8ecadfc9-d1a3-4ea4-b844-0d9f87e4d7c8",
 			ResourceGroupName:             "resourceGroupName",
-			UserAssignedIdentityClientID:  "userAssignedID",
+			UserAssignedIdentityClientID:  "f5c1b1b3-4a7e-4a1b-9c1e-6d2f8d3e9a10",
 			ConfigGPUDriverIfNeeded:       true,
 			EnableGPUDevicePluginIfNeeded: false,
 			EnableKubeletConfigFile:       false,
@@ -1872,3 +1898,2621 @@ var _ = Describe("Test normalizeResourceGroupNameForLabel", func() {
 		Expect(normalizeResourceGroupNameForLabel(s + "-")).To(Equal(s + "-z"))
 	})
 })
+
+var _ = Describe("Test validateAndSetContainerLogConfig", func() {
+	var config *datamodel.NodeBootstrappingConfiguration
+
+	BeforeEach(func() {
+		config = &datamodel.NodeBootstrappingConfiguration{
+			KubeletConfig: map[string]string{},
+		}
+	})
+
+	It("should not touch kubelet flags when ContainerLogConfig is not set", func() {
+		Expect(validateAndSetContainerLogConfig(config)).To(Succeed())
+		Expect(config.KubeletConfig).NotTo(HaveKey("--container-log-max-size"))
+		Expect(config.KubeletConfig).NotTo(HaveKey("--container-log-max-files"))
+	})
+
+	It("should set defaults for unset values", func() {
+		config.ContainerLogConfig = &datamodel.ContainerLogConfig{}
+		Expect(validateAndSetContainerLogConfig(config)).To(Succeed())
+		Expect(config.ContainerLogConfig.MaxSizeMB).To(Equal(datamodel.DefaultContainerLogMaxSizeMB))
+		Expect(config.ContainerLogConfig.MaxFiles).To(Equal(datamodel.DefaultContainerLogMaxFiles))
+		Expect(config.KubeletConfig["--container-log-max-size"]).To(Equal("10Mi"))
+		Expect(config.KubeletConfig["--container-log-max-files"]).To(Equal("5"))
+	})
+
+	It("should render configured values into kubelet flags", func() {
+		config.KubeletConfig = nil
+		config.ContainerLogConfig = &datamodel.ContainerLogConfig{MaxSizeMB: 50, MaxFiles: 3}
+		Expect(validateAndSetContainerLogConfig(config)).To(Succeed())
+		Expect(config.KubeletConfig["--container-log-max-size"]).To(Equal("50Mi"))
+		Expect(config.KubeletConfig["--container-log-max-files"]).To(Equal("3"))
+	})
+
+	It("should return an error for negative values", func() {
+		config.ContainerLogConfig = &datamodel.ContainerLogConfig{MaxSizeMB: -1}
+		Expect(validateAndSetContainerLogConfig(config)).NotTo(Succeed())
+		config.ContainerLogConfig = &datamodel.ContainerLogConfig{MaxFiles: -1}
+		Expect(validateAndSetContainerLogConfig(config)).NotTo(Succeed())
+	})
+
+	It("should return an error when max files is less than 2", func() {
+		config.ContainerLogConfig = &datamodel.ContainerLogConfig{MaxFiles: 1}
+		Expect(validateAndSetContainerLogConfig(config)).NotTo(Succeed())
+	})
+})
+
+var _ = Describe("Test validateAndSetNTPServers", func() {
+	It("should default to the Azure time server when no NTP servers are set", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{}
+		Expect(validateAndSetNTPServers(config)).To(Succeed())
+		Expect(config.NTPServers).To(Equal([]string{datamodel.DefaultNTPServer}))
+	})
+
+	It("should de-duplicate NTP servers while keeping their order", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			NTPServers: []string{"ntp1.contoso.com", "10.0.0.4", "NTP1.contoso.com", " 10.0.0.4 "},
+		}
+		Expect(validateAndSetNTPServers(config)).To(Succeed())
+		Expect(config.NTPServers).To(Equal([]string{"ntp1.contoso.com", "10.0.0.4"}))
+	})
+
+	It("should return an error for an invalid NTP server", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			NTPServers: []string{"ntp1.contoso.com", "not a hostname"},
+		}
+		Expect(validateAndSetNTPServers(config)).NotTo(Succeed())
+	})
+})
+
+var _ = Describe("Test validateAndSetKubeletTLSCipherSuites", func() {
+	It("should keep the kubelet flags as is when no cipher suites are set", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			KubeletConfig: map[string]string{"--tls-cipher-suites": "TLS_AES_128_GCM_SHA256"},
+		}
+		Expect(validateAndSetKubeletTLSCipherSuites(config)).To(Succeed())
+		Expect(config.KubeletConfig["--tls-cipher-suites"]).To(Equal("TLS_AES_128_GCM_SHA256"))
+	})
+
+	It("should render secure cipher suites into the kubelet flags", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			KubeletTLSCipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256", "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"},
+		}
+		Expect(validateAndSetKubeletTLSCipherSuites(config)).To(Succeed())
+		Expect(config.KubeletConfig["--tls-cipher-suites"]).To(Equal("TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"))
+	})
+
+	It("should reject unknown cipher suites", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			KubeletTLSCipherSuites:              []string{"TLS_NOT_A_CIPHER_SUITE"},
+			AllowInsecureKubeletTLSCipherSuites: true,
+		}
+		Expect(validateAndSetKubeletTLSCipherSuites(config)).NotTo(Succeed())
+	})
+
+	It("should reject insecure cipher suites unless they are explicitly allowed", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			KubeletTLSCipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"},
+		}
+		Expect(validateAndSetKubeletTLSCipherSuites(config)).NotTo(Succeed())
+
+		config.AllowInsecureKubeletTLSCipherSuites = true
+		Expect(validateAndSetKubeletTLSCipherSuites(config)).To(Succeed())
+		Expect(config.KubeletConfig["--tls-cipher-suites"]).To(Equal("TLS_RSA_WITH_RC4_128_SHA"))
+	})
+})
+
+var _ = Describe("Test getContainerdConfigTemplate", func() {
+	It("should return the default templates when no version is set", func() {
+		tmpl, err := getContainerdConfigTemplate("", true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tmpl).To(Equal(containerdConfigTemplateString))
+
+		tmpl, err = getContainerdConfigTemplate("", false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tmpl).To(Equal(containerdConfigNoGpuTemplateString))
+	})
+
+	It("should return the templates of the specified version", func() {
+		tmpl, err := getContainerdConfigTemplate(containerdConfigTemplateVersionV1, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tmpl).To(Equal(containerdConfigNoGpuTemplateString))
+	})
+
+	It("should return an error for an unknown version", func() {
+		_, err := getContainerdConfigTemplate("v0", false)
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("Test validateArcConfig", func() {
+	var config *datamodel.NodeBootstrappingConfiguration
+
+	BeforeEach(func() {
+		config = &datamodel.NodeBootstrappingConfiguration{
+			ContainerService: &datamodel.ContainerService{
+				Location: "westus2",
+				Properties: &datamodel.Properties{
+					ServicePrincipalProfile: &datamodel.ServicePrincipalProfile{ClientID: "ClientID", Secret: "Sec'ret"},
+				},
+			},
+			SubscriptionID: "subID",
+			ArcConfig: &datamodel.ArcConfig{
+				TenantID:      "72f988bf-86f1-41af-91ab-2d7cd011db47",
+				ResourceGroup: "resourceGroup",
+				ClusterName:   "clusterName",
+			},
+		}
+	})
+
+	It("should succeed when Arc is not enabled", func() {
+		config.ArcConfig = nil
+		Expect(validateArcConfig(config)).To(Succeed())
+	})
+
+	It("should succeed when all Arc fields are set", func() {
+		Expect(validateArcConfig(config)).To(Succeed())
+	})
+
+	It("should return an error when a required Arc field is missing", func() {
+		config.ArcConfig.TenantID = ""
+		Expect(validateArcConfig(config)).NotTo(Succeed())
+		config.ArcConfig.TenantID = "72f988bf-86f1-41af-91ab-2d7cd011db47"
+		config.ArcConfig.ResourceGroup = ""
+		Expect(validateArcConfig(config)).NotTo(Succeed())
+		config.ArcConfig.ResourceGroup = "resourceGroup"
+		config.ArcConfig.ClusterName = ""
+		Expect(validateArcConfig(config)).NotTo(Succeed())
+	})
+
+	It("should return an error when combined with TLS bootstrapping", func() {
+		config.KubeletClientTLSBootstrapToken = to.StringPtr("07401b.f395accd246ae52d")
+		Expect(validateArcConfig(config)).NotTo(Succeed())
+		config.KubeletClientTLSBootstrapToken = nil
+		config.EnableSecureTLSBootstrapping = true
+		Expect(validateArcConfig(config)).NotTo(Succeed())
+	})
+
+	It("should return an error without the service principal, subscription or location", func() {
+		config.ContainerService.Properties.ServicePrincipalProfile = nil
+		Expect(validateArcConfig(config)).To(MatchError("arc-connected nodes require the service principal of the cluster"))
+		config.ContainerService.Location = ""
+		Expect(validateArcConfig(config)).To(MatchError("arc-connected nodes require the subscription ID and location"))
+	})
+
+	It("should return an error on Windows nodes", func() {
+		config.AgentPoolProfile = &datamodel.AgentPoolProfile{OSType: datamodel.Windows}
+		Expect(validateArcConfig(config)).To(MatchError("arc-connected Windows nodes are not supported"))
+	})
+
+	It("should install and connect the Arc agent instead of the kubelet bootstrap", func() {
+		config.AgentPoolProfile = &datamodel.AgentPoolProfile{OSType: datamodel.Linux}
+		Expect(InitializeTemplateGenerator().getNodeBootstrappingCmd(config)).To(Equal(
+			"curl -fsSL -o /tmp/install_linux_azcmagent.sh https://gbl.his.arc.azure.com/azcmagent-linux && " +
+				"bash /tmp/install_linux_azcmagent.sh && azcmagent connect --service-principal-id 'ClientID' " +
+				`--service-principal-secret 'Sec'\''ret' --tenant-id '72f988bf-86f1-41af-91ab-2d7cd011db47' ` +
+				"--subscription-id 'subID' --resource-group 'resourceGroup' --location 'westus2' --tags 'ConnectedCluster=clusterName'"))
+	})
+})
+
+var _ = Describe("Test validateAndSetImageGCThresholds", func() {
+	It("should render the thresholds into the kubelet flags", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			ImageGCHighThresholdPercent: to.IntPtr(90),
+			ImageGCLowThresholdPercent:  to.IntPtr(70),
+		}
+		Expect(validateAndSetImageGCThresholds(config)).To(Succeed())
+		Expect(config.KubeletConfig["--image-gc-high-threshold"]).To(Equal("90"))
+		Expect(config.KubeletConfig["--image-gc-low-threshold"]).To(Equal("70"))
+	})
+
+	It("should validate a single threshold against the existing kubelet flags", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			KubeletConfig: map[string]string{
+				"--image-gc-high-threshold": "85",
+				"--image-gc-low-threshold":  "80",
+			},
+			ImageGCLowThresholdPercent: to.IntPtr(85),
+		}
+		Expect(validateAndSetImageGCThresholds(config)).NotTo(Succeed())
+	})
+
+	It("should return an error for thresholds out of range", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			ImageGCHighThresholdPercent: to.IntPtr(101),
+		}
+		Expect(validateAndSetImageGCThresholds(config)).NotTo(Succeed())
+
+		config = &datamodel.NodeBootstrappingConfiguration{
+			ImageGCLowThresholdPercent: to.IntPtr(-1),
+		}
+		Expect(validateAndSetImageGCThresholds(config)).NotTo(Succeed())
+	})
+
+	It("should return an error when the high threshold is not greater than the low threshold", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			ImageGCHighThresholdPercent: to.IntPtr(70),
+			ImageGCLowThresholdPercent:  to.IntPtr(70),
+		}
+		Expect(validateAndSetImageGCThresholds(config)).NotTo(Succeed())
+	})
+})
+
+var _ = Describe("Test validateProvisionCompleteMarker", func() {
+	It("should succeed when the marker is not set", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{}
+		Expect(validateProvisionCompleteMarker(config)).To(Succeed())
+	})
+
+	It("should succeed for a single line marker", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{ProvisionCompleteMarker: "provisioning completed"}
+		Expect(validateProvisionCompleteMarker(config)).To(Succeed())
+	})
+
+	It("should return an error for a blank marker", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{ProvisionCompleteMarker: "  "}
+		Expect(validateProvisionCompleteMarker(config)).NotTo(Succeed())
+	})
+
+	It("should return an error for a multi-line marker", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{ProvisionCompleteMarker: "done\nrm -rf /"}
+		Expect(validateProvisionCompleteMarker(config)).NotTo(Succeed())
+	})
+
+	It("should return an error for a marker that is too long", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{ProvisionCompleteMarker: strings.Repeat("a", maxProvisionCompleteMarkerLength+1)}
+		Expect(validateProvisionCompleteMarker(config)).NotTo(Succeed())
+	})
+})
+
+var _ = Describe("Test validatePrePullImages", func() {
+	It("should succeed for valid images with and without auth", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			PrePullImages: []datamodel.PrePullImage{
+				{Image: "mcr.microsoft.com/oss/kubernetes/pause:3.6"},
+				{
+					Image: "myregistry.azurecr.io/app:v1",
+					Auth:  base64.StdEncoding.EncodeToString([]byte(`{"auths":{"myregistry.azurecr.io":{"auth":"dXNlcjpwYXNz"}}}`)),
+				},
+			},
+		}
+		Expect(validatePrePullImages(config)).To(Succeed())
+	})
+
+	It("should return an error for an invalid image reference", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			PrePullImages: []datamodel.PrePullImage{{Image: "not a valid image"}},
+		}
+		Expect(validatePrePullImages(config)).NotTo(Succeed())
+	})
+
+	It("should return an error for an auth which is not a docker config JSON", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			PrePullImages: []datamodel.PrePullImage{{Image: "myregistry.azurecr.io/app:v1", Auth: "user:pass"}},
+		}
+		Expect(validatePrePullImages(config)).NotTo(Succeed())
+	})
+})
+
+var _ = Describe("Test getPrePullImagesNotOnVHD", func() {
+	It("should skip the images cached on the VHD", func() {
+		onVHD := &cache.OnVHD{
+			FromComponentContainerImages: map[string]cache.ContainerImage{
+				"pause": {
+					DownloadURL:       "mcr.microsoft.com/oss/kubernetes/pause:*",
+					MultiArchVersions: []string{"3.6"},
+				},
+			},
+		}
+		images := []datamodel.PrePullImage{
+			{Image: "mcr.microsoft.com/oss/kubernetes/pause:3.6"},
+			{Image: "myregistry.azurecr.io/app:v1"},
+		}
+		Expect(getPrePullImagesNotOnVHD(images, onVHD)).To(Equal([]datamodel.PrePullImage{{Image: "myregistry.azurecr.io/app:v1"}}))
+	})
+})
+
+var _ = Describe("Test validateDistroKubernetesVersion", func() {
+	newConfig := func(distro datamodel.Distro, version string) *datamodel.NodeBootstrappingConfiguration {
+		return &datamodel.NodeBootstrappingConfiguration{
+			ContainerService: &datamodel.ContainerService{
+				Properties: &datamodel.Properties{
+					OrchestratorProfile: &datamodel.OrchestratorProfile{OrchestratorVersion: version},
+				},
+			},
+			AgentPoolProfile: &datamodel.AgentPoolProfile{Distro: distro},
+		}
+	}
+
+	It("should succeed for a supported distro and version", func() {
+		Expect(validateDistroKubernetesVersion(newConfig(datamodel.AKSAzureLinuxV2Gen2, "1.29.2"))).To(Succeed())
+	})
+
+	It("should succeed for a distro without restrictions", func() {
+		Expect(validateDistroKubernetesVersion(newConfig(datamodel.CustomizedImage, "1.10.0"))).To(Succeed())
+	})
+
+	It("should return an error with the supported range for an unsupported version", func() {
+		err := validateDistroKubernetesVersion(newConfig(datamodel.AKSUbuntuContainerd1804, "1.28.0"))
+		Expect(err).To(MatchError(ContainSubstring("supported versions are any to 1.24")))
+	})
+})
+
+var _ = Describe("Test validateNetworkPolicy", func() {
+	newConfig := func(networkPolicy, networkPlugin string) *datamodel.NodeBootstrappingConfiguration {
+		return &datamodel.NodeBootstrappingConfiguration{
+			ContainerService: &datamodel.ContainerService{
+				Properties: &datamodel.Properties{
+					OrchestratorProfile: &datamodel.OrchestratorProfile{
+						OrchestratorVersion: "1.29.2",
+						KubernetesConfig:    &datamodel.KubernetesConfig{NetworkPolicy: networkPolicy, NetworkPlugin: networkPlugin},
+					},
+				},
+			},
+		}
+	}
+
+	It("should succeed for a compatible network policy engine", func() {
+		Expect(validateNetworkPolicy(newConfig(NetworkPolicyCalico, NetworkPluginKubenet))).To(Succeed())
+		Expect(validateNetworkPolicy(newConfig(NetworkPolicyCilium, NetworkPluginCilium))).To(Succeed())
+		Expect(validateNetworkPolicy(newConfig("", NetworkPluginCilium))).To(Succeed())
+	})
+
+	It("should succeed for cilium with azure cni powered by cilium", func() {
+		Expect(validateNetworkPolicy(newConfig(NetworkPolicyCilium, NetworkPluginAzure))).To(Succeed())
+	})
+
+	It("should return an error for an incompatible network plugin", func() {
+		Expect(validateNetworkPolicy(newConfig(NetworkPolicyAzure, NetworkPluginKubenet))).
+			To(MatchError(ContainSubstring("network policy engine azure does not support the kubenet dataplane")))
+	})
+})
+
+var _ = Describe("Test validateAndSetSandboxImage", func() {
+	newConfig := func(distro datamodel.Distro, version string) *datamodel.NodeBootstrappingConfiguration {
+		return &datamodel.NodeBootstrappingConfiguration{
+			ContainerService: &datamodel.ContainerService{
+				Properties: &datamodel.Properties{
+					OrchestratorProfile: &datamodel.OrchestratorProfile{OrchestratorVersion: version},
+				},
+			},
+			AgentPoolProfile: &datamodel.AgentPoolProfile{Distro: distro},
+			K8sComponents:    &datamodel.K8sComponents{},
+		}
+	}
+
+	It("should select the pause image matching the Kubernetes version", func() {
+		config := newConfig(datamodel.AKSUbuntuContainerd2204, "1.29.2")
+		Expect(validateAndSetSandboxImage(config)).To(Succeed())
+		Expect(config.SandboxImage).To(Equal("mcr.microsoft.com/oss/kubernetes/pause:3.9"))
+		Expect(getSandboxImage(config)).To(Equal("mcr.microsoft.com/oss/kubernetes/pause:3.9"))
+	})
+
+	It("should keep a pinned sandbox image", func() {
+		config := newConfig(datamodel.AKSUbuntuContainerd2204, "1.29.2")
+		config.SandboxImage = "myregistry.azurecr.io/pause:3.6"
+		config.K8sComponents.PodInfraContainerImageURL = "mcr.microsoft.com/oss/kubernetes/pause:3.6"
+		Expect(validateAndSetSandboxImage(config)).To(Succeed())
+		Expect(getSandboxImage(config)).To(Equal("myregistry.azurecr.io/pause:3.6"))
+	})
+
+	It("should keep the pod infra container image", func() {
+		config := newConfig(datamodel.AKSUbuntuContainerd2204, "1.29.2")
+		config.K8sComponents.PodInfraContainerImageURL = "mcr.microsoft.com/oss/kubernetes/pause:3.6"
+		Expect(validateAndSetSandboxImage(config)).To(Succeed())
+		Expect(config.SandboxImage).To(BeEmpty())
+		Expect(getSandboxImage(config)).To(Equal("mcr.microsoft.com/oss/kubernetes/pause:3.6"))
+	})
+
+	It("should select the latest sandbox image for new Kubernetes versions", func() {
+		config := newConfig(datamodel.AKSUbuntuContainerd2204, "1.32.0")
+		Expect(validateAndSetSandboxImage(config)).To(Succeed())
+		Expect(config.SandboxImage).To(Equal("mcr.microsoft.com/oss/kubernetes/pause:3.10"))
+	})
+
+	It("should return an error for an unsupported Kubernetes version", func() {
+		config := newConfig(datamodel.AKSUbuntuContainerd2204, "1.14.10")
+		Expect(validateAndSetSandboxImage(config)).To(MatchError(ContainSubstring("failed to select the sandbox image")))
+	})
+
+	It("should not select a sandbox image for Windows nodes", func() {
+		config := newConfig(datamodel.AKSWindows2022Containerd, "1.29.2")
+		Expect(validateAndSetSandboxImage(config)).To(Succeed())
+		Expect(config.SandboxImage).To(BeEmpty())
+
+		config.SandboxImage = "mcr.microsoft.com/oss/kubernetes/pause:3.9"
+		Expect(validateAndSetSandboxImage(config)).To(MatchError(ContainSubstring("not supported on Windows nodes")))
+	})
+})
+
+var _ = Describe("Test validateOSSKU", func() {
+	newConfig := func(osSKU string, distro datamodel.Distro) *datamodel.NodeBootstrappingConfiguration {
+		return &datamodel.NodeBootstrappingConfiguration{
+			OSSKU:            osSKU,
+			AgentPoolProfile: &datamodel.AgentPoolProfile{Distro: distro},
+		}
+	}
+
+	It("should succeed when the OS SKU matches the distro", func() {
+		Expect(validateOSSKU(newConfig(datamodel.OSSKUUbuntu, datamodel.AKSUbuntuContainerd2204))).To(Succeed())
+		Expect(validateOSSKU(newConfig(datamodel.OSSKUMariner, datamodel.AKSCBLMarinerV2Gen2))).To(Succeed())
+		Expect(validateOSSKU(newConfig("azurelinux", datamodel.AKSAzureLinuxV2Gen2))).To(Succeed())
+		Expect(validateOSSKU(newConfig("Windows2022", datamodel.AKSWindows2022Containerd))).To(Succeed())
+	})
+
+	It("should succeed when the OS SKU or the distro family is unknown", func() {
+		Expect(validateOSSKU(newConfig("", datamodel.AKSAzureLinuxV2Gen2))).To(Succeed())
+		Expect(validateOSSKU(newConfig(datamodel.OSSKUUbuntu, datamodel.CustomizedImage))).To(Succeed())
+	})
+
+	It("should return an error when the OS SKU does not match the distro", func() {
+		err := validateOSSKU(newConfig(datamodel.OSSKUUbuntu, datamodel.AKSAzureLinuxV2Gen2))
+		Expect(err).To(MatchError("OS SKU Ubuntu selects the Ubuntu OS family, but distro aks-azurelinux-v2-gen2 is of the AzureLinux OS family"))
+	})
+})
+
+var _ = Describe("Test validateResolvConfMode", func() {
+	It("should succeed when the mode is not set", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{AgentPoolProfile: &datamodel.AgentPoolProfile{Distro: datamodel.AKSUbuntu1604}}
+		Expect(validateResolvConfMode(config)).To(Succeed())
+	})
+
+	It("should succeed for direct mode on a Linux distro", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			ResolvConfMode:   datamodel.ResolvConfModeDirect,
+			AgentPoolProfile: &datamodel.AgentPoolProfile{Distro: datamodel.AKSUbuntuContainerd2204},
+		}
+		Expect(validateResolvConfMode(config)).To(Succeed())
+	})
+
+	It("should return an error for an unknown mode", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{ResolvConfMode: "stub"}
+		Expect(validateResolvConfMode(config)).NotTo(Succeed())
+	})
+
+	It("should return an error for direct mode on Windows", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			ResolvConfMode:   datamodel.ResolvConfModeDirect,
+			AgentPoolProfile: &datamodel.AgentPoolProfile{OSType: datamodel.Windows, Distro: datamodel.AKSWindows2022Containerd},
+		}
+		Expect(validateResolvConfMode(config)).NotTo(Succeed())
+	})
+
+	It("should return an error for systemd-resolved mode on Ubuntu 16.04", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			ResolvConfMode:   datamodel.ResolvConfModeSystemdResolved,
+			AgentPoolProfile: &datamodel.AgentPoolProfile{Distro: datamodel.AKSUbuntu1604},
+		}
+		Expect(validateResolvConfMode(config)).NotTo(Succeed())
+	})
+})
+
+var _ = Describe("Test validateAdminUsername", func() {
+	newConfig := func(osType datamodel.OSType, linuxAdminUsername, windowsAdminUsername string) *datamodel.NodeBootstrappingConfiguration {
+		return &datamodel.NodeBootstrappingConfiguration{
+			ContainerService: &datamodel.ContainerService{
+				Properties: &datamodel.Properties{
+					LinuxProfile:   &datamodel.LinuxProfile{AdminUsername: linuxAdminUsername},
+					WindowsProfile: &datamodel.WindowsProfile{AdminUsername: windowsAdminUsername},
+				},
+			},
+			AgentPoolProfile: &datamodel.AgentPoolProfile{OSType: osType},
+		}
+	}
+
+	It("should succeed for a valid Linux admin username", func() {
+		Expect(validateAdminUsername(newConfig(datamodel.Linux, "azureuser", "root"))).To(Succeed())
+	})
+
+	It("should return an error for a reserved Linux admin username", func() {
+		Expect(validateAdminUsername(newConfig(datamodel.Linux, "root", "azureuser"))).NotTo(Succeed())
+	})
+
+	It("should validate the Windows admin username for Windows nodes", func() {
+		Expect(validateAdminUsername(newConfig(datamodel.Windows, "root", "AzureUser"))).To(Succeed())
+		Expect(validateAdminUsername(newConfig(datamodel.Windows, "azureuser", "Administrator"))).NotTo(Succeed())
+	})
+})
+
+var _ = Describe("Test validateSSHPublicKeys", func() {
+	newConfig := func(osType datamodel.OSType, sshEnabled bool, keys ...string) *datamodel.NodeBootstrappingConfiguration {
+		linuxProfile := &datamodel.LinuxProfile{}
+		for _, key := range keys {
+			linuxProfile.SSH.PublicKeys = append(linuxProfile.SSH.PublicKeys, datamodel.PublicKey{KeyData: key})
+		}
+		return &datamodel.NodeBootstrappingConfiguration{
+			ContainerService: &datamodel.ContainerService{
+				Properties: &datamodel.Properties{
+					LinuxProfile:   linuxProfile,
+					WindowsProfile: &datamodel.WindowsProfile{SSHEnabled: to.BoolPtr(sshEnabled)},
+				},
+			},
+			AgentPoolProfile: &datamodel.AgentPoolProfile{OSType: osType},
+		}
+	}
+
+	It("should succeed for valid keys", func() {
+		Expect(validateSSHPublicKeys(newConfig(datamodel.Linux, false, testSSHPublicKey, testSSHPublicKey+" azureuser"))).To(Succeed())
+	})
+
+	It("should return an error naming the index of an invalid key", func() {
+		err := validateSSHPublicKeys(newConfig(datamodel.Linux, false, testSSHPublicKey, "ssh-ed25519 AAAA"))
+		Expect(err).To(MatchError(ContainSubstring("invalid SSH public key at index 1")))
+	})
+
+	It("should return an error for keys without a key type", func() {
+		Expect(validateSSHPublicKeys(newConfig(datamodel.Linux, false, "testsshkey"))).NotTo(Succeed())
+		Expect(validateSSHPublicKeys(newConfig(datamodel.Linux, false, "AAAAC3NzaC1lZDI1NTE5AAAAIB+hjmlQ6cmdGOuGL3hWX5jWxj8L3fl5ES+lD5PxYYoG"))).NotTo(Succeed())
+	})
+
+	It("should validate the keys of Windows nodes only when SSH is enabled", func() {
+		Expect(validateSSHPublicKeys(newConfig(datamodel.Windows, false, "ssh-ed25519 AAAA"))).To(Succeed())
+		Expect(validateSSHPublicKeys(newConfig(datamodel.Windows, true, "ssh-ed25519 AAAA"))).NotTo(Succeed())
+	})
+})
+
+var _ = Describe("Test validateAndSetKernelModules", func() {
+	newConfig := func(osType datamodel.OSType, modules ...string) *datamodel.NodeBootstrappingConfiguration {
+		return &datamodel.NodeBootstrappingConfiguration{
+			KernelModules:    modules,
+			AgentPoolProfile: &datamodel.AgentPoolProfile{OSType: osType},
+		}
+	}
+
+	It("should remove duplicate modules and keep the load order", func() {
+		config := newConfig(datamodel.Linux, "br_netfilter", "ip_vs", "br-netfilter", "nf_conntrack", "ip_vs")
+		Expect(validateAndSetKernelModules(config)).To(Succeed())
+		Expect(config.KernelModules).To(Equal([]string{"br_netfilter", "ip_vs", "nf_conntrack"}))
+		Expect(getKernelModulesLoadContent(config.KernelModules)).To(Equal("br_netfilter\nip_vs\nnf_conntrack\n"))
+	})
+
+	It("should return an error for invalid module names", func() {
+		Expect(validateAndSetKernelModules(newConfig(datamodel.Linux, "ip_vs; reboot"))).NotTo(Succeed())
+		Expect(validateAndSetKernelModules(newConfig(datamodel.Linux, "../ip_vs"))).NotTo(Succeed())
+		Expect(validateAndSetKernelModules(newConfig(datamodel.Linux, ""))).NotTo(Succeed())
+	})
+
+	It("should return an error on Windows nodes", func() {
+		Expect(validateAndSetKernelModules(newConfig(datamodel.Windows, "ip_vs"))).NotTo(Succeed())
+	})
+})
+
+var _ = Describe("Test validateEnvironmentVariables", func() {
+	newConfig := func(osType datamodel.OSType, variables map[string]string) *datamodel.NodeBootstrappingConfiguration {
+		return &datamodel.NodeBootstrappingConfiguration{
+			EnvironmentVariables: variables,
+			AgentPoolProfile:     &datamodel.AgentPoolProfile{OSType: osType},
+		}
+	}
+
+	It("should succeed for valid variables and render them sorted", func() {
+		variables := map[string]string{"LC_ALL": "C.UTF-8", "_AGENT_MODE": "node agent"}
+		Expect(validateEnvironmentVariables(newConfig(datamodel.Linux, variables))).To(Succeed())
+		Expect(getEnvironmentVariablesContent(variables)).To(Equal("LC_ALL=\"C.UTF-8\"\n_AGENT_MODE=\"node agent\"\n"))
+	})
+
+	It("should return an error for names which are not shell identifiers", func() {
+		Expect(validateEnvironmentVariables(newConfig(datamodel.Linux, map[string]string{"1LANG": "C"}))).NotTo(Succeed())
+		Expect(validateEnvironmentVariables(newConfig(datamodel.Linux, map[string]string{"AGENT-MODE": "node"}))).NotTo(Succeed())
+	})
+
+	It("should return an error for values with newlines", func() {
+		Expect(validateEnvironmentVariables(newConfig(datamodel.Linux, map[string]string{"LANG": "C\nPATH=/tmp"}))).NotTo(Succeed())
+	})
+
+	It("should return an error for proxy variables", func() {
+		Expect(validateEnvironmentVariables(newConfig(datamodel.Linux, map[string]string{"https_proxy": "http://proxy:3128"}))).
+			To(MatchError(ContainSubstring("HTTPProxyConfig")))
+	})
+
+	It("should return an error on Windows nodes", func() {
+		Expect(validateEnvironmentVariables(newConfig(datamodel.Windows, map[string]string{"LANG": "C"}))).NotTo(Succeed())
+	})
+})
+
+var _ = Describe("Test validateUdevRules", func() {
+	const nvmeRule = `KERNEL=="nvme[0-9]*n[0-9]*", ATTRS{model}=="Microsoft NVMe Direct Disk*", SYMLINK+="disk/azure/local/%k"`
+
+	It("should succeed for rules files on a Linux node", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			UdevRules:        map[string]string{"99-nvme.rules": nvmeRule},
+			AgentPoolProfile: &datamodel.AgentPoolProfile{Distro: datamodel.AKSUbuntuContainerd2204},
+		}
+		Expect(validateUdevRules(config)).To(Succeed())
+	})
+
+	It("should return an error for a file name not ending in .rules", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{UdevRules: map[string]string{"99-nvme.conf": nvmeRule}}
+		Expect(validateUdevRules(config)).NotTo(Succeed())
+	})
+
+	It("should return an error for a file name with a path", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{UdevRules: map[string]string{"../99-nvme.rules": nvmeRule}}
+		Expect(validateUdevRules(config)).NotTo(Succeed())
+	})
+
+	It("should return an error for empty content", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{UdevRules: map[string]string{"99-nvme.rules": " \n"}}
+		Expect(validateUdevRules(config)).NotTo(Succeed())
+	})
+
+	It("should return an error on Windows", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			UdevRules:        map[string]string{"99-nvme.rules": nvmeRule},
+			AgentPoolProfile: &datamodel.AgentPoolProfile{OSType: datamodel.Windows, Distro: datamodel.AKSWindows2022Containerd},
+		}
+		Expect(validateUdevRules(config)).NotTo(Succeed())
+	})
+})
+
+var _ = Describe("Test validateAndSetMaxPods", func() {
+	var config *datamodel.NodeBootstrappingConfiguration
+
+	BeforeEach(func() {
+		config = &datamodel.NodeBootstrappingConfiguration{
+			ContainerService: &datamodel.ContainerService{
+				Properties: &datamodel.Properties{
+					OrchestratorProfile: &datamodel.OrchestratorProfile{
+						KubernetesConfig: &datamodel.KubernetesConfig{NetworkPlugin: "azure"},
+					},
+				},
+			},
+			AgentPoolProfile: &datamodel.AgentPoolProfile{VMSize: "Standard_D16s_v3"},
+		}
+	})
+
+	It("should default max pods of Azure CNI nodes by VM size when unset", func() {
+		Expect(validateAndSetMaxPods(config)).To(Succeed())
+		Expect(config.KubeletConfig).To(HaveKeyWithValue("--max-pods", "250"))
+	})
+
+	It("should keep the kubelet default of other network plugins", func() {
+		config.ContainerService.Properties.OrchestratorProfile.KubernetesConfig.NetworkPlugin = "kubenet"
+		Expect(validateAndSetMaxPods(config)).To(Succeed())
+		Expect(config.KubeletConfig).NotTo(HaveKey("--max-pods"))
+	})
+
+	It("should keep the kubelet default of Windows nodes", func() {
+		config.AgentPoolProfile.OSType = datamodel.Windows
+		Expect(validateAndSetMaxPods(config)).To(Succeed())
+		Expect(config.KubeletConfig).NotTo(HaveKey("--max-pods"))
+	})
+
+	It("should prefer the max pods of the cluster over the default", func() {
+		config.ContainerService.Properties.OrchestratorProfile.KubernetesConfig.MaxPods = 50
+		Expect(validateAndSetMaxPods(config)).To(Succeed())
+		Expect(config.KubeletConfig).To(HaveKeyWithValue("--max-pods", "50"))
+	})
+
+	It("should keep the kubelet flag when set", func() {
+		config.KubeletConfig = map[string]string{"--max-pods": "110"}
+		Expect(validateAndSetMaxPods(config)).To(Succeed())
+		Expect(config.KubeletConfig).To(HaveKeyWithValue("--max-pods", "110"))
+	})
+
+	It("should return an error for an invalid kubelet flag", func() {
+		config.KubeletConfig = map[string]string{"--max-pods": "-1"}
+		Expect(validateAndSetMaxPods(config)).NotTo(Succeed())
+	})
+})
+
+var _ = Describe("Test validateAndSetExternalCloudProvider", func() {
+	newConfig := func(version string, external bool) *datamodel.NodeBootstrappingConfiguration {
+		return &datamodel.NodeBootstrappingConfiguration{
+			ContainerService: &datamodel.ContainerService{
+				Properties: &datamodel.Properties{
+					OrchestratorProfile: &datamodel.OrchestratorProfile{OrchestratorVersion: version},
+				},
+			},
+			KubeletConfig: map[string]string{
+				"--cloud-provider": "azure",
+				"--cloud-config":   "/etc/kubernetes/azure.json",
+			},
+			ExternalCloudProvider: external,
+		}
+	}
+
+	It("should set the kubelet flags of the external cloud provider", func() {
+		config := newConfig("1.29.2", true)
+		Expect(validateAndSetExternalCloudProvider(config)).To(Succeed())
+		Expect(config.KubeletConfig).To(HaveKeyWithValue("--cloud-provider", "external"))
+		Expect(config.KubeletConfig).NotTo(HaveKey("--cloud-config"))
+	})
+
+	It("should keep the in-tree cloud provider when not enabled", func() {
+		config := newConfig("1.29.2", false)
+		Expect(validateAndSetExternalCloudProvider(config)).To(Succeed())
+		Expect(config.KubeletConfig).To(HaveKeyWithValue("--cloud-provider", "azure"))
+		Expect(config.KubeletConfig).To(HaveKey("--cloud-config"))
+	})
+
+	It("should return an error for an external cloud provider on an old kubernetes version", func() {
+		Expect(validateAndSetExternalCloudProvider(newConfig("1.20.9", true))).NotTo(Succeed())
+	})
+
+	It("should return an error for the in-tree cloud provider after it was removed", func() {
+		Expect(validateAndSetExternalCloudProvider(newConfig("1.31.1", false))).NotTo(Succeed())
+		Expect(validateAndSetExternalCloudProvider(newConfig("1.31.1", true))).To(Succeed())
+	})
+})
+
+var _ = Describe("Test validateAndSetWorkloadIdentityConfig", func() {
+	var config *datamodel.NodeBootstrappingConfiguration
+
+	BeforeEach(func() {
+		config = &datamodel.NodeBootstrappingConfiguration{
+			ContainerService: &datamodel.ContainerService{
+				Properties: &datamodel.Properties{
+					OrchestratorProfile: &datamodel.OrchestratorProfile{KubernetesConfig: &datamodel.KubernetesConfig{}},
+				},
+			},
+			WorkloadIdentityConfig: &datamodel.WorkloadIdentityConfig{
+				Issuer: "https://southcentralus.oic.prod-aks.azure.com/tenantID/issuerID/",
+			},
+		}
+	})
+
+	It("should default the audience", func() {
+		Expect(validateAndSetWorkloadIdentityConfig(config)).To(Succeed())
+		Expect(config.WorkloadIdentityConfig.Audience).To(Equal(datamodel.DefaultWorkloadIdentityAudience))
+	})
+
+	It("should keep a custom audience", func() {
+		config.WorkloadIdentityConfig.Audience = "api://AzureADTokenExchangeChina"
+		Expect(validateAndSetWorkloadIdentityConfig(config)).To(Succeed())
+		Expect(config.WorkloadIdentityConfig.Audience).To(Equal("api://AzureADTokenExchangeChina"))
+	})
+
+	It("should succeed when workload identity is not enabled", func() {
+		config.WorkloadIdentityConfig = nil
+		Expect(validateAndSetWorkloadIdentityConfig(config)).To(Succeed())
+	})
+
+	It("should return an error for an issuer which is not an https URL", func() {
+		config.WorkloadIdentityConfig.Issuer = "http://southcentralus.oic.prod-aks.azure.com/tenantID/issuerID/"
+		Expect(validateAndSetWorkloadIdentityConfig(config)).NotTo(Succeed())
+		config.WorkloadIdentityConfig.Issuer = "southcentralus.oic.prod-aks.azure.com"
+		Expect(validateAndSetWorkloadIdentityConfig(config)).NotTo(Succeed())
+	})
+
+	It("should return an error when combined with AAD pod identity", func() {
+		config.ContainerService.Properties.OrchestratorProfile.KubernetesConfig.Addons = []datamodel.KubernetesAddon{
+			{Name: datamodel.AADPodIdentityAddonName, Enabled: to.BoolPtr(true)},
+		}
+		Expect(validateAndSetWorkloadIdentityConfig(config)).To(MatchError(ContainSubstring("aad-pod-identity")))
+	})
+})
+
+var _ = Describe("Test validateAndSetRegisterWithTaints", func() {
+	It("should render the sorted taints into the kubelet flag", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			KubeletConfig: map[string]string{"--register-with-taints": "sku=gpu:NoSchedule"},
+			RegisterWithTaints: []datamodel.Taint{
+				{Key: "sku", Value: "gpu", Effect: datamodel.TaintEffectNoSchedule},
+				{Key: "node.kubernetes.io/not-ready", Effect: datamodel.TaintEffectNoExecute},
+				{Key: "node.kubernetes.io/not-ready", Effect: datamodel.TaintEffectNoSchedule},
+			},
+		}
+		Expect(validateAndSetRegisterWithTaints(config)).To(Succeed())
+		Expect(config.KubeletConfig).To(HaveKeyWithValue("--register-with-taints",
+			"node.kubernetes.io/not-ready:NoExecute,node.kubernetes.io/not-ready:NoSchedule,sku=gpu:NoSchedule"))
+	})
+
+	It("should keep the kubelet flag when no taints are set", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			KubeletConfig: map[string]string{"--register-with-taints": "sku=gpu:NoSchedule"},
+		}
+		Expect(validateAndSetRegisterWithTaints(config)).To(Succeed())
+		Expect(config.KubeletConfig).To(HaveKeyWithValue("--register-with-taints", "sku=gpu:NoSchedule"))
+	})
+
+	It("should return an error for an invalid effect", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			RegisterWithTaints: []datamodel.Taint{{Key: "sku", Value: "gpu", Effect: "NoExec"}},
+		}
+		Expect(validateAndSetRegisterWithTaints(config)).NotTo(Succeed())
+	})
+
+	It("should return an error for duplicate taints", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			RegisterWithTaints: []datamodel.Taint{
+				{Key: "sku", Value: "gpu", Effect: datamodel.TaintEffectNoSchedule},
+				{Key: "sku", Value: "cpu", Effect: datamodel.TaintEffectNoSchedule},
+			},
+		}
+		Expect(validateAndSetRegisterWithTaints(config)).NotTo(Succeed())
+	})
+})
+
+var _ = Describe("Test validateExtraHostsEntries", func() {
+	newConfig := func(entries ...datamodel.HostEntry) *datamodel.NodeBootstrappingConfiguration {
+		return &datamodel.NodeBootstrappingConfiguration{
+			ExtraHostsEntries: entries,
+			AgentPoolProfile:  &datamodel.AgentPoolProfile{Distro: datamodel.AKSUbuntuContainerd2204},
+		}
+	}
+
+	It("should succeed for valid entries", func() {
+		config := newConfig(
+			datamodel.HostEntry{IP: "10.0.0.4", Hostnames: []string{"myregistry.privatelink.azurecr.io", "myregistry"}},
+			datamodel.HostEntry{IP: "fd00::4", Hostnames: []string{"mykeyvault.privatelink.vaultcore.azure.net"}},
+		)
+		Expect(validateExtraHostsEntries(config)).To(Succeed())
+		Expect(getExtraHostsEntriesContent(config.ExtraHostsEntries)).To(Equal(
+			"10.0.0.4 myregistry.privatelink.azurecr.io myregistry\nfd00::4 mykeyvault.privatelink.vaultcore.azure.net\n"))
+	})
+
+	It("should return an error for an invalid IP", func() {
+		Expect(validateExtraHostsEntries(newConfig(datamodel.HostEntry{IP: "10.0.0", Hostnames: []string{"myregistry"}}))).NotTo(Succeed())
+	})
+
+	It("should return an error for an invalid hostname", func() {
+		Expect(validateExtraHostsEntries(newConfig(datamodel.HostEntry{IP: "10.0.0.4", Hostnames: []string{"my_registry"}}))).NotTo(Succeed())
+		Expect(validateExtraHostsEntries(newConfig(datamodel.HostEntry{IP: "10.0.0.4"}))).NotTo(Succeed())
+	})
+
+	It("should return an error for entries overriding the localhost entries", func() {
+		Expect(validateExtraHostsEntries(newConfig(datamodel.HostEntry{IP: "127.0.0.1", Hostnames: []string{"myregistry"}}))).NotTo(Succeed())
+		Expect(validateExtraHostsEntries(newConfig(datamodel.HostEntry{IP: "10.0.0.4", Hostnames: []string{"localhost"}}))).NotTo(Succeed())
+	})
+
+	It("should return an error on Windows", func() {
+		config := newConfig(datamodel.HostEntry{IP: "10.0.0.4", Hostnames: []string{"myregistry"}})
+		config.AgentPoolProfile = &datamodel.AgentPoolProfile{OSType: datamodel.Windows, Distro: datamodel.AKSWindows2022Containerd}
+		Expect(validateExtraHostsEntries(config)).NotTo(Succeed())
+	})
+})
+
+var _ = Describe("Test validateBootCmds", func() {
+	newConfig := func(cmds ...string) *datamodel.NodeBootstrappingConfiguration {
+		return &datamodel.NodeBootstrappingConfiguration{
+			BootCmds: cmds,
+			ContainerService: &datamodel.ContainerService{
+				Properties: &datamodel.Properties{},
+			},
+			AgentPoolProfile: &datamodel.AgentPoolProfile{Distro: datamodel.AKSUbuntuContainerd2204},
+		}
+	}
+
+	It("should render the boot commands into the bootcmd section", func() {
+		config := newConfig("sgdisk -n 0:0:0 /dev/sdc", `echo "done" > /tmp/bootcmd`)
+		Expect(validateBootCmds(config)).To(Succeed())
+		Expect(getBootCmdsWarnings(config)).To(BeEmpty())
+		Expect(getBootCmdsContent(config.BootCmds)).To(Equal(
+			"bootcmd:\n  - \"sgdisk -n 0:0:0 /dev/sdc\"\n  - \"echo \\\"done\\\" > /tmp/bootcmd\"\n"))
+	})
+
+	It("should return an error for an empty boot command", func() {
+		Expect(validateBootCmds(newConfig("mkfs.ext4 /dev/sdc", " "))).To(MatchError("boot command 1 is empty"))
+	})
+
+	It("should return an error for Windows nodes", func() {
+		config := newConfig("mkfs.ext4 /dev/sdc")
+		config.AgentPoolProfile = &datamodel.AgentPoolProfile{OSType: datamodel.Windows}
+		Expect(validateBootCmds(config)).NotTo(Succeed())
+	})
+
+	It("should warn about boot commands conflicting with AgentBaker", func() {
+		config := newConfig("rm -f /etc/kubernetes/azure.json", "systemctl mask containerd", "systemctl start chronyd")
+		Expect(validateBootCmds(config)).To(Succeed())
+		Expect(getBootCmdsWarnings(config)).To(Equal([]string{
+			`boot command "rm -f /etc/kubernetes/azure.json" touches /etc/kubernetes/azure.json, which AgentBaker writes after the boot commands run`,
+			`boot command "systemctl mask containerd" controls systemd unit containerd.service, which is managed by AgentBaker`,
+		}))
+	})
+})
+
+var _ = Describe("Test validateAndSetEvictionThresholds", func() {
+	It("should render the thresholds into the kubelet flags", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			KubeletConfig: map[string]string{"--eviction-hard": "memory.available<750Mi"},
+			EvictionHard: map[string]string{
+				"nodefs.available":  "10%",
+				"memory.available":  "500Mi",
+				"nodefs.inodesFree": "5%",
+			},
+			EvictionSoft:            map[string]string{"memory.available": "1Gi"},
+			EvictionSoftGracePeriod: map[string]string{"memory.available": "1m30s"},
+		}
+		Expect(validateAndSetEvictionThresholds(config)).To(Succeed())
+		Expect(config.KubeletConfig).To(HaveKeyWithValue("--eviction-hard", "memory.available<500Mi,nodefs.available<10%,nodefs.inodesFree<5%"))
+		Expect(config.KubeletConfig).To(HaveKeyWithValue("--eviction-soft", "memory.available<1Gi"))
+		Expect(config.KubeletConfig).To(HaveKeyWithValue("--eviction-soft-grace-period", "memory.available=1m30s"))
+	})
+
+	It("should keep the kubelet flags when no thresholds are set", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			KubeletConfig: map[string]string{"--eviction-hard": "memory.available<750Mi"},
+		}
+		Expect(validateAndSetEvictionThresholds(config)).To(Succeed())
+		Expect(config.KubeletConfig).To(Equal(map[string]string{"--eviction-hard": "memory.available<750Mi"}))
+	})
+
+	It("should return an error for an unknown signal", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{EvictionHard: map[string]string{"cpu.available": "10%"}}
+		Expect(validateAndSetEvictionThresholds(config)).NotTo(Succeed())
+	})
+
+	It("should return an error for a soft threshold without grace period", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{EvictionSoft: map[string]string{"memory.available": "1Gi"}}
+		Expect(validateAndSetEvictionThresholds(config)).NotTo(Succeed())
+	})
+
+	It("should return an error for a grace period without soft threshold", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{EvictionSoftGracePeriod: map[string]string{"memory.available": "30s"}}
+		Expect(validateAndSetEvictionThresholds(config)).NotTo(Succeed())
+	})
+
+	It("should return an error for an invalid grace period", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			EvictionSoft:            map[string]string{"memory.available": "1Gi"},
+			EvictionSoftGracePeriod: map[string]string{"memory.available": "soon"},
+		}
+		Expect(validateAndSetEvictionThresholds(config)).NotTo(Succeed())
+	})
+})
+
+var _ = Describe("Test validateAPTSources", func() {
+	const signingKey = `-----BEGIN PGP PUBLIC KEY BLOCK-----
+
+mDMEatCSJxYJKwYBBAHaRw8BAQdAO3qgej8vphvKqIpp7SGxbiGn90IyghUbOArw
+1KDZYvy0HFRlc3QgUmVwbyA8cmVwb0BleGFtcGxlLmNvbT6IkAQTFggAOBYhBFY6
+DCyfMXuLM2m5DtP8i/pqIJHnBQJq0JInAhsDBQsJCAcCBhUKCQgLAgQWAgMBAh4B
+AheAAAoJENP8i/pqIJHnAUoBAP+WKQus2Ud8pmm1CTwOLNNR61Gczso4lEYRef4o
+uw95AP9XhcpKTKNoHAHIcA0mQCL7mo9lmzXFnDulf4V5/9e5Dw==
+=TN7i
+-----END PGP PUBLIC KEY BLOCK-----`
+	var config *datamodel.NodeBootstrappingConfiguration
+
+	BeforeEach(func() {
+		config = &datamodel.NodeBootstrappingConfiguration{
+			AgentPoolProfile: &datamodel.AgentPoolProfile{Distro: datamodel.AKSUbuntuContainerd2204},
+			APTSources: []datamodel.APTSource{
+				{URI: "https://packages.example.com/ubuntu", Suite: "jammy", Components: []string{"main", "universe"}, SigningKey: signingKey},
+				{URI: "file:///opt/packages", Suite: "./"},
+			},
+		}
+	})
+
+	It("should succeed for valid APT sources on Ubuntu", func() {
+		Expect(validateAPTSources(config)).To(Succeed())
+	})
+
+	It("should succeed for customized Ubuntu images", func() {
+		config.AgentPoolProfile.Distro = datamodel.CustomizedImage
+		config.OSSKU = datamodel.OSSKUUbuntu
+		Expect(validateAPTSources(config)).To(Succeed())
+	})
+
+	It("should return an error for non-Ubuntu distros", func() {
+		config.AgentPoolProfile.Distro = datamodel.AKSAzureLinuxV2Gen2
+		Expect(validateAPTSources(config)).NotTo(Succeed())
+	})
+
+	It("should return an error for an unsupported URI scheme", func() {
+		config.APTSources[0].URI = "ftp://packages.example.com/ubuntu"
+		Expect(validateAPTSources(config)).NotTo(Succeed())
+	})
+
+	It("should return an error for a suite without components", func() {
+		config.APTSources[0].Components = nil
+		Expect(validateAPTSources(config)).NotTo(Succeed())
+	})
+
+	It("should return an error for a signing key which is not an armored GPG key", func() {
+		config.APTSources[0].SigningKey = "not a key"
+		Expect(validateAPTSources(config)).NotTo(Succeed())
+	})
+})
+
+var _ = Describe("Test getAPTSourcesListContent", func() {
+	It("should render one line per source and reference the signing keys", func() {
+		content := getAPTSourcesListContent([]datamodel.APTSource{
+			{URI: "https://packages.example.com/ubuntu", Suite: "jammy", Components: []string{"main"}, SigningKey: "key"},
+			{URI: "file:///opt/packages", Suite: "./"},
+		})
+		Expect(content).To(Equal("deb [signed-by=/etc/apt/keyrings/aks-custom-0.asc] https://packages.example.com/ubuntu jammy main\n" +
+			"deb file:///opt/packages ./\n"))
+	})
+})
+
+var _ = Describe("Test validateAndSetDownloadRetryConfig", func() {
+	It("should set the defaults when DownloadRetryConfig is not set", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{}
+		Expect(validateAndSetDownloadRetryConfig(config)).To(Succeed())
+		Expect(config.DownloadRetryConfig).To(Equal(&datamodel.DownloadRetryConfig{
+			MaxRetries:     datamodel.DefaultDownloadMaxRetries,
+			BackoffSeconds: datamodel.DefaultDownloadBackoffSeconds,
+		}))
+	})
+
+	It("should keep configured values and fill in the unset ones", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{DownloadRetryConfig: &datamodel.DownloadRetryConfig{MaxRetries: 3}}
+		Expect(validateAndSetDownloadRetryConfig(config)).To(Succeed())
+		Expect(config.DownloadRetryConfig.MaxRetries).To(Equal(3))
+		Expect(config.DownloadRetryConfig.BackoffSeconds).To(Equal(datamodel.DefaultDownloadBackoffSeconds))
+	})
+
+	It("should return an error for negative max retries", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{DownloadRetryConfig: &datamodel.DownloadRetryConfig{MaxRetries: -1}}
+		Expect(validateAndSetDownloadRetryConfig(config)).NotTo(Succeed())
+	})
+
+	It("should return an error for a negative backoff", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{DownloadRetryConfig: &datamodel.DownloadRetryConfig{BackoffSeconds: -5}}
+		Expect(validateAndSetDownloadRetryConfig(config)).NotTo(Succeed())
+	})
+})
+
+var _ = Describe("Test validateAndSetDownloadConcurrency", func() {
+	It("should download serially by default", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{}
+		Expect(validateAndSetDownloadConcurrency(config)).To(Succeed())
+		Expect(config.DownloadConcurrency).To(Equal(datamodel.DefaultDownloadConcurrency))
+	})
+
+	It("should keep a configured concurrency", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{DownloadConcurrency: datamodel.MaxDownloadConcurrency}
+		Expect(validateAndSetDownloadConcurrency(config)).To(Succeed())
+		Expect(config.DownloadConcurrency).To(Equal(datamodel.MaxDownloadConcurrency))
+	})
+
+	It("should return an error for a concurrency out of range", func() {
+		Expect(validateAndSetDownloadConcurrency(&datamodel.NodeBootstrappingConfiguration{DownloadConcurrency: -1})).
+			To(MatchError("download concurrency must be between 1 and 16, got -1"))
+		Expect(validateAndSetDownloadConcurrency(&datamodel.NodeBootstrappingConfiguration{DownloadConcurrency: 17})).NotTo(Succeed())
+	})
+})
+
+var _ = Describe("Test validateAndSetContainerdSnapshotter", func() {
+	var (
+		config *datamodel.NodeBootstrappingConfiguration
+		onVHD  *cache.OnVHD
+	)
+
+	BeforeEach(func() {
+		config = &datamodel.NodeBootstrappingConfiguration{
+			AgentPoolProfile:      &datamodel.AgentPoolProfile{Distro: datamodel.AKSUbuntuContainerd2204},
+			ContainerdSnapshotter: datamodel.ContainerdSnapshotterStargz,
+		}
+		onVHD = &cache.OnVHD{
+			FromComponentDownloadedFiles: map[string]cache.DownloadFile{
+				"stargz-snapshotter": {Versions: []string{"0.15.1"}},
+			},
+		}
+	})
+
+	It("should default to overlayfs", func() {
+		config.ContainerdSnapshotter = ""
+		Expect(validateAndSetContainerdSnapshotter(config, nil)).To(Succeed())
+		Expect(config.ContainerdSnapshotter).To(Equal(datamodel.ContainerdSnapshotterOverlayfs))
+	})
+
+	It("should accept stargz when its plugin is cached on the VHD", func() {
+		Expect(validateAndSetContainerdSnapshotter(config, onVHD)).To(Succeed())
+		Expect(config.ContainerdSnapshotter).To(Equal(datamodel.ContainerdSnapshotterStargz))
+	})
+
+	It("should return an error when the stargz plugin is not cached on the VHD", func() {
+		onVHD.FromComponentDownloadedFiles = nil
+		Expect(validateAndSetContainerdSnapshotter(config, onVHD)).To(MatchError(ContainSubstring("not cached on the VHD")))
+	})
+
+	It("should return an error when combined with artifact streaming", func() {
+		config.EnableArtifactStreaming = true
+		Expect(validateAndSetContainerdSnapshotter(config, onVHD)).NotTo(Succeed())
+	})
+
+	It("should return an error for an unknown snapshotter", func() {
+		config.ContainerdSnapshotter = "zfs"
+		Expect(validateAndSetContainerdSnapshotter(config, onVHD)).To(MatchError(ContainSubstring(`"zfs"`)))
+	})
+})
+
+var _ = Describe("Test validateAndSetRuntimeRequestTimeout", func() {
+	It("should not set the kubelet flag when unset", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{}
+		Expect(validateAndSetRuntimeRequestTimeout(config)).To(Succeed())
+		Expect(config.RuntimeRequestTimeout).To(BeEmpty())
+		Expect(config.KubeletConfig).NotTo(HaveKey("--runtime-request-timeout"))
+	})
+
+	It("should prefer RuntimeRequestTimeout over the kubelet flag", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			RuntimeRequestTimeout: "15m",
+			KubeletConfig:         map[string]string{"--runtime-request-timeout": "5m"},
+		}
+		Expect(validateAndSetRuntimeRequestTimeout(config)).To(Succeed())
+		Expect(config.KubeletConfig).To(HaveKeyWithValue("--runtime-request-timeout", "15m"))
+	})
+
+	It("should keep an existing kubelet flag", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			KubeletConfig: map[string]string{"--runtime-request-timeout": "5m"},
+		}
+		Expect(validateAndSetRuntimeRequestTimeout(config)).To(Succeed())
+		Expect(config.KubeletConfig).To(HaveKeyWithValue("--runtime-request-timeout", "5m"))
+	})
+
+	It("should return an error for an invalid or non-positive timeout", func() {
+		Expect(validateAndSetRuntimeRequestTimeout(&datamodel.NodeBootstrappingConfiguration{RuntimeRequestTimeout: "5 minutes"})).NotTo(Succeed())
+		Expect(validateAndSetRuntimeRequestTimeout(&datamodel.NodeBootstrappingConfiguration{RuntimeRequestTimeout: "0s"})).NotTo(Succeed())
+	})
+})
+
+var _ = Describe("Test setDefaultFeatureGates", func() {
+	const userFeatureGates = "DisableAcceleratorUsageMetrics=true,DynamicKubeletConfig=true,RotateKubeletServerCertificate=true"
+
+	newConfig := func(version string) *datamodel.NodeBootstrappingConfiguration {
+		return &datamodel.NodeBootstrappingConfiguration{
+			ContainerService: &datamodel.ContainerService{
+				Properties: &datamodel.Properties{
+					OrchestratorProfile: &datamodel.OrchestratorProfile{OrchestratorVersion: version},
+				},
+			},
+			KubeletConfig: map[string]string{"--feature-gates": userFeatureGates},
+		}
+	}
+
+	It("should force the version defaults over the user gates on Linux nodes", func() {
+		config := newConfig("1.22.6")
+		setDefaultFeatureGates(config, false)
+		Expect(config.KubeletConfig["--feature-gates"]).To(Equal(
+			"DisableAcceleratorUsageMetrics=false,DynamicKubeletConfig=false,RotateKubeletServerCertificate=true"))
+	})
+
+	It("should only force DynamicKubeletConfig on Windows nodes", func() {
+		config := newConfig("1.22.6")
+		setDefaultFeatureGates(config, true)
+		Expect(config.KubeletConfig["--feature-gates"]).To(Equal(
+			"DisableAcceleratorUsageMetrics=true,DynamicKubeletConfig=false,RotateKubeletServerCertificate=true"))
+	})
+
+	It("should drop DynamicKubeletConfig from 1.24 on", func() {
+		config := newConfig("1.24.9")
+		setDefaultFeatureGates(config, false)
+		Expect(config.KubeletConfig["--feature-gates"]).To(Equal("DisableAcceleratorUsageMetrics=false,RotateKubeletServerCertificate=true"))
+	})
+})
+
+var _ = Describe("Test validateAndSetWindowsKubeletConfig", func() {
+	var config *datamodel.NodeBootstrappingConfiguration
+
+	BeforeEach(func() {
+		config = &datamodel.NodeBootstrappingConfiguration{
+			AgentPoolProfile: &datamodel.AgentPoolProfile{OSType: datamodel.Windows},
+			KubeletConfig:    map[string]string{"--max-pods": "30", "--cluster-dns": "10.0.0.10"},
+		}
+	})
+
+	It("should succeed without a windows kubelet config", func() {
+		config.AgentPoolProfile = &datamodel.AgentPoolProfile{Distro: datamodel.AKSUbuntuContainerd2204}
+		Expect(validateAndSetWindowsKubeletConfig(config)).To(Succeed())
+		Expect(config.KubeletConfig).To(HaveLen(2))
+	})
+
+	It("should merge the flags into the kubelet config", func() {
+		config.WindowsKubeletConfig = map[string]string{"--max-pods": "60", "--image-gc-high-threshold": "90"}
+		Expect(validateAndSetWindowsKubeletConfig(config)).To(Succeed())
+		Expect(config.KubeletConfig).To(HaveKeyWithValue("--max-pods", "60"))
+		Expect(config.KubeletConfig).To(HaveKeyWithValue("--image-gc-high-threshold", "90"))
+		Expect(config.KubeletConfig).To(HaveKeyWithValue("--cluster-dns", "10.0.0.10"))
+		Expect(config.GetOrderedKubeletConfigStringForPowershell(nil)).To(ContainSubstring(`"--max-pods=60"`))
+	})
+
+	It("should return an error for a flag which is not allowed", func() {
+		config.WindowsKubeletConfig = map[string]string{"--bootstrap-kubeconfig": "c:\\k\\config"}
+		Expect(validateAndSetWindowsKubeletConfig(config)).To(MatchError(ContainSubstring("--bootstrap-kubeconfig is not allowed")))
+	})
+
+	It("should return an error for a Linux only flag", func() {
+		config.WindowsKubeletConfig = map[string]string{"--shutdown-grace-period": "30s"}
+		Expect(validateAndSetWindowsKubeletConfig(config)).To(MatchError(ContainSubstring("--shutdown-grace-period is not allowed")))
+		config.WindowsKubeletConfig = map[string]string{"--cpu-manager-policy": "static"}
+		Expect(validateAndSetWindowsKubeletConfig(config)).To(MatchError(ContainSubstring("--cpu-manager-policy is not allowed")))
+	})
+
+	It("should return an error for a flag without a value", func() {
+		config.WindowsKubeletConfig = map[string]string{"--max-pods": ""}
+		Expect(validateAndSetWindowsKubeletConfig(config)).NotTo(Succeed())
+	})
+
+	It("should return an error for Linux nodes", func() {
+		config.AgentPoolProfile = &datamodel.AgentPoolProfile{Distro: datamodel.AKSUbuntuContainerd2204}
+		config.WindowsKubeletConfig = map[string]string{"--max-pods": "60"}
+		Expect(validateAndSetWindowsKubeletConfig(config)).NotTo(Succeed())
+	})
+})
+
+var _ = Describe("Test validateAndSetHousekeepingInterval", func() {
+	It("should keep the kubelet flags when unset", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{}
+		Expect(validateAndSetHousekeepingInterval(config)).To(Succeed())
+		Expect(config.KubeletConfig).NotTo(HaveKey("--housekeeping-interval"))
+
+		config.KubeletConfig = map[string]string{"--housekeeping-interval": "15s"}
+		Expect(validateAndSetHousekeepingInterval(config)).To(Succeed())
+		Expect(config.KubeletConfig).To(HaveKeyWithValue("--housekeeping-interval", "15s"))
+	})
+
+	It("should prefer HousekeepingInterval over the kubelet flag", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			HousekeepingInterval: "30s",
+			KubeletConfig:        map[string]string{"--housekeeping-interval": "15s"},
+		}
+		Expect(validateAndSetHousekeepingInterval(config)).To(Succeed())
+		Expect(config.KubeletConfig).To(HaveKeyWithValue("--housekeeping-interval", "30s"))
+	})
+
+	It("should return an error on Windows", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			AgentPoolProfile:     &datamodel.AgentPoolProfile{Distro: datamodel.AKSWindows2022Containerd},
+			HousekeepingInterval: "30s",
+		}
+		Expect(validateAndSetHousekeepingInterval(config)).To(MatchError("housekeeping interval is not supported on Windows nodes"))
+	})
+
+	It("should return an error for an invalid or non-positive interval", func() {
+		Expect(validateAndSetHousekeepingInterval(&datamodel.NodeBootstrappingConfiguration{HousekeepingInterval: "30 seconds"})).NotTo(Succeed())
+		Expect(validateAndSetHousekeepingInterval(&datamodel.NodeBootstrappingConfiguration{HousekeepingInterval: "-1s"})).NotTo(Succeed())
+	})
+})
+
+var _ = Describe("Test validateAndSetVerboseProvisioning", func() {
+	It("should be off by default and not change the kubelet log verbosity", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{KubeletConfig: map[string]string{"--v": "2"}}
+		Expect(validateAndSetVerboseProvisioning(config)).To(Succeed())
+		Expect(config.VerboseProvisioning).To(BeFalse())
+		Expect(config.KubeletConfig).To(HaveKeyWithValue("--v", "2"))
+	})
+
+	It("should raise the kubelet log verbosity", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			VerboseProvisioning: true,
+			KubeletConfig:       map[string]string{"--v": "2"},
+		}
+		Expect(validateAndSetVerboseProvisioning(config)).To(Succeed())
+		Expect(config.KubeletConfig).To(HaveKeyWithValue("--v", "4"))
+
+		config = &datamodel.NodeBootstrappingConfiguration{VerboseProvisioning: true}
+		Expect(validateAndSetVerboseProvisioning(config)).To(Succeed())
+		Expect(config.KubeletConfig).To(HaveKeyWithValue("--v", "4"))
+	})
+
+	It("should keep a higher kubelet log verbosity", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			VerboseProvisioning: true,
+			KubeletConfig:       map[string]string{"--v": "6"},
+		}
+		Expect(validateAndSetVerboseProvisioning(config)).To(Succeed())
+		Expect(config.KubeletConfig).To(HaveKeyWithValue("--v", "6"))
+	})
+
+	It("should return an error for an invalid kubelet log verbosity", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			VerboseProvisioning: true,
+			KubeletConfig:       map[string]string{"--v": "verbose"},
+		}
+		Expect(validateAndSetVerboseProvisioning(config)).To(MatchError(ContainSubstring("invalid kubelet log verbosity")))
+	})
+})
+
+var _ = Describe("Test validateAndSetVolumePluginDir", func() {
+	It("should default the directory and render it into the kubelet config", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{}
+		Expect(validateAndSetVolumePluginDir(config)).To(Succeed())
+		Expect(config.VolumePluginDir).To(Equal(datamodel.DefaultVolumePluginDir))
+		Expect(config.KubeletConfig).To(HaveKeyWithValue("--volume-plugin-dir", "/etc/kubernetes/volumeplugins"))
+	})
+
+	It("should prefer VolumePluginDir over the kubelet flag", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			VolumePluginDir: "/var/lib/kubelet/volumeplugins/",
+			KubeletConfig:   map[string]string{"--volume-plugin-dir": "/opt/volumeplugins"},
+		}
+		Expect(validateAndSetVolumePluginDir(config)).To(Succeed())
+		Expect(config.KubeletConfig).To(HaveKeyWithValue("--volume-plugin-dir", "/var/lib/kubelet/volumeplugins"))
+	})
+
+	It("should return an error for a relative path or whitespace", func() {
+		Expect(validateAndSetVolumePluginDir(&datamodel.NodeBootstrappingConfiguration{VolumePluginDir: "volumeplugins"})).
+			To(MatchError(ContainSubstring("must be an absolute path")))
+		Expect(validateAndSetVolumePluginDir(&datamodel.NodeBootstrappingConfiguration{VolumePluginDir: "/opt/volume plugins"})).
+			To(MatchError(ContainSubstring("must not contain whitespace")))
+	})
+
+	It("should not set the directory of Windows nodes", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			AgentPoolProfile: &datamodel.AgentPoolProfile{Distro: datamodel.AKSWindows2022Containerd},
+		}
+		Expect(validateAndSetVolumePluginDir(config)).To(Succeed())
+		Expect(config.KubeletConfig).NotTo(HaveKey("--volume-plugin-dir"))
+
+		config.VolumePluginDir = "/etc/kubernetes/volumeplugins"
+		Expect(validateAndSetVolumePluginDir(config)).To(MatchError(ContainSubstring("not supported on Windows nodes")))
+	})
+})
+
+var _ = Describe("Test validateAndSetContainerdDirs", func() {
+	It("should keep the default directories when unset", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{}
+		Expect(validateAndSetContainerdDirs(config)).To(Succeed())
+		Expect(getContainerdRootDir(config)).To(Equal("/var/lib/containerd"))
+		Expect(getContainerdStateDir(config)).To(Equal("/run/containerd"))
+		Expect(getContainerdDirsDropinContent(config)).To(BeEmpty())
+	})
+
+	It("should clean the directories and wait for their mounts", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			ContainerdRootDir:  "/mnt/data/containerd/",
+			ContainerdStateDir: "/mnt/data/run/containerd",
+		}
+		Expect(validateAndSetContainerdDirs(config)).To(Succeed())
+		Expect(config.ContainerdRootDir).To(Equal("/mnt/data/containerd"))
+		Expect(getContainerdDirsDropinContent(config)).To(Equal("[Unit]\nRequiresMountsFor=/mnt/data/containerd /mnt/data/run/containerd\n\n" +
+			"[Service]\nExecStartPre=/bin/mkdir -p /mnt/data/containerd /mnt/data/run/containerd\n"))
+	})
+
+	It("should return an error for a relative path or whitespace", func() {
+		Expect(validateAndSetContainerdDirs(&datamodel.NodeBootstrappingConfiguration{ContainerdRootDir: "containerd"})).
+			To(MatchError(ContainSubstring("containerd root dir must be an absolute path")))
+		Expect(validateAndSetContainerdDirs(&datamodel.NodeBootstrappingConfiguration{ContainerdStateDir: "/run/container d"})).
+			To(MatchError(ContainSubstring("containerd state dir must not contain whitespace")))
+	})
+
+	It("should return an error when the directories contain each other", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{ContainerdStateDir: "/var/lib/containerd/state"}
+		Expect(validateAndSetContainerdDirs(config)).To(MatchError(ContainSubstring("must not contain each other")))
+	})
+})
+
+var _ = Describe("Test validateAndSetSeccompDefault", func() {
+	newConfig := func(version string, seccompDefault bool) *datamodel.NodeBootstrappingConfiguration {
+		return &datamodel.NodeBootstrappingConfiguration{
+			ContainerService: &datamodel.ContainerService{
+				Properties: &datamodel.Properties{
+					OrchestratorProfile: &datamodel.OrchestratorProfile{OrchestratorVersion: version},
+				},
+			},
+			SeccompDefault: seccompDefault,
+		}
+	}
+
+	It("should not enable seccomp by default when unset", func() {
+		config := newConfig("1.29.2", false)
+		Expect(validateAndSetSeccompDefault(config)).To(Succeed())
+		Expect(config.KubeletConfig).NotTo(HaveKey("--seccomp-default"))
+	})
+
+	It("should enable seccomp by default in kubelet", func() {
+		config := newConfig("1.29.2", true)
+		Expect(validateAndSetSeccompDefault(config)).To(Succeed())
+		Expect(config.KubeletConfig).To(HaveKeyWithValue("--seccomp-default", "true"))
+	})
+
+	It("should return an error for a kubernetes version without seccomp by default", func() {
+		config := newConfig("1.24.9", true)
+		Expect(validateAndSetSeccompDefault(config)).To(MatchError(ContainSubstring("requires kubernetes 1.25.0 or later")))
+	})
+})
+
+var _ = Describe("Test validateAndSetPodMaxPids", func() {
+	It("should keep the kubelet flags when unset", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{KubeletConfig: map[string]string{"--pod-max-pids": "-1"}}
+		Expect(validateAndSetPodMaxPids(config)).To(Succeed())
+		Expect(config.KubeletConfig).To(HaveKeyWithValue("--pod-max-pids", "-1"))
+	})
+
+	It("should render the PID limit into the kubelet config", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			PodMaxPids:    to.IntPtr(1024),
+			KubeletConfig: map[string]string{"--pod-max-pids": "-1"},
+		}
+		Expect(validateAndSetPodMaxPids(config)).To(Succeed())
+		Expect(config.KubeletConfig).To(HaveKeyWithValue("--pod-max-pids", "1024"))
+	})
+
+	It("should return an error for a negative PID limit", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{PodMaxPids: to.IntPtr(-1)}
+		Expect(validateAndSetPodMaxPids(config)).To(MatchError(ContainSubstring("must not be negative")))
+	})
+
+	It("should return an error when the custom kubelet config has a different PID limit", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			PodMaxPids: to.IntPtr(1024),
+			AgentPoolProfile: &datamodel.AgentPoolProfile{
+				CustomKubeletConfig: &datamodel.CustomKubeletConfig{PodMaxPids: to.Int32Ptr(2048)},
+			},
+		}
+		Expect(validateAndSetPodMaxPids(config)).To(MatchError(ContainSubstring("conflicts with the pod max pids 2048")))
+
+		config.AgentPoolProfile.CustomKubeletConfig.PodMaxPids = to.Int32Ptr(1024)
+		Expect(validateAndSetPodMaxPids(config)).To(Succeed())
+	})
+})
+
+var _ = Describe("Test validateNodeProblemDetectorConfig", func() {
+	var onVHD *cache.OnVHD
+
+	BeforeEach(func() {
+		onVHD = &cache.OnVHD{
+			FromComponentDownloadedFiles: map[string]cache.DownloadFile{
+				"node-problem-detector": {Versions: []string{"0.8.19"}},
+			},
+		}
+	})
+
+	newConfig := func(npdConfig *datamodel.NodeProblemDetectorConfig) *datamodel.NodeBootstrappingConfiguration {
+		return &datamodel.NodeBootstrappingConfiguration{NodeProblemDetectorConfig: npdConfig}
+	}
+
+	It("should succeed when node-problem-detector is disabled", func() {
+		Expect(validateNodeProblemDetectorConfig(newConfig(nil), nil)).To(Succeed())
+		Expect(validateNodeProblemDetectorConfig(newConfig(&datamodel.NodeProblemDetectorConfig{}), nil)).To(Succeed())
+	})
+
+	It("should succeed with a valid custom config", func() {
+		config := newConfig(&datamodel.NodeProblemDetectorConfig{
+			Enabled: true,
+			CustomConfig: []byte(`{"plugin": "journald", "pluginConfig": {"source": "myservice"}, "source": "myservice-monitor",
+				"conditions": [], "rules": [{"type": "temporary", "reason": "MyServiceCrashed", "pattern": "panic:.*"}]}`),
+		})
+		Expect(validateNodeProblemDetectorConfig(config, onVHD)).To(Succeed())
+		Expect(getNodeProblemDetectorServiceContent(config.NodeProblemDetectorConfig, "10.0.0.4")).To(ContainSubstring(
+			"ExecStart=/usr/local/bin/node-problem-detector --apiserver-override=https://10.0.0.4:443?inClusterConfig=false&auth=/var/lib/kubelet/kubeconfig " +
+				"--config.system-log-monitor=/etc/node-problem-detector.d/kernel-monitor.json,/etc/node-problem-detector.d/custom-config.json\n"))
+	})
+
+	It("should only monitor the kernel without a custom config", func() {
+		config := newConfig(&datamodel.NodeProblemDetectorConfig{Enabled: true})
+		Expect(validateNodeProblemDetectorConfig(config, onVHD)).To(Succeed())
+		Expect(getNodeProblemDetectorServiceContent(config.NodeProblemDetectorConfig, "10.0.0.4")).To(ContainSubstring(
+			"--config.system-log-monitor=/etc/node-problem-detector.d/kernel-monitor.json\n"))
+	})
+
+	It("should return an error for an invalid custom config", func() {
+		for _, customConfig := range []string{`{"plugin": "journald"`, `{"plugin": "journald", "source": "myservice-monitor"}`, `{"rules": [{}]}`} {
+			config := newConfig(&datamodel.NodeProblemDetectorConfig{Enabled: true, CustomConfig: []byte(customConfig)})
+			Expect(validateNodeProblemDetectorConfig(config, onVHD)).NotTo(Succeed())
+		}
+	})
+
+	It("should return an error for a custom config when node-problem-detector is disabled", func() {
+		config := newConfig(&datamodel.NodeProblemDetectorConfig{CustomConfig: []byte(`{}`)})
+		Expect(validateNodeProblemDetectorConfig(config, onVHD)).NotTo(Succeed())
+	})
+
+	It("should return an error when node-problem-detector is not cached on the VHD", func() {
+		config := newConfig(&datamodel.NodeProblemDetectorConfig{Enabled: true})
+		Expect(validateNodeProblemDetectorConfig(config, &cache.OnVHD{})).To(MatchError("node-problem-detector is not cached on the VHD"))
+	})
+})
+
+var _ = Describe("Test validateContainerdOOMScore", func() {
+	It("should succeed when the OOM score is unset or in range", func() {
+		Expect(validateContainerdOOMScore(&datamodel.NodeBootstrappingConfiguration{})).To(Succeed())
+		for _, score := range []int{-1000, -999, 0, 1000} {
+			Expect(validateContainerdOOMScore(&datamodel.NodeBootstrappingConfiguration{ContainerdOOMScore: to.IntPtr(score)})).To(Succeed())
+		}
+	})
+
+	It("should return an error for an OOM score out of range", func() {
+		Expect(validateContainerdOOMScore(&datamodel.NodeBootstrappingConfiguration{ContainerdOOMScore: to.IntPtr(-1001)})).NotTo(Succeed())
+		Expect(validateContainerdOOMScore(&datamodel.NodeBootstrappingConfiguration{ContainerdOOMScore: to.IntPtr(1001)})).NotTo(Succeed())
+	})
+
+	It("should render the OOM score into the containerd drop-in", func() {
+		Expect(getContainerdOOMScoreDropinContent(-999)).To(Equal("[Service]\nOOMScoreAdjust=-999\n"))
+	})
+})
+
+var _ = Describe("Test validateContainerdMaxConcurrentDownloads", func() {
+	It("should succeed when the max concurrent downloads are unset or in range", func() {
+		Expect(validateContainerdMaxConcurrentDownloads(&datamodel.NodeBootstrappingConfiguration{})).To(Succeed())
+		for _, downloads := range []int{1, 3, 32} {
+			config := &datamodel.NodeBootstrappingConfiguration{ContainerdMaxConcurrentDownloads: to.IntPtr(downloads)}
+			Expect(validateContainerdMaxConcurrentDownloads(config)).To(Succeed())
+		}
+	})
+
+	It("should return an error for max concurrent downloads out of range", func() {
+		for _, downloads := range []int{0, 33} {
+			config := &datamodel.NodeBootstrappingConfiguration{ContainerdMaxConcurrentDownloads: to.IntPtr(downloads)}
+			Expect(validateContainerdMaxConcurrentDownloads(config)).NotTo(Succeed())
+		}
+	})
+})
+
+var _ = Describe("Test validateAndSetMIGProfile", func() {
+	newConfig := func(vmSize, migProfile string) *datamodel.NodeBootstrappingConfiguration {
+		return &datamodel.NodeBootstrappingConfiguration{
+			AgentPoolProfile: &datamodel.AgentPoolProfile{VMSize: vmSize},
+			MIGProfile:       migProfile,
+		}
+	}
+
+	It("should set the GPU instance profile on a MIG capable SKU", func() {
+		config := newConfig("Standard_NC24ads_A100_v4", "MIG3g")
+		Expect(validateAndSetMIGProfile(config)).To(Succeed())
+		Expect(config.GPUInstanceProfile).To(Equal("MIG3g"))
+	})
+
+	It("should succeed without a MIG profile", func() {
+		Expect(validateAndSetMIGProfile(newConfig("Standard_D4s_v3", ""))).To(Succeed())
+	})
+
+	It("should keep a GPU instance profile without a MIG profile", func() {
+		config := newConfig("Standard_D4s_v3", "")
+		config.GPUInstanceProfile = "MIG1g"
+		Expect(validateAndSetMIGProfile(config)).To(Succeed())
+		Expect(config.GPUInstanceProfile).To(Equal("MIG1g"))
+	})
+
+	It("should return an error for an unknown MIG profile", func() {
+		Expect(validateAndSetMIGProfile(newConfig("Standard_NC24ads_A100_v4", "MIG5g"))).To(MatchError(ContainSubstring("unknown MIG profile")))
+	})
+
+	It("should return an error on a SKU which does not support MIG", func() {
+		Expect(validateAndSetMIGProfile(newConfig("Standard_NC6s_v3", "MIG1g"))).To(MatchError(ContainSubstring("does not support MIG")))
+	})
+
+	It("should return an error when the GPU instance profile conflicts", func() {
+		config := newConfig("Standard_NC24ads_A100_v4", "MIG3g")
+		config.GPUInstanceProfile = "MIG7g"
+		Expect(validateAndSetMIGProfile(config)).To(MatchError(ContainSubstring("conflicts")))
+	})
+})
+
+var _ = Describe("Test validateDefaultContainerdRuntime", func() {
+	newConfig := func(distro datamodel.Distro, defaultRuntime string) *datamodel.NodeBootstrappingConfiguration {
+		return &datamodel.NodeBootstrappingConfiguration{
+			AgentPoolProfile:         &datamodel.AgentPoolProfile{Distro: distro},
+			DefaultContainerdRuntime: defaultRuntime,
+		}
+	}
+
+	It("should succeed when the default runtime is unset or configured", func() {
+		Expect(validateDefaultContainerdRuntime(newConfig(datamodel.AKSUbuntuContainerd2204, ""))).To(Succeed())
+		Expect(validateDefaultContainerdRuntime(newConfig(datamodel.AKSUbuntuContainerd2204, "runc"))).To(Succeed())
+		Expect(validateDefaultContainerdRuntime(newConfig(datamodel.AKSAzureLinuxV2Gen2Kata, "kata"))).To(Succeed())
+	})
+
+	It("should return an error for a runtime handler which is not configured", func() {
+		err := validateDefaultContainerdRuntime(newConfig(datamodel.AKSUbuntuContainerd2204, "kata"))
+		Expect(err).To(MatchError(ContainSubstring(`default containerd runtime "kata" is not configured`)))
+		config := newConfig(datamodel.AKSUbuntuContainerd2204, "nvidia-container-runtime")
+		Expect(validateDefaultContainerdRuntime(config)).NotTo(Succeed())
+		config.EnableNvidia = true
+		Expect(validateDefaultContainerdRuntime(config)).To(Succeed())
+	})
+
+	It("should keep the default runtime of the template for runc and the nvidia runtime", func() {
+		Expect(getContainerdDefaultRuntime(newConfig(datamodel.AKSAzureLinuxV2Gen2Kata, "kata"), "runc")).To(Equal("kata"))
+		Expect(getContainerdDefaultRuntime(newConfig(datamodel.AKSUbuntuContainerd2204, "nvidia-container-runtime"), "runc")).To(Equal("runc"))
+		Expect(getContainerdDefaultRuntime(newConfig(datamodel.AKSUbuntuContainerd2204, ""), "nvidia-container-runtime")).
+			To(Equal("nvidia-container-runtime"))
+	})
+})
+
+var _ = Describe("Test validateKubeletStartupRetries", func() {
+	var config *datamodel.NodeBootstrappingConfiguration
+
+	BeforeEach(func() {
+		config = &datamodel.NodeBootstrappingConfiguration{
+			AgentPoolProfile: &datamodel.AgentPoolProfile{Distro: datamodel.AKSUbuntuContainerd2204},
+		}
+	})
+
+	It("should start kubelet once by default", func() {
+		Expect(validateKubeletStartupRetries(config)).To(Succeed())
+		Expect(getKubeletStartCommand(config.KubeletStartupRetries)).To(Equal("systemctl start kubelet"))
+	})
+
+	It("should retry starting kubelet with a backoff", func() {
+		config.KubeletStartupRetries = 3
+		Expect(validateKubeletStartupRetries(config)).To(Succeed())
+		Expect(getKubeletStartCommand(config.KubeletStartupRetries)).To(Equal("for i in $(seq 1 4); do systemctl start kubelet && sleep 10 && " +
+			"systemctl is-active --quiet kubelet && break; if [ $i -eq 4 ]; then exit $ERR_KUBELET_START_FAIL; fi; " +
+			"systemctl reset-failed kubelet; sleep $((i * 5)); done"))
+	})
+
+	It("should return an error for out of range retries", func() {
+		config.KubeletStartupRetries = -1
+		Expect(validateKubeletStartupRetries(config)).To(MatchError("kubelet startup retries must be between 0 and 10, got -1"))
+		config.KubeletStartupRetries = 11
+		Expect(validateKubeletStartupRetries(config)).NotTo(Succeed())
+	})
+
+	It("should return an error on Windows nodes", func() {
+		config.AgentPoolProfile = &datamodel.AgentPoolProfile{OSType: datamodel.Windows}
+		config.KubeletStartupRetries = 3
+		Expect(validateKubeletStartupRetries(config)).To(MatchError("kubelet startup retries are not supported on Windows nodes"))
+	})
+})
+
+var _ = Describe("Test validateAndSetControlPlaneReadyWait", func() {
+	var config *datamodel.NodeBootstrappingConfiguration
+
+	BeforeEach(func() {
+		config = &datamodel.NodeBootstrappingConfiguration{
+			ContainerService: &datamodel.ContainerService{
+				Properties: &datamodel.Properties{
+					HostedMasterProfile: &datamodel.HostedMasterProfile{FQDN: "cluster.hcp.eastus.azmk8s.io"},
+				},
+			},
+			AgentPoolProfile:             &datamodel.AgentPoolProfile{Distro: datamodel.AKSUbuntuContainerd2204},
+			EnableSecureTLSBootstrapping: true,
+			WaitForControlPlaneReady:     true,
+		}
+	})
+
+	It("should succeed when not waiting for the control plane", func() {
+		Expect(validateAndSetControlPlaneReadyWait(&datamodel.NodeBootstrappingConfiguration{})).To(Succeed())
+	})
+
+	It("should set the default timeout", func() {
+		Expect(validateAndSetControlPlaneReadyWait(config)).To(Succeed())
+		Expect(config.ControlPlaneReadyTimeoutSeconds).To(Equal(datamodel.DefaultControlPlaneReadyTimeoutSeconds))
+	})
+
+	It("should return an error without TLS bootstrapping", func() {
+		config.EnableSecureTLSBootstrapping = false
+		Expect(validateAndSetControlPlaneReadyWait(config)).To(MatchError(ContainSubstring("requires TLS bootstrapping")))
+	})
+
+	It("should return an error for a timeout out of range", func() {
+		config.ControlPlaneReadyTimeoutSeconds = datamodel.MaxControlPlaneReadyTimeoutSeconds + 1
+		Expect(validateAndSetControlPlaneReadyWait(config)).NotTo(Succeed())
+		config.ControlPlaneReadyTimeoutSeconds = -1
+		Expect(validateAndSetControlPlaneReadyWait(config)).NotTo(Succeed())
+	})
+
+	It("should return an error for a timeout without waiting for the control plane", func() {
+		config.WaitForControlPlaneReady = false
+		config.ControlPlaneReadyTimeoutSeconds = 60
+		Expect(validateAndSetControlPlaneReadyWait(config)).NotTo(Succeed())
+	})
+
+	It("should render the wait command polling the readyz endpoint", func() {
+		Expect(getControlPlaneReadyWaitCommand("10.0.0.1", 60)).To(Equal("timeout 60 sh -c 'until curl -sf --max-time 10 " +
+			"--cacert /etc/kubernetes/certs/ca.crt https://10.0.0.1:443/readyz >/dev/null; do sleep 5; done'"))
+	})
+})
+
+var _ = Describe("Test validateAndSetDisableSystemdUnits", func() {
+	newConfig := func(distro datamodel.Distro, units ...string) *datamodel.NodeBootstrappingConfiguration {
+		return &datamodel.NodeBootstrappingConfiguration{
+			AgentPoolProfile:    &datamodel.AgentPoolProfile{Distro: distro},
+			DisableSystemdUnits: units,
+		}
+	}
+
+	It("should add the service suffix and remove duplicates", func() {
+		config := newConfig(datamodel.AKSUbuntuContainerd2204, "snapd", "apt-daily.timer", "snapd.service", "getty@tty1")
+		Expect(validateAndSetDisableSystemdUnits(config)).To(Succeed())
+		Expect(config.DisableSystemdUnits).To(Equal([]string{"snapd.service", "apt-daily.timer", "getty@tty1.service"}))
+	})
+
+	It("should return an error for an invalid unit name", func() {
+		Expect(validateAndSetDisableSystemdUnits(newConfig(datamodel.AKSUbuntuContainerd2204, "snapd; reboot"))).
+			To(MatchError(ContainSubstring("invalid systemd unit name")))
+	})
+
+	It("should return an error for a unit managed by AgentBaker", func() {
+		Expect(validateAndSetDisableSystemdUnits(newConfig(datamodel.AKSUbuntuContainerd2204, "kubelet"))).
+			To(MatchError("systemd unit kubelet.service is managed by AgentBaker and can not be disabled"))
+		Expect(validateAndSetDisableSystemdUnits(newConfig(datamodel.AKSAzureLinuxV2Gen2, "containerd.service"))).NotTo(Succeed())
+		Expect(validateAndSetDisableSystemdUnits(newConfig(datamodel.AKSUbuntuContainerd2204, "snapshot-update.timer"))).NotTo(Succeed())
+	})
+
+	It("should list the managed units sorted", func() {
+		units := ManagedSystemdUnits()
+		Expect(units).To(ContainElements("kubelet.service", "containerd.service", "mig-partition.service"))
+		Expect(sort.StringsAreSorted(units)).To(BeTrue())
+		units[0] = "modified"
+		Expect(ManagedSystemdUnits()).NotTo(ContainElement("modified"))
+	})
+
+	It("should return an error on Windows", func() {
+		Expect(validateAndSetDisableSystemdUnits(newConfig(datamodel.AKSWindows2022Containerd, "snapd"))).NotTo(Succeed())
+	})
+
+	It("should warn about units which are not known to be on the distro", func() {
+		config := newConfig(datamodel.AKSAzureLinuxV2Gen2, "snapd", "dnf-automatic.timer")
+		Expect(validateAndSetDisableSystemdUnits(config)).To(Succeed())
+		Expect(getDisableSystemdUnitsWarnings(config)).To(Equal([]string{
+			"systemd unit snapd.service to disable is not known to be present on distro aks-azurelinux-v2-gen2",
+		}))
+		Expect(getDisableSystemdUnitsWarnings(newConfig(datamodel.CustomizedImage, "snapd.service"))).To(BeEmpty())
+	})
+})
+
+var _ = Describe("Test validateTrustedLaunch", func() {
+	newConfig := func(distro datamodel.Distro, vmSize string) *datamodel.NodeBootstrappingConfiguration {
+		return &datamodel.NodeBootstrappingConfiguration{
+			AgentPoolProfile: &datamodel.AgentPoolProfile{Distro: distro, VMSize: vmSize},
+			TrustedLaunch:    &datamodel.TrustedLaunch{SecureBoot: true, VTPM: true},
+		}
+	}
+
+	It("should succeed for a supported distro and VM size", func() {
+		Expect(validateTrustedLaunch(newConfig(datamodel.AKSUbuntuContainerd2204TLGen2, "Standard_D4s_v3"))).To(Succeed())
+	})
+
+	It("should succeed without trusted launch", func() {
+		config := newConfig(datamodel.AKSUbuntuContainerd2204, "Standard_A2_v2")
+		config.TrustedLaunch = nil
+		Expect(validateTrustedLaunch(config)).To(Succeed())
+	})
+
+	It("should return an error for a distro which does not support trusted launch", func() {
+		Expect(validateTrustedLaunch(newConfig(datamodel.AKSUbuntuContainerd2204, "Standard_D4s_v3"))).
+			To(MatchError(ContainSubstring("does not support trusted launch, a Gen 2 x86 distro is required")))
+	})
+
+	It("should return an error for a VM size which does not support trusted launch", func() {
+		Expect(validateTrustedLaunch(newConfig(datamodel.AKSUbuntuContainerd2204TLGen2, "Standard_A2_v2"))).
+			To(MatchError("VM size Standard_A2_v2 does not support trusted launch"))
+	})
+})
+
+var _ = Describe("Test validateHostnamePattern", func() {
+	var config *datamodel.NodeBootstrappingConfiguration
+
+	BeforeEach(func() {
+		profile := &datamodel.AgentPoolProfile{
+			Name:                "nodepool1",
+			AvailabilityProfile: datamodel.VirtualMachineScaleSets,
+			Distro:              datamodel.AKSUbuntuContainerd2204,
+		}
+		config = &datamodel.NodeBootstrappingConfiguration{
+			ContainerService: &datamodel.ContainerService{
+				Properties: &datamodel.Properties{
+					OrchestratorProfile: &datamodel.OrchestratorProfile{OrchestratorType: datamodel.Kubernetes},
+					HostedMasterProfile: &datamodel.HostedMasterProfile{DNSPrefix: "foo"},
+					AgentPoolProfiles:   []*datamodel.AgentPoolProfile{profile},
+				},
+			},
+			AgentPoolProfile: profile,
+			VMInstanceIndex:  3,
+		}
+	})
+
+	It("should succeed without a hostname pattern", func() {
+		Expect(validateHostnamePattern(config)).To(Succeed())
+	})
+
+	It("should succeed for a hostname pattern rendering a DNS label", func() {
+		config.HostnamePattern = "{poolName}-{instanceID}"
+		Expect(validateHostnamePattern(config)).To(Succeed())
+	})
+
+	It("should return an error for an unknown token", func() {
+		config.HostnamePattern = "{poolName}-{vmName}"
+		Expect(validateHostnamePattern(config)).To(MatchError(ContainSubstring("unknown token {vmName} in hostname pattern")))
+	})
+
+	It("should return an error for a VMSS hostname pattern without the instance ID", func() {
+		config.HostnamePattern = "{poolName}-{clusterID}"
+		Expect(validateHostnamePattern(config)).To(MatchError(ContainSubstring("of VMSS nodes must contain {instanceID}")))
+	})
+
+	It("should resolve the instance ID of VMSS nodes on the node", func() {
+		config.HostnamePattern = "K8s-{poolName}-{instanceID}"
+		Expect(validateHostnamePattern(config)).To(Succeed())
+		Expect(getSetHostnameCommand(config)).To(Equal("vm_name=$(curl -sf -H Metadata:true --noproxy '*' " +
+			"'http://169.254.169.254/metadata/instance/compute/name?api-version=2021-02-01&format=text') && " +
+			`instance_id=${vm_name##*_} && hostnamectl set-hostname "k8s-nodepool1-${instance_id}"`))
+	})
+
+	It("should render the hostname of availability set nodes", func() {
+		config.AgentPoolProfile.AvailabilityProfile = datamodel.AvailabilitySet
+		config.HostnamePattern = "{poolName}-{instanceID}"
+		Expect(validateHostnamePattern(config)).To(Succeed())
+		Expect(getSetHostnameCommand(config)).To(Equal("hostnamectl set-hostname nodepool1-3"))
+	})
+})
+
+var _ = Describe("Test validateCertificateProfile", func() {
+	newCertificatePEM := func(template, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) ([]byte, *ecdsa.PrivateKey) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		if parent == nil {
+			parent, parentKey = template, key
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+		Expect(err).NotTo(HaveOccurred())
+		return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), key
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kubernetes-ca"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	apiServerTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "apiserver"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	var config *datamodel.NodeBootstrappingConfiguration
+	var caCertPEM, apiServerCertPEM []byte
+
+	BeforeEach(func() {
+		var caKey *ecdsa.PrivateKey
+		caCertPEM, caKey = newCertificatePEM(caTemplate, nil, nil)
+		apiServerCertPEM, _ = newCertificatePEM(apiServerTemplate, caTemplate, caKey)
+		config = &datamodel.NodeBootstrappingConfiguration{
+			ContainerService: &datamodel.ContainerService{
+				Properties: &datamodel.Properties{
+					CertificateProfile: &datamodel.CertificateProfile{CaCertificate: string(caCertPEM)},
+				},
+			},
+		}
+	})
+
+	It("should accept the cluster CA and an API server certificate signed by it", func() {
+		Expect(validateCertificateProfile(config)).To(Succeed())
+		config.ContainerService.Properties.CertificateProfile.APIServerCertificate = string(apiServerCertPEM)
+		Expect(validateCertificateProfile(config)).To(Succeed())
+		Expect(validateCertificateProfile(&datamodel.NodeBootstrappingConfiguration{})).To(Succeed())
+	})
+
+	It("should return an error for a malformed cluster CA", func() {
+		config.ContainerService.Properties.CertificateProfile.CaCertificate = "fooBarBaz"
+		Expect(validateCertificateProfile(config)).To(MatchError(ContainSubstring("invalid cluster CA certificate")))
+		leafCertPEM, err := base64.StdEncoding.DecodeString(encodedTestCert)
+		Expect(err).NotTo(HaveOccurred())
+		config.ContainerService.Properties.CertificateProfile.CaCertificate = string(leafCertPEM)
+		Expect(validateCertificateProfile(config)).To(MatchError(ContainSubstring("is not a CA certificate")))
+	})
+
+	It("should return an error for an API server certificate signed by another CA", func() {
+		config.ContainerService.Properties.CertificateProfile.APIServerCertificate = string(apiServerCertPEM)
+		config.ContainerService.Properties.CertificateProfile.CaCertificate = testClusterCACert
+		Expect(validateCertificateProfile(config)).To(MatchError(ContainSubstring("does not chain to the CA certificate bundle")))
+	})
+
+	It("should return an error for an API server certificate without the cluster CA", func() {
+		config.ContainerService.Properties.CertificateProfile = &datamodel.CertificateProfile{APIServerCertificate: string(apiServerCertPEM)}
+		Expect(validateCertificateProfile(config)).To(MatchError("API server certificate requires the cluster CA certificate"))
+	})
+})
+
+var _ = Describe("Test validateJournaldConfig", func() {
+	newConfig := func(journaldConfig *datamodel.JournaldConfig) *datamodel.NodeBootstrappingConfiguration {
+		return &datamodel.NodeBootstrappingConfiguration{
+			AgentPoolProfile: &datamodel.AgentPoolProfile{Distro: datamodel.AKSUbuntuContainerd2204},
+			JournaldConfig:   journaldConfig,
+		}
+	}
+
+	It("should succeed when journald is not configured or the config is valid", func() {
+		Expect(validateJournaldConfig(newConfig(nil))).To(Succeed())
+		Expect(validateJournaldConfig(newConfig(&datamodel.JournaldConfig{}))).To(Succeed())
+		Expect(validateJournaldConfig(newConfig(&datamodel.JournaldConfig{
+			StorageMode: datamodel.JournaldStoragePersistent, MaxUseMB: 512, MaxRetentionDays: 7,
+		}))).To(Succeed())
+	})
+
+	It("should return an error for an unknown storage mode", func() {
+		Expect(validateJournaldConfig(newConfig(&datamodel.JournaldConfig{StorageMode: "disk"}))).
+			To(MatchError(ContainSubstring(`unknown journald storage mode "disk"`)))
+	})
+
+	It("should return an error for negative limits", func() {
+		Expect(validateJournaldConfig(newConfig(&datamodel.JournaldConfig{MaxUseMB: -1}))).NotTo(Succeed())
+		Expect(validateJournaldConfig(newConfig(&datamodel.JournaldConfig{MaxRetentionDays: -1}))).NotTo(Succeed())
+	})
+
+	It("should return an error on Windows", func() {
+		config := newConfig(&datamodel.JournaldConfig{StorageMode: datamodel.JournaldStorageVolatile})
+		config.AgentPoolProfile.Distro = datamodel.AKSWindows2022Containerd
+		Expect(validateJournaldConfig(config)).NotTo(Succeed())
+	})
+
+	It("should render the set settings into the drop-in", func() {
+		Expect(getJournaldConfigDropinContent(&datamodel.JournaldConfig{StorageMode: "persistent", MaxUseMB: 512, MaxRetentionDays: 7})).
+			To(Equal("[Journal]\nStorage=persistent\nSystemMaxUse=512M\nMaxRetentionSec=7day\n"))
+		Expect(getJournaldConfigDropinContent(&datamodel.JournaldConfig{MaxUseMB: 100})).To(Equal("[Journal]\nSystemMaxUse=100M\n"))
+	})
+})
+
+var _ = Describe("Test validateKubernetesVersion", func() {
+	newConfig := func(version string) *datamodel.NodeBootstrappingConfiguration {
+		return &datamodel.NodeBootstrappingConfiguration{
+			ContainerService: &datamodel.ContainerService{
+				Properties: &datamodel.Properties{
+					OrchestratorProfile: &datamodel.OrchestratorProfile{OrchestratorVersion: version},
+				},
+			},
+		}
+	}
+
+	It("should succeed for a supported version or without a version", func() {
+		Expect(validateKubernetesVersion(newConfig("1.29.2"))).To(Succeed())
+		Expect(validateKubernetesVersion(newConfig(""))).To(Succeed())
+	})
+
+	It("should return an error for a version which is not a semantic version", func() {
+		Expect(validateKubernetesVersion(newConfig("1.28"))).To(MatchError(ContainSubstring(`invalid Kubernetes version "1.28"`)))
+	})
+
+	It("should return an error for a version outside the supported window", func() {
+		Expect(validateKubernetesVersion(newConfig("1.14.0"))).
+			To(MatchError("Kubernetes version 1.14.0 is not supported, supported versions are 1.15 to latest"))
+	})
+})
+
+var _ = Describe("Test validateWindowsIsolationMode", func() {
+	newConfig := func(runtimes *datamodel.ContainerdWindowsRuntimes) *datamodel.NodeBootstrappingConfiguration {
+		return &datamodel.NodeBootstrappingConfiguration{
+			ContainerService: &datamodel.ContainerService{
+				Properties: &datamodel.Properties{
+					WindowsProfile: &datamodel.WindowsProfile{ContainerdWindowsRuntimes: runtimes},
+				},
+			},
+		}
+	}
+
+	It("should succeed for process isolation", func() {
+		Expect(validateWindowsIsolationMode(newConfig(nil))).To(Succeed())
+		Expect(validateWindowsIsolationMode(newConfig(&datamodel.ContainerdWindowsRuntimes{DefaultSandboxIsolation: "process"}))).To(Succeed())
+	})
+
+	It("should succeed for Hyper-V isolation with runtime handlers", func() {
+		Expect(validateWindowsIsolationMode(newConfig(&datamodel.ContainerdWindowsRuntimes{
+			DefaultSandboxIsolation: "hyperv",
+			RuntimeHandlers:         []datamodel.RuntimeHandlers{{BuildNumber: "17763"}, {BuildNumber: "20348"}},
+		}))).To(Succeed())
+	})
+
+	It("should return an error for Hyper-V isolation without runtime handlers", func() {
+		Expect(validateWindowsIsolationMode(newConfig(&datamodel.ContainerdWindowsRuntimes{DefaultSandboxIsolation: "hyperv"}))).
+			To(MatchError(ContainSubstring("requires the Hyper-V runtime handlers")))
+	})
+
+	It("should return an error for an unknown isolation mode or build number", func() {
+		Expect(validateWindowsIsolationMode(newConfig(&datamodel.ContainerdWindowsRuntimes{DefaultSandboxIsolation: "hyper-v"}))).
+			To(MatchError(`unknown windows isolation mode "hyper-v", must be one of process, hyperv`))
+		Expect(validateWindowsIsolationMode(newConfig(&datamodel.ContainerdWindowsRuntimes{
+			RuntimeHandlers: []datamodel.RuntimeHandlers{{BuildNumber: "ltsc2022"}},
+		}))).NotTo(Succeed())
+	})
+})
+
+var _ = Describe("Test validateAndSetNodeIP", func() {
+	newConfig := func(nodeIP string, dualStack bool) *datamodel.NodeBootstrappingConfiguration {
+		return &datamodel.NodeBootstrappingConfiguration{
+			ContainerService: &datamodel.ContainerService{
+				Properties: &datamodel.Properties{
+					FeatureFlags: &datamodel.FeatureFlags{EnableIPv6DualStack: dualStack},
+				},
+			},
+			NodeIP: nodeIP,
+		}
+	}
+
+	It("should not set the flag without a node IP", func() {
+		config := newConfig("", false)
+		Expect(validateAndSetNodeIP(config)).To(Succeed())
+		Expect(config.KubeletConfig).NotTo(HaveKey("--node-ip"))
+	})
+
+	It("should render the node IP into the kubelet flag", func() {
+		config := newConfig("10.240.0.4", false)
+		Expect(validateAndSetNodeIP(config)).To(Succeed())
+		Expect(config.KubeletConfig).To(HaveKeyWithValue("--node-ip", "10.240.0.4"))
+	})
+
+	It("should allow an IPv4 and IPv6 pair on dual-stack clusters", func() {
+		config := newConfig("10.240.0.4, fd00::4", true)
+		Expect(validateAndSetNodeIP(config)).To(Succeed())
+		Expect(config.KubeletConfig).To(HaveKeyWithValue("--node-ip", "10.240.0.4,fd00::4"))
+		Expect(validateAndSetNodeIP(newConfig("10.240.0.4,fd00::4", false))).To(MatchError(ContainSubstring("only dual-stack clusters")))
+		Expect(validateAndSetNodeIP(newConfig("10.240.0.4,10.240.0.5", true))).To(MatchError(ContainSubstring("an IPv4 and an IPv6 address")))
+	})
+
+	It("should return an error for an invalid IP", func() {
+		Expect(validateAndSetNodeIP(newConfig("10.240.0.256", false))).To(MatchError(ContainSubstring("is not an IP address")))
+	})
+})
+
+var _ = Describe("Test validateAndSetKubeletClientCACert", func() {
+	var caCertPEM []byte
+
+	BeforeEach(func() {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		template := &x509.Certificate{
+			SerialNumber:          big.NewInt(1),
+			Subject:               pkix.Name{CommonName: "kubelet-client-ca"},
+			NotBefore:             time.Now(),
+			NotAfter:              time.Now().Add(time.Hour),
+			IsCA:                  true,
+			BasicConstraintsValid: true,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+		Expect(err).NotTo(HaveOccurred())
+		caCertPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	})
+
+	It("should keep the cluster CA without a kubelet client CA", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			KubeletConfig: map[string]string{"--client-ca-file": "/etc/kubernetes/certs/ca.crt"},
+		}
+		Expect(validateAndSetKubeletClientCACert(config)).To(Succeed())
+		Expect(config.KubeletConfig).To(HaveKeyWithValue("--client-ca-file", "/etc/kubernetes/certs/ca.crt"))
+	})
+
+	It("should point the kubelet client CA file to the bundle", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			AgentPoolProfile:    &datamodel.AgentPoolProfile{Distro: datamodel.AKSUbuntuContainerd2204},
+			KubeletConfig:       map[string]string{"--client-ca-file": "/etc/kubernetes/certs/ca.crt"},
+			KubeletClientCACert: caCertPEM,
+		}
+		Expect(validateAndSetKubeletClientCACert(config)).To(Succeed())
+		Expect(config.KubeletConfig).To(HaveKeyWithValue("--client-ca-file", "/etc/kubernetes/certs/kubelet-client-ca.crt"))
+	})
+
+	It("should append the cluster CA to the bundle", func() {
+		clusterCAPEM := "-----BEGIN CERTIFICATE-----\ncluster-ca\n-----END CERTIFICATE-----"
+		config := &datamodel.NodeBootstrappingConfiguration{
+			ContainerService: &datamodel.ContainerService{
+				Properties: &datamodel.Properties{
+					CertificateProfile: &datamodel.CertificateProfile{CaCertificate: clusterCAPEM + "\n"},
+				},
+			},
+			KubeletClientCACert: caCertPEM,
+		}
+		Expect(getKubeletClientCABundle(config)).To(Equal(string(caCertPEM) + clusterCAPEM + "\n"))
+		config.KubeletClientCACert = []byte(getKubeletClientCABundle(config))
+		Expect(getKubeletClientCABundle(config)).To(Equal(string(caCertPEM) + clusterCAPEM + "\n"))
+	})
+
+	It("should return an error for a certificate which is not a CA", func() {
+		leafCertPEM, err := base64.StdEncoding.DecodeString(encodedTestCert)
+		Expect(err).NotTo(HaveOccurred())
+		config := &datamodel.NodeBootstrappingConfiguration{KubeletClientCACert: leafCertPEM}
+		Expect(validateAndSetKubeletClientCACert(config)).To(MatchError(ContainSubstring("is not a CA certificate")))
+	})
+
+	It("should return an error on Windows", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			AgentPoolProfile:    &datamodel.AgentPoolProfile{Distro: datamodel.AKSWindows2022Containerd},
+			KubeletClientCACert: caCertPEM,
+		}
+		Expect(validateAndSetKubeletClientCACert(config)).NotTo(Succeed())
+	})
+})
+
+var _ = Describe("Test validateAndSetCIDRs", func() {
+	newConfig := func(dualStack bool, serviceCIDR, podCIDR string) *datamodel.NodeBootstrappingConfiguration {
+		return &datamodel.NodeBootstrappingConfiguration{
+			ContainerService: &datamodel.ContainerService{
+				Properties: &datamodel.Properties{
+					OrchestratorProfile: &datamodel.OrchestratorProfile{
+						KubernetesConfig: &datamodel.KubernetesConfig{ServiceCIDR: serviceCIDR, ClusterSubnet: podCIDR},
+					},
+					FeatureFlags: &datamodel.FeatureFlags{},
+				},
+			},
+			DualStack: dualStack,
+		}
+	}
+
+	It("should not set the kube-proxy cluster CIDR on single-stack nodes", func() {
+		config := newConfig(false, "10.0.0.0/16", "10.244.0.0/16")
+		Expect(validateAndSetCIDRs(config)).To(Succeed())
+		Expect(config.KubeproxyConfig).NotTo(HaveKey("--cluster-cidr"))
+	})
+
+	It("should render the pod CIDR pair into the kube-proxy cluster CIDR on dual-stack nodes", func() {
+		config := newConfig(true, "10.0.0.0/16,fd12:3456:789a:1::/108", "10.244.0.0/16, fd12:3456:789a::/64")
+		Expect(validateAndSetCIDRs(config)).To(Succeed())
+		Expect(config.KubeproxyConfig).To(HaveKeyWithValue("--cluster-cidr", "10.244.0.0/16,fd12:3456:789a::/64"))
+	})
+
+	It("should treat the dual-stack feature flag as dual-stack", func() {
+		config := newConfig(false, "10.0.0.0/16", "10.244.0.0/16")
+		config.ContainerService.Properties.FeatureFlags.EnableIPv6DualStack = true
+		Expect(validateAndSetCIDRs(config)).To(MatchError(ContainSubstring("must be an IPv4 and an IPv6 CIDR")))
+	})
+
+	It("should accept a single-stack node subnet on dual-stack nodes", func() {
+		config := newConfig(true, "10.0.0.0/16,fd12:3456:789a:1::/108", "10.244.0.0/16,fd12:3456:789a::/64")
+		config.ContainerService.Properties.HostedMasterProfile = &datamodel.HostedMasterProfile{Subnet: "10.240.0.0/16"}
+		Expect(validateAndSetCIDRs(config)).To(Succeed())
+	})
+
+	It("should return an error for an inconsistent family order", func() {
+		config := newConfig(true, "10.0.0.0/16,fd12:3456:789a:1::/108", "fd12:3456:789a::/64,10.244.0.0/16")
+		Expect(validateAndSetCIDRs(config)).To(MatchError(ContainSubstring("different order than the service CIDR")))
+	})
+
+	It("should return an error for a node IP in a different family order than the service CIDR", func() {
+		config := newConfig(true, "10.0.0.0/16,fd12:3456:789a:1::/108", "10.244.0.0/16,fd12:3456:789a::/64")
+		config.NodeIP = "fd00::4,10.240.0.4"
+		Expect(validateAndSetNodeIP(config)).To(MatchError(ContainSubstring("different order than the service CIDR")))
+		config.NodeIP = "10.240.0.4,fd00::4"
+		Expect(validateAndSetNodeIP(config)).To(Succeed())
+	})
+})
+
+var _ = Describe("Test validateAndSetImagePulls", func() {
+	var config *datamodel.NodeBootstrappingConfiguration
+
+	BeforeEach(func() {
+		config = &datamodel.NodeBootstrappingConfiguration{
+			ContainerService: &datamodel.ContainerService{
+				Properties: &datamodel.Properties{
+					OrchestratorProfile: &datamodel.OrchestratorProfile{
+						OrchestratorType:    datamodel.Kubernetes,
+						OrchestratorVersion: "1.29.2",
+					},
+				},
+			},
+			AgentPoolProfile:        &datamodel.AgentPoolProfile{Distro: datamodel.AKSUbuntuContainerd2204},
+			EnableKubeletConfigFile: true,
+		}
+	})
+
+	It("should keep the kubelet default when unset", func() {
+		Expect(validateAndSetImagePulls(config)).To(Succeed())
+		Expect(config.KubeletConfig).NotTo(HaveKey("--serialize-image-pulls"))
+	})
+
+	It("should render parallel image pulls into the kubelet config", func() {
+		config.SerializeImagePulls = to.BoolPtr(false)
+		config.MaxParallelImagePulls = to.IntPtr(5)
+		Expect(validateAndSetImagePulls(config)).To(Succeed())
+		Expect(config.KubeletConfig).To(HaveKeyWithValue("--serialize-image-pulls", "false"))
+		Expect(config.KubeletConfig).To(HaveKeyWithValue("--max-parallel-image-pulls", "5"))
+	})
+
+	It("should return an error for max parallel image pulls with serialized pulls", func() {
+		config.MaxParallelImagePulls = to.IntPtr(5)
+		Expect(validateAndSetImagePulls(config)).To(MatchError("max parallel image pulls requires serialize image pulls to be false"))
+		config.SerializeImagePulls = to.BoolPtr(true)
+		Expect(validateAndSetImagePulls(config)).NotTo(Succeed())
+	})
+
+	It("should return an error for max parallel image pulls without the kubelet config file", func() {
+		config.EnableKubeletConfigFile = false
+		config.SerializeImagePulls = to.BoolPtr(false)
+		config.MaxParallelImagePulls = to.IntPtr(5)
+		Expect(validateAndSetImagePulls(config)).To(MatchError("max parallel image pulls requires the kubelet config file"))
+	})
+
+	It("should return an error for max parallel image pulls before kubernetes 1.27", func() {
+		config.ContainerService.Properties.OrchestratorProfile.OrchestratorVersion = "1.26.6"
+		config.SerializeImagePulls = to.BoolPtr(false)
+		config.MaxParallelImagePulls = to.IntPtr(5)
+		Expect(validateAndSetImagePulls(config)).To(MatchError(ContainSubstring("requires kubernetes 1.27.0 or later")))
+	})
+})
+
+var _ = Describe("Test validateAndSetGracefulNodeShutdown", func() {
+	var config *datamodel.NodeBootstrappingConfiguration
+
+	BeforeEach(func() {
+		config = &datamodel.NodeBootstrappingConfiguration{
+			ContainerService: &datamodel.ContainerService{
+				Properties: &datamodel.Properties{
+					OrchestratorProfile: &datamodel.OrchestratorProfile{
+						OrchestratorType:    datamodel.Kubernetes,
+						OrchestratorVersion: "1.29.2",
+					},
+				},
+			},
+			AgentPoolProfile:        &datamodel.AgentPoolProfile{Distro: datamodel.AKSUbuntuContainerd2204},
+			EnableKubeletConfigFile: true,
+			GracefulNodeShutdown: &datamodel.GracefulNodeShutdown{
+				Enabled:                true,
+				ShutdownGracePeriod:    "90s",
+				CriticalPodGracePeriod: "30s",
+			},
+		}
+	})
+
+	It("should keep the kubelet defaults when disabled", func() {
+		config.GracefulNodeShutdown.Enabled = false
+		Expect(validateAndSetGracefulNodeShutdown(config)).To(Succeed())
+		Expect(config.KubeletConfig).NotTo(HaveKey("--shutdown-grace-period"))
+		Expect(getGracefulNodeShutdownDropinContent(config)).To(BeEmpty())
+	})
+
+	It("should render the grace periods into the kubelet config and the logind drop-in", func() {
+		Expect(validateAndSetGracefulNodeShutdown(config)).To(Succeed())
+		Expect(config.KubeletConfig).To(HaveKeyWithValue("--shutdown-grace-period", "90s"))
+		Expect(config.KubeletConfig).To(HaveKeyWithValue("--shutdown-grace-period-critical-pods", "30s"))
+		Expect(getGracefulNodeShutdownDropinContent(config)).To(Equal("[Login]\nInhibitDelayMaxSec=90\n"))
+
+		kubeletConfig := getAKSKubeletConfiguration(config.KubeletConfig)
+		Expect(kubeletConfig.ShutdownGracePeriod).To(Equal(datamodel.Duration("90s")))
+		Expect(kubeletConfig.ShutdownGracePeriodCriticalPods).To(Equal(datamodel.Duration("30s")))
+	})
+
+	It("should default the critical pod grace period to zero", func() {
+		config.GracefulNodeShutdown.CriticalPodGracePeriod = ""
+		Expect(validateAndSetGracefulNodeShutdown(config)).To(Succeed())
+		Expect(config.KubeletConfig).To(HaveKeyWithValue("--shutdown-grace-period-critical-pods", "0s"))
+	})
+
+	It("should return an error for a critical pod grace period longer than the shutdown grace period", func() {
+		config.GracefulNodeShutdown.CriticalPodGracePeriod = "2m"
+		Expect(validateAndSetGracefulNodeShutdown(config)).To(MatchError(ContainSubstring("critical pod grace period must be between")))
+	})
+
+	It("should return an error for invalid grace periods", func() {
+		config.GracefulNodeShutdown.ShutdownGracePeriod = "0s"
+		Expect(validateAndSetGracefulNodeShutdown(config)).To(MatchError(ContainSubstring("must be a positive duration")))
+		config.GracefulNodeShutdown.ShutdownGracePeriod = "soon"
+		Expect(validateAndSetGracefulNodeShutdown(config)).To(MatchError(ContainSubstring("invalid shutdown grace period")))
+	})
+
+	It("should return an error without the kubelet config file", func() {
+		config.EnableKubeletConfigFile = false
+		Expect(validateAndSetGracefulNodeShutdown(config)).To(MatchError("graceful node shutdown requires the kubelet config file"))
+	})
+
+	It("should return an error before kubernetes 1.21", func() {
+		config.ContainerService.Properties.OrchestratorProfile.OrchestratorVersion = "1.20.15"
+		Expect(validateAndSetGracefulNodeShutdown(config)).To(MatchError(ContainSubstring("requires kubernetes 1.21.0 or later")))
+	})
+})
+
+var _ = Describe("Test validateUserAssignedIdentityIDs", func() {
+	const identityID = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg/providers/" +
+		"Microsoft.ManagedIdentity/userAssignedIdentities/identity"
+	var config *datamodel.NodeBootstrappingConfiguration
+
+	BeforeEach(func() {
+		config = &datamodel.NodeBootstrappingConfiguration{
+			ContainerService: &datamodel.ContainerService{
+				Properties: &datamodel.Properties{
+					OrchestratorProfile: &datamodel.OrchestratorProfile{
+						KubernetesConfig: &datamodel.KubernetesConfig{UseManagedIdentity: true, UserAssignedID: identityID},
+					},
+					AddonProfiles: map[string]datamodel.AddonProfile{
+						"omsagent": {Enabled: true, Identity: &datamodel.UserAssignedIdentity{ResourceID: identityID}},
+					},
+				},
+			},
+		}
+	})
+
+	It("should accept well-formed identity IDs", func() {
+		Expect(validateUserAssignedIdentityIDs(config)).To(Succeed())
+	})
+
+	It("should accept a kubelet identity given by name", func() {
+		config.ContainerService.Properties.OrchestratorProfile.KubernetesConfig.UserAssignedID = "identity"
+		Expect(validateUserAssignedIdentityIDs(config)).To(Succeed())
+	})
+
+	It("should return an error for a malformed kubelet identity ID", func() {
+		config.ContainerService.Properties.OrchestratorProfile.KubernetesConfig.UserAssignedID = "/subscriptions/sub/identity"
+		Expect(validateUserAssignedIdentityIDs(config)).To(MatchError(HavePrefix("invalid kubelet identity: ")))
+	})
+
+	It("should return an error for a malformed addon identity ID", func() {
+		config.ContainerService.Properties.AddonProfiles["omsagent"] = datamodel.AddonProfile{
+			Identity: &datamodel.UserAssignedIdentity{ResourceID: identityID + "/extra"},
+		}
+		Expect(validateUserAssignedIdentityIDs(config)).To(MatchError(HavePrefix("invalid identity of addon omsagent: ")))
+	})
+})
+
+var _ = Describe("Test validateAndSetCredentialProviders", func() {
+	var (
+		config *datamodel.NodeBootstrappingConfiguration
+		onVHD  *cache.OnVHD
+	)
+
+	BeforeEach(func() {
+		config = &datamodel.NodeBootstrappingConfiguration{
+			ContainerService: &datamodel.ContainerService{
+				Properties: &datamodel.Properties{
+					OrchestratorProfile: &datamodel.OrchestratorProfile{OrchestratorVersion: "1.29.2"},
+				},
+			},
+			CredentialProviders: []datamodel.CredentialProviderConfig{
+				{
+					Name:                 "acr-credential-provider",
+					MatchImages:          []string{"*.azurecr.io"},
+					DefaultCacheDuration: "10m",
+					Args:                 []string{"/etc/kubernetes/azure.json"},
+				},
+			},
+		}
+		onVHD = &cache.OnVHD{
+			FromComponentDownloadedFiles: map[string]cache.DownloadFile{
+				"cloud-provider-azure": {Versions: []string{"1.29.4"}},
+			},
+		}
+	})
+
+	It("should point the kubelet flags to the credential provider config", func() {
+		Expect(validateAndSetCredentialProviders(config, onVHD)).To(Succeed())
+		Expect(config.CredentialProviders[0].APIVersion).To(Equal(datamodel.DefaultCredentialProviderAPIVersion))
+		Expect(config.KubeletConfig).To(HaveKeyWithValue("--image-credential-provider-config", "/var/lib/kubelet/aks-credential-provider-config.json"))
+		Expect(config.KubeletConfig).To(HaveKeyWithValue("--image-credential-provider-bin-dir", "/var/lib/kubelet/credential-provider"))
+	})
+
+	It("should default the cache duration", func() {
+		config.CredentialProviders[0].DefaultCacheDuration = ""
+		Expect(validateAndSetCredentialProviders(config, onVHD)).To(Succeed())
+		Expect(config.CredentialProviders[0].DefaultCacheDuration).To(Equal(datamodel.DefaultCredentialProviderCacheDuration))
+	})
+
+	It("should return an error for a non-positive cache duration", func() {
+		config.CredentialProviders[0].DefaultCacheDuration = "0s"
+		Expect(validateAndSetCredentialProviders(config, onVHD)).To(MatchError(ContainSubstring("must be positive")))
+	})
+
+	It("should return an error with the out-of-tree credential provider", func() {
+		config.K8sComponents = &datamodel.K8sComponents{LinuxCredentialProviderURL: "https://acs-mirror.azureedge.net/cloud-provider-azure/v1.29.4/binaries/azure-acr-credential-provider-linux-amd64-v1.29.4.tar.gz"}
+		Expect(validateAndSetCredentialProviders(config, onVHD)).To(MatchError(ContainSubstring("out-of-tree credential provider")))
+		config.K8sComponents = nil
+		config.KubeletConfig = map[string]string{"--image-credential-provider-config": "/var/lib/kubelet/credential-provider-config.yaml"}
+		Expect(validateAndSetCredentialProviders(config, onVHD)).To(MatchError(ContainSubstring("--image-credential-provider-config")))
+	})
+
+	It("should render the kubelet credential provider config", func() {
+		Expect(validateAndSetCredentialProviders(config, onVHD)).To(Succeed())
+		content, err := getCredentialProviderConfigContent(config.CredentialProviders)
+		Expect(err).NotTo(HaveOccurred())
+		var providerConfig map[string]interface{}
+		Expect(json.Unmarshal([]byte(content), &providerConfig)).To(Succeed())
+		Expect(providerConfig).To(HaveKeyWithValue("kind", "CredentialProviderConfig"))
+		Expect(providerConfig["providers"]).To(ConsistOf(HaveKeyWithValue("name", "acr-credential-provider")))
+	})
+
+	It("should return an error when the provider binary is not cached on the VHD", func() {
+		onVHD.FromComponentDownloadedFiles = nil
+		Expect(validateAndSetCredentialProviders(config, onVHD)).
+			To(MatchError("credential provider acr-credential-provider is not cached on the VHD"))
+	})
+
+	It("should return an error for an invalid provider", func() {
+		config.CredentialProviders[0].MatchImages = nil
+		Expect(validateAndSetCredentialProviders(config, onVHD)).To(MatchError(ContainSubstring("must match at least one image")))
+		config.CredentialProviders[0].MatchImages = []string{"*.azurecr.io"}
+		config.CredentialProviders[0].Name = "../acr-credential-provider"
+		Expect(validateAndSetCredentialProviders(config, onVHD)).To(MatchError(ContainSubstring("invalid credential provider name")))
+		config.CredentialProviders[0].Name = "acr-credential-provider"
+		config.CredentialProviders[0].APIVersion = "credentialprovider.kubelet.k8s.io/v1alpha1"
+		Expect(validateAndSetCredentialProviders(config, onVHD)).To(MatchError(ContainSubstring("unsupported API version")))
+	})
+
+	It("should return an error before kubernetes 1.26", func() {
+		config.ContainerService.Properties.OrchestratorProfile.OrchestratorVersion = "1.25.6"
+		Expect(validateAndSetCredentialProviders(config, onVHD)).To(MatchError(ContainSubstring("require kubernetes 1.26.0 or later")))
+	})
+})
+
+var _ = Describe("Test validateAndSetNvidiaRuntimeConfig", func() {
+	var (
+		config *datamodel.NodeBootstrappingConfiguration
+		onVHD  *cache.OnVHD
+	)
+
+	BeforeEach(func() {
+		config = &datamodel.NodeBootstrappingConfiguration{
+			AgentPoolProfile:    &datamodel.AgentPoolProfile{VMSize: "Standard_NC24ads_A100_v4"},
+			NvidiaRuntimeConfig: &datamodel.NvidiaRuntimeConfig{Enabled: true},
+		}
+		onVHD = &cache.OnVHD{
+			FromManifest: &cache.Manifest{NvidiaContainerRuntime: cache.Dependency{Versions: []string{"1.14.1"}}},
+		}
+	})
+
+	It("should register the nvidia runtime handler", func() {
+		Expect(validateAndSetNvidiaRuntimeConfig(config, onVHD)).To(Succeed())
+		Expect(config.DefaultContainerdRuntime).To(BeEmpty())
+		Expect(getContainerdRuntimeHandlers(config)).To(ContainElement("nvidia"))
+	})
+
+	It("should make the nvidia runtime the default containerd runtime", func() {
+		config.NvidiaRuntimeConfig.DefaultRuntime = true
+		Expect(validateAndSetNvidiaRuntimeConfig(config, onVHD)).To(Succeed())
+		Expect(config.DefaultContainerdRuntime).To(Equal("nvidia"))
+		Expect(validateDefaultContainerdRuntime(config)).To(Succeed())
+		Expect(getContainerdDefaultRuntime(config, "runc")).To(Equal("nvidia"))
+	})
+
+	It("should return an error on a non GPU SKU", func() {
+		config.AgentPoolProfile.VMSize = "Standard_D4s_v3"
+		Expect(validateAndSetNvidiaRuntimeConfig(config, onVHD)).To(MatchError(ContainSubstring("not an NVIDIA GPU SKU")))
+	})
+
+	It("should return an error when nvidia-container-runtime is not cached on the VHD", func() {
+		onVHD.FromManifest = nil
+		Expect(validateAndSetNvidiaRuntimeConfig(config, onVHD)).
+			To(MatchError("the nvidia runtime requires nvidia-container-runtime, which is not cached on the VHD"))
+	})
+
+	It("should return an error for a conflicting default containerd runtime", func() {
+		config.NvidiaRuntimeConfig.DefaultRuntime = true
+		config.DefaultContainerdRuntime = "runc"
+		Expect(validateAndSetNvidiaRuntimeConfig(config, onVHD)).To(MatchError(ContainSubstring("conflicts with default containerd runtime")))
+	})
+
+	It("should return an error for a disabled default runtime", func() {
+		config.NvidiaRuntimeConfig = &datamodel.NvidiaRuntimeConfig{DefaultRuntime: true}
+		Expect(validateAndSetNvidiaRuntimeConfig(config, onVHD)).To(MatchError(ContainSubstring("must be enabled")))
+	})
+})
+
+var _ = Describe("Test validateCustomDataSize", func() {
+	It("should return an error when the custom data exceeds the limit of the OS", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			AgentPoolProfile: &datamodel.AgentPoolProfile{OSType: datamodel.Windows},
+		}
+		nodeBootstrapping := &datamodel.NodeBootstrapping{CustomData: strings.Repeat("A", datamodel.MaxWindowsCustomDataSize)}
+		Expect(validateCustomDataSize(config, nodeBootstrapping)).To(Succeed())
+
+		nodeBootstrapping.CustomData += "AAAA"
+		err := validateCustomDataSize(config, nodeBootstrapping)
+		Expect(errors.Is(err, datamodel.ErrCustomDataTooLarge)).To(BeTrue())
+		var tooLargeErr *datamodel.CustomDataTooLargeError
+		Expect(errors.As(err, &tooLargeErr)).To(BeTrue())
+		Expect(tooLargeErr.OSType).To(Equal(datamodel.Windows))
+		Expect(tooLargeErr.Size).To(Equal(datamodel.MaxWindowsCustomDataSize + 4))
+	})
+})
+
+var _ = Describe("Test validateAndSetOSDiskType", func() {
+	var config *datamodel.NodeBootstrappingConfiguration
+
+	BeforeEach(func() {
+		config = &datamodel.NodeBootstrappingConfiguration{
+			AgentPoolProfile: &datamodel.AgentPoolProfile{VMSize: "Standard_D4ds_v5"},
+			OSDiskType:       datamodel.OSDiskTypeEphemeral,
+		}
+	})
+
+	It("should default to a managed OS disk", func() {
+		config.OSDiskType = ""
+		Expect(validateAndSetOSDiskType(config)).To(Succeed())
+		Expect(config.OSDiskType).To(Equal(datamodel.OSDiskTypeManaged))
+		Expect(config.KubeletConfig).NotTo(HaveKey("--root-dir"))
+	})
+
+	It("should move the kubelet root dir of an ephemeral OS disk node to the ephemeral mount", func() {
+		Expect(validateAndSetOSDiskType(config)).To(Succeed())
+		Expect(config.KubeletConfig).To(HaveKeyWithValue("--root-dir", "/mnt/aks/ephemeral/kubelet"))
+	})
+
+	It("should keep a kubelet root dir set explicitly", func() {
+		config.KubeletConfig = map[string]string{"--root-dir": "/var/lib/kubelet"}
+		Expect(validateAndSetOSDiskType(config)).To(Succeed())
+		Expect(config.KubeletConfig).To(HaveKeyWithValue("--root-dir", "/var/lib/kubelet"))
+	})
+
+	It("should return an error for a VM size without a local disk", func() {
+		config.AgentPoolProfile.VMSize = "Standard_D4s_v5"
+		Expect(validateAndSetOSDiskType(config)).
+			To(MatchError("VM size Standard_D4s_v5 does not support ephemeral OS disks, it has no local disk"))
+	})
+
+	It("should return an error for a temporary kubelet disk", func() {
+		config.AgentPoolProfile.KubeletDiskType = datamodel.TempDisk
+		Expect(validateAndSetOSDiskType(config)).To(MatchError(ContainSubstring("can not be combined with an ephemeral OS disk")))
+	})
+
+	It("should return an error for an unknown OS disk type", func() {
+		config.OSDiskType = "Ultra"
+		Expect(validateAndSetOSDiskType(config)).To(MatchError(ContainSubstring(`unknown OS disk type "Ultra"`)))
+	})
+})
+
+var _ = Describe("Test validatePackageVersionOverrides", func() {
+	var onVHD *cache.OnVHD
+
+	BeforeEach(func() {
+		onVHD = &cache.OnVHD{
+			FromComponentDownloadedFiles: map[string]cache.DownloadFile{
+				"cni-plugins": {Versions: []string{"1.4.1"}},
+				"azure-cni":   {Versions: []string{"1.5.28"}},
+			},
+		}
+	})
+
+	It("should accept package versions cached on the VHD", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			PackageVersionOverrides: map[string]string{"cni-plugins": "1.4.1", "azure-cni": "1.5.28"},
+		}
+		Expect(validatePackageVersionOverrides(config, onVHD)).To(Succeed())
+		Expect(getPackageVersionOverridesContent(config.PackageVersionOverrides)).To(Equal("azure-cni=1.5.28,cni-plugins=1.4.1"))
+	})
+
+	It("should return an error for a package version which is not cached on the VHD", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			PackageVersionOverrides: map[string]string{"cni-plugins": "1.4.1", "azure-cni": "1.6.0"},
+		}
+		Expect(validatePackageVersionOverrides(config, onVHD)).To(MatchError("package azure-cni version 1.6.0 is not cached on the VHD"))
+	})
+})
+
+var _ = Describe("Test validateAndSetKubeProxyMode", func() {
+	var config *datamodel.NodeBootstrappingConfiguration
+
+	BeforeEach(func() {
+		config = &datamodel.NodeBootstrappingConfiguration{
+			ContainerService: &datamodel.ContainerService{
+				Properties: &datamodel.Properties{
+					OrchestratorProfile: &datamodel.OrchestratorProfile{OrchestratorVersion: "1.29.2"},
+				},
+			},
+			AgentPoolProfile: &datamodel.AgentPoolProfile{Distro: datamodel.AKSUbuntuContainerd2204Gen2},
+			KubeProxyMode:    datamodel.KubeProxyModeIPVS,
+		}
+	})
+
+	It("should set the proxy mode and IPVS scheduler in the kube-proxy config", func() {
+		config.KubeProxyIPVSScheduler = "lc"
+		Expect(validateAndSetKubeProxyMode(config)).To(Succeed())
+		Expect(config.KubeproxyConfig).To(HaveKeyWithValue("--proxy-mode", "ipvs"))
+		Expect(config.KubeproxyConfig).To(HaveKeyWithValue("--ipvs-scheduler", "lc"))
+	})
+
+	It("should return an error for nftables before kubernetes 1.31", func() {
+		config.KubeProxyMode = datamodel.KubeProxyModeNFTables
+		Expect(validateAndSetKubeProxyMode(config)).To(MatchError("kube-proxy mode nftables requires kubernetes 1.31.0 or later, got 1.29.2"))
+		config.ContainerService.Properties.OrchestratorProfile.OrchestratorVersion = "1.31.1"
+		Expect(validateAndSetKubeProxyMode(config)).To(Succeed())
+	})
+
+	It("should return an error for an IPVS scheduler without the IPVS mode", func() {
+		config.KubeProxyMode = datamodel.KubeProxyModeIPTables
+		config.KubeProxyIPVSScheduler = "rr"
+		Expect(validateAndSetKubeProxyMode(config)).To(MatchError(ContainSubstring("requires kube-proxy mode ipvs")))
+	})
+
+	It("should return an error for an unknown IPVS scheduler", func() {
+		config.KubeProxyIPVSScheduler = "fifo"
+		Expect(validateAndSetKubeProxyMode(config)).To(MatchError(ContainSubstring(`unknown kube-proxy IPVS scheduler "fifo"`)))
+	})
+
+	It("should return an error for a conflicting --proxy-mode kube-proxy flag", func() {
+		config.KubeproxyConfig = map[string]string{"--proxy-mode": "iptables"}
+		Expect(validateAndSetKubeProxyMode(config)).To(MatchError(ContainSubstring("conflicts with the --proxy-mode kube-proxy flag")))
+	})
+
+	It("should return an error on Windows nodes", func() {
+		config.AgentPoolProfile = &datamodel.AgentPoolProfile{OSType: datamodel.Windows}
+		Expect(validateAndSetKubeProxyMode(config)).To(MatchError("kube-proxy mode is not supported on Windows nodes"))
+	})
+})
+
+var _ = Describe("Test validateGUIDs", func() {
+	It("should succeed for GUIDs and unset IDs", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			TenantID:       "72f988bf-86f1-41af-91ab-2d7cd011db47",
+			SubscriptionID: "8ecadfc9-d1a3-4ea4-b844-0d9f87e4d7c8",
+		}
+		Expect(validateGUIDs(config)).To(Succeed())
+	})
+
+	It("should return an error naming the malformed field", func() {
+		config := &datamodel.NodeBootstrappingConfiguration{
+			TenantID:                     "72f988bf-86f1-41af-91ab-2d7cd011db47",
+			UserAssignedIdentityClientID: "userAssignedID",
+		}
+		Expect(validateGUIDs(config)).To(MatchError(
+			"invalid user-assigned identity client ID: 'userAssignedID' is not a GUID of the form 00000000-0000-0000-0000-000000000000"))
+
+		config.UserAssignedIdentityClientID = ""
+		config.ArcConfig = &datamodel.ArcConfig{TenantID: "tenantID"}
+		Expect(validateGUIDs(config)).To(MatchError(ContainSubstring("invalid arc tenant ID")))
+	})
+})
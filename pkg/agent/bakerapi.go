@@ -6,6 +6,7 @@ package agent
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/Azure/agentbaker/pkg/agent/datamodel"
 	"github.com/Azure/agentbaker/pkg/agent/toggles"
@@ -15,8 +16,11 @@ import (
 //nolint:revive // Name does not need to be modified to baker
 type AgentBaker interface {
 	GetNodeBootstrapping(ctx context.Context, config *datamodel.NodeBootstrappingConfiguration) (*datamodel.NodeBootstrapping, error)
+	GetNodeBootstrappingWithResolution(ctx context.Context,
+		config *datamodel.NodeBootstrappingConfiguration) (*datamodel.NodeBootstrapping, *datamodel.ImageResolution, error)
 	GetLatestSigImageConfig(sigConfig datamodel.SIGConfig, distro datamodel.Distro, envInfo *datamodel.EnvironmentInfo) (*datamodel.SigImageConfig, error)
 	GetDistroSigImageConfig(sigConfig datamodel.SIGConfig, envInfo *datamodel.EnvironmentInfo) (map[datamodel.Distro]datamodel.SigImageConfig, error)
+	GetDistroSigImageConfigAllRegions(sigConfig datamodel.SIGConfig, distro datamodel.Distro) (map[string]datamodel.SigImageConfig, error)
 	GetCachedVersionsOnVHD() *cache.OnVHD
 }
 
@@ -26,11 +30,31 @@ type agentBakerImpl struct {
 
 var _ AgentBaker = (*agentBakerImpl)(nil)
 
+// AgentBakerOption configures the AgentBaker created by NewAgentBaker.
+type AgentBakerOption func(agentBaker *agentBakerImpl) error
+
+// WithValidatedToggles makes NewAgentBaker validate the toggles before using them.
+func WithValidatedToggles(t *toggles.Toggles) AgentBakerOption {
+	return func(agentBaker *agentBakerImpl) error {
+		if err := t.Validate(); err != nil {
+			return fmt.Errorf("invalid toggles: %w", err)
+		}
+		agentBaker.toggles = t
+		return nil
+	}
+}
+
 //nolint:revive // fine to return unexported type due to interface usage
-func NewAgentBaker() (*agentBakerImpl, error) {
-	return &agentBakerImpl{
+func NewAgentBaker(opts ...AgentBakerOption) (*agentBakerImpl, error) {
+	agentBaker := &agentBakerImpl{
 		toggles: toggles.New(),
-	}, nil
+	}
+	for _, opt := range opts {
+		if err := opt(agentBaker); err != nil {
+			return nil, err
+		}
+	}
+	return agentBaker, nil
 }
 
 func (agentBaker *agentBakerImpl) WithToggles(toggles *toggles.Toggles) *agentBakerImpl {
@@ -38,29 +62,85 @@ func (agentBaker *agentBakerImpl) WithToggles(toggles *toggles.Toggles) *agentBa
 	return agentBaker
 }
 
-//nolint:revive, nolintlint // ctx is not used, but may be in the future
 func (agentBaker *agentBakerImpl) GetNodeBootstrapping(ctx context.Context, config *datamodel.NodeBootstrappingConfiguration) (*datamodel.NodeBootstrapping, error) {
+	nodeBootstrapping, _, err := agentBaker.GetNodeBootstrappingWithResolution(ctx, config)
+	return nodeBootstrapping, err
+}
+
+// GetNodeBootstrappingWithResolution is GetNodeBootstrapping which also explains how the node image was resolved: from
+// the shared image gallery, from the OS images or not at all, for which distro and whether its version was overridden.
+//
+//nolint:revive, nolintlint // ctx is not used, but may be in the future
+func (agentBaker *agentBakerImpl) GetNodeBootstrappingWithResolution(ctx context.Context,
+	config *datamodel.NodeBootstrappingConfiguration) (*datamodel.NodeBootstrapping, *datamodel.ImageResolution, error) {
+	if !config.AgentPoolProfile.IsWindows() {
+		// handle containerd config template version toggle/override
+		e := toggles.NewEntityFromNodeBootstrappingConfiguration(config)
+		if version, ok := agentBaker.toggles.GetContainerdConfigTemplateVersion(e); ok {
+			config.ContainerdConfigTemplateVersion = version
+		}
+		// handle CNI plugin version toggle/override
+		if version, ok := agentBaker.toggles.GetCNIPluginVersion(e); ok {
+			config.CNIPluginVersion = version
+		}
+		// handle staged package versions, which take precedence over the versions of the config.
+		for name, version := range agentBaker.toggles.GetPackageVersionOverrides(e) {
+			if config.PackageVersionOverrides == nil {
+				config.PackageVersionOverrides = make(map[string]string)
+			}
+			config.PackageVersionOverrides[name] = version
+		}
+	}
+
 	// validate and fix input before passing config to the template generator.
+	var err error
 	if config.AgentPoolProfile.IsWindows() {
-		validateAndSetWindowsNodeBootstrappingConfiguration(config)
+		err = validateAndSetWindowsNodeBootstrappingConfiguration(config)
 	} else {
-		validateAndSetLinuxNodeBootstrappingConfiguration(config)
+		err = validateAndSetLinuxNodeBootstrappingConfiguration(config)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	featureGates, err := config.ResolvedFeatureGates()
+	if err != nil {
+		return nil, nil, err
 	}
 
+	distro := config.AgentPoolProfile.Distro
+	var warnings []string
+	if message, deprecated := datamodel.DeprecatedDistros()[distro]; deprecated {
+		deprecatedErr := &datamodel.DeprecatedDistroError{Distro: distro, Message: message}
+		if config.RejectDeprecatedDistro {
+			return nil, nil, deprecatedErr
+		}
+		warnings = append(warnings, deprecatedErr.Error())
+	}
+	warnings = append(warnings, config.RemovedFeatureGateWarnings()...)
+	warnings = append(warnings, getDisableSystemdUnitsWarnings(config)...)
+	warnings = append(warnings, getBootCmdsWarnings(config)...)
+
 	templateGenerator := InitializeTemplateGenerator()
 	nodeBootstrapping := &datamodel.NodeBootstrapping{
-		CustomData: templateGenerator.getNodeBootstrappingPayload(config),
-		CSE:        templateGenerator.getNodeBootstrappingCmd(config),
+		CustomData:   templateGenerator.getNodeBootstrappingPayload(config),
+		CSE:          templateGenerator.getNodeBootstrappingCmd(config),
+		FeatureGates: featureGates,
+		Warnings:     warnings,
+	}
+	if err = validateCustomDataSize(config, nodeBootstrapping); err != nil {
+		return nil, nil, err
 	}
 
-	distro := config.AgentPoolProfile.Distro
-	if distro == datamodel.CustomizedWindowsOSImage || distro == datamodel.CustomizedImage || distro == datamodel.CustomizedImageKata {
-		return nodeBootstrapping, nil
+	if !needsImageResolution(config) {
+		resolution := &datamodel.ImageResolution{Source: datamodel.ImageSourceOverride, Distro: distro}
+		nodeBootstrapping.Manifest = getProvisioningManifest(config, nodeBootstrapping)
+		return nodeBootstrapping, resolution, nil
 	}
 
 	osImageConfigMap, hasCloud := datamodel.AzureCloudToOSImageMap[config.CloudSpecConfig.CloudName]
 	if !hasCloud {
-		return nil, fmt.Errorf("don't have settings for cloud %s", config.CloudSpecConfig.CloudName)
+		return nil, nil, &datamodel.CloudNotFoundError{CloudName: config.CloudSpecConfig.CloudName}
 	}
 
 	if osImageConfig, hasImage := osImageConfigMap[distro]; hasImage {
@@ -69,24 +149,60 @@ func (agentBaker *agentBakerImpl) GetNodeBootstrapping(ctx context.Context, conf
 
 	sigAzureEnvironmentSpecConfig, err := datamodel.GetSIGAzureCloudSpecConfig(config.SIGConfig, config.ContainerService.Location)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	nodeBootstrapping.SigImageConfig = findSIGImageConfig(sigAzureEnvironmentSpecConfig, distro)
 	if nodeBootstrapping.SigImageConfig == nil && nodeBootstrapping.OSImageConfig == nil {
-		return nil, fmt.Errorf("can't find image for distro %s", distro)
+		return nil, nil, fmt.Errorf("can't find image for distro %s", distro)
 	}
 
-	if !config.AgentPoolProfile.IsWindows() {
-		// handle node image version toggle/override
-		e := toggles.NewEntityFromNodeBootstrappingConfiguration(config)
-		imageVersionOverrides := agentBaker.toggles.GetLinuxNodeImageVersion(e)
-		if imageVersion, ok := imageVersionOverrides[string(distro)]; ok {
-			nodeBootstrapping.SigImageConfig.Version = imageVersion
+	resolution := &datamodel.ImageResolution{Source: datamodel.ImageSourceOSImage, Distro: distro}
+	if nodeBootstrapping.SigImageConfig == nil {
+		resolution.Version = nodeBootstrapping.OSImageConfig.ImageVersion
+	} else {
+		resolution.Source = datamodel.ImageSourceSIG
+		if !config.AgentPoolProfile.IsWindows() {
+			// handle node image version toggle/override
+			e := toggles.NewEntityFromNodeBootstrappingConfiguration(config)
+			imageVersionOverrides := agentBaker.toggles.GetLinuxNodeImageVersion(e)
+			if imageVersion, ok := imageVersionOverrides[string(distro)]; ok {
+				nodeBootstrapping.SigImageConfig.Version = imageVersion
+				resolution.VersionOverridden = true
+			}
 		}
+		resolution.Version = nodeBootstrapping.SigImageConfig.Version
 	}
 
-	return nodeBootstrapping, nil
+	nodeBootstrapping.Manifest = getProvisioningManifest(config, nodeBootstrapping)
+	return nodeBootstrapping, resolution, nil
+}
+
+// needsImageResolution returns true if the node image has to be resolved against the cloud and region of the node.
+func needsImageResolution(config *datamodel.NodeBootstrappingConfiguration) bool {
+	distro := config.AgentPoolProfile.Distro
+	if distro == datamodel.CustomizedWindowsOSImage || distro == datamodel.CustomizedImage || distro == datamodel.CustomizedImageKata {
+		return false
+	}
+	// Arc-connected nodes are not provisioned from an AKS image.
+	return !config.IsArcEnabled()
+}
+
+/*
+IsOfflineRenderable returns whether the node bootstrapping of the config can be generated without resolving
+anything against Azure. If it can't, the fields driving the cloud and region specific lookups are returned.
+*/
+func IsOfflineRenderable(config *datamodel.NodeBootstrappingConfiguration) (bool, []string) {
+	if config == nil || config.AgentPoolProfile == nil || !needsImageResolution(config) {
+		return true, nil
+	}
+	// the OS image is looked up by cloud, and the SIG image by gallery and region.
+	return false, []string{
+		"AgentPoolProfile.Distro",
+		"CloudSpecConfig.CloudName",
+		"ContainerService.Location",
+		"SIGConfig",
+	}
 }
 
 func (agentBaker *agentBakerImpl) GetLatestSigImageConfig(sigConfig datamodel.SIGConfig,
@@ -111,6 +227,28 @@ func (agentBaker *agentBakerImpl) GetLatestSigImageConfig(sigConfig datamodel.SI
 	return sigImageConfig, nil
 }
 
+/*
+GetDistroSigImageConfigAllRegions returns the SIG image config of the distro in every known region, keyed by region.
+Regions where the distro is unavailable are named in the returned error, along with the configs of the other regions.
+*/
+func (agentBaker *agentBakerImpl) GetDistroSigImageConfigAllRegions(
+	sigConfig datamodel.SIGConfig, distro datamodel.Distro) (map[string]datamodel.SigImageConfig, error) {
+	configs := map[string]datamodel.SigImageConfig{}
+	unavailable := []string{}
+	for _, region := range datamodel.AzureRegions {
+		sigImageConfig, err := agentBaker.GetLatestSigImageConfig(sigConfig, distro, &datamodel.EnvironmentInfo{Region: region})
+		if err != nil {
+			unavailable = append(unavailable, region)
+			continue
+		}
+		configs[region] = *sigImageConfig
+	}
+	if len(unavailable) > 0 {
+		return configs, fmt.Errorf("distro %s is unavailable in regions: %s", distro, strings.Join(unavailable, ", "))
+	}
+	return configs, nil
+}
+
 func (agentBaker *agentBakerImpl) GetDistroSigImageConfig(
 	sigConfig datamodel.SIGConfig, envInfo *datamodel.EnvironmentInfo) (map[datamodel.Distro]datamodel.SigImageConfig, error) {
 	allAzureSigConfig, err := datamodel.GetSIGAzureCloudSpecConfig(sigConfig, envInfo.Region)
@@ -2,9 +2,11 @@ package agent
 
 import (
 	"context"
+	"errors"
 
 	"github.com/Azure/agentbaker/pkg/agent/datamodel"
 	agenttoggles "github.com/Azure/agentbaker/pkg/agent/toggles"
+	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/barkimedes/go-deepcopy"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -54,7 +56,7 @@ var _ = Describe("AgentBaker API implementation tests", func() {
 			},
 		}
 		cs.Properties.LinuxProfile.SSH.PublicKeys = []datamodel.PublicKey{{
-			KeyData: string("testsshkey"),
+			KeyData: testSSHPublicKey,
 		}}
 
 		agentPool := cs.Properties.AgentPoolProfiles[0]
@@ -142,10 +144,10 @@ var _ = Describe("AgentBaker API implementation tests", func() {
 			CloudSpecConfig:               datamodel.AzurePublicCloudSpecForTest,
 			K8sComponents:                 k8sComponents,
 			AgentPoolProfile:              agentPool,
-			TenantID:                      "tenantID",
-			SubscriptionID:                "subID",
+			TenantID:                      "72f988bf-86f1-41af-91ab-2d7cd011db47",
+			SubscriptionID:                "8ecadfc9-d1a3-4ea4-b844-0d9f87e4d7c8",
 			ResourceGroupName:             "resourceGroupName",
-			UserAssignedIdentityClientID:  "userAssignedID",
+			UserAssignedIdentityClientID:  "f5c1b1b3-4a7e-4a1b-9c1e-6d2f8d3e9a10",
 			ConfigGPUDriverIfNeeded:       true,
 			EnableGPUDevicePluginIfNeeded: false,
 			EnableKubeletConfigFile:       false,
@@ -182,6 +184,63 @@ var _ = Describe("AgentBaker API implementation tests", func() {
 			Expect(nodeBootStrapping.SigImageConfig.Version).To(Equal("2021.11.06"))
 		})
 
+		It("should return the resolved feature gates", func() {
+			agentBaker, err := NewAgentBaker()
+			Expect(err).NotTo(HaveOccurred())
+			agentBaker = agentBaker.WithToggles(toggles)
+
+			nodeBootStrapping, err := agentBaker.GetNodeBootstrapping(context.Background(), config)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(nodeBootStrapping.FeatureGates).To(HaveKeyWithValue("RotateKubeletServerCertificate", true))
+			Expect(nodeBootStrapping.FeatureGates).To(HaveKeyWithValue("DynamicKubeletConfig", false))
+		})
+
+		It("should warn about a deprecated distro", func() {
+			agentBaker, err := NewAgentBaker()
+			Expect(err).NotTo(HaveOccurred())
+			agentBaker = agentBaker.WithToggles(toggles)
+
+			nodeBootStrapping, err := agentBaker.GetNodeBootstrapping(context.Background(), config)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(nodeBootStrapping.Warnings).To(ConsistOf(ContainSubstring("distro aks-ubuntu-16.04 is deprecated")))
+		})
+
+		It("should return an error for a deprecated distro when deprecated distros are rejected", func() {
+			config.RejectDeprecatedDistro = true
+
+			agentBaker, err := NewAgentBaker()
+			Expect(err).NotTo(HaveOccurred())
+			agentBaker = agentBaker.WithToggles(toggles)
+
+			_, err = agentBaker.GetNodeBootstrapping(context.Background(), config)
+			var deprecatedErr *datamodel.DeprecatedDistroError
+			Expect(errors.As(err, &deprecatedErr)).To(BeTrue())
+			Expect(deprecatedErr.Distro).To(Equal(datamodel.AKSUbuntu1604))
+		})
+
+		It("should return the provisioning manifest of the resolved image and versions", func() {
+			config.ContainerdVersion = "1.7.1"
+
+			agentBaker, err := NewAgentBaker()
+			Expect(err).NotTo(HaveOccurred())
+			agentBaker = agentBaker.WithToggles(toggles)
+
+			nodeBootStrapping, err := agentBaker.GetNodeBootstrapping(context.Background(), config)
+			Expect(err).NotTo(HaveOccurred())
+
+			manifest, err := nodeBootStrapping.ProvisioningManifest()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(manifest.Distro).To(Equal(datamodel.AKSUbuntu1604))
+			Expect(manifest.KubernetesVersion).To(Equal("1.16.15"))
+			Expect(manifest.ComponentVersions).To(Equal(map[string]string{
+				"kubernetes": "1.16.15",
+				"containerd": "1.7.1",
+			}))
+			Expect(manifest.SigImageConfig).To(Equal(nodeBootStrapping.SigImageConfig))
+			Expect(manifest.OSImageConfig).To(Equal(nodeBootStrapping.OSImageConfig))
+			Expect(manifest.KubeletFlags).To(Equal(config.KubeletConfig))
+		})
+
 		It("should return the correct bootstrapping data when linux node image version override is present", func() {
 			toggles.Maps = map[string]agenttoggles.MapToggle{
 				"linux-node-image-version": func(entity *agenttoggles.Entity) map[string]string {
@@ -235,6 +294,70 @@ var _ = Describe("AgentBaker API implementation tests", func() {
 			Expect(nodeBootStrapping.SigImageConfig.Version).To(Equal("2021.11.06"))
 		})
 
+		It("should overlay the distro default kubelet flags", func() {
+			agentBaker, err := NewAgentBaker()
+			Expect(err).NotTo(HaveOccurred())
+			agentBaker = agentBaker.WithToggles(toggles)
+
+			_, err = agentBaker.GetNodeBootstrapping(context.Background(), config)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config.KubeletConfig).To(HaveKeyWithValue("--kubelet-cgroups", "/system.slice/kubelet.service"))
+			Expect(config.KubeletConfig).NotTo(HaveKey("--cgroup-driver"))
+		})
+
+		It("should not override user provided kubelet flags with the distro defaults", func() {
+			config.KubeletConfig["--kubelet-cgroups"] = "/kubelet.slice"
+			agentBaker, err := NewAgentBaker()
+			Expect(err).NotTo(HaveOccurred())
+			agentBaker = agentBaker.WithToggles(toggles)
+
+			_, err = agentBaker.GetNodeBootstrapping(context.Background(), config)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config.KubeletConfig).To(HaveKeyWithValue("--kubelet-cgroups", "/kubelet.slice"))
+		})
+
+		It("should return an error if the containerd config template version toggle is unknown", func() {
+			toggles.Strings = map[string]agenttoggles.StringToggle{
+				"containerd-config-template-version": func(entity *agenttoggles.Entity) string {
+					return "v0"
+				},
+			}
+			agentBaker, err := NewAgentBaker()
+			Expect(err).NotTo(HaveOccurred())
+			agentBaker = agentBaker.WithToggles(toggles)
+
+			_, err = agentBaker.GetNodeBootstrapping(context.Background(), config)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should return an error naming the toggled CNI plugin version if it is not cached on the VHD", func() {
+			toggles.Strings = map[string]agenttoggles.StringToggle{
+				"cni-plugin-version": func(entity *agenttoggles.Entity) string {
+					return "0.0.1"
+				},
+			}
+			agentBaker, err := NewAgentBaker()
+			Expect(err).NotTo(HaveOccurred())
+			agentBaker = agentBaker.WithToggles(toggles)
+
+			_, err = agentBaker.GetNodeBootstrapping(context.Background(), config)
+			Expect(err).To(MatchError("CNI plugin version 0.0.1 is not cached on the VHD"))
+		})
+
+		It("should return an error naming the toggled package version if it is not cached on the VHD", func() {
+			toggles.Maps = map[string]agenttoggles.MapToggle{
+				"package-version-overrides": func(entity *agenttoggles.Entity) map[string]string {
+					return map[string]string{"cni-plugins": "0.0.1"}
+				},
+			}
+			agentBaker, err := NewAgentBaker()
+			Expect(err).NotTo(HaveOccurred())
+			agentBaker = agentBaker.WithToggles(toggles)
+
+			_, err = agentBaker.GetNodeBootstrapping(context.Background(), config)
+			Expect(err).To(MatchError("package cni-plugins version 0.0.1 is not cached on the VHD"))
+		})
+
 		It("should return an error if cloud is not found", func() {
 			// this CloudSpecConfig is shared across all AgentBaker UTs,
 			// thus we need to make and use a copy when performing mutations for mocking
@@ -293,6 +416,56 @@ var _ = Describe("AgentBaker API implementation tests", func() {
 		})
 	})
 
+	Context("GetNodeBootstrappingWithResolution", func() {
+		It("should report the SIG image the node image was resolved from", func() {
+			agentBaker, err := NewAgentBaker()
+			Expect(err).NotTo(HaveOccurred())
+			agentBaker = agentBaker.WithToggles(toggles)
+
+			nodeBootStrapping, resolution, err := agentBaker.GetNodeBootstrappingWithResolution(context.Background(), config)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(nodeBootStrapping.SigImageConfig).NotTo(BeNil())
+			Expect(resolution).To(Equal(&datamodel.ImageResolution{
+				Source:  datamodel.ImageSourceSIG,
+				Distro:  datamodel.AKSUbuntu1604,
+				Version: "2021.11.06",
+			}))
+		})
+
+		It("should report the linux node image version override", func() {
+			toggles.Maps = map[string]agenttoggles.MapToggle{
+				"linux-node-image-version": func(entity *agenttoggles.Entity) map[string]string {
+					return map[string]string{
+						string(datamodel.AKSUbuntu1604): "202402.27.0",
+					}
+				},
+			}
+			agentBaker, err := NewAgentBaker()
+			Expect(err).NotTo(HaveOccurred())
+			agentBaker = agentBaker.WithToggles(toggles)
+
+			_, resolution, err := agentBaker.GetNodeBootstrappingWithResolution(context.Background(), config)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resolution.Source).To(Equal(datamodel.ImageSourceSIG))
+			Expect(resolution.Version).To(Equal("202402.27.0"))
+			Expect(resolution.VersionOverridden).To(BeTrue())
+		})
+
+		It("should report an override for customized images", func() {
+			config.AgentPoolProfile.Distro = datamodel.CustomizedImage
+			agentBaker, err := NewAgentBaker()
+			Expect(err).NotTo(HaveOccurred())
+			agentBaker = agentBaker.WithToggles(toggles)
+
+			_, resolution, err := agentBaker.GetNodeBootstrappingWithResolution(context.Background(), config)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resolution).To(Equal(&datamodel.ImageResolution{
+				Source: datamodel.ImageSourceOverride,
+				Distro: datamodel.CustomizedImage,
+			}))
+		})
+	})
+
 	Context("GetLatestSigImageConfig", func() {
 		It("should return correct value for existing distro", func() {
 			agentBaker, err := NewAgentBaker()
@@ -538,4 +711,135 @@ var _ = Describe("AgentBaker API implementation tests", func() {
 			Expect(cachedOnVHD.FromComponentDownloadedFiles).ToNot(BeEmpty())
 		})
 	})
+
+	Context("RenderContainerdConfig", func() {
+		It("should render the containerd config without the nvidia runtime for non-GPU nodes", func() {
+			containerdConfig, err := RenderContainerdConfig(config)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(containerdConfig).To(HavePrefix("version = 2\n"))
+			Expect(containerdConfig).NotTo(ContainSubstring("nvidia-container-runtime"))
+		})
+
+		It("should render the containerd config with the nvidia runtime for GPU nodes", func() {
+			config.AgentPoolProfile.VMSize = "Standard_NC6s_v3"
+			config.EnableNvidia = true
+			containerdConfig, err := RenderContainerdConfig(config)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(containerdConfig).To(ContainSubstring(`default_runtime_name = "nvidia-container-runtime"`))
+		})
+
+		It("should render the max concurrent downloads of containerd", func() {
+			config.ContainerdMaxConcurrentDownloads = to.IntPtr(10)
+			containerdConfig, err := RenderContainerdConfig(config)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(containerdConfig).To(ContainSubstring("\n  max_concurrent_downloads = 10\n"))
+		})
+
+		It("should render the root and state directories of containerd", func() {
+			config.ContainerdRootDir = "/mnt/data/containerd"
+			config.ContainerdStateDir = "/mnt/data/run/containerd"
+			containerdConfig, err := RenderContainerdConfig(config)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(containerdConfig).To(HavePrefix("version = 2\noom_score = 0\nroot = \"/mnt/data/containerd\"\nstate = \"/mnt/data/run/containerd\"\n"))
+		})
+
+		It("should return an error for Windows nodes", func() {
+			config.AgentPoolProfile.OSType = datamodel.Windows
+			_, err := RenderContainerdConfig(config)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+	Context("IsOfflineRenderable", func() {
+		It("should require the image lookup fields for AKS images", func() {
+			renderable, fields := IsOfflineRenderable(config)
+			Expect(renderable).To(BeFalse())
+			Expect(fields).To(ConsistOf("AgentPoolProfile.Distro", "CloudSpecConfig.CloudName", "ContainerService.Location", "SIGConfig"))
+		})
+
+		It("should be renderable offline for customized images", func() {
+			config.AgentPoolProfile.Distro = datamodel.CustomizedImage
+			renderable, fields := IsOfflineRenderable(config)
+			Expect(renderable).To(BeTrue())
+			Expect(fields).To(BeEmpty())
+		})
+
+		It("should be renderable offline for Arc-connected nodes", func() {
+			config.ArcConfig = &datamodel.ArcConfig{
+				TenantID:      "72f988bf-86f1-41af-91ab-2d7cd011db47",
+				ResourceGroup: "resourceGroup",
+				ClusterName:   "clusterName",
+			}
+			renderable, fields := IsOfflineRenderable(config)
+			Expect(renderable).To(BeTrue())
+			Expect(fields).To(BeEmpty())
+		})
+	})
+	Context("EstimateCustomDataSize", func() {
+		It("should estimate the custom data size within 10% of the rendered size", func() {
+			const tolerance = 0.1
+			estimate, err := EstimateCustomDataSize(config)
+			Expect(err).NotTo(HaveOccurred())
+
+			agentBaker, err := NewAgentBaker()
+			Expect(err).NotTo(HaveOccurred())
+			agentBaker = agentBaker.WithToggles(toggles)
+			nodeBootStrapping, err := agentBaker.GetNodeBootstrapping(context.Background(), config)
+			Expect(err).NotTo(HaveOccurred())
+
+			actual := float64(len(nodeBootStrapping.CustomData))
+			Expect(float64(estimate)).To(BeNumerically("~", actual, actual*tolerance))
+		})
+
+		It("should return an error without agent pool profile", func() {
+			config.AgentPoolProfile = nil
+			_, err := EstimateCustomDataSize(config)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+	Context("NewAgentBaker with validated toggles", func() {
+		It("should use valid toggles", func() {
+			agentBaker, err := NewAgentBaker(WithValidatedToggles(toggles))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(agentBaker.toggles).To(Equal(toggles))
+		})
+
+		It("should return an error for invalid toggles", func() {
+			toggles.Strings["unknown-toggle"] = func(entity *agenttoggles.Entity) string {
+				return ""
+			}
+			_, err := NewAgentBaker(WithValidatedToggles(toggles))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+	Context("GetDistroSigImageConfigAllRegions", func() {
+		It("should return the image config of the distro in every region with the regional overrides", func() {
+			toggles.Maps = map[string]agenttoggles.MapToggle{
+				"linux-node-image-version": func(entity *agenttoggles.Entity) map[string]string {
+					if entity.Fields["region"] == "westus2" {
+						return map[string]string{string(datamodel.AKSUbuntuContainerd2204Gen2): "202405.10.0"}
+					}
+					return map[string]string{}
+				},
+			}
+			agentBaker, err := NewAgentBaker()
+			Expect(err).NotTo(HaveOccurred())
+			agentBaker = agentBaker.WithToggles(toggles)
+
+			configs, err := agentBaker.GetDistroSigImageConfigAllRegions(config.SIGConfig, datamodel.AKSUbuntuContainerd2204Gen2)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configs).To(HaveLen(len(datamodel.AzureRegions)))
+			Expect(configs["westus2"].Version).To(Equal("202405.10.0"))
+			Expect(configs["eastus"].Version).To(Equal(datamodel.LinuxSIGImageVersion))
+		})
+
+		It("should report the regions where the distro is unavailable", func() {
+			agentBaker, err := NewAgentBaker()
+			Expect(err).NotTo(HaveOccurred())
+			agentBaker = agentBaker.WithToggles(toggles)
+
+			configs, err := agentBaker.GetDistroSigImageConfigAllRegions(config.SIGConfig, "unknown")
+			Expect(err).To(MatchError(ContainSubstring("eastus")))
+			Expect(configs).To(BeEmpty())
+		})
+	})
 })
@@ -0,0 +1,30 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package agent
+
+import (
+	"encoding/json"
+
+	"github.com/Azure/agentbaker/pkg/agent/datamodel"
+)
+
+/*
+RenderAzureCloudConfig returns the azure.json cloud config of the node, as written to /etc/kubernetes/azure.json.
+It contains the service principal secret, if any, so the result must be handled as a secret.
+*/
+func RenderAzureCloudConfig(config *datamodel.NodeBootstrappingConfiguration) ([]byte, error) {
+	cloudProviderConfig, err := config.CloudProviderConfig()
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(cloudProviderConfig, "", "    ")
+}
+
+// getTargetEnvironment returns the name of the cloud the cluster runs in.
+func getTargetEnvironment(cs *datamodel.ContainerService) string {
+	if cs.IsAKSCustomCloud() {
+		return cs.Properties.CustomCloudEnv.Name
+	}
+	return GetCloudTargetEnv(cs.Location)
+}
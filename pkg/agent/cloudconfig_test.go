@@ -0,0 +1,100 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package agent
+
+import (
+	"encoding/json"
+
+	"github.com/Azure/agentbaker/pkg/agent/datamodel"
+	"github.com/Azure/go-autorest/autorest/to"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Test RenderAzureCloudConfig", func() {
+	var config *datamodel.NodeBootstrappingConfiguration
+
+	BeforeEach(func() {
+		config = &datamodel.NodeBootstrappingConfiguration{
+			TenantID:                     "tenantID",
+			SubscriptionID:               "subID",
+			ResourceGroupName:            "resourceGroupName",
+			PrimaryScaleSetName:          "aks-nodepool1-12345678-vmss",
+			UserAssignedIdentityClientID: "userAssignedID",
+			ContainerService: &datamodel.ContainerService{
+				Location: "southcentralus",
+				Properties: &datamodel.Properties{
+					ClusterID: "12345678",
+					OrchestratorProfile: &datamodel.OrchestratorProfile{
+						OrchestratorType:    datamodel.Kubernetes,
+						OrchestratorVersion: "1.29.2",
+						KubernetesConfig: &datamodel.KubernetesConfig{
+							UseManagedIdentity:           true,
+							UseInstanceMetadata:          to.BoolPtr(true),
+							LoadBalancerSku:              "Standard",
+							CloudProviderBackoff:         to.BoolPtr(true),
+							CloudProviderBackoffMode:     "v2",
+							CloudProviderBackoffRetries:  6,
+							CloudProviderBackoffDuration: 5,
+							CloudProviderRateLimit:       to.BoolPtr(true),
+							CloudProviderRateLimitQPS:    10,
+							CloudProviderRateLimitBucket: 100,
+						},
+					},
+					ServicePrincipalProfile: &datamodel.ServicePrincipalProfile{ClientID: "msi"},
+					AgentPoolProfiles: []*datamodel.AgentPoolProfile{
+						{
+							Name:                "nodepool1",
+							AvailabilityProfile: datamodel.VirtualMachineScaleSets,
+							VnetSubnetID: "/subscriptions/subID/resourceGroups/vnetRG/providers/Microsoft.Network/" +
+								"virtualNetworks/aks-vnet/subnets/aks-subnet",
+						},
+					},
+				},
+			},
+		}
+	})
+
+	It("should render the cloud config of the cluster", func() {
+		content, err := RenderAzureCloudConfig(config)
+		Expect(err).NotTo(HaveOccurred())
+
+		var cloudConfig map[string]interface{}
+		Expect(json.Unmarshal(content, &cloudConfig)).To(Succeed())
+		Expect(cloudConfig).To(HaveKeyWithValue("cloud", "AzurePublicCloud"))
+		Expect(cloudConfig).To(HaveKeyWithValue("tenantId", "tenantID"))
+		Expect(cloudConfig).To(HaveKeyWithValue("subscriptionId", "subID"))
+		Expect(cloudConfig).To(HaveKeyWithValue("aadClientId", "msi"))
+		Expect(cloudConfig).To(HaveKeyWithValue("resourceGroup", "resourceGroupName"))
+		Expect(cloudConfig).To(HaveKeyWithValue("location", "southcentralus"))
+		Expect(cloudConfig).To(HaveKeyWithValue("vmType", "vmss"))
+		Expect(cloudConfig).To(HaveKeyWithValue("subnetName", "aks-subnet"))
+		Expect(cloudConfig).To(HaveKeyWithValue("vnetName", "aks-vnet"))
+		Expect(cloudConfig).To(HaveKeyWithValue("vnetResourceGroup", "vnetRG"))
+		Expect(cloudConfig).To(HaveKeyWithValue("primaryScaleSetName", "aks-nodepool1-12345678-vmss"))
+		Expect(cloudConfig).To(HaveKeyWithValue("cloudProviderBackoffMode", "v2"))
+		Expect(cloudConfig).To(HaveKeyWithValue("cloudProviderBackoff", true))
+		Expect(cloudConfig).To(HaveKeyWithValue("cloudProviderBackoffRetries", float64(6)))
+		Expect(cloudConfig).To(HaveKeyWithValue("cloudProviderRateLimitQPS", float64(10)))
+		Expect(cloudConfig).To(HaveKeyWithValue("useManagedIdentityExtension", true))
+		Expect(cloudConfig).To(HaveKeyWithValue("userAssignedIdentityID", "userAssignedID"))
+		Expect(cloudConfig).To(HaveKeyWithValue("useInstanceMetadata", true))
+		Expect(cloudConfig).To(HaveKeyWithValue("loadBalancerSku", "Standard"))
+		Expect(cloudConfig).To(HaveKeyWithValue("excludeMasterFromStandardLB", true))
+	})
+
+	It("should render the name of a custom cloud", func() {
+		config.ContainerService.Properties.CustomCloudEnv = &datamodel.CustomCloudEnv{Name: "akscustom"}
+		content, err := RenderAzureCloudConfig(config)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(ContainSubstring(`"cloud": "akscustom"`))
+	})
+
+	It("should return an error without an orchestrator profile", func() {
+		config.ContainerService.Properties.OrchestratorProfile = nil
+		_, err := RenderAzureCloudConfig(config)
+		Expect(err).To(HaveOccurred())
+	})
+})
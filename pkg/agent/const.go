@@ -103,11 +103,128 @@ const (
 	dhcpV6ServiceCSEScriptFilepath       = "/etc/systemd/system/dhcpv6.service"
 	dhcpV6ConfigCSEScriptFilepath        = "/opt/azure/containers/enable-dhcpv6.sh"
 	initAKSCustomCloudFilepath           = "/opt/azure/containers/init-aks-custom-cloud.sh"
+	provisionCompleteMarkerFilepath      = "/opt/azure/containers/provision.complete.marker"
+	aptSourcesListFilepath               = "/etc/apt/sources.list.d/aks-custom.list"
+	aptSourceSigningKeyFilepathFormat    = "/etc/apt/keyrings/aks-custom-%d.asc"
+	udevRulesDirectory                   = "/etc/udev/rules.d"
+	kernelModulesLoadFilepath            = "/etc/modules-load.d/aks-kernel-modules.conf"
+	etcEnvironmentFilepath               = "/etc/environment"
+	containerdOOMScoreDropinFilepath     = "/etc/systemd/system/containerd.service.d/20-oom-score.conf"
+	containerdDirsDropinFilepath         = "/etc/systemd/system/containerd.service.d/30-dirs.conf"
+	containerdBaseRuntimeSpecFilepath    = "/etc/containerd/base-runtime-spec.json"
+	journaldConfigDropinFilepath         = "/etc/systemd/journald.conf.d/aks-journald.conf"
+	workloadIdentityTokenFilepath        = "/var/run/secrets/azure/tokens/azure-identity-token"
+	kubernetesCACertFilepath             = "/etc/kubernetes/certs/ca.crt"
+	kubeletClientCACertFilepath          = "/etc/kubernetes/certs/kubelet-client-ca.crt"
+	azureCloudConfigFilepath             = "/etc/kubernetes/azure.json"
+	credentialProviderConfigFilepath     = "/var/lib/kubelet/aks-credential-provider-config.json"
+	credentialProviderBinDir             = "/var/lib/kubelet/credential-provider"
+	kubeletSystemdServiceFilepath        = "/etc/systemd/system/kubelet.service"
+	bootstrapKubeconfigFilepath          = "/var/lib/kubelet/bootstrap-kubeconfig"
+	secureTLSBootstrapClientFilepath     = "/opt/azure/tlsbootstrap/tls-bootstrap-client"
+	kubeletKubeconfigFilepath            = "/var/lib/kubelet/kubeconfig"
+	npdSystemdServiceFilepath            = "/etc/systemd/system/node-problem-detector.service"
+	npdBinaryFilepath                    = "/usr/local/bin/node-problem-detector"
+	npdKernelMonitorConfigFilepath       = "/etc/node-problem-detector.d/kernel-monitor.json"
+	npdCustomConfigFilepath              = "/etc/node-problem-detector.d/custom-config.json"
+	gracefulNodeShutdownDropinFilepath   = "/etc/systemd/logind.conf.d/aks-graceful-node-shutdown.conf"
 )
 
+// imdsComputeNameURL is the IMDS endpoint returning the name of the VM as text.
+const imdsComputeNameURL = "http://169.254.169.254/metadata/instance/compute/name?api-version=2021-02-01&format=text"
+
+// Install script of the Azure Connected Machine agent of Arc-connected Linux nodes.
+const (
+	arcAgentInstallScriptURL      = "https://gbl.his.arc.azure.com/azcmagent-linux"
+	arcAgentInstallScriptFilepath = "/tmp/install_linux_azcmagent.sh"
+)
+
+// defaultSecureTLSBootstrapAADResource is the AAD server application the secure TLS bootstrap client requests JWTs for by default.
+const defaultSecureTLSBootstrapAADResource = "6dae42f8-4368-4678-94ff-3960e28e3630"
+
+// provisionCompleteMarkerWindowsFilepath is where Windows CSE writes the provision complete marker.
+const provisionCompleteMarkerWindowsFilepath = "c:\\AzureData\\provision.complete.marker"
+
+// verboseKubeletLogLevel is the minimum kubelet log verbosity --v of nodes with verbose provisioning.
+const verboseKubeletLogLevel = 4
+
+// maxProvisionCompleteMarkerLength is the max length of the provision complete marker.
+const maxProvisionCompleteMarkerLength = 256
+
 const (
 	// AADPodIdentityAddonName is the name of the aad-pod-identity addon deployment.
 	AADPodIdentityAddonName = "aad-pod-identity"
 	// ACIConnectorAddonName is the name of the aci-connector addon deployment.
 	ACIConnectorAddonName = "aci-connector"
 )
+
+// Containerd config template versions.
+const (
+	containerdConfigTemplateVersionV1      = "v1"
+	defaultContainerdConfigTemplateVersion = containerdConfigTemplateVersionV1
+)
+
+// Kubernetes versions bounding the cloud provider of kubelet.
+const (
+	// externalCloudProviderMinKubernetesVersion is the first version the out-of-tree cloud-provider-azure supports.
+	externalCloudProviderMinKubernetesVersion = "1.21.0"
+	// inTreeCloudProviderRemovedKubernetesVersion is the version the in-tree azure cloud provider was removed in.
+	inTreeCloudProviderRemovedKubernetesVersion = "1.31.0"
+)
+
+// maxParallelImagePullsMinKubernetesVersion is the first version kubelet supports maxParallelImagePulls in.
+const maxParallelImagePullsMinKubernetesVersion = "1.27.0"
+
+// gracefulNodeShutdownMinKubernetesVersion is the first version the kubelet GracefulNodeShutdown feature gate is enabled by default in.
+const gracefulNodeShutdownMinKubernetesVersion = "1.21.0"
+
+// Names of downloaded file components on the VHD.
+const (
+	// cniPluginsComponentName is the name of the CNI plugins downloaded file component on the VHD.
+	cniPluginsComponentName = "cni-plugins"
+	// npdComponentName is the name of the node-problem-detector downloaded file component on the VHD.
+	npdComponentName = "node-problem-detector"
+	// stargzSnapshotterComponentName is the name of the stargz snapshotter downloaded file component on the VHD.
+	stargzSnapshotterComponentName = "stargz-snapshotter"
+	// cloudProviderAzureComponentName is the name of the cloud-provider-azure downloaded file component on the VHD,
+	// which contains the ACR credential provider.
+	cloudProviderAzureComponentName = "cloud-provider-azure"
+)
+
+// kubeProxyNFTablesMinKubernetesVersion is the first version the nftables kube-proxy mode is enabled by default in.
+const kubeProxyNFTablesMinKubernetesVersion = "1.31.0"
+
+// kubeProxyIPVSSchedulers are the IPVS schedulers kube-proxy supports.
+//
+//nolint:gochecknoglobals
+var kubeProxyIPVSSchedulers = []string{"rr", "wrr", "lc", "wlc", "lblc", "lblcr", "sh", "dh", "sed", "nq", "mh"}
+
+// credentialProvidersMinKubernetesVersion is the first version kubelet credential providers are GA in.
+const credentialProvidersMinKubernetesVersion = "1.26.0"
+
+// seccompDefaultMinKubernetesVersion is the first version the kubelet SeccompDefault feature gate is enabled by default in.
+const seccompDefaultMinKubernetesVersion = "1.25.0"
+
+// Runtime handlers of the containerd config templates.
+const (
+	containerdRuntimeRunc      = "runc"
+	containerdRuntimeNvidia    = "nvidia-container-runtime"
+	containerdRuntimeUntrusted = "untrusted"
+	// containerdRuntimeNvidiaHandler is the runtime handler registered by NvidiaRuntimeConfig.
+	containerdRuntimeNvidiaHandler = "nvidia"
+)
+
+// nvidiaContainerRuntimeDependencyName is the name of the nvidia-container-runtime dependency in the VHD manifest.
+const nvidiaContainerRuntimeDependencyName = "nvidia-container-runtime"
+
+// containerdKrustletRuntimes are the runtime handlers of the containerd config templates for WASM workloads.
+//
+//nolint:gochecknoglobals
+var containerdKrustletRuntimes = []string{
+	"spin", "slight", "spin-v0-3-0", "slight-v0-3-0", "spin-v0-5-1", "slight-v0-5-1", "spin-v0-8-0", "slight-v0-8-0", "wws-v0-8-0",
+}
+
+// containerdKataRuntimes are the runtime handlers of the containerd config templates for kata distros.
+//
+//nolint:gochecknoglobals
+var containerdKataRuntimes = []string{"kata", "katacli", "kata-cc"}
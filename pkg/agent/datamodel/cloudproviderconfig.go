@@ -0,0 +1,102 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package datamodel
+
+import (
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/pkg/errors"
+)
+
+// CloudProviderConfig is the cloud config of the azure cloud provider, written to /etc/kubernetes/azure.json.
+type CloudProviderConfig struct {
+	Cloud                             string  `json:"cloud"`
+	TenantID                          string  `json:"tenantId"`
+	SubscriptionID                    string  `json:"subscriptionId"`
+	AADClientID                       string  `json:"aadClientId"`
+	AADClientSecret                   string  `json:"aadClientSecret"`
+	ResourceGroup                     string  `json:"resourceGroup"`
+	Location                          string  `json:"location"`
+	VMType                            string  `json:"vmType"`
+	SubnetName                        string  `json:"subnetName"`
+	SecurityGroupName                 string  `json:"securityGroupName"`
+	VnetName                          string  `json:"vnetName"`
+	VnetResourceGroup                 string  `json:"vnetResourceGroup"`
+	RouteTableName                    string  `json:"routeTableName"`
+	PrimaryAvailabilitySetName        string  `json:"primaryAvailabilitySetName"`
+	PrimaryScaleSetName               string  `json:"primaryScaleSetName"`
+	CloudProviderBackoffMode          string  `json:"cloudProviderBackoffMode"`
+	CloudProviderBackoff              bool    `json:"cloudProviderBackoff"`
+	CloudProviderBackoffRetries       int     `json:"cloudProviderBackoffRetries"`
+	CloudProviderBackoffExponent      float64 `json:"cloudProviderBackoffExponent"`
+	CloudProviderBackoffDuration      int     `json:"cloudProviderBackoffDuration"`
+	CloudProviderBackoffJitter        float64 `json:"cloudProviderBackoffJitter"`
+	CloudProviderRateLimit            bool    `json:"cloudProviderRateLimit"`
+	CloudProviderRateLimitQPS         float64 `json:"cloudProviderRateLimitQPS"`
+	CloudProviderRateLimitBucket      int     `json:"cloudProviderRateLimitBucket"`
+	CloudProviderRateLimitQPSWrite    float64 `json:"cloudProviderRateLimitQPSWrite"`
+	CloudProviderRateLimitBucketWrite int     `json:"cloudProviderRateLimitBucketWrite"`
+	UseManagedIdentityExtension       bool    `json:"useManagedIdentityExtension"`
+	UserAssignedIdentityID            string  `json:"userAssignedIdentityID"`
+	UseInstanceMetadata               bool    `json:"useInstanceMetadata"`
+	LoadBalancerSku                   string  `json:"loadBalancerSku"`
+	DisableOutboundSNAT               bool    `json:"disableOutboundSNAT"`
+	ExcludeMasterFromStandardLB       bool    `json:"excludeMasterFromStandardLB"`
+	MaximumLoadBalancerRuleCount      int     `json:"maximumLoadBalancerRuleCount"`
+}
+
+/*
+CloudProviderConfig returns the resolved settings of the azure.json cloud config of the node, e.g. for credential
+rotation tooling which needs single fields without parsing the file. It contains the service principal secret, if
+any, so the result must be handled as a secret.
+*/
+func (config *NodeBootstrappingConfiguration) CloudProviderConfig() (*CloudProviderConfig, error) {
+	cs := config.ContainerService
+	if cs == nil || cs.Properties == nil || cs.Properties.OrchestratorProfile == nil {
+		return nil, errors.New("container service orchestrator profile is required to resolve the cloud provider config")
+	}
+	properties := cs.Properties
+	cloudProviderConfig := &CloudProviderConfig{
+		Cloud:                       GetCloudTargetEnv(cs.Location),
+		TenantID:                    config.TenantID,
+		SubscriptionID:              config.SubscriptionID,
+		ResourceGroup:               config.ResourceGroupName,
+		Location:                    cs.Location,
+		VMType:                      properties.GetVMType(),
+		SubnetName:                  properties.GetSubnetName(),
+		SecurityGroupName:           properties.GetNSGName(),
+		VnetName:                    properties.GetVirtualNetworkName(),
+		VnetResourceGroup:           properties.GetVNetResourceGroupName(),
+		RouteTableName:              properties.GetRouteTableName(),
+		PrimaryAvailabilitySetName:  properties.GetPrimaryAvailabilitySetName(),
+		PrimaryScaleSetName:         config.PrimaryScaleSetName,
+		UserAssignedIdentityID:      config.UserAssignedIdentityClientID,
+		ExcludeMasterFromStandardLB: true,
+	}
+	if cs.IsAKSCustomCloud() {
+		cloudProviderConfig.Cloud = properties.CustomCloudEnv.Name
+	}
+	if properties.ServicePrincipalProfile != nil {
+		cloudProviderConfig.AADClientID = properties.ServicePrincipalProfile.ClientID
+		cloudProviderConfig.AADClientSecret = properties.ServicePrincipalProfile.Secret
+	}
+	if kubernetesConfig := properties.OrchestratorProfile.KubernetesConfig; kubernetesConfig != nil {
+		cloudProviderConfig.CloudProviderBackoffMode = kubernetesConfig.CloudProviderBackoffMode
+		cloudProviderConfig.CloudProviderBackoff = to.Bool(kubernetesConfig.CloudProviderBackoff)
+		cloudProviderConfig.CloudProviderBackoffRetries = kubernetesConfig.CloudProviderBackoffRetries
+		cloudProviderConfig.CloudProviderBackoffExponent = kubernetesConfig.CloudProviderBackoffExponent
+		cloudProviderConfig.CloudProviderBackoffDuration = kubernetesConfig.CloudProviderBackoffDuration
+		cloudProviderConfig.CloudProviderBackoffJitter = kubernetesConfig.CloudProviderBackoffJitter
+		cloudProviderConfig.CloudProviderRateLimit = to.Bool(kubernetesConfig.CloudProviderRateLimit)
+		cloudProviderConfig.CloudProviderRateLimitQPS = kubernetesConfig.CloudProviderRateLimitQPS
+		cloudProviderConfig.CloudProviderRateLimitBucket = kubernetesConfig.CloudProviderRateLimitBucket
+		cloudProviderConfig.CloudProviderRateLimitQPSWrite = kubernetesConfig.CloudProviderRateLimitQPSWrite
+		cloudProviderConfig.CloudProviderRateLimitBucketWrite = kubernetesConfig.CloudProviderRateLimitBucketWrite
+		cloudProviderConfig.UseManagedIdentityExtension = kubernetesConfig.UseManagedIdentity
+		cloudProviderConfig.UseInstanceMetadata = to.Bool(kubernetesConfig.UseInstanceMetadata)
+		cloudProviderConfig.LoadBalancerSku = kubernetesConfig.LoadBalancerSku
+		cloudProviderConfig.DisableOutboundSNAT = to.Bool(kubernetesConfig.CloudProviderDisableOutboundSNAT)
+		cloudProviderConfig.MaximumLoadBalancerRuleCount = kubernetesConfig.MaximumLoadBalancerRuleCount
+	}
+	return cloudProviderConfig, nil
+}
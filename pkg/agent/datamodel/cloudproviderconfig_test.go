@@ -0,0 +1,98 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package datamodel
+
+import (
+	"testing"
+
+	"github.com/Azure/go-autorest/autorest/to"
+)
+
+func TestCloudProviderConfig(t *testing.T) {
+	newConfig := func() *NodeBootstrappingConfiguration {
+		return &NodeBootstrappingConfiguration{
+			TenantID:                     "tenantID",
+			SubscriptionID:               "subID",
+			ResourceGroupName:            "resourceGroupName",
+			PrimaryScaleSetName:          "aks-nodepool1-12345678-vmss",
+			UserAssignedIdentityClientID: "userAssignedID",
+			ContainerService: &ContainerService{
+				Location: "chinaeast2",
+				Properties: &Properties{
+					ClusterID: "12345678",
+					OrchestratorProfile: &OrchestratorProfile{
+						KubernetesConfig: &KubernetesConfig{
+							UseManagedIdentity:           true,
+							CloudProviderBackoff:         to.BoolPtr(true),
+							CloudProviderBackoffRetries:  6,
+							MaximumLoadBalancerRuleCount: 250,
+						},
+					},
+					ServicePrincipalProfile: &ServicePrincipalProfile{ClientID: "msi"},
+					AgentPoolProfiles: []*AgentPoolProfile{
+						{
+							AvailabilityProfile: VirtualMachineScaleSets,
+							VnetSubnetID: "/subscriptions/subID/resourceGroups/vnetRG/providers/Microsoft.Network/" +
+								"virtualNetworks/aks-vnet/subnets/aks-subnet",
+						},
+					},
+				},
+			},
+		}
+	}
+
+	t.Run("resolves the settings of the config", func(t *testing.T) {
+		t.Parallel()
+		cloudProviderConfig, err := newConfig().CloudProviderConfig()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expected := CloudProviderConfig{
+			Cloud:                        AzureChinaCloud,
+			TenantID:                     "tenantID",
+			SubscriptionID:               "subID",
+			AADClientID:                  "msi",
+			ResourceGroup:                "resourceGroupName",
+			Location:                     "chinaeast2",
+			VMType:                       "vmss",
+			SubnetName:                   "aks-subnet",
+			SecurityGroupName:            "-agentpool-12345678-nsg",
+			VnetName:                     "aks-vnet",
+			VnetResourceGroup:            "vnetRG",
+			RouteTableName:               "-agentpool-12345678-routetable",
+			PrimaryScaleSetName:          "aks-nodepool1-12345678-vmss",
+			CloudProviderBackoff:         true,
+			CloudProviderBackoffRetries:  6,
+			UseManagedIdentityExtension:  true,
+			UserAssignedIdentityID:       "userAssignedID",
+			ExcludeMasterFromStandardLB:  true,
+			MaximumLoadBalancerRuleCount: 250,
+		}
+		if *cloudProviderConfig != expected {
+			t.Errorf("expected cloud provider config %+v, got %+v", expected, *cloudProviderConfig)
+		}
+	})
+
+	t.Run("uses the name of a custom cloud", func(t *testing.T) {
+		t.Parallel()
+		config := newConfig()
+		config.ContainerService.Properties.CustomCloudEnv = &CustomCloudEnv{Name: "akscustom"}
+		cloudProviderConfig, err := config.CloudProviderConfig()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cloudProviderConfig.Cloud != "akscustom" {
+			t.Errorf("expected cloud akscustom, got %q", cloudProviderConfig.Cloud)
+		}
+	})
+
+	t.Run("returns an error without an orchestrator profile", func(t *testing.T) {
+		t.Parallel()
+		config := newConfig()
+		config.ContainerService.Properties.OrchestratorProfile = nil
+		if _, err := config.CloudProviderConfig(); err == nil {
+			t.Error("expected an error without an orchestrator profile")
+		}
+	})
+}
@@ -15,7 +15,15 @@ const (
 	// KubernetesDefaultWindowsSku is the default SKU for Windows VMs in kubernetes.
 	KubernetesDefaultWindowsSku = "Datacenter-Core-1809-with-Containers-smalldisk"
 	// KubernetesDefaultContainerdWindowsSandboxIsolation is the default containerd handler for windows pods.
-	KubernetesDefaultContainerdWindowsSandboxIsolation = "process"
+	KubernetesDefaultContainerdWindowsSandboxIsolation = WindowsIsolationModeProcess
+)
+
+// Windows container isolation modes.
+const (
+	// WindowsIsolationModeProcess runs containers as processes sharing the kernel of the node.
+	WindowsIsolationModeProcess = "process"
+	// WindowsIsolationModeHyperV runs containers in Hyper-V utility VMs, which requires the Hyper-V runtime handlers.
+	WindowsIsolationModeHyperV = "hyperv"
 )
 
 // Availability profiles.
@@ -40,6 +48,23 @@ const (
 	ScaleSetPrioritySpot = "Spot"
 )
 
+// VMSS instance naming, the instance index is appended to the computer name prefix as 6 base36 digits.
+const (
+	vmssInstanceIndexBase   = 36
+	vmssInstanceIndexLength = 6
+	vmssMaxInstanceIndex    = 2176782336 // 36^6
+)
+
+// Tokens of the hostname pattern.
+const (
+	HostnamePatternTokenPoolName   = "{poolName}"
+	HostnamePatternTokenClusterID  = "{clusterID}"
+	HostnamePatternTokenInstanceID = "{instanceID}"
+)
+
+// maxHostnameLength is the max length of a hostname, which must be a DNS label.
+const maxHostnameLength = 63
+
 // Supported container runtimes.
 const (
 	Docker         = "docker"
@@ -115,12 +140,21 @@ const (
 	/* TempDiskContainerDataDir is the path used to mount docker images, emptyDir volumes, and kubelet data
 	when KubeletDiskType == TempDisk. */
 	TempDiskContainerDataDir = "/mnt/aks/containers"
+	// EphemeralOSDiskContainerDataDir is the containerd root of Linux nodes with an ephemeral OS disk.
+	EphemeralOSDiskContainerDataDir = "/mnt/aks/ephemeral/containerd"
+	// DefaultContainerdRootDir is the default directory containerd persists its data like images in.
+	DefaultContainerdRootDir = "/var/lib/containerd"
+	// DefaultContainerdStateDir is the default directory containerd keeps its ephemeral state like sockets in.
+	DefaultContainerdStateDir = "/run/containerd"
+	// EphemeralOSDiskKubeletRootDir is the kubelet --root-dir of Linux nodes with an ephemeral OS disk.
+	EphemeralOSDiskKubeletRootDir = "/mnt/aks/ephemeral/kubelet"
 )
 
 const (
 	OSSKUCBLMariner = "CBLMariner"
 	OSSKUMariner    = "Mariner"
 	OSSKUAzureLinux = "AzureLinux"
+	OSSKUUbuntu     = "Ubuntu"
 )
 
 // Feature Flags.
@@ -131,3 +165,111 @@ const (
 	EnableIPv6Only        = "EnableIPv6Only"
 	EnableWinDSR          = "EnableWinDSR"
 )
+
+// DefaultRuntimeRequestTimeout is the default kubelet runtime request timeout, matching the kubelet default.
+const DefaultRuntimeRequestTimeout Duration = "2m"
+
+// DefaultVolumePluginDir is the default directory kubelet searches for FlexVolume drivers on Linux nodes.
+const DefaultVolumePluginDir = "/etc/kubernetes/volumeplugins"
+
+// Container log rotation defaults, matching the kubelet defaults.
+const (
+	// DefaultContainerLogMaxSizeMB is the default max size in MB of a container log file before it is rotated.
+	DefaultContainerLogMaxSizeMB = 10
+	// DefaultContainerLogMaxFiles is the default max number of container log files kept per container.
+	DefaultContainerLogMaxFiles = 5
+)
+
+// Credential provider API versions of kubelet.
+const (
+	// DefaultCredentialProviderAPIVersion is the credential provider API version plugins use by default.
+	DefaultCredentialProviderAPIVersion = "credentialprovider.kubelet.k8s.io/v1"
+	// CredentialProviderAPIVersionV1Beta1 is the beta credential provider API version.
+	CredentialProviderAPIVersionV1Beta1 = "credentialprovider.kubelet.k8s.io/v1beta1"
+	// DefaultCredentialProviderCacheDuration is the default cache duration of credential providers that don't set one.
+	DefaultCredentialProviderCacheDuration Duration = "10m"
+)
+
+// Component download retry and concurrency defaults of the CSE.
+const (
+	// DefaultDownloadMaxRetries is the default number of times the CSE retries a failed component download.
+	DefaultDownloadMaxRetries = 120
+	// DefaultDownloadBackoffSeconds is the default number of seconds the CSE waits between component download retries.
+	DefaultDownloadBackoffSeconds = 5
+	// DefaultDownloadConcurrency is the default number of components the CSE downloads in parallel, i.e. serially.
+	DefaultDownloadConcurrency = 1
+	// MaxDownloadConcurrency is the max number of components the CSE downloads in parallel.
+	MaxDownloadConcurrency = 16
+)
+
+// Control plane readiness wait bounds of the CSE.
+const (
+	// DefaultControlPlaneReadyTimeoutSeconds is the default number of seconds the CSE waits for the API server to be ready.
+	DefaultControlPlaneReadyTimeoutSeconds = 300
+	// MaxControlPlaneReadyTimeoutSeconds is the max number of seconds the CSE waits for the API server to be ready.
+	MaxControlPlaneReadyTimeoutSeconds = 1800
+)
+
+// Kubelet startup retry bounds of the CSE.
+const (
+	// MaxKubeletStartupRetries is the max number of times the CSE retries starting kubelet.
+	MaxKubeletStartupRetries = 10
+	// KubeletStartupBackoffSeconds is the number of seconds the CSE waits before the first kubelet start retry, each
+	// following retry waits this much longer.
+	KubeletStartupBackoffSeconds = 5
+	// KubeletStartupSettleSeconds is the number of seconds the CSE waits after starting kubelet before checking it is
+	// still active, since the kubelet unit is Type=simple and systemctl start returns once the process is spawned.
+	KubeletStartupSettleSeconds = 10
+)
+
+// Journald storage modes.
+const (
+	JournaldStorageVolatile   = "volatile"
+	JournaldStoragePersistent = "persistent"
+	JournaldStorageAuto       = "auto"
+	JournaldStorageNone       = "none"
+)
+
+// Containerd snapshotters.
+const (
+	// ContainerdSnapshotterOverlayfs is the default snapshotter of containerd.
+	ContainerdSnapshotterOverlayfs = "overlayfs"
+	// ContainerdSnapshotterStargz is the stargz snapshotter, which lazily pulls eStargz images.
+	ContainerdSnapshotterStargz = "stargz"
+)
+
+// DefaultWorkloadIdentityAudience is the audience of the service account tokens exchanged for Microsoft Entra tokens.
+const DefaultWorkloadIdentityAudience = "api://AzureADTokenExchange"
+
+// Max pods defaults of the kubelet, see DefaultMaxPods.
+const (
+	// DefaultMaxPodsKubenet is the default max pods of kubenet nodes, and any other nodes whose pods do not get IPs
+	// from the node subnet. It is the kubelet default.
+	DefaultMaxPodsKubenet = 110
+	// DefaultMaxPodsAzureCNI is the default max pods of Azure CNI nodes of small or unknown VM sizes, every pod IP is
+	// allocated from the node subnet.
+	DefaultMaxPodsAzureCNI = 30
+	// DefaultMaxPodsAzureCNIMediumSKU is the default max pods of Azure CNI nodes with more than MaxPodsSmallSKUCPUs vCPUs.
+	DefaultMaxPodsAzureCNIMediumSKU = 110
+	// DefaultMaxPodsAzureCNILargeSKU is the default max pods of Azure CNI nodes with at least MaxPodsLargeSKUCPUs vCPUs.
+	DefaultMaxPodsAzureCNILargeSKU = 250
+	// MaxPodsSmallSKUCPUs is the number of vCPUs up to which an Azure CNI node gets DefaultMaxPodsAzureCNI.
+	MaxPodsSmallSKUCPUs = 2
+	// MaxPodsLargeSKUCPUs is the number of vCPUs from which an Azure CNI node gets DefaultMaxPodsAzureCNILargeSKU.
+	MaxPodsLargeSKUCPUs = 16
+)
+
+const (
+	// DefaultNTPServer is the time server nodes sync with when no NTP servers are configured.
+	DefaultNTPServer = "time.windows.com"
+)
+
+/*
+Custom data limits of the node bootstrapping payload, in base64 encoded characters. Azure accepts at most 65535
+bytes of custom data, i.e. 87380 characters once base64 encoded. Linux nodes pass the custom data to cloud-init,
+Windows nodes store it as C:\AzureData\CustomData.bin and run it as a PowerShell script.
+*/
+const (
+	MaxLinuxCustomDataSize   = 87380
+	MaxWindowsCustomDataSize = 87380
+)
@@ -0,0 +1,70 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package datamodel
+
+import "fmt"
+
+// distroDeprecation describes why a distro is deprecated and which distro replaces it.
+type distroDeprecation struct {
+	reason      string
+	replacement Distro
+}
+
+const (
+	ubuntu1604EOLReason = "Ubuntu 16.04 has reached end of life"
+	ubuntu1804EOLReason = "Ubuntu 18.04 has reached end of standard support"
+)
+
+// deprecatedDistros is the deprecation table of the distros nodes should no longer be provisioned with.
+//
+//nolint:gochecknoglobals
+var deprecatedDistros = map[Distro]distroDeprecation{
+	AKSUbuntu1604:                       {ubuntu1604EOLReason, AKSUbuntuContainerd2204},
+	AKS1604Deprecated:                   {ubuntu1604EOLReason, AKSUbuntuContainerd2204},
+	Ubuntu1804:                          {ubuntu1804EOLReason, AKSUbuntuContainerd2204},
+	Ubuntu1804Gen2:                      {ubuntu1804EOLReason, AKSUbuntuContainerd2204Gen2},
+	AKSUbuntu1804:                       {ubuntu1804EOLReason, AKSUbuntuContainerd2204},
+	AKS1804Deprecated:                   {ubuntu1804EOLReason, AKSUbuntuContainerd2204},
+	AKSUbuntuGPU1804:                    {ubuntu1804EOLReason, AKSUbuntuContainerd2204},
+	AKSUbuntuGPU1804Gen2:                {ubuntu1804EOLReason, AKSUbuntuContainerd2204Gen2},
+	AKSUbuntuContainerd1804:             {ubuntu1804EOLReason, AKSUbuntuContainerd2204},
+	AKSUbuntuContainerd1804Gen2:         {ubuntu1804EOLReason, AKSUbuntuContainerd2204Gen2},
+	AKSUbuntuGPUContainerd1804:          {ubuntu1804EOLReason, AKSUbuntuContainerd2204},
+	AKSUbuntuGPUContainerd1804Gen2:      {ubuntu1804EOLReason, AKSUbuntuContainerd2204Gen2},
+	AKSUbuntuFipsContainerd1804:         {ubuntu1804EOLReason, AKSUbuntuFipsContainerd2204},
+	AKSUbuntuFipsContainerd1804Gen2:     {ubuntu1804EOLReason, AKSUbuntuFipsContainerd2204Gen2},
+	AKSUbuntuEdgeZoneContainerd1804:     {ubuntu1804EOLReason, AKSUbuntuEdgeZoneContainerd2204},
+	AKSUbuntuEdgeZoneContainerd1804Gen2: {ubuntu1804EOLReason, AKSUbuntuEdgeZoneContainerd2204Gen2},
+	AKSCBLMarinerV1:                     {"CBL-Mariner 1.0 has reached end of life", AKSAzureLinuxV2},
+	AKSWindows2019:                      {"the Docker container runtime is no longer supported on Windows", AKSWindows2019Containerd},
+}
+
+// DeprecatedDistros returns the deprecated distros, mapped to a message naming the distro to use instead.
+func DeprecatedDistros() map[Distro]string {
+	distros := make(map[Distro]string, len(deprecatedDistros))
+	for distro, deprecation := range deprecatedDistros {
+		distros[distro] = deprecation.message()
+	}
+	return distros
+}
+
+// IsDeprecated returns true if the distro is deprecated.
+func (d Distro) IsDeprecated() bool {
+	_, ok := deprecatedDistros[d]
+	return ok
+}
+
+func (d distroDeprecation) message() string {
+	return fmt.Sprintf("%s, use %s instead", d.reason, d.replacement)
+}
+
+// DeprecatedDistroError is returned when a node is bootstrapped with a deprecated distro.
+type DeprecatedDistroError struct {
+	Distro  Distro
+	Message string
+}
+
+func (err *DeprecatedDistroError) Error() string {
+	return fmt.Sprintf("distro %s is deprecated: %s", err.Distro, err.Message)
+}
@@ -0,0 +1,34 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package datamodel
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDeprecatedDistros(t *testing.T) {
+	distros := DeprecatedDistros()
+	for _, distro := range []Distro{AKSUbuntu1604, AKSUbuntuContainerd1804Gen2, AKSCBLMarinerV1, AKSWindows2019} {
+		if _, ok := distros[distro]; !ok || !distro.IsDeprecated() {
+			t.Errorf("expected distro %s to be deprecated", distro)
+		}
+	}
+	for _, distro := range []Distro{AKSUbuntuContainerd2204, AKSAzureLinuxV2, AKSWindows2022Containerd} {
+		if _, ok := distros[distro]; ok || distro.IsDeprecated() {
+			t.Errorf("expected distro %s not to be deprecated", distro)
+		}
+	}
+}
+
+func TestDeprecatedDistrosReplacements(t *testing.T) {
+	for distro, deprecation := range deprecatedDistros {
+		if deprecation.replacement.IsDeprecated() {
+			t.Errorf("distro %s is replaced by %s, which is deprecated as well", distro, deprecation.replacement)
+		}
+		if message := DeprecatedDistros()[distro]; !strings.Contains(message, string(deprecation.replacement)) {
+			t.Errorf("expected the message of distro %s to name its replacement %s, got %q", distro, deprecation.replacement, message)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package datamodel
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// featureGateDefault is a kubelet feature gate set on the nodes of the Kubernetes versions matching versionConstraint.
+type featureGateDefault struct {
+	name              string
+	value             bool
+	versionConstraint string
+	linuxOnly         bool
+}
+
+//nolint:gochecknoglobals
+var (
+	featureGateDefaults = []featureGateDefault{
+		// dynamic kubelet config is not supported on AKS nodes.
+		{name: "DynamicKubeletConfig", value: false, versionConstraint: ">= 1.11.0 < 1.24.0"},
+		// keeps the GPU accelerator usage metrics of cAdvisor used by Container Insights.
+		{name: "DisableAcceleratorUsageMetrics", value: false, versionConstraint: ">= 1.20.0 < 1.25.0", linuxOnly: true},
+	}
+
+	/* removedFeatureGates maps kubelet feature gates to the Kubernetes version they can no longer be set from, see
+	https://kubernetes.io/docs/reference/command-line-tools-reference/feature-gates-removed/. DynamicKubeletConfig
+	is dropped from 1.24 on AKS nodes, where false is its only value. */
+	removedFeatureGates = map[string]string{
+		"DynamicKubeletConfig":           "1.24.0",
+		"IPv6DualStack":                  "1.25.0",
+		"ExpandCSIVolumes":               "1.26.0",
+		"CSIMigration":                   "1.27.0",
+		"DisableAcceleratorUsageMetrics": "1.27.0",
+		"EphemeralContainers":            "1.27.0",
+		"DevicePlugins":                  "1.28.0",
+		"KubeletPodResources":            "1.28.0",
+		"TopologyManager":                "1.29.0",
+	}
+
+	featureGateNameRegex = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*$`)
+)
+
+// DefaultFeatureGates returns the kubelet feature gates AKS forces on the nodes of the Kubernetes version.
+func DefaultFeatureGates(orchestratorProfile *OrchestratorProfile, isWindows bool) map[string]bool {
+	gates := map[string]bool{}
+	for _, gate := range featureGateDefaults {
+		if gate.linuxOnly && isWindows {
+			continue
+		}
+		if orchestratorProfile.VersionIs(gate.versionConstraint) {
+			gates[gate.name] = gate.value
+		}
+	}
+	return gates
+}
+
+/*
+ResolvedFeatureGates returns the effective kubelet feature gates of the node, the --feature-gates kubelet flag with
+the version defaults of DefaultFeatureGates forced over it. An error is returned for malformed gate names, gates
+removed in the Kubernetes version of the node are reported by RemovedFeatureGateWarnings instead.
+*/
+func (config *NodeBootstrappingConfiguration) ResolvedFeatureGates() (map[string]bool, error) {
+	gates := map[string]bool{}
+	for _, pair := range strings.Split(config.KubeletConfig["--feature-gates"], ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, value, found := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if !found || !featureGateNameRegex.MatchString(name) {
+			return nil, errors.Errorf("invalid feature gate '%s', must be of the form <name>=<true|false>", pair)
+		}
+		// like in the kubelet config file, values other than true disable the gate.
+		gates[name], _ = strconv.ParseBool(strings.TrimSpace(value))
+	}
+	isWindows := config.AgentPoolProfile != nil && config.AgentPoolProfile.IsWindows()
+	for name, value := range DefaultFeatureGates(config.orchestratorProfile(), isWindows) {
+		gates[name] = value
+	}
+	return gates, nil
+}
+
+/*
+RemovedFeatureGateWarnings returns a warning for each gate of the --feature-gates kubelet flag which is removed in the
+Kubernetes version of the node, kubelet may refuse to start with them. Malformed gates are left to ResolvedFeatureGates.
+*/
+func (config *NodeBootstrappingConfiguration) RemovedFeatureGateWarnings() []string {
+	orchestratorProfile := config.orchestratorProfile()
+	var warnings []string
+	for _, pair := range strings.Split(config.KubeletConfig["--feature-gates"], ",") {
+		name, _, _ := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if removedIn, removed := removedFeatureGates[name]; removed && orchestratorProfile.VersionIs(">= "+removedIn) {
+			warnings = append(warnings, fmt.Sprintf("feature gate %s was removed in Kubernetes %s and should not be set on version %s",
+				name, removedIn, orchestratorProfile.OrchestratorVersion))
+		}
+	}
+	return warnings
+}
+
+func (config *NodeBootstrappingConfiguration) orchestratorProfile() *OrchestratorProfile {
+	if config.ContainerService == nil || config.ContainerService.Properties == nil {
+		return nil
+	}
+	return config.ContainerService.Properties.OrchestratorProfile
+}
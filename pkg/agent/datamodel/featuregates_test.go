@@ -0,0 +1,149 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package datamodel
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResolvedFeatureGates(t *testing.T) {
+	cases := []struct {
+		name              string
+		kubernetesVersion string
+		isWindows         bool
+		featureGates      string
+		expected          map[string]bool
+		expectErr         bool
+	}{
+		{
+			name:              "version defaults",
+			kubernetesVersion: "1.22.6",
+			expected:          map[string]bool{"DynamicKubeletConfig": false, "DisableAcceleratorUsageMetrics": false},
+		},
+		{
+			name:              "Linux only defaults are not set on Windows",
+			kubernetesVersion: "1.22.6",
+			isWindows:         true,
+			expected:          map[string]bool{"DynamicKubeletConfig": false},
+		},
+		{
+			name:              "version defaults are forced over the user gates",
+			kubernetesVersion: "1.22.6",
+			featureGates:      "RotateKubeletServerCertificate=true,DisableAcceleratorUsageMetrics=true,DynamicKubeletConfig=true",
+			expected: map[string]bool{
+				"DynamicKubeletConfig":           false,
+				"DisableAcceleratorUsageMetrics": false,
+				"RotateKubeletServerCertificate": true,
+			},
+		},
+		{
+			name:              "no version defaults on recent versions",
+			kubernetesVersion: "1.29.2",
+			featureGates:      "RotateKubeletServerCertificate=true",
+			expected:          map[string]bool{"RotateKubeletServerCertificate": true},
+		},
+		{
+			name:              "gate removed in the version",
+			kubernetesVersion: "1.29.2",
+			featureGates:      "TopologyManager=true",
+			expected:          map[string]bool{"TopologyManager": true},
+		},
+		{
+			name:              "gate removed in a later version",
+			kubernetesVersion: "1.28.5",
+			featureGates:      "TopologyManager=true",
+			expected:          map[string]bool{"TopologyManager": true},
+		},
+		{
+			name:              "malformed gate",
+			kubernetesVersion: "1.29.2",
+			featureGates:      "RotateKubeletServerCertificate",
+			expectErr:         true,
+		},
+		{
+			name:              "invalid gate name",
+			kubernetesVersion: "1.29.2",
+			featureGates:      "Rotate Kubelet=true",
+			expectErr:         true,
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+			osType := Linux
+			if c.isWindows {
+				osType = Windows
+			}
+			config := &NodeBootstrappingConfiguration{
+				ContainerService: &ContainerService{
+					Properties: &Properties{
+						OrchestratorProfile: &OrchestratorProfile{OrchestratorVersion: c.kubernetesVersion},
+					},
+				},
+				AgentPoolProfile: &AgentPoolProfile{OSType: osType},
+				KubeletConfig:    map[string]string{"--feature-gates": c.featureGates},
+			}
+			gates, err := config.ResolvedFeatureGates()
+			if c.expectErr {
+				if err == nil {
+					t.Errorf("expected an error for feature gates %q, but got none", c.featureGates)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error for feature gates %q, but got %v", c.featureGates, err)
+			}
+			if !reflect.DeepEqual(gates, c.expected) {
+				t.Errorf("expected feature gates %v, got %v", c.expected, gates)
+			}
+		})
+	}
+}
+
+func TestRemovedFeatureGateWarnings(t *testing.T) {
+	cases := []struct {
+		name              string
+		kubernetesVersion string
+		featureGates      string
+		expected          []string
+	}{
+		{
+			name:              "no removed gates",
+			kubernetesVersion: "1.27.3",
+			featureGates:      "RotateKubeletServerCertificate=true",
+		},
+		{
+			name:              "gate removed in the version",
+			kubernetesVersion: "1.27.3",
+			featureGates:      "CSIMigration=true,RotateKubeletServerCertificate=true",
+			expected:          []string{"feature gate CSIMigration was removed in Kubernetes 1.27.0 and should not be set on version 1.27.3"},
+		},
+		{
+			name:              "gate removed in a later version",
+			kubernetesVersion: "1.26.6",
+			featureGates:      "CSIMigration=true",
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+			config := &NodeBootstrappingConfiguration{
+				ContainerService: &ContainerService{
+					Properties: &Properties{
+						OrchestratorProfile: &OrchestratorProfile{OrchestratorVersion: c.kubernetesVersion},
+					},
+				},
+				KubeletConfig: map[string]string{"--feature-gates": c.featureGates},
+			}
+			if warnings := config.RemovedFeatureGateWarnings(); !reflect.DeepEqual(warnings, c.expected) {
+				t.Errorf("expected warnings %v, got %v", c.expected, warnings)
+			}
+		})
+	}
+}
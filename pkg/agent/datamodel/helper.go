@@ -6,10 +6,18 @@ package datamodel
 import (
 	"bufio"
 	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/pem"
 	"fmt"
+	"math/big"
+	"net"
 	"net/url"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -19,6 +27,17 @@ const (
 	minSizeNamePartCount = 2
 )
 
+// Minimum CIDR sizes, expressed as the longest allowed prefix length.
+const (
+	// a pod CIDR must at least fit a /24 (IPv4) or /120 (IPv6) range for a single node,
+	// which is large enough for the maximum max-pods value.
+	maxPodCIDRPrefixLengthIPv4 = 24
+	maxPodCIDRPrefixLengthIPv6 = 120
+	// a service CIDR must at least fit the kubernetes service IP and the DNS service IP (x.x.x.10).
+	maxServiceCIDRPrefixLengthIPv4 = 28
+	maxServiceCIDRPrefixLengthIPv6 = 124
+)
+
 // ValidateDNSPrefix is a helper function to check that a DNS Prefix is valid.
 func ValidateDNSPrefix(dnsName string) error {
 	dnsNameRegex := `^([A-Za-z][A-Za-z0-9-]{1,43}[A-Za-z0-9])$`
@@ -35,6 +54,776 @@ func ValidateDNSPrefix(dnsName string) error {
 	return nil
 }
 
+// ValidateHostnameOrIP is a helper function to check that a string is a valid IP address or RFC 1123 hostname.
+func ValidateHostnameOrIP(host string) error {
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+	hostnameRegex := `^([a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)(\.[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$`
+	const maxHostnameLength = 253
+	re, err := regexp.Compile(hostnameRegex)
+	if err != nil {
+		return err
+	}
+	if len(host) > maxHostnameLength || !re.MatchString(host) {
+		return errors.Errorf("'%s' is neither a valid IP address nor a valid hostname", host)
+	}
+	return nil
+}
+
+/*
+ValidateCIDRs is a helper function to check that the service, pod and node CIDRs are valid,
+don't overlap with each other and are large enough. Each of them can be a comma-separated list
+of CIDRs for dual-stack clusters, and empty values are skipped.
+*/
+func ValidateCIDRs(serviceCIDR, podCIDR, nodeCIDR string) error {
+	type namedCIDR struct {
+		kind  string
+		cidr  *net.IPNet
+		value string
+	}
+	var cidrs []namedCIDR
+	for _, c := range []struct {
+		kind  string
+		value string
+	}{
+		{"service", serviceCIDR},
+		{"pod", podCIDR},
+		{"node", nodeCIDR},
+	} {
+		if c.value == "" {
+			continue
+		}
+		for _, value := range strings.Split(c.value, ",") {
+			value = strings.TrimSpace(value)
+			_, cidr, err := net.ParseCIDR(value)
+			if err != nil {
+				return errors.Errorf("%s CIDR '%s' is invalid: %v", c.kind, value, err)
+			}
+			if err = validateCIDRSize(c.kind, value, cidr); err != nil {
+				return err
+			}
+			cidrs = append(cidrs, namedCIDR{kind: c.kind, cidr: cidr, value: value})
+		}
+	}
+
+	for i := range cidrs {
+		for j := i + 1; j < len(cidrs); j++ {
+			a, b := cidrs[i], cidrs[j]
+			if a.cidr.Contains(b.cidr.IP) || b.cidr.Contains(a.cidr.IP) {
+				return errors.Errorf("%s CIDR '%s' overlaps with %s CIDR '%s'", a.kind, a.value, b.kind, b.value)
+			}
+		}
+	}
+	return nil
+}
+
+/*
+ValidateDualStackCIDRs is a helper function to check that each of the service and pod CIDRs of a dual-stack
+cluster is a pair of an IPv4 and an IPv6 CIDR, and that both pairs list the IP families in the same order. The node
+CIDR is not checked, the node subnet is usually single-stack. Empty values are skipped, the CIDRs themselves are
+validated by ValidateCIDRs.
+*/
+func ValidateDualStackCIDRs(serviceCIDR, podCIDR string) error {
+	const dualStackCIDRCount = 2
+	var primaryIPv4 *bool
+	var primaryKind string
+	for _, c := range []struct {
+		kind  string
+		value string
+	}{
+		{"service", serviceCIDR},
+		{"pod", podCIDR},
+	} {
+		if c.value == "" {
+			continue
+		}
+		values := strings.Split(c.value, ",")
+		if len(values) != dualStackCIDRCount {
+			return errors.Errorf("dual-stack %s CIDR '%s' must be an IPv4 and an IPv6 CIDR", c.kind, c.value)
+		}
+		isIPv4 := make([]bool, 0, dualStackCIDRCount)
+		for _, value := range values {
+			ip, _, err := net.ParseCIDR(strings.TrimSpace(value))
+			if err != nil {
+				return errors.Errorf("%s CIDR '%s' is invalid: %v", c.kind, strings.TrimSpace(value), err)
+			}
+			isIPv4 = append(isIPv4, ip.To4() != nil)
+		}
+		if isIPv4[0] == isIPv4[1] {
+			return errors.Errorf("dual-stack %s CIDR '%s' must be an IPv4 and an IPv6 CIDR", c.kind, c.value)
+		}
+		if primaryIPv4 == nil {
+			primaryIPv4, primaryKind = &isIPv4[0], c.kind
+			continue
+		}
+		if *primaryIPv4 != isIPv4[0] {
+			return errors.Errorf("dual-stack %s CIDR '%s' lists the IP families in a different order than the %s CIDR",
+				c.kind, c.value, primaryKind)
+		}
+	}
+	return nil
+}
+
+func validateCIDRSize(kind, value string, cidr *net.IPNet) error {
+	ones, bits := cidr.Mask.Size()
+	isIPv4 := cidr.IP.To4() != nil
+	maxPrefixLength := bits
+	switch {
+	case kind == "pod" && isIPv4:
+		maxPrefixLength = maxPodCIDRPrefixLengthIPv4
+	case kind == "pod":
+		maxPrefixLength = maxPodCIDRPrefixLengthIPv6
+	case kind == "service" && isIPv4:
+		maxPrefixLength = maxServiceCIDRPrefixLengthIPv4
+	case kind == "service":
+		maxPrefixLength = maxServiceCIDRPrefixLengthIPv6
+	}
+	if ones > maxPrefixLength {
+		return errors.Errorf("%s CIDR '%s' is too small, the prefix length must be at most /%d", kind, value, maxPrefixLength)
+	}
+	return nil
+}
+
+// ValidateImageReference is a helper function to check that a string is a valid container image reference.
+func ValidateImageReference(image string) error {
+	imageRegex := `^(?:[a-zA-Z0-9.-]+(?::[0-9]+)?/)?[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*` +
+		`(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*(?::[\w][\w.-]{0,127})?(?:@sha256:[a-f0-9]{64})?$`
+	re, err := regexp.Compile(imageRegex)
+	if err != nil {
+		return err
+	}
+	if !re.MatchString(image) {
+		return errors.Errorf("'%s' is not a valid container image reference", image)
+	}
+	return nil
+}
+
+// ValidateDockerConfigJSON is a helper function to check that a base64 encoded string is a docker config JSON.
+func ValidateDockerConfigJSON(encoded string) error {
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return errors.Wrap(err, "docker config JSON is not base64 encoded")
+	}
+	var dockerConfig struct {
+		Auths map[string]json.RawMessage `json:"auths"`
+	}
+	if err = json.Unmarshal(decoded, &dockerConfig); err != nil {
+		return errors.Wrap(err, "invalid docker config JSON")
+	}
+	if len(dockerConfig.Auths) == 0 {
+		return errors.New("docker config JSON has no auths")
+	}
+	return nil
+}
+
+// ValidateEvictionThreshold is a helper function to check a kubelet eviction signal and its threshold.
+// Thresholds are either a percentage or a resource quantity, for example "10%" or "500Mi".
+func ValidateEvictionThreshold(signal, threshold string) error {
+	const maxPercent = 100
+	switch signal {
+	case "memory.available", "nodefs.available", "nodefs.inodesFree", "imagefs.available", "imagefs.inodesFree",
+		"containerfs.available", "containerfs.inodesFree", "pid.available":
+	default:
+		return errors.Errorf("unknown eviction signal '%s'", signal)
+	}
+	if percent, found := strings.CutSuffix(threshold, "%"); found {
+		value, err := strconv.ParseFloat(percent, 64)
+		if err != nil || value < 0 || value > maxPercent {
+			return errors.Errorf("eviction threshold '%s' of signal %s must be a percentage between 0%% and 100%%", threshold, signal)
+		}
+		return nil
+	}
+	quantityRegex := regexp.MustCompile(`^[0-9]+(\.[0-9]+)?(Ki|Mi|Gi|Ti|Pi|Ei|k|M|G|T|P|E)?$`)
+	if !quantityRegex.MatchString(threshold) {
+		return errors.Errorf("eviction threshold '%s' of signal %s must be a percentage or a quantity", threshold, signal)
+	}
+	return nil
+}
+
+// ValidateArmoredGPGPublicKey is a helper function to check that a string is an ASCII armored GPG public key.
+func ValidateArmoredGPGPublicKey(key string) error {
+	const (
+		header          = "-----BEGIN PGP PUBLIC KEY BLOCK-----"
+		footer          = "-----END PGP PUBLIC KEY BLOCK-----"
+		publicKeyTag    = 6
+		packetTagBit    = 0x80
+		newFormatBit    = 0x40
+		newFormatTag    = 0x3f
+		oldFormatTag    = 0x3c
+		oldFormatOffset = 2
+	)
+	lines := strings.Split(strings.TrimSpace(strings.ReplaceAll(key, "\r\n", "\n")), "\n")
+	if len(lines) < 3 || strings.TrimSpace(lines[0]) != header || strings.TrimSpace(lines[len(lines)-1]) != footer {
+		return errors.New("GPG public key must be ASCII armored")
+	}
+	lines = lines[1 : len(lines)-1]
+	// armor headers, such as "Version: ...", are separated from the data by a blank line.
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			lines = lines[i+1:]
+			break
+		}
+	}
+	var body strings.Builder
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		// the optional CRC24 checksum line starts with "=".
+		if strings.HasPrefix(line, "=") {
+			break
+		}
+		body.WriteString(line)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(body.String())
+	if err != nil {
+		return errors.Wrap(err, "GPG public key armor is not base64 encoded")
+	}
+	if len(decoded) == 0 || decoded[0]&packetTagBit == 0 {
+		return errors.New("GPG public key armor does not contain an OpenPGP packet")
+	}
+	tag := int(decoded[0]&oldFormatTag) >> oldFormatOffset
+	if decoded[0]&newFormatBit != 0 {
+		tag = int(decoded[0] & newFormatTag)
+	}
+	if tag != publicKeyTag {
+		return errors.New("GPG public key armor does not start with a public key packet")
+	}
+	return nil
+}
+
+// reservedAdminUsernames are the admin usernames rejected by Azure VM provisioning, for both Linux and Windows.
+//
+//nolint:gochecknoglobals
+var reservedAdminUsernames = map[string]bool{
+	"1": true, "123": true, "a": true, "actuser": true, "adm": true, "admin": true, "admin1": true,
+	"admin2": true, "administrator": true, "aspnet": true, "backup": true, "console": true, "david": true,
+	"guest": true, "john": true, "owner": true, "root": true, "server": true, "sql": true, "support": true,
+	"support_388945a0": true, "sys": true, "test": true, "test1": true, "test2": true, "test3": true,
+	"user": true, "user1": true, "user2": true, "user3": true, "user4": true, "user5": true,
+}
+
+// IsValidAdminUsername is a helper function to check that an admin username is accepted by the given OS.
+func IsValidAdminUsername(name string, os OSType) error {
+	const (
+		maxLinuxAdminUsernameLength   = 64
+		maxWindowsAdminUsernameLength = 20
+	)
+	if name == "" {
+		return errors.New("admin username must not be empty")
+	}
+	if reservedAdminUsernames[strings.ToLower(name)] {
+		return errors.Errorf("admin username '%s' is reserved", name)
+	}
+	switch os {
+	case Linux:
+		if len(name) > maxLinuxAdminUsernameLength {
+			return errors.Errorf("Linux admin username '%s' must not be longer than %d characters", name, maxLinuxAdminUsernameLength)
+		}
+		// the pattern of the AKS API's linuxProfile.adminUsername.
+		if !regexp.MustCompile(`^[A-Za-z][-A-Za-z0-9_]*$`).MatchString(name) {
+			return errors.Errorf("Linux admin username '%s' must start with a letter"+
+				" and contain only letters, digits, underscores, and hyphens", name)
+		}
+	case Windows:
+		if len(name) > maxWindowsAdminUsernameLength {
+			return errors.Errorf("Windows admin username '%s' must not be longer than %d characters", name, maxWindowsAdminUsernameLength)
+		}
+		if strings.ContainsAny(name, `\/"[]:|<>+=;,?*@`) || strings.HasSuffix(name, ".") {
+			return errors.Errorf("Windows admin username '%s' must not contain any of \\/\"[]:|<>+=;,?*@ or end with a period", name)
+		}
+	default:
+		return errors.Errorf("unknown OS type '%s'", os)
+	}
+	return nil
+}
+
+// sshECDSACurves maps the ECDSA SSH public key types to the names of their curves.
+//
+//nolint:gochecknoglobals
+var sshECDSACurves = map[string]string{
+	"ecdsa-sha2-nistp256":                "nistp256",
+	"ecdsa-sha2-nistp384":                "nistp384",
+	"ecdsa-sha2-nistp521":                "nistp521",
+	"sk-ecdsa-sha2-nistp256@openssh.com": "nistp256",
+}
+
+const (
+	// sshCertificateSuffix is the suffix of the key types of OpenSSH certificates, e.g. ssh-ed25519-cert-v01@openssh.com.
+	sshCertificateSuffix = "-cert-v01@openssh.com"
+	// sshSecurityKeySuffix is the suffix of the key types of FIDO security keys, e.g. sk-ssh-ed25519@openssh.com.
+	sshSecurityKeySuffix = "@openssh.com"
+)
+
+// sshPublicKeyType returns the type of the public key of an SSH key type, i.e. without the certificate suffix.
+func sshPublicKeyType(keyType string) (string, bool) {
+	publicKeyType := keyType
+	isCertificate := strings.HasSuffix(keyType, sshCertificateSuffix)
+	if isCertificate {
+		publicKeyType = strings.TrimSuffix(keyType, sshCertificateSuffix)
+		if strings.HasPrefix(publicKeyType, "sk-") {
+			publicKeyType += sshSecurityKeySuffix
+		}
+	}
+	return publicKeyType, isCertificate
+}
+
+// isSSHKeyType returns whether keyType is a supported SSH public key or certificate type.
+func isSSHKeyType(keyType string) bool {
+	publicKeyType, _ := sshPublicKeyType(keyType)
+	_, isECDSA := sshECDSACurves[publicKeyType]
+	return isECDSA || publicKeyType == "ssh-rsa" || publicKeyType == "ssh-ed25519" || publicKeyType == "sk-ssh-ed25519@openssh.com"
+}
+
+// splitSSHKeyOptions splits the options of an authorized_keys entry, which may contain quoted whitespace, from the key.
+func splitSSHKeyOptions(entry string) (string, string) {
+	inQuotes := false
+	for i := 0; i < len(entry); i++ {
+		switch entry[i] {
+		case '\\':
+			i++
+		case '"':
+			inQuotes = !inQuotes
+		case ' ', '\t':
+			if !inQuotes {
+				return entry[:i], strings.TrimSpace(entry[i:])
+			}
+		}
+	}
+	return entry, ""
+}
+
+/*
+ValidateSSHPublicKey is a helper function to check that a key is a valid SSH public key in the authorized_keys
+format, i.e. "[options] <type> <base64 key data> [comment]". RSA, ed25519 and ECDSA keys, their FIDO security key
+variants and OpenSSH certificates of them are supported. RSA keys must have at least 2048 bits.
+*/
+func ValidateSSHPublicKey(key string) error {
+	const minSSHKeyFields = 2
+	key = strings.TrimSpace(key)
+	if fields := strings.Fields(key); len(fields) > 0 && !isSSHKeyType(fields[0]) {
+		_, key = splitSSHKeyOptions(key)
+	}
+	fields := strings.Fields(key)
+	if len(fields) < minSSHKeyFields {
+		return errors.New("SSH public key must be of the form '[options] <type> <base64 key data> [comment]'")
+	}
+	keyType := fields[0]
+	if !isSSHKeyType(keyType) {
+		return errors.Errorf("unsupported SSH public key type '%s'", keyType)
+	}
+	data, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return errors.Errorf("SSH public key data is not valid base64: %s", err)
+	}
+	dataType, data, ok := readSSHString(data)
+	if !ok {
+		return errors.New("SSH public key data is truncated")
+	}
+	if string(dataType) != keyType {
+		return errors.Errorf("SSH public key data is of type '%s', not '%s'", dataType, keyType)
+	}
+	publicKeyType, isCertificate := sshPublicKeyType(keyType)
+	if isCertificate {
+		if _, data, ok = readSSHString(data); !ok {
+			return errors.New("SSH certificate nonce is truncated")
+		}
+	}
+	if data, err = readSSHPublicKey(publicKeyType, data); err != nil {
+		return err
+	}
+	// the remaining certificate fields are signed by the CA and verified by sshd.
+	if !isCertificate && len(data) > 0 {
+		return errors.New("SSH public key data has trailing bytes")
+	}
+	return nil
+}
+
+// readSSHPublicKey reads and validates the key fields of an SSH public key of the given type, and returns the remaining data.
+func readSSHPublicKey(publicKeyType string, data []byte) ([]byte, error) {
+	const (
+		minRSAKeyBits      = 2048
+		ed25519KeyByteSize = 32
+	)
+	var ok bool
+	switch publicKeyType {
+	case "ssh-rsa":
+		var exponent, modulus []byte
+		exponent, data, ok = readSSHString(data)
+		if ok {
+			modulus, data, ok = readSSHString(data)
+		}
+		if !ok || len(exponent) == 0 || len(modulus) == 0 {
+			return nil, errors.New("SSH public key data is truncated")
+		}
+		if bits := new(big.Int).SetBytes(modulus).BitLen(); bits < minRSAKeyBits {
+			return nil, errors.Errorf("SSH RSA public key must have at least %d bits, got %d", minRSAKeyBits, bits)
+		}
+	case "ssh-ed25519", "sk-ssh-ed25519@openssh.com":
+		var publicKey []byte
+		publicKey, data, ok = readSSHString(data)
+		if !ok || len(publicKey) != ed25519KeyByteSize {
+			return nil, errors.Errorf("SSH ed25519 public key must be %d bytes", ed25519KeyByteSize)
+		}
+	default:
+		var keyCurve, point []byte
+		keyCurve, data, ok = readSSHString(data)
+		if ok {
+			point, data, ok = readSSHString(data)
+		}
+		if !ok || len(point) == 0 {
+			return nil, errors.New("SSH public key data is truncated")
+		}
+		if curve := sshECDSACurves[publicKeyType]; string(keyCurve) != curve {
+			return nil, errors.Errorf("SSH public key curve '%s' does not match key type '%s'", keyCurve, publicKeyType)
+		}
+	}
+	if strings.HasPrefix(publicKeyType, "sk-") {
+		// security keys end with the FIDO application of the key, e.g. "ssh:".
+		var application []byte
+		if application, data, ok = readSSHString(data); !ok || len(application) == 0 {
+			return nil, errors.New("SSH security key application is missing")
+		}
+	}
+	return data, nil
+}
+
+// readSSHString reads a length-prefixed string of the SSH wire format, and returns it with the remaining data.
+func readSSHString(data []byte) ([]byte, []byte, bool) {
+	const lengthByteSize = 4
+	if len(data) < lengthByteSize {
+		return nil, nil, false
+	}
+	length := binary.BigEndian.Uint32(data)
+	data = data[lengthByteSize:]
+	if uint64(len(data)) < uint64(length) {
+		return nil, nil, false
+	}
+	return data[:length], data[length:], true
+}
+
+// ociRuntimeSpec is the part of the OCI runtime spec which is validated, the other fields are passed on as is.
+type ociRuntimeSpec struct {
+	OCIVersion string `json:"ociVersion"`
+	Process    *struct {
+		Rlimits []struct {
+			Type string `json:"type"`
+			Hard uint64 `json:"hard"`
+			Soft uint64 `json:"soft"`
+		} `json:"rlimits"`
+	} `json:"process"`
+	Root *struct {
+		Path string `json:"path"`
+	} `json:"root"`
+	Mounts []struct {
+		Destination string   `json:"destination"`
+		Type        string   `json:"type"`
+		Source      string   `json:"source"`
+		Options     []string `json:"options"`
+	} `json:"mounts"`
+	Linux map[string]json.RawMessage `json:"linux"`
+}
+
+/*
+ValidateOCIRuntimeSpec is a helper function to check that spec is an OCI runtime spec JSON document, as used by
+containerd as the base runtime spec. The ociVersion, rlimits, root and mounts are validated, the types of the other
+fields are left to the runtime.
+*/
+func ValidateOCIRuntimeSpec(spec []byte) error {
+	var ociSpec ociRuntimeSpec
+	if err := json.Unmarshal(spec, &ociSpec); err != nil {
+		return errors.Wrap(err, "OCI runtime spec is not valid JSON")
+	}
+	if !regexp.MustCompile(`^1\.[0-9]+\.[0-9]+(-[0-9A-Za-z.-]+)?$`).MatchString(ociSpec.OCIVersion) {
+		return errors.Errorf("OCI runtime spec must have an ociVersion of the form 1.x.y, got '%s'", ociSpec.OCIVersion)
+	}
+	if ociSpec.Process != nil {
+		rlimitTypeRegex := regexp.MustCompile(`^RLIMIT_[A-Z]+$`)
+		for _, rlimit := range ociSpec.Process.Rlimits {
+			if !rlimitTypeRegex.MatchString(rlimit.Type) {
+				return errors.Errorf("OCI runtime spec has an invalid rlimit type '%s'", rlimit.Type)
+			}
+			if rlimit.Soft > rlimit.Hard {
+				return errors.Errorf("OCI runtime spec rlimit %s has a soft limit %d above its hard limit %d", rlimit.Type, rlimit.Soft, rlimit.Hard)
+			}
+		}
+	}
+	if ociSpec.Root != nil && ociSpec.Root.Path == "" {
+		return errors.New("OCI runtime spec root must have a path")
+	}
+	for i, mount := range ociSpec.Mounts {
+		if !strings.HasPrefix(mount.Destination, "/") {
+			return errors.Errorf("OCI runtime spec mount %d must have an absolute destination, got '%s'", i, mount.Destination)
+		}
+	}
+	return nil
+}
+
+/*
+ValidateCABundle is a helper function to check that bundle is a PEM bundle of one or more x509 CA certificates, with
+nothing but whitespace between and after the certificates.
+*/
+func ValidateCABundle(bundle []byte) error {
+	var count int
+	rest := bundle
+	for len(bytes.TrimSpace(rest)) > 0 {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return errors.Errorf("CA certificate bundle has invalid PEM data after %d certificates", count)
+		}
+		if block.Type != "CERTIFICATE" {
+			return errors.Errorf("CA certificate bundle must only contain CERTIFICATE PEM blocks, got '%s'", block.Type)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return errors.Wrapf(err, "CA certificate bundle has an invalid certificate at index %d", count)
+		}
+		if !cert.IsCA {
+			return errors.Errorf("certificate '%s' of the CA certificate bundle is not a CA certificate", cert.Subject)
+		}
+		count++
+	}
+	if count == 0 {
+		return errors.New("CA certificate bundle must contain at least one certificate")
+	}
+	return nil
+}
+
+/*
+ValidateCertificateChain is a helper function to check that cert is a PEM encoded x509 certificate which is valid now
+and chains to a certificate of the PEM CA bundle caBundle, e.g. that the API server certificate is signed by the
+cluster CA.
+*/
+func ValidateCertificateChain(cert, caBundle []byte) error {
+	block, rest := pem.Decode(cert)
+	if block == nil || len(bytes.TrimSpace(rest)) > 0 {
+		return errors.New("certificate must be a single PEM block")
+	}
+	if block.Type != "CERTIFICATE" {
+		return errors.Errorf("certificate must be a CERTIFICATE PEM block, got '%s'", block.Type)
+	}
+	parsed, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return errors.Wrap(err, "invalid certificate")
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(caBundle) {
+		return errors.New("CA certificate bundle must contain at least one certificate")
+	}
+	if _, err = parsed.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}); err != nil {
+		return errors.Wrapf(err, "certificate '%s' does not chain to the CA certificate bundle", parsed.Subject)
+	}
+	return nil
+}
+
+/*
+ValidateUserAssignedIdentityID is a helper function to check that id is the ARM resource ID of a user-assigned
+managed identity, i.e.
+/subscriptions/<subscription ID>/resourceGroups/<resource group>/providers/Microsoft.ManagedIdentity/userAssignedIdentities/<name>.
+The segment names are case insensitive, like in ARM.
+*/
+func ValidateUserAssignedIdentityID(id string) error {
+	const (
+		identityIDSegmentCount       = 8
+		maxResourceGroupNameLength   = 90
+		minIdentityNameLength        = 3
+		maxIdentityNameLength        = 128
+		identityResourceProviderName = "Microsoft.ManagedIdentity"
+		identityResourceTypeName     = "userAssignedIdentities"
+	)
+	if !strings.HasPrefix(id, "/") {
+		return errors.Errorf("user-assigned identity ID '%s' must be an ARM resource ID starting with /subscriptions/", id)
+	}
+	segments := strings.Split(strings.TrimPrefix(id, "/"), "/")
+	if len(segments) != identityIDSegmentCount {
+		return errors.Errorf("user-assigned identity ID '%s' must have the form "+
+			"/subscriptions/<id>/resourceGroups/<name>/providers/Microsoft.ManagedIdentity/userAssignedIdentities/<name>", id)
+	}
+	for _, fixed := range []struct {
+		index int
+		name  string
+	}{
+		{0, "subscriptions"},
+		{2, "resourceGroups"},
+		{4, "providers"},
+		{6, identityResourceTypeName},
+	} {
+		if !strings.EqualFold(segments[fixed.index], fixed.name) {
+			return errors.Errorf("user-assigned identity ID '%s' has segment '%s' where '%s' is expected", id, segments[fixed.index], fixed.name)
+		}
+	}
+	if ValidateGUID(segments[1]) != nil {
+		return errors.Errorf("user-assigned identity ID '%s' has an invalid subscription ID '%s', it must be a GUID", id, segments[1])
+	}
+	resourceGroupRegex := regexp.MustCompile(`^[-\w._()]*[-\w_()]$`)
+	if len(segments[3]) > maxResourceGroupNameLength || !resourceGroupRegex.MatchString(segments[3]) {
+		return errors.Errorf("user-assigned identity ID '%s' has an invalid resource group name '%s'", id, segments[3])
+	}
+	if !strings.EqualFold(segments[5], identityResourceProviderName) {
+		return errors.Errorf("user-assigned identity ID '%s' must be of resource provider %s, got '%s'", id, identityResourceProviderName, segments[5])
+	}
+	identityNameRegex := regexp.MustCompile(`^[A-Za-z0-9][-A-Za-z0-9_]*$`)
+	if name := segments[7]; len(name) < minIdentityNameLength || len(name) > maxIdentityNameLength || !identityNameRegex.MatchString(name) {
+		return errors.Errorf("user-assigned identity ID '%s' has an invalid identity name '%s', it must be %d to %d alphanumerics, "+
+			"hyphens and underscores, starting with an alphanumeric", id, name, minIdentityNameLength, maxIdentityNameLength)
+	}
+	return nil
+}
+
+// ValidateGUID returns an error if s is not a GUID of the form 00000000-0000-0000-0000-000000000000, e.g. a tenant ID.
+func ValidateGUID(s string) error {
+	guidRegex := regexp.MustCompile(`^[0-9A-Fa-f]{8}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{12}$`)
+	if !guidRegex.MatchString(s) {
+		return errors.Errorf("'%s' is not a GUID of the form 00000000-0000-0000-0000-000000000000", s)
+	}
+	return nil
+}
+
+// ValidateTaint is a helper function to check that a node taint has a valid key, value, and effect.
+func ValidateTaint(taint Taint) error {
+	const (
+		maxTaintKeyPrefixLength = 253
+		maxTaintNameLength      = 63
+	)
+	nameRegex := regexp.MustCompile(`^[A-Za-z0-9]([-A-Za-z0-9_.]*[A-Za-z0-9])?$`)
+	name := taint.Key
+	if prefix, suffix, found := strings.Cut(taint.Key, "/"); found {
+		if len(prefix) > maxTaintKeyPrefixLength || ValidateHostnameOrIP(prefix) != nil || net.ParseIP(prefix) != nil {
+			return errors.Errorf("taint key '%s' must have a DNS subdomain prefix", taint.Key)
+		}
+		name = suffix
+	}
+	if len(name) > maxTaintNameLength || !nameRegex.MatchString(name) {
+		return errors.Errorf("taint key '%s' must be a qualified name of at most %d characters", taint.Key, maxTaintNameLength)
+	}
+	if taint.Value != "" && (len(taint.Value) > maxTaintNameLength || !nameRegex.MatchString(taint.Value)) {
+		return errors.Errorf("taint value '%s' of key %s must be at most %d alphanumeric characters, '-', '_' or '.'",
+			taint.Value, taint.Key, maxTaintNameLength)
+	}
+	switch taint.Effect {
+	case TaintEffectNoSchedule, TaintEffectPreferNoSchedule, TaintEffectNoExecute:
+	default:
+		return errors.Errorf("taint effect '%s' of key %s must be one of %s, %s or %s", taint.Effect, taint.Key,
+			TaintEffectNoSchedule, TaintEffectPreferNoSchedule, TaintEffectNoExecute)
+	}
+	return nil
+}
+
+/*
+DefaultMaxPods returns the max pods default of a node of the given VM size and network plugin. Nodes of other network
+plugins than Azure CNI default to DefaultMaxPodsKubenet, the kubelet default. Azure CNI nodes, whose pod IPs are all
+allocated from the node subnet up front, scale with the vCPUs of the VM size from the SKU capabilities table:
+DefaultMaxPodsAzureCNIMediumSKU from more than MaxPodsSmallSKUCPUs vCPUs and DefaultMaxPodsAzureCNILargeSKU from
+MaxPodsLargeSKUCPUs vCPUs. VM sizes which are not in the table fall back to DefaultMaxPodsAzureCNI.
+*/
+func DefaultMaxPods(vmSize string, networkPlugin string) int {
+	if !strings.EqualFold(networkPlugin, NetworkPluginAzure) {
+		return DefaultMaxPodsKubenet
+	}
+	caps, err := SKUCapabilities(vmSize)
+	switch {
+	case err != nil, caps.VCPUs <= MaxPodsSmallSKUCPUs:
+		return DefaultMaxPodsAzureCNI
+	case caps.VCPUs >= MaxPodsLargeSKUCPUs:
+		return DefaultMaxPodsAzureCNILargeSKU
+	default:
+		return DefaultMaxPodsAzureCNIMediumSKU
+	}
+}
+
+// GPU SKU families: the N-series, except the NVv4 family which has AMD GPUs.
+//
+//nolint:gochecknoglobals
+var (
+	gpuSKURegexp    = regexp.MustCompile(`^standard_n[cdv][0-9]+`)
+	amdGPUSKURegexp = regexp.MustCompile(`^standard_nv[0-9]+as_v4$`)
+)
+
+/*
+IsGPUSKU determines if a VM SKU has an NVIDIA GPU, from its N-series family: NC and ND for compute, NV for
+visualization. The NVv4 family has AMD GPUs and is not a GPU SKU for AKS.
+*/
+func IsGPUSKU(vmSize string) bool {
+	vmSize = strings.TrimSuffix(strings.ToLower(vmSize), "_promo")
+	if amdGPUSKURegexp.MatchString(vmSize) {
+		return false
+	}
+	return gpuSKURegexp.MatchString(vmSize)
+}
+
+// migProfiles are the multi-instance GPU partition profiles nodes can be partitioned with.
+//
+//nolint:gochecknoglobals
+var migProfiles = []string{"MIG1g", "MIG2g", "MIG3g", "MIG4g", "MIG7g"}
+
+// IsValidMIGProfile determines if a multi-instance GPU partition profile is known.
+func IsValidMIGProfile(profile string) bool {
+	for _, migProfile := range migProfiles {
+		if profile == migProfile {
+			return true
+		}
+	}
+	return false
+}
+
+// IsMIGCapableSKU determines if a VM SKU has GPUs supporting multi-instance GPU partitioning, i.e. A100 or H100 GPUs.
+func IsMIGCapableSKU(vmSize string) bool {
+	if !IsGPUSKU(vmSize) {
+		return false
+	}
+	vmSize = strings.ToLower(vmSize)
+	// Standard_ND96asr_v4 has A100 GPUs without naming them.
+	return strings.Contains(vmSize, "_a100_") || strings.Contains(vmSize, "_h100_") ||
+		strings.TrimSuffix(vmSize, "_promo") == "standard_nd96asr_v4"
+}
+
+// trustedLaunchUnsupportedSKURegexes match the VM sizes which don't support trusted launch, as listed in
+// https://learn.microsoft.com/azure/virtual-machines/trusted-launch#virtual-machines-sizes.
+//
+//nolint:gochecknoglobals
+var trustedLaunchUnsupportedSKURegexes = []*regexp.Regexp{
+	regexp.MustCompile(`^basic_a[0-9]+$`),
+	regexp.MustCompile(`^standard_a[0-9]+(m)?_v2$`),
+	regexp.MustCompile(`^standard_gs?[0-9]+`),
+	regexp.MustCompile(`^standard_m[0-9]+`),
+	regexp.MustCompile(`^standard_l[0-9]+s_v2$`),
+	regexp.MustCompile(`^standard_dc[0-9]+s_v2$`),
+	regexp.MustCompile(`^standard_nc[0-9]+s_v3$`),
+	regexp.MustCompile(`^standard_nc[0-9]+ads_a100_v4$`),
+	regexp.MustCompile(`^standard_nd96a(m)?sr_(a100_)?v4$`),
+}
+
+// IsTrustedLaunchSupportedSKU returns true if the VM size supports trusted launch.
+func IsTrustedLaunchSupportedSKU(vmSize string) bool {
+	vmSize = strings.TrimSuffix(strings.ToLower(vmSize), "_promo")
+	for _, unsupported := range trustedLaunchUnsupportedSKURegexes {
+		if unsupported.MatchString(vmSize) {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+IsEphemeralOSDiskSupportedSKU returns true if the VM size has a local cache or temp disk to place an ephemeral OS disk
+on. Sizes of the v4 and later generations only have a local disk if their name has the d capability, e.g.
+Standard_D4ds_v5, older generations always have one.
+*/
+func IsEphemeralOSDiskSupportedSKU(vmSize string) bool {
+	// the first generation of VM sizes which have no local disk without the d capability.
+	const firstGenerationWithoutLocalDisk = 4
+	vmSize = strings.TrimSuffix(strings.ToLower(vmSize), "_promo")
+	matches := regexp.MustCompile(`^standard_[a-z]+[0-9]+(?:-[0-9]+)?([a-z]*)(?:_[a-z0-9]+)*?(?:_v([0-9]+))?$`).FindStringSubmatch(vmSize)
+	if matches == nil {
+		return false
+	}
+	generation := 1
+	if matches[2] != "" {
+		generation, _ = strconv.Atoi(matches[2])
+	}
+	return generation < firstGenerationWithoutLocalDisk || strings.Contains(matches[1], "d")
+}
+
 // IsSgxEnabledSKU determines if an VM SKU has SGX driver support.
 func IsSgxEnabledSKU(vmSize string) bool {
 	switch vmSize {
@@ -0,0 +1,86 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package datamodel
+
+import (
+	"strings"
+
+	"github.com/blang/semver"
+	"github.com/pkg/errors"
+)
+
+// Network policy engines and dataplanes of the network policy compatibility table.
+const (
+	NetworkPolicyEngineCalico = "calico"
+	NetworkPolicyEngineCilium = "cilium"
+	NetworkPolicyEngineAzure  = "azure"
+	NetworkPolicyEngineAntrea = "antrea"
+
+	NetworkDataplaneAzure   = "azure"
+	NetworkDataplaneKubenet = "kubenet"
+	NetworkDataplaneCilium  = "cilium"
+)
+
+// networkPolicyCompatibility is the Kubernetes versions and dataplanes a network policy engine can run with.
+type networkPolicyCompatibility struct {
+	kubernetesVersions kubernetesVersionRange
+	dataplanes         []string
+}
+
+// networkPolicyCompatibilities are the known compatibilities of the network policy engines, keyed by engine. Update
+// the entries with the releases of the engines, e.g. when a new Kubernetes minor version is validated with an engine.
+// Cilium runs with the azure network plugin on Azure CNI Powered by Cilium clusters.
+//
+//nolint:gochecknoglobals
+var networkPolicyCompatibilities = map[string]networkPolicyCompatibility{
+	NetworkPolicyEngineCalico: {dataplanes: []string{NetworkDataplaneAzure, NetworkDataplaneKubenet}},
+	NetworkPolicyEngineCilium: {
+		kubernetesVersions: kubernetesVersionRange{min: "1.25"},
+		dataplanes:         []string{NetworkDataplaneCilium, NetworkDataplaneAzure},
+	},
+	NetworkPolicyEngineAzure:  {dataplanes: []string{NetworkDataplaneAzure}},
+	NetworkPolicyEngineAntrea: {dataplanes: []string{NetworkDataplaneAzure}},
+}
+
+/*
+ValidateNetworkPolicy validates that the network policy engine can run with the Kubernetes version and the dataplane,
+the network plugin forwarding the pod traffic, so nodes are not provisioned with an engine which won't start. An empty
+engine means no network policy, an empty Kubernetes version or dataplane is not validated.
+*/
+func ValidateNetworkPolicy(engine, k8sVersion, dataplane string) error {
+	if engine == "" {
+		return nil
+	}
+	compatibility, ok := networkPolicyCompatibilities[engine]
+	if !ok {
+		return errors.Errorf("unknown network policy engine %q", engine)
+	}
+	if k8sVersion != "" {
+		v, err := semver.ParseTolerant(k8sVersion)
+		if err != nil {
+			return errors.Wrapf(err, "invalid Kubernetes version %q", k8sVersion)
+		}
+		if !compatibility.kubernetesVersions.containsMinorVersion(v) {
+			minVersion, maxVersion := compatibility.kubernetesVersions.min, compatibility.kubernetesVersions.max
+			if minVersion == "" {
+				minVersion = "any"
+			}
+			if maxVersion == "" {
+				maxVersion = "latest"
+			}
+			return errors.Errorf("network policy engine %s does not support Kubernetes version %s, supported versions are %s to %s",
+				engine, k8sVersion, minVersion, maxVersion)
+		}
+	}
+	if dataplane == "" {
+		return nil
+	}
+	for _, supported := range compatibility.dataplanes {
+		if dataplane == supported {
+			return nil
+		}
+	}
+	return errors.Errorf("network policy engine %s does not support the %s dataplane, supported dataplanes are %s",
+		engine, dataplane, strings.Join(compatibility.dataplanes, ", "))
+}
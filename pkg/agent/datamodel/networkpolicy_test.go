@@ -0,0 +1,66 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package datamodel
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateNetworkPolicy(t *testing.T) {
+	cases := []struct {
+		name        string
+		engine      string
+		k8sVersion  string
+		dataplane   string
+		expectedErr string
+	}{
+		{name: "no network policy", dataplane: NetworkDataplaneCilium},
+		{name: "calico with azure cni", engine: "calico", k8sVersion: "1.29.2", dataplane: "azure"},
+		{name: "calico with kubenet", engine: "calico", k8sVersion: "1.29.2", dataplane: "kubenet"},
+		{name: "cilium with cilium", engine: "cilium", k8sVersion: "1.29.2", dataplane: "cilium"},
+		{name: "cilium with azure cni", engine: "cilium", k8sVersion: "1.29.2", dataplane: "azure"},
+		{
+			name: "cilium with kubenet", engine: "cilium", k8sVersion: "1.29.2", dataplane: "kubenet",
+			expectedErr: "network policy engine cilium does not support the kubenet dataplane, supported dataplanes are cilium, azure",
+		},
+		{name: "azure npm with azure cni", engine: "azure", k8sVersion: "1.29.2", dataplane: "azure"},
+		{name: "unknown version and dataplane", engine: "calico"},
+		{name: "unknown engine", engine: "weave", expectedErr: `unknown network policy engine "weave"`},
+		{
+			name: "calico with cilium", engine: "calico", k8sVersion: "1.29.2", dataplane: "cilium",
+			expectedErr: "network policy engine calico does not support the cilium dataplane, supported dataplanes are azure, kubenet",
+		},
+		{
+			name: "azure npm with kubenet", engine: "azure", k8sVersion: "1.29.2", dataplane: "kubenet",
+			expectedErr: "network policy engine azure does not support the kubenet dataplane",
+		},
+		{
+			name: "cilium before 1.25", engine: "cilium", k8sVersion: "1.24.9", dataplane: "cilium",
+			expectedErr: "network policy engine cilium does not support Kubernetes version 1.24.9, supported versions are 1.25 to latest",
+		},
+		{
+			name: "antrea with kubenet", engine: "antrea", k8sVersion: "1.29.2", dataplane: "kubenet",
+			expectedErr: "network policy engine antrea does not support the kubenet dataplane",
+		},
+		{name: "invalid version", engine: "calico", k8sVersion: "latest", expectedErr: `invalid Kubernetes version "latest"`},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+			err := ValidateNetworkPolicy(c.engine, c.k8sVersion, c.dataplane)
+			if c.expectedErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.expectedErr) {
+				t.Errorf("expected an error containing %q, got %v", c.expectedErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package datamodel
+
+import (
+	"encoding/json"
+	"go/token"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/pkg/errors"
+)
+
+/*
+AssertConfigRoundTrip marshals the config to JSON, unmarshals it again and returns an error with the diff if the
+result differs from the config, e.g. when a field is dropped by its JSON tag. It is meant as a guard in tests.
+Unexported fields are not serialized, so they are not compared, and nil and empty slices and maps are equal.
+*/
+func AssertConfigRoundTrip(config *NodeBootstrappingConfiguration) error {
+	raw, err := json.Marshal(config)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal NodeBootstrappingConfiguration")
+	}
+	var roundTripped *NodeBootstrappingConfiguration
+	if err = json.Unmarshal(raw, &roundTripped); err != nil {
+		return errors.Wrap(err, "failed to unmarshal NodeBootstrappingConfiguration")
+	}
+	ignoreUnexported := cmp.FilterPath(func(path cmp.Path) bool {
+		field, ok := path.Last().(cmp.StructField)
+		return ok && !token.IsExported(field.Name())
+	}, cmp.Ignore())
+	if diff := cmp.Diff(config, roundTripped, ignoreUnexported, cmpopts.EquateEmpty()); diff != "" {
+		return errors.Errorf("NodeBootstrappingConfiguration changed in a JSON round trip (-before +after):\n%s", diff)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package datamodel
+
+import (
+	"testing"
+
+	"github.com/Azure/go-autorest/autorest/to"
+)
+
+func TestConfigRoundTrip(t *testing.T) {
+	cases := []struct {
+		name   string
+		config *NodeBootstrappingConfiguration
+	}{
+		{
+			name:   "empty configuration",
+			config: &NodeBootstrappingConfiguration{},
+		},
+		{
+			name: "populated configuration",
+			config: &NodeBootstrappingConfiguration{
+				ContainerService: &ContainerService{
+					Location:   "southcentralus",
+					Properties: GetK8sDefaultProperties(true),
+				},
+				AgentPoolProfile:     &AgentPoolProfile{Name: "nodepool1", Distro: AKSUbuntuContainerd2204},
+				KubeletConfig:        map[string]string{"--max-pods": "110"},
+				PrePullImages:        []PrePullImage{{Image: "mcr.microsoft.com/oss/kubernetes/pause:3.6"}},
+				RegisterWithTaints:   []Taint{{Key: "sku", Value: "gpu", Effect: TaintEffectNoSchedule}},
+				ContainerdOOMScore:   to.IntPtr(-999),
+				KernelModules:        []string{"br_netfilter"},
+				EnvironmentVariables: map[string]string{"FOO": "bar"},
+				DownloadRetryConfig:  &DownloadRetryConfig{MaxRetries: 3, BackoffSeconds: 1},
+				EnableNvidia:         true,
+				SIGConfig:            SIGConfig{TenantID: "tenantID"},
+			},
+		},
+		{
+			name: "nil and empty slices and maps",
+			config: &NodeBootstrappingConfiguration{
+				KubeletConfig: map[string]string{},
+				KernelModules: []string{},
+			},
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+			if err := AssertConfigRoundTrip(c.config); err != nil {
+				t.Errorf("expected the configuration to round trip, but got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package datamodel
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+	jsonSchemaDefsRef = "#/$defs/"
+)
+
+/*
+ConfigJSONSchema returns the JSON Schema of NodeBootstrappingConfiguration. It is generated from the Go types,
+following the field naming of encoding/json, so it can not drift from what the types accept. Every object is closed,
+i.e. unknown fields are invalid.
+*/
+func ConfigJSONSchema() ([]byte, error) {
+	return json.MarshalIndent(nodeBootstrappingConfigurationSchema(), "", "  ")
+}
+
+/*
+ValidateConfigJSON validates raw NodeBootstrappingConfiguration JSON against ConfigJSONSchema before it is
+unmarshalled. All unknown fields and type errors are reported, each with the JSON path it occurred at.
+*/
+func ValidateConfigJSON(raw []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return errors.Wrap(err, "invalid NodeBootstrappingConfiguration JSON")
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return errors.New("invalid NodeBootstrappingConfiguration JSON: unexpected data after the top-level value")
+	}
+	schema := nodeBootstrappingConfigurationSchema()
+	defs, _ := schema["$defs"].(map[string]interface{})
+	if violations := validateJSONSchema(schema, defs, value, "$"); len(violations) > 0 {
+		return errors.Errorf("invalid NodeBootstrappingConfiguration JSON: %s", strings.Join(violations, "; "))
+	}
+	return nil
+}
+
+func nodeBootstrappingConfigurationSchema() map[string]interface{} {
+	generator := &jsonSchemaGenerator{defs: map[string]interface{}{}}
+	root := generator.schemaFor(reflect.TypeOf(NodeBootstrappingConfiguration{}))
+	return map[string]interface{}{
+		"$schema": jsonSchemaDialect,
+		"title":   "NodeBootstrappingConfiguration",
+		"$ref":    root["$ref"],
+		"$defs":   generator.defs,
+	}
+}
+
+// jsonSchemaGenerator generates JSON Schemas of Go types, named struct types are shared through $defs.
+type jsonSchemaGenerator struct {
+	defs map[string]interface{}
+}
+
+func (g *jsonSchemaGenerator) schemaFor(t reflect.Type) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return nullableJSONSchema(g.schemaFor(t.Elem()))
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		if _, ok := g.defs[t.Name()]; !ok {
+			// reserve the name first, so recursive types reference it instead of recursing forever.
+			g.defs[t.Name()] = nil
+			g.defs[t.Name()] = g.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": jsonSchemaDefsRef + t.Name()}
+	case reflect.Slice:
+		// encoding/json encodes byte slices as base64 strings.
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": []interface{}{"string", "null"}}
+		}
+		return map[string]interface{}{"type": []interface{}{"array", "null"}, "items": g.schemaFor(t.Elem())}
+	case reflect.Array:
+		return map[string]interface{}{"type": "array", "items": g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": []interface{}{"object", "null"}, "additionalProperties": g.schemaFor(t.Elem())}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	default:
+		// interfaces accept any value.
+		return map[string]interface{}{}
+	}
+}
+
+func (g *jsonSchemaGenerator) structSchema(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	g.addStructProperties(t, properties)
+	return map[string]interface{}{"type": "object", "properties": properties, "additionalProperties": false}
+}
+
+// addStructProperties adds the properties of the struct fields, promoting the fields of embedded structs like encoding/json.
+func (g *jsonSchemaGenerator) addStructProperties(t reflect.Type, properties map[string]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				g.addStructProperties(embedded, properties)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = g.schemaFor(field.Type)
+	}
+}
+
+func nullableJSONSchema(schema map[string]interface{}) map[string]interface{} {
+	switch schemaType := schema["type"].(type) {
+	case string:
+		nullable := map[string]interface{}{}
+		for k, v := range schema {
+			nullable[k] = v
+		}
+		nullable["type"] = []interface{}{schemaType, "null"}
+		return nullable
+	case []interface{}:
+		return schema
+	}
+	if len(schema) == 0 {
+		return schema
+	}
+	return map[string]interface{}{"anyOf": []interface{}{schema, map[string]interface{}{"type": "null"}}}
+}
+
+// validateJSONSchema validates a value decoded with json.Decoder.UseNumber against the subset of JSON Schema
+// generated by jsonSchemaGenerator, and returns the violations.
+func validateJSONSchema(schema, defs map[string]interface{}, value interface{}, path string) []string {
+	if ref, ok := schema["$ref"].(string); ok {
+		def, _ := defs[strings.TrimPrefix(ref, jsonSchemaDefsRef)].(map[string]interface{})
+		return validateJSONSchema(def, defs, value, path)
+	}
+	if anyOf, ok := schema["anyOf"].([]interface{}); ok {
+		var violations []string
+		for _, option := range anyOf {
+			optionSchema, _ := option.(map[string]interface{})
+			optionViolations := validateJSONSchema(optionSchema, defs, value, path)
+			if len(optionViolations) == 0 {
+				return nil
+			}
+			if violations == nil {
+				violations = optionViolations
+			}
+		}
+		return violations
+	}
+	if types := jsonSchemaTypes(schema); len(types) > 0 && !jsonValueMatchesTypes(value, types) {
+		return []string{fmt.Sprintf("%s: expected %s, got %s", path, strings.Join(types, " or "), jsonValueType(value))}
+	}
+	var violations []string
+	switch v := value.(type) {
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if property, ok := properties[key].(map[string]interface{}); ok {
+				violations = append(violations, validateJSONSchema(property, defs, v[key], path+"."+key)...)
+				continue
+			}
+			switch additional := schema["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					violations = append(violations, fmt.Sprintf("%s: unknown field %q", path, key))
+				}
+			case map[string]interface{}:
+				violations = append(violations, validateJSONSchema(additional, defs, v[key], path+"."+key)...)
+			}
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				violations = append(violations, validateJSONSchema(items, defs, item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	case json.Number:
+		if minimum, ok := schema["minimum"].(int); ok {
+			if f, err := v.Float64(); err == nil && f < float64(minimum) {
+				violations = append(violations, fmt.Sprintf("%s: must be at least %d, got %s", path, minimum, v))
+			}
+		}
+	}
+	return violations
+}
+
+func jsonSchemaTypes(schema map[string]interface{}) []string {
+	switch schemaType := schema["type"].(type) {
+	case string:
+		return []string{schemaType}
+	case []interface{}:
+		types := make([]string, 0, len(schemaType))
+		for _, t := range schemaType {
+			if s, ok := t.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+func jsonValueMatchesTypes(value interface{}, types []string) bool {
+	valueType := jsonValueType(value)
+	for _, t := range types {
+		if t == valueType || (t == "number" && valueType == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonValueType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
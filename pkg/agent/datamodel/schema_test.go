@@ -0,0 +1,119 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package datamodel
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestConfigJSONSchema(t *testing.T) {
+	raw, err := ConfigJSONSchema()
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	var schema map[string]interface{}
+	if err = json.Unmarshal(raw, &schema); err != nil {
+		t.Fatalf("expected the schema to be valid JSON, but got %v", err)
+	}
+	if schema["$ref"] != "#/$defs/NodeBootstrappingConfiguration" {
+		t.Fatalf("expected the schema to reference NodeBootstrappingConfiguration, got %v", schema["$ref"])
+	}
+	defs, _ := schema["$defs"].(map[string]interface{})
+	for _, name := range []string{"NodeBootstrappingConfiguration", "ContainerService", "AgentPoolProfile", "KubernetesConfig"} {
+		if _, ok := defs[name]; !ok {
+			t.Errorf("expected the schema to define %s", name)
+		}
+	}
+}
+
+func TestValidateConfigJSON(t *testing.T) {
+	config := &NodeBootstrappingConfiguration{
+		ContainerService: &ContainerService{
+			Location:   "southcentralus",
+			Properties: GetK8sDefaultProperties(true),
+		},
+		AgentPoolProfile:   &AgentPoolProfile{Name: "nodepool1", Distro: AKSUbuntuContainerd2204},
+		KubeletConfig:      map[string]string{"--max-pods": "110"},
+		PrePullImages:      []PrePullImage{{Image: "mcr.microsoft.com/oss/kubernetes/pause:3.6"}},
+		RegisterWithTaints: []Taint{{Key: "sku", Value: "gpu", Effect: TaintEffectNoSchedule}},
+	}
+	valid, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("failed to marshal the configuration: %v", err)
+	}
+
+	cases := []struct {
+		name        string
+		raw         string
+		expectedErr string
+	}{
+		{
+			name: "marshalled configuration",
+			raw:  string(valid),
+		},
+		{
+			name: "empty configuration",
+			raw:  `{}`,
+		},
+		{
+			name:        "unknown field",
+			raw:         `{"ContainerService": {"location": "southcentralus", "unknown": true}}`,
+			expectedErr: `$.ContainerService: unknown field "unknown"`,
+		},
+		{
+			name:        "type error",
+			raw:         `{"AgentPoolProfile": {"name": 1}}`,
+			expectedErr: "$.AgentPoolProfile.name: expected string, got integer",
+		},
+		{
+			name:        "type error in map",
+			raw:         `{"KubeletConfig": {"--max-pods": 110}}`,
+			expectedErr: "$.KubeletConfig.--max-pods: expected string, got integer",
+		},
+		{
+			name:        "type error in slice",
+			raw:         `{"PrePullImages": [{"image": ["mcr.microsoft.com/oss/kubernetes/pause:3.6"]}]}`,
+			expectedErr: "$.PrePullImages[0].image: expected string, got array",
+		},
+		{
+			name:        "fractional integer",
+			raw:         `{"DownloadRetryConfig": {"maxRetries": 1.5}}`,
+			expectedErr: "$.DownloadRetryConfig.maxRetries: expected integer, got number",
+		},
+		{
+			name:        "negative unsigned integer",
+			raw:         `{"ContainerService": {"properties": {"windowsProfile": {"hnsRemediatorIntervalInMinutes": -1}}}}`,
+			expectedErr: "$.ContainerService.properties.windowsProfile.hnsRemediatorIntervalInMinutes: must be at least 0",
+		},
+		{
+			name:        "malformed JSON",
+			raw:         `{"KubeletConfig": `,
+			expectedErr: "invalid NodeBootstrappingConfiguration JSON",
+		},
+		{
+			name:        "trailing data",
+			raw:         `{} {}`,
+			expectedErr: "unexpected data after the top-level value",
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+			err := ValidateConfigJSON([]byte(c.raw))
+			if c.expectedErr == "" {
+				if err != nil {
+					t.Fatalf("test case: %s, expected no error, but got %v", c.name, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.expectedErr) {
+				t.Fatalf("test case: %s, expected error containing %q, but got %v", c.name, c.expectedErr, err)
+			}
+		})
+	}
+}
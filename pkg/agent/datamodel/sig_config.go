@@ -71,6 +71,25 @@ func GetCloudTargetEnv(location string) string {
 	}
 }
 
+// AzureRegions are the known Azure regions AKS node images are published to.
+//
+//nolint:gochecknoglobals
+var AzureRegions = []string{
+	// Azure public cloud.
+	"australiacentral", "australiacentral2", "australiaeast", "australiasoutheast", "brazilsouth", "brazilsoutheast",
+	"canadacentral", "canadaeast", "centralindia", "centralus", "centraluseuap", "eastasia", "eastus", "eastus2",
+	"eastus2euap", "francecentral", "francesouth", "germanynorth", "germanywestcentral", "israelcentral", "italynorth",
+	"japaneast", "japanwest", "jioindiacentral", "jioindiawest", "koreacentral", "koreasouth", "mexicocentral",
+	"northcentralus", "northeurope", "norwayeast", "norwaywest", "polandcentral", "qatarcentral", "southafricanorth",
+	"southafricawest", "southcentralus", "southeastasia", "southindia", "spaincentral", "swedencentral", "swedensouth",
+	"switzerlandnorth", "switzerlandwest", "uaecentral", "uaenorth", "uksouth", "ukwest", "westcentralus", "westeurope",
+	"westindia", "westus", "westus2", "westus3",
+	// Azure China cloud.
+	"chinaeast", "chinaeast2", "chinaeast3", "chinanorth", "chinanorth2", "chinanorth3",
+	// Azure US Government cloud.
+	"usdodcentral", "usdodeast", "usgovarizona", "usgovtexas", "usgovvirginia",
+}
+
 /*
 AvailableUbuntu1804Distros : TODO(amaheshwari): these vars are not consumed by Agentbaker but by RP. do a
 cleanup to remove these after 20.04 work.
@@ -255,6 +274,12 @@ func (d Distro) IsAzureLinuxDistro() bool {
 	}
 	return false
 }
+
+// IsUbuntuDistro returns true if the distro is an Ubuntu distro.
+func (d Distro) IsUbuntuDistro() bool {
+	return strings.Contains(string(d), "ubuntu") || d == AKS1604Deprecated || d == AKS1804Deprecated
+}
+
 func (d Distro) IsWindowsSIGDistro() bool {
 	for _, distro := range AvailableWindowsSIGDistros {
 		if d == distro {
@@ -277,6 +302,55 @@ func (d Distro) IsWindowsDistro() bool {
 	return d.IsWindowsSIGDistro() || d.IsWindowsPIRDistro()
 }
 
+// SupportsTrustedLaunch returns true if the distro can boot trusted launch VMs, which requires a Gen 2 x86 image.
+// Confidential VM images use a different security type.
+func (d Distro) SupportsTrustedLaunch() bool {
+	if d == AKSWindows2022ContainerdGen2 || d == AKSWindows23H2Gen2 {
+		return true
+	}
+	return d.IsGen2Distro() && !strings.Contains(string(d), "arm64") && d != AKSUbuntuContainerd2004CVMGen2
+}
+
+// DistroFamily represents the OS family of a distro, which is what the OS SKU of an agent pool selects.
+type DistroFamily string
+
+// DistroFamily string consts.
+const (
+	DistroFamilyUnknown    DistroFamily = ""
+	DistroFamilyUbuntu     DistroFamily = "Ubuntu"
+	DistroFamilyAzureLinux DistroFamily = "AzureLinux"
+	DistroFamilyWindows    DistroFamily = "Windows"
+)
+
+// Family returns the OS family of the distro, DistroFamilyUnknown for e.g. custom images.
+func (d Distro) Family() DistroFamily {
+	switch {
+	case d.IsWindowsDistro():
+		return DistroFamilyWindows
+	case d.IsAzureLinuxDistro():
+		return DistroFamilyAzureLinux
+	case d.IsUbuntuDistro():
+		return DistroFamilyUbuntu
+	default:
+		return DistroFamilyUnknown
+	}
+}
+
+// OSSKUFamily returns the OS family an OS SKU selects, DistroFamilyUnknown for an empty or unknown OS SKU.
+func OSSKUFamily(osSKU string) DistroFamily {
+	switch {
+	case strings.EqualFold(osSKU, OSSKUUbuntu):
+		return DistroFamilyUbuntu
+	case strings.EqualFold(osSKU, OSSKUCBLMariner), strings.EqualFold(osSKU, OSSKUMariner), strings.EqualFold(osSKU, OSSKUAzureLinux):
+		return DistroFamilyAzureLinux
+	case strings.HasPrefix(strings.ToLower(osSKU), "windows"):
+		// e.g. Windows2019, Windows2022 and WindowsAnnual.
+		return DistroFamilyWindows
+	default:
+		return DistroFamilyUnknown
+	}
+}
+
 // SigImageConfigTemplate represents the SIG image configuration template.
 type SigImageConfigTemplate struct {
 	ResourceGroup string
@@ -811,6 +885,21 @@ func getSigUbuntuEdgeZoneImageConfigMapWithOpts(opts ...SigImageConfigOpt) map[D
 	}
 }
 
+// IsLinuxSIGDistro returns true if the distro is a Linux distro with an AKS SIG image.
+func (d Distro) IsLinuxSIGDistro() bool {
+	for _, configs := range []map[Distro]SigImageConfig{
+		getSigUbuntuImageConfigMapWithOpts(),
+		getSigCBLMarinerImageConfigMapWithOpts(),
+		getSigAzureLinuxImageConfigMapWithOpts(),
+		getSigUbuntuEdgeZoneImageConfigMapWithOpts(),
+	} {
+		if _, ok := configs[d]; ok {
+			return true
+		}
+	}
+	return false
+}
+
 // GetSIGAzureCloudSpecConfig get cloud specific sig config.
 func GetSIGAzureCloudSpecConfig(sigConfig SIGConfig, region string) (SIGAzureEnvironmentSpecConfig, error) {
 	if sigConfig.Galleries == nil || strings.EqualFold(sigConfig.SubscriptionID, "") || strings.EqualFold(sigConfig.TenantID, "") {
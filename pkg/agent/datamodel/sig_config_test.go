@@ -238,3 +238,40 @@ var _ = Describe("GetSIGAzureCloudSpecConfig", func() {
 		Expect(aksUbuntuEgressContainerd2204Gen2.Version).To(Equal("2022.10.03"))
 	})
 })
+
+var _ = Describe("Distro Family", func() {
+	It("should return the OS family of the distro", func() {
+		Expect(AKSUbuntuContainerd2204.Family()).To(Equal(DistroFamilyUbuntu))
+		Expect(AKS1804Deprecated.Family()).To(Equal(DistroFamilyUbuntu))
+		Expect(AKSCBLMarinerV2Gen2.Family()).To(Equal(DistroFamilyAzureLinux))
+		Expect(AKSAzureLinuxV2Gen2Kata.Family()).To(Equal(DistroFamilyAzureLinux))
+		Expect(AKSWindows2022Containerd.Family()).To(Equal(DistroFamilyWindows))
+		Expect(CustomizedImage.Family()).To(Equal(DistroFamilyUnknown))
+	})
+
+	It("should return the OS family the OS SKU selects", func() {
+		Expect(OSSKUFamily(OSSKUUbuntu)).To(Equal(DistroFamilyUbuntu))
+		Expect(OSSKUFamily(OSSKUCBLMariner)).To(Equal(DistroFamilyAzureLinux))
+		Expect(OSSKUFamily("mariner")).To(Equal(DistroFamilyAzureLinux))
+		Expect(OSSKUFamily(OSSKUAzureLinux)).To(Equal(DistroFamilyAzureLinux))
+		Expect(OSSKUFamily("Windows2019")).To(Equal(DistroFamilyWindows))
+		Expect(OSSKUFamily("")).To(Equal(DistroFamilyUnknown))
+		Expect(OSSKUFamily("RHEL")).To(Equal(DistroFamilyUnknown))
+	})
+})
+
+var _ = Describe("Distro SupportsTrustedLaunch", func() {
+	It("should support trusted launch on Gen 2 x86 distros", func() {
+		Expect(AKSUbuntuContainerd2204TLGen2.SupportsTrustedLaunch()).To(BeTrue())
+		Expect(AKSUbuntuContainerd2204Gen2.SupportsTrustedLaunch()).To(BeTrue())
+		Expect(AKSAzureLinuxV2Gen2TL.SupportsTrustedLaunch()).To(BeTrue())
+		Expect(AKSWindows2022ContainerdGen2.SupportsTrustedLaunch()).To(BeTrue())
+	})
+
+	It("should not support trusted launch on Gen 1, arm64 or confidential VM distros", func() {
+		Expect(AKSUbuntuContainerd2204.SupportsTrustedLaunch()).To(BeFalse())
+		Expect(AKSAzureLinuxV2Arm64Gen2.SupportsTrustedLaunch()).To(BeFalse())
+		Expect(AKSUbuntuContainerd2004CVMGen2.SupportsTrustedLaunch()).To(BeFalse())
+		Expect(AKSWindows2022Containerd.SupportsTrustedLaunch()).To(BeFalse())
+	})
+})
@@ -0,0 +1,86 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package datamodel
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrUnknownSKU is returned by SKUCapabilities for VM sizes which are not in the SKU capabilities table.
+var ErrUnknownSKU = errors.New("unknown VM size")
+
+// SKUCaps are the capabilities of a VM size which node bootstrapping features depend on.
+type SKUCaps struct {
+	// VCPUs is the number of vCPUs of the VM size.
+	VCPUs int `json:"vCPUs"`
+	// GPU is true if the VM size has an NVIDIA GPU.
+	GPU bool `json:"gpu"`
+	// AcceleratedNetworking is true if the VM size supports accelerated networking.
+	AcceleratedNetworking bool `json:"acceleratedNetworking"`
+	// EphemeralOSDisk is true if the VM size has a local disk an ephemeral OS disk can be placed on.
+	EphemeralOSDisk bool `json:"ephemeralOSDisk"`
+	// TrustedLaunch is true if the VM size supports trusted launch.
+	TrustedLaunch bool `json:"trustedLaunch"`
+	// MIG is true if the GPUs of the VM size support multi-instance GPU partitioning.
+	MIG bool `json:"mig"`
+}
+
+// skuCapabilities are the capabilities of the VM sizes commonly used for AKS nodes, keyed by the lower case VM size.
+// Look the capabilities of new VM sizes up in the Azure VM size documentation rather than deriving them from the
+// name, the name based helpers like IsGPUSKU are tested against this table. Trusted launch support follows
+// https://learn.microsoft.com/azure/virtual-machines/trusted-launch#virtual-machines-sizes.
+//
+//nolint:gochecknoglobals
+var skuCapabilities = map[string]SKUCaps{
+	// general purpose
+	"standard_ds2_v2":  {VCPUs: 2, AcceleratedNetworking: true, EphemeralOSDisk: true, TrustedLaunch: true},
+	"standard_d2s_v3":  {VCPUs: 2, EphemeralOSDisk: true, TrustedLaunch: true},
+	"standard_d4s_v3":  {VCPUs: 4, AcceleratedNetworking: true, EphemeralOSDisk: true, TrustedLaunch: true},
+	"standard_d8s_v3":  {VCPUs: 8, AcceleratedNetworking: true, EphemeralOSDisk: true, TrustedLaunch: true},
+	"standard_d16s_v3": {VCPUs: 16, AcceleratedNetworking: true, EphemeralOSDisk: true, TrustedLaunch: true},
+	"standard_d4s_v4":  {VCPUs: 4, AcceleratedNetworking: true, TrustedLaunch: true},
+	"standard_d4ds_v4": {VCPUs: 4, AcceleratedNetworking: true, EphemeralOSDisk: true, TrustedLaunch: true},
+	"standard_d2s_v5":  {VCPUs: 2, AcceleratedNetworking: true, TrustedLaunch: true},
+	"standard_d4s_v5":  {VCPUs: 4, AcceleratedNetworking: true, TrustedLaunch: true},
+	"standard_d8s_v5":  {VCPUs: 8, AcceleratedNetworking: true, TrustedLaunch: true},
+	"standard_d2ds_v5": {VCPUs: 2, AcceleratedNetworking: true, EphemeralOSDisk: true, TrustedLaunch: true},
+	"standard_d4ds_v5": {VCPUs: 4, AcceleratedNetworking: true, EphemeralOSDisk: true, TrustedLaunch: true},
+	"standard_d8ds_v5": {VCPUs: 8, AcceleratedNetworking: true, EphemeralOSDisk: true, TrustedLaunch: true},
+	"standard_a2_v2":   {VCPUs: 2, EphemeralOSDisk: true},
+	// compute optimized
+	"standard_f4s_v2":  {VCPUs: 4, AcceleratedNetworking: true, EphemeralOSDisk: true, TrustedLaunch: true},
+	"standard_f16s_v2": {VCPUs: 16, AcceleratedNetworking: true, EphemeralOSDisk: true, TrustedLaunch: true},
+	// memory optimized
+	"standard_e4s_v3":  {VCPUs: 4, AcceleratedNetworking: true, EphemeralOSDisk: true, TrustedLaunch: true},
+	"standard_e16s_v5": {VCPUs: 16, AcceleratedNetworking: true, TrustedLaunch: true},
+	"standard_e8ds_v5": {VCPUs: 8, AcceleratedNetworking: true, EphemeralOSDisk: true, TrustedLaunch: true},
+	"standard_m128s":   {VCPUs: 128, AcceleratedNetworking: true, EphemeralOSDisk: true},
+	// storage optimized
+	"standard_l8s_v2": {VCPUs: 8, AcceleratedNetworking: true, EphemeralOSDisk: true},
+	// GPU
+	"standard_nc6s_v3":         {VCPUs: 6, GPU: true, EphemeralOSDisk: true},
+	"standard_nc4as_t4_v3":     {VCPUs: 4, GPU: true, AcceleratedNetworking: true, EphemeralOSDisk: true, TrustedLaunch: true},
+	"standard_nc8as_t4_v3":     {VCPUs: 8, GPU: true, AcceleratedNetworking: true, EphemeralOSDisk: true, TrustedLaunch: true},
+	"standard_nc16as_t4_v3":    {VCPUs: 16, GPU: true, AcceleratedNetworking: true, EphemeralOSDisk: true, TrustedLaunch: true},
+	"standard_nc64as_t4_v3":    {VCPUs: 64, GPU: true, AcceleratedNetworking: true, EphemeralOSDisk: true, TrustedLaunch: true},
+	"standard_nc24ads_a100_v4": {VCPUs: 24, GPU: true, AcceleratedNetworking: true, EphemeralOSDisk: true, MIG: true},
+	"standard_nc48ads_a100_v4": {VCPUs: 48, GPU: true, AcceleratedNetworking: true, EphemeralOSDisk: true, MIG: true},
+	"standard_nc96ads_a100_v4": {VCPUs: 96, GPU: true, AcceleratedNetworking: true, EphemeralOSDisk: true, MIG: true},
+	"standard_nv36ads_a10_v5":  {VCPUs: 36, GPU: true, AcceleratedNetworking: true, EphemeralOSDisk: true, TrustedLaunch: true},
+}
+
+/*
+SKUCapabilities returns the capabilities of a VM size from the SKU capabilities table. It returns an error wrapping
+ErrUnknownSKU for VM sizes which are not in the table, so callers can decide whether to fall back to the name based
+helpers like IsGPUSKU or to reject the VM size.
+*/
+func SKUCapabilities(vmSize string) (SKUCaps, error) {
+	caps, ok := skuCapabilities[strings.TrimSuffix(strings.ToLower(vmSize), "_promo")]
+	if !ok {
+		return SKUCaps{}, errors.Wrapf(ErrUnknownSKU, "%q", vmSize)
+	}
+	return caps, nil
+}
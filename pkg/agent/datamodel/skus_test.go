@@ -0,0 +1,63 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package datamodel
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestSKUCapabilities(t *testing.T) {
+	cases := []struct {
+		vmSize   string
+		expected SKUCaps
+	}{
+		{"Standard_D4s_v3", SKUCaps{VCPUs: 4, AcceleratedNetworking: true, EphemeralOSDisk: true, TrustedLaunch: true}},
+		{"Standard_D2s_v3", SKUCaps{VCPUs: 2, EphemeralOSDisk: true, TrustedLaunch: true}},
+		{"standard_d4s_v5", SKUCaps{VCPUs: 4, AcceleratedNetworking: true, TrustedLaunch: true}},
+		{"Standard_NC24ads_A100_v4_Promo", SKUCaps{VCPUs: 24, GPU: true, AcceleratedNetworking: true, EphemeralOSDisk: true, MIG: true}},
+		{"Standard_M128s", SKUCaps{VCPUs: 128, AcceleratedNetworking: true, EphemeralOSDisk: true}},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.vmSize, func(t *testing.T) {
+			t.Parallel()
+			caps, err := SKUCapabilities(c.vmSize)
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			if caps != c.expected {
+				t.Fatalf("expected SKUCapabilities(%s) to return %+v, but instead got %+v", c.vmSize, c.expected, caps)
+			}
+		})
+	}
+}
+
+func TestSKUCapabilitiesUnknownSKU(t *testing.T) {
+	for _, vmSize := range []string{"", "Standard_Foo42_v9"} {
+		if _, err := SKUCapabilities(vmSize); !errors.Is(err, ErrUnknownSKU) {
+			t.Fatalf("expected SKUCapabilities(%q) to return ErrUnknownSKU, but instead got %v", vmSize, err)
+		}
+	}
+}
+
+// the name based SKU helpers must agree with the SKU capabilities table.
+func TestSKUCapabilitiesMatchSKUHelpers(t *testing.T) {
+	for vmSize, caps := range skuCapabilities {
+		if IsGPUSKU(vmSize) != caps.GPU {
+			t.Errorf("expected IsGPUSKU(%s) to return %t", vmSize, caps.GPU)
+		}
+		if IsMIGCapableSKU(vmSize) != caps.MIG {
+			t.Errorf("expected IsMIGCapableSKU(%s) to return %t", vmSize, caps.MIG)
+		}
+		if IsEphemeralOSDiskSupportedSKU(vmSize) != caps.EphemeralOSDisk {
+			t.Errorf("expected IsEphemeralOSDiskSupportedSKU(%s) to return %t", vmSize, caps.EphemeralOSDisk)
+		}
+		if IsTrustedLaunchSupportedSKU(vmSize) != caps.TrustedLaunch {
+			t.Errorf("expected IsTrustedLaunchSupportedSKU(%s) to return %t", vmSize, caps.TrustedLaunch)
+		}
+	}
+}
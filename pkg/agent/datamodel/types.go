@@ -6,11 +6,14 @@ package datamodel
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"hash/fnv"
 	"math/rand"
 	neturl "net/url"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -112,6 +115,16 @@ const (
 	TempDisk KubeletDiskType = "Temporary"
 )
 
+// OSDiskType describes the type of the OS disk of a node.
+type OSDiskType string
+
+const (
+	// OSDiskTypeManaged indicates the OS disk is a managed disk, backed by remote storage.
+	OSDiskTypeManaged OSDiskType = "Managed"
+	// OSDiskTypeEphemeral indicates the OS disk is placed on the local cache or temp disk of the VM.
+	OSDiskTypeEphemeral OSDiskType = "Ephemeral"
+)
+
 // WorkloadRuntime describes choices for the type of workload: container or wasm-wasi, currently.
 type WorkloadRuntime string
 
@@ -122,6 +135,28 @@ const (
 	WasmWasi WorkloadRuntime = "WasmWasi"
 )
 
+// ResolvConfMode describes how /etc/resolv.conf is configured on Linux nodes.
+type ResolvConfMode string
+
+const (
+	// ResolvConfModeSystemdResolved points /etc/resolv.conf at the systemd-resolved stub resolver.
+	ResolvConfModeSystemdResolved ResolvConfMode = "systemd-resolved"
+	// ResolvConfModeDirect points /etc/resolv.conf directly at the upstream DNS servers, bypassing systemd-resolved.
+	ResolvConfModeDirect ResolvConfMode = "direct"
+)
+
+// KubeProxyMode describes the proxy mode of kube-proxy.
+type KubeProxyMode string
+
+const (
+	// KubeProxyModeIPTables implements services with iptables rules.
+	KubeProxyModeIPTables KubeProxyMode = "iptables"
+	// KubeProxyModeIPVS implements services with IPVS virtual servers, which scale better to many services.
+	KubeProxyModeIPVS KubeProxyMode = "ipvs"
+	// KubeProxyModeNFTables implements services with nftables rules.
+	KubeProxyModeNFTables KubeProxyMode = "nftables"
+)
+
 // OutboundType describes the options for outbound internet access.
 const (
 	OutboundTypeNone  string = "none"
@@ -133,7 +168,7 @@ CommandLineOmittedKubeletConfigFlags are the flags set by RP that should NOT be
 command line flags when configuring kubelet.
 */
 func GetCommandLineOmittedKubeletConfigFlags() map[string]bool {
-	flags := map[string]bool{"--node-status-report-frequency": true}
+	flags := map[string]bool{"--node-status-report-frequency": true, "--max-parallel-image-pulls": true}
 	return flags
 }
 
@@ -303,6 +338,24 @@ func (d Distro) IsKataDistro() bool {
 	return d == AKSCBLMarinerV2Gen2Kata || d == AKSAzureLinuxV2Gen2Kata || d == AKSCBLMarinerV2KataGen2TL || d == CustomizedImageKata
 }
 
+// IsCgroupV2Distro returns true if the distro runs with cgroup v2.
+func (d Distro) IsCgroupV2Distro() bool {
+	return d.Is2204VHDDistro() || d.IsAzureLinuxCgroupV2VHDDistro()
+}
+
+/*
+DefaultKubeletFlags returns the kubelet flags the distro needs by default, i.e. the cgroup paths of the
+kubelet systemd unit of the VHD. They are only applied when unset, user provided kubelet flags take precedence.
+*/
+func (d Distro) DefaultKubeletFlags() map[string]string {
+	if d.IsWindowsDistro() || !d.IsVHDDistro() {
+		return map[string]string{}
+	}
+	return map[string]string{
+		"--kubelet-cgroups": "/system.slice/kubelet.service",
+	}
+}
+
 /*
 KeyvaultSecretRef specifies path to the Azure keyvault along with secret name and (optionaly) version
 for Service Principal's secret.
@@ -1300,6 +1353,11 @@ func (w *WindowsProfile) GetContainerdWindowsRuntimeHandlers() string {
 	return ""
 }
 
+// WindowsIsolationModes returns the isolation modes Windows containers can be run with.
+func WindowsIsolationModes() []string {
+	return []string{WindowsIsolationModeProcess, WindowsIsolationModeHyperV}
+}
+
 // IsAlwaysPullWindowsPauseImage returns true if the windows pause image always needs a force pull.
 func (w *WindowsProfile) IsAlwaysPullWindowsPauseImage() bool {
 	return w.AlwaysPullWindowsPauseImage != nil && *w.AlwaysPullWindowsPauseImage
@@ -1574,6 +1632,222 @@ func (config *NodeBootstrappingConfiguration) GetOrderedKubeproxyConfigStringFor
 	return strings.TrimSuffix(buf.String(), ", ")
 }
 
+// IsArcEnabled returns true if the node is bootstrapped through Azure Arc.
+func (config *NodeBootstrappingConfiguration) IsArcEnabled() bool {
+	return config.ArcConfig != nil
+}
+
+/*
+IsGPUNode returns true if the node has an NVIDIA GPU, either because NVIDIA is enabled or because of its VM size.
+The GPU driver and runtime are only set up when EnableNvidia is set, GPU nodes without it skip the driver install.
+*/
+func (config *NodeBootstrappingConfiguration) IsGPUNode() bool {
+	return config.EnableNvidia || (config.AgentPoolProfile != nil && IsGPUSKU(config.AgentPoolProfile.VMSize))
+}
+
+// IsDualStack returns true if the node is dual-stack, either explicitly or through the feature flag of the cluster.
+func (config *NodeBootstrappingConfiguration) IsDualStack() bool {
+	if config.DualStack {
+		return true
+	}
+	return config.ContainerService != nil && config.ContainerService.Properties != nil &&
+		config.ContainerService.Properties.FeatureFlags.IsFeatureEnabled(EnableIPv6DualStack)
+}
+
+// IsSecureBootEnabled returns true if the node is a trusted launch VM with secure boot, which only loads signed kernel modules.
+func (config *NodeBootstrappingConfiguration) IsSecureBootEnabled() bool {
+	return config.TrustedLaunch != nil && config.TrustedLaunch.SecureBoot
+}
+
+/*
+EffectiveSummary returns the key settings of the node as resolved by the validation, e.g. for a one-line provisioning
+log. Settings which are not set are left out. It contains no secrets.
+*/
+func (config *NodeBootstrappingConfiguration) EffectiveSummary() map[string]string {
+	summary := map[string]string{
+		"osSKU":                 config.OSSKU,
+		"maxPods":               config.KubeletConfig["--max-pods"],
+		"nodeIP":                config.NodeIP,
+		"containerdVersion":     config.ContainerdVersion,
+		"dualStack":             strconv.FormatBool(config.IsDualStack()),
+		"gpu":                   strconv.FormatBool(config.IsGPUNode()),
+		"fips":                  strconv.FormatBool(config.FIPSEnabled),
+		"arm64":                 strconv.FormatBool(config.IsARM64),
+		"arc":                   strconv.FormatBool(config.IsArcEnabled()),
+		"kubeletConfigFile":     strconv.FormatBool(config.EnableKubeletConfigFile),
+		"secureTLSBootstrap":    strconv.FormatBool(config.EnableSecureTLSBootstrapping),
+		"externalCloudProvider": strconv.FormatBool(config.ExternalCloudProvider),
+		"runtimeRequestTimeout": string(config.RuntimeRequestTimeout),
+		"containerdSnapshotter": config.ContainerdSnapshotter,
+		"osDiskType":            string(config.OSDiskType),
+	}
+	if summary["runtimeRequestTimeout"] == "" {
+		summary["runtimeRequestTimeout"] = string(DefaultRuntimeRequestTimeout)
+	}
+	if profile := config.AgentPoolProfile; profile != nil {
+		summary["distro"] = string(profile.Distro)
+		summary["vmSize"] = profile.VMSize
+		if profile.IsWindows() {
+			// the containerd snapshotter is only configured on Linux nodes.
+			delete(summary, "containerdSnapshotter")
+		} else if summary["containerdSnapshotter"] == "" {
+			summary["containerdSnapshotter"] = ContainerdSnapshotterOverlayfs
+		}
+	}
+	if config.ContainerService != nil && config.ContainerService.Properties != nil {
+		if orchestratorProfile := config.ContainerService.Properties.OrchestratorProfile; orchestratorProfile != nil {
+			summary["kubernetesVersion"] = orchestratorProfile.OrchestratorVersion
+			if kubernetesConfig := orchestratorProfile.KubernetesConfig; kubernetesConfig != nil {
+				summary["networkPlugin"] = kubernetesConfig.NetworkPlugin
+				summary["networkPolicy"] = kubernetesConfig.NetworkPolicy
+				summary["containerRuntime"] = kubernetesConfig.ContainerRuntime
+			}
+		}
+	}
+	for key, value := range summary {
+		if value == "" {
+			delete(summary, key)
+		}
+	}
+	return summary
+}
+
+/*
+ExpectedNodeName returns the node name kubelet will register with, which is the lowercased hostname of the VM.
+VMSS instances are named after the computer name prefix followed by the base36 encoded instance index,
+availability set VMs are named <orchestrator>-<pool>-<clusterID>-<index>. HostnamePattern overrides both.
+*/
+func (config *NodeBootstrappingConfiguration) ExpectedNodeName() (string, error) {
+	profile := config.AgentPoolProfile
+	if profile == nil {
+		return "", fmt.Errorf("agent pool profile is required to compute the node name")
+	}
+	if config.ContainerService == nil || config.ContainerService.Properties == nil {
+		return "", fmt.Errorf("container service properties are required to compute the node name")
+	}
+	if config.VMInstanceIndex < 0 || config.VMInstanceIndex >= vmssMaxInstanceIndex {
+		return "", fmt.Errorf("invalid VM instance index %d", config.VMInstanceIndex)
+	}
+	properties := config.ContainerService.Properties
+	if config.HostnamePattern != "" {
+		return config.renderHostnamePattern()
+	}
+
+	switch {
+	case profile.IsVirtualMachineScaleSets():
+		prefix := config.PrimaryScaleSetName
+		if profile.IsWindows() {
+			// Windows computer names are limited to 15 characters, so the scale set name can't be used.
+			prefix = properties.K8sOrchestratorName() + profile.Name
+		}
+		if prefix == "" {
+			return "", fmt.Errorf("primary scale set name is required to compute the node name of a VMSS node")
+		}
+		index := strconv.FormatInt(int64(config.VMInstanceIndex), vmssInstanceIndexBase)
+		return strings.ToLower(prefix + strings.Repeat("0", vmssInstanceIndexLength-len(index)) + index), nil
+	case profile.IsAvailabilitySets():
+		if profile.IsWindows() {
+			return "", fmt.Errorf("node name of Windows availability set nodes is not supported")
+		}
+		name := fmt.Sprintf("%s-%s-%s-%d", properties.K8sOrchestratorName(), profile.Name, properties.GetClusterID(), config.VMInstanceIndex)
+		return strings.ToLower(name), nil
+	default:
+		return "", fmt.Errorf("unsupported availability profile %q", profile.AvailabilityProfile)
+	}
+}
+
+/*
+ExpectedProviderID returns the provider ID the cloud provider sets on the node object, which is the resource ID of the
+VM prefixed with azure://. For VMSS nodes it is the ID of the scale set VM with the instance index as instance ID, for
+availability set nodes the ID of the VM. The cloud provider lowercases the resource group name.
+*/
+func (config *NodeBootstrappingConfiguration) ExpectedProviderID() (string, error) {
+	profile := config.AgentPoolProfile
+	if profile == nil {
+		return "", fmt.Errorf("agent pool profile is required to compute the provider ID")
+	}
+	if config.ContainerService == nil || config.ContainerService.Properties == nil {
+		return "", fmt.Errorf("container service properties are required to compute the provider ID")
+	}
+	if config.SubscriptionID == "" || config.ResourceGroupName == "" {
+		return "", fmt.Errorf("subscription ID and resource group name are required to compute the provider ID")
+	}
+	if config.VMInstanceIndex < 0 || config.VMInstanceIndex >= vmssMaxInstanceIndex {
+		return "", fmt.Errorf("invalid VM instance index %d", config.VMInstanceIndex)
+	}
+	prefix := fmt.Sprintf("azure:///subscriptions/%s/resourceGroups/%s/providers/Microsoft.Compute",
+		config.SubscriptionID, strings.ToLower(config.ResourceGroupName))
+
+	switch {
+	case profile.IsVirtualMachineScaleSets():
+		if config.PrimaryScaleSetName == "" {
+			return "", fmt.Errorf("primary scale set name is required to compute the provider ID of a VMSS node")
+		}
+		return fmt.Sprintf("%s/virtualMachineScaleSets/%s/virtualMachines/%d", prefix, config.PrimaryScaleSetName, config.VMInstanceIndex), nil
+	case profile.IsAvailabilitySets():
+		if profile.IsWindows() {
+			return "", fmt.Errorf("provider ID of Windows availability set nodes is not supported")
+		}
+		properties := config.ContainerService.Properties
+		vmName := fmt.Sprintf("%s-%s-%s-%d", properties.K8sOrchestratorName(), profile.Name, properties.GetClusterID(), config.VMInstanceIndex)
+		return fmt.Sprintf("%s/virtualMachines/%s", prefix, vmName), nil
+	default:
+		return "", fmt.Errorf("unsupported availability profile %q", profile.AvailabilityProfile)
+	}
+}
+
+// renderHostnamePattern replaces the tokens of the hostname pattern and validates the result is a DNS label.
+func (config *NodeBootstrappingConfiguration) renderHostnamePattern() (string, error) {
+	hostname, err := config.ExpandHostnamePattern(strconv.Itoa(config.VMInstanceIndex))
+	if err != nil {
+		return "", err
+	}
+	if len(hostname) > maxHostnameLength || !regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`).MatchString(hostname) {
+		return "", fmt.Errorf("hostname %q of hostname pattern %q must be a DNS label of at most %d letters, digits and hyphens",
+			hostname, config.HostnamePattern, maxHostnameLength)
+	}
+	return hostname, nil
+}
+
+/*
+ExpandHostnamePattern replaces the tokens of the hostname pattern, with instanceID as the instance ID, and lowercases
+the rest like kubelet does. The result is not validated, so instanceID can be a shell expression resolving the
+instance ID on the node. VMSS instances share the custom data of the scale set, so their hostname pattern must contain
+{instanceID}, which is resolved on the node.
+*/
+func (config *NodeBootstrappingConfiguration) ExpandHostnamePattern(instanceID string) (string, error) {
+	profile := config.AgentPoolProfile
+	if profile.IsWindows() || profile.Distro.IsWindowsDistro() {
+		return "", fmt.Errorf("hostname pattern is not supported on Windows nodes")
+	}
+	replacements := map[string]string{
+		HostnamePatternTokenPoolName:   strings.ToLower(profile.Name),
+		HostnamePatternTokenClusterID:  strings.ToLower(config.ContainerService.Properties.GetClusterID()),
+		HostnamePatternTokenInstanceID: instanceID,
+	}
+	pattern := config.HostnamePattern
+	// kubelet registers the node with the lowercased hostname, the instance ID is kept as is.
+	var hostname strings.Builder
+	last := 0
+	for _, loc := range regexp.MustCompile(`\{[^{}]*\}`).FindAllStringIndex(pattern, -1) {
+		token := pattern[loc[0]:loc[1]]
+		replacement, ok := replacements[token]
+		if !ok {
+			return "", fmt.Errorf("unknown token %s in hostname pattern %q, must be one of %s, %s or %s", token,
+				pattern, HostnamePatternTokenPoolName, HostnamePatternTokenClusterID, HostnamePatternTokenInstanceID)
+		}
+		hostname.WriteString(strings.ToLower(pattern[last:loc[0]]))
+		hostname.WriteString(replacement)
+		last = loc[1]
+	}
+	hostname.WriteString(strings.ToLower(pattern[last:]))
+	if profile.IsVirtualMachineScaleSets() && !strings.Contains(pattern, HostnamePatternTokenInstanceID) {
+		return "", fmt.Errorf("hostname pattern %q of VMSS nodes must contain %s, the instances share the custom data of the scale set",
+			pattern, HostnamePatternTokenInstanceID)
+	}
+	return hostname.String(), nil
+}
+
 // IsEnabled returns true if the addon is enabled.
 func (a *KubernetesAddon) IsEnabled() bool {
 	if a.Enabled == nil {
@@ -1704,6 +1978,166 @@ type NodeBootstrappingConfiguration struct {
 	// CNI, which will overwrite the `filter` table so that we can only insert to `mangle` table to avoid
 	// our added rule is overwritten by Cilium.
 	InsertIMDSRestrictionRuleToMangleTable bool
+	// KubeProxyMode is the kube-proxy --proxy-mode of Linux nodes, the kube-proxy default is kept when empty.
+	KubeProxyMode KubeProxyMode
+	// KubeProxyIPVSScheduler is the kube-proxy --ipvs-scheduler, e.g. lc, it requires KubeProxyModeIPVS.
+	KubeProxyIPVSScheduler string
+	// ContainerLogConfig overrides the container log rotation settings of kubelet.
+	ContainerLogConfig *ContainerLogConfig
+	// JournaldConfig overrides the storage and retention settings of journald on Linux nodes.
+	JournaldConfig *JournaldConfig
+	// RuntimeRequestTimeout is the kubelet --runtime-request-timeout, e.g. "5m". The kubelet flags are kept when empty,
+	// kubelet defaults to DefaultRuntimeRequestTimeout.
+	RuntimeRequestTimeout Duration
+	// HousekeepingInterval is the kubelet --housekeeping-interval of the cAdvisor container stats, e.g. "30s", which
+	// trades the freshness of the stats for CPU on dense Linux nodes. The kubelet flags are kept when empty.
+	HousekeepingInterval Duration
+	/* VerboseProvisioning makes the CSE trace the commands it runs, like set -x, and raises the kubelet log verbosity
+	--v to at least 4, to diagnose node failures without re-imaging the node. It is off by default. The CSE logs to
+	/var/log/azure/cluster-provision.log on Linux and to c:\AzureData\CustomDataSetupScript.log on Windows. */
+	VerboseProvisioning bool
+	// VolumePluginDir is the kubelet --volume-plugin-dir of Linux nodes, which CSI drivers may need to be at a custom
+	// path. It must be an absolute path and is created by the CSE. DefaultVolumePluginDir when empty.
+	VolumePluginDir string
+	// PodMaxPids is the kubelet --pod-max-pids, the maximum number of PIDs in a pod, e.g. to limit fork bombs. It must
+	// not be negative. The kubelet flags are kept when nil.
+	PodMaxPids *int
+	// WindowsKubeletConfig are kubelet flags of Windows nodes, e.g. "--max-pods": "60", which override KubeletConfig.
+	// Only the flags AgentBaker can translate into the kubelet config file are allowed, like on Linux.
+	WindowsKubeletConfig map[string]string
+	// SerializeImagePulls is the kubelet --serialize-image-pulls, the kubelet default of serial pulls is kept when nil.
+	SerializeImagePulls *bool
+	// MaxParallelImagePulls is the max number of images kubelet pulls in parallel, it requires SerializeImagePulls
+	// to be false and the kubelet config file.
+	MaxParallelImagePulls *int
+	// NodeIP is the kubelet --node-ip on Linux and Windows nodes, a comma-separated IPv4 and IPv6 pair on dual-stack clusters.
+	NodeIP string
+	// DualStack makes the node dual-stack, it is implied by the EnableIPv6DualStack feature flag of the cluster.
+	// The service and pod CIDRs must then each be a comma-separated IPv4 and IPv6 pair, in the same family order.
+	DualStack bool
+	// KubeletClientCACert is a PEM bundle of the CAs kubelet verifies client certificates with on Linux nodes,
+	// e.g. of a custom PKI. The cluster CA is appended to the bundle written to disk, and used alone when empty.
+	KubeletClientCACert []byte
+	// CredentialProviders are the kubelet image credential provider plugins of Linux nodes, e.g. for ACR. They are
+	// rendered into the kubelet credential provider config, the binaries must be cached on the VHD.
+	CredentialProviders []CredentialProviderConfig
+	// NTPServers is the list of NTP servers chrony/systemd-timesyncd on Linux and w32time on Windows sync with.
+	NTPServers []string
+	// KubeletTLSCipherSuites is the list of TLS cipher suites kubelet is allowed to serve with, using Go cipher suite names.
+	KubeletTLSCipherSuites []string
+	// AllowInsecureKubeletTLSCipherSuites allows KubeletTLSCipherSuites to contain suites Go considers insecure.
+	AllowInsecureKubeletTLSCipherSuites bool
+	// ContainerdConfigTemplateVersion selects the version of the containerd config template, the default is used when empty.
+	ContainerdConfigTemplateVersion string
+	// PackageVersionOverrides maps the downloaded file components the CSE installs on Linux nodes, e.g. cni-plugins,
+	// to the versions to install instead of the VHD defaults. Each version must be cached on the VHD.
+	PackageVersionOverrides map[string]string
+	// CNIPluginVersion pins the version of the CNI plugins on Linux nodes, it must be cached on the VHD.
+	// The VHD default is used when empty.
+	CNIPluginVersion string
+	// ContainerdSnapshotter is the snapshotter of containerd on Linux nodes, ContainerdSnapshotterOverlayfs when empty.
+	ContainerdSnapshotter string
+	// ContainerdOOMScore is the OOMScoreAdjust of the containerd service, the default of the VHD is kept when nil.
+	ContainerdOOMScore *int
+	// ContainerdMaxConcurrentDownloads is the number of image layers containerd downloads in parallel per pull,
+	// the containerd default of 3 is kept when nil.
+	ContainerdMaxConcurrentDownloads *int
+	// ContainerdRootDir is the root directory of containerd on Linux nodes, e.g. on a data disk. It overrides the data
+	// dir of the container runtime config. DefaultContainerdRootDir, or the data dir, when empty.
+	ContainerdRootDir string
+	// ContainerdStateDir is the state directory of containerd on Linux nodes, DefaultContainerdStateDir when empty.
+	ContainerdStateDir string
+	// ContainerdBaseRuntimeSpec is an OCI runtime spec JSON document containerd uses as the base spec of the default
+	// runtime, e.g. to set default rlimits. The containerd default spec is used when empty.
+	ContainerdBaseRuntimeSpec []byte
+	// SeccompDefault runs kubelet of Linux nodes with --seccomp-default, which confines pods without a seccomp profile
+	// with the RuntimeDefault profile of the container runtime. It requires Kubernetes 1.25.
+	SeccompDefault bool
+	// GracefulNodeShutdown makes kubelet on Linux nodes delay the node shutdown to terminate the pods gracefully, the
+	// kubelet defaults, which disable it, are kept when nil. It requires the kubelet config file.
+	GracefulNodeShutdown *GracefulNodeShutdown
+	// DefaultContainerdRuntime is the runtime handler containerd runs pods without a runtime class with, e.g. kata.
+	// It must be configured by the containerd config template, whose default runtime is kept when empty.
+	DefaultContainerdRuntime string
+	// MIGProfile is the multi-instance GPU partition profile of the node, e.g. MIG1g. It sets GPUInstanceProfile.
+	MIGProfile string
+	// NvidiaRuntimeConfig registers the nvidia runtime handler of containerd on GPU nodes, nvidia-container-runtime
+	// must be cached on the VHD.
+	NvidiaRuntimeConfig *NvidiaRuntimeConfig
+	// NodeProblemDetectorConfig runs node-problem-detector as a systemd service on Linux nodes, it must be cached on the VHD.
+	NodeProblemDetectorConfig *NodeProblemDetectorConfig
+	// RejectDeprecatedDistro makes a deprecated distro an error instead of a warning of the node bootstrapping.
+	RejectDeprecatedDistro bool
+	// ArcConfig is set when the node joins the cluster through Azure Arc instead of a managed control plane.
+	ArcConfig *ArcConfig
+	// ImageGCHighThresholdPercent is the disk usage percent after which kubelet image garbage collection always runs.
+	ImageGCHighThresholdPercent *int
+	// ImageGCLowThresholdPercent is the disk usage percent before which kubelet image garbage collection never runs.
+	ImageGCLowThresholdPercent *int
+	// ProvisionCompleteMarker is written by CSE to a marker file at the very end of a successful provisioning.
+	ProvisionCompleteMarker string
+	// PrePullImages is the list of container images pulled during provisioning, images cached on the VHD are skipped.
+	PrePullImages []PrePullImage
+	// EvictionHard maps kubelet eviction signals to hard thresholds, it replaces the --eviction-hard kubelet flag when set.
+	EvictionHard map[string]string
+	// EvictionSoft maps kubelet eviction signals to soft thresholds, each of them needs a grace period.
+	EvictionSoft map[string]string
+	// EvictionSoftGracePeriod maps kubelet eviction signals to the grace periods of their soft thresholds.
+	EvictionSoftGracePeriod map[string]string
+	// DownloadRetryConfig controls how the CSE retries failed component downloads, defaults are used when unset.
+	DownloadRetryConfig *DownloadRetryConfig
+	// DownloadConcurrency is the number of components the CSE downloads in parallel, DefaultDownloadConcurrency when 0.
+	DownloadConcurrency int
+	// WaitForControlPlaneReady makes the CSE wait for the API server to be ready before starting kubelet.
+	// It requires TLS bootstrapping.
+	WaitForControlPlaneReady bool
+	// ControlPlaneReadyTimeoutSeconds bounds the wait for the API server, DefaultControlPlaneReadyTimeoutSeconds when 0.
+	ControlPlaneReadyTimeoutSeconds int
+	// KubeletStartupRetries is the number of times the CSE retries starting kubelet on Linux nodes after a failed start,
+	// e.g. while the network is not ready yet, with a linear backoff. Kubelet is started once when 0.
+	KubeletStartupRetries int
+	// OSDiskType is the type of the OS disk of the node, OSDiskTypeManaged when empty. The containerd and kubelet
+	// data of Linux nodes with an ephemeral OS disk is moved to the ephemeral mount.
+	OSDiskType OSDiskType
+	// TrustedLaunch is set when the node is a trusted launch VM, the distro and VM size must support trusted launch.
+	TrustedLaunch *TrustedLaunch
+	// WorkloadIdentityConfig enables workload identity on the node, it can not be combined with AAD pod identity.
+	WorkloadIdentityConfig *WorkloadIdentityConfig
+	// APTSources are additional APT package repositories written to /etc/apt/sources.list.d/ on Ubuntu nodes.
+	APTSources []APTSource
+	// ResolvConfMode selects how /etc/resolv.conf is configured on Linux nodes, the distro default is kept when empty.
+	ResolvConfMode ResolvConfMode
+	// RegisterWithTaints are the taints kubelet registers the node with, it replaces the --register-with-taints kubelet flag when set.
+	RegisterWithTaints []Taint
+	// ExternalCloudProvider runs kubelet with the out-of-tree cloud-provider-azure instead of the in-tree cloud provider.
+	ExternalCloudProvider bool
+	// ExtraHostsEntries are static entries appended to /etc/hosts on Linux nodes, after the default localhost entries.
+	ExtraHostsEntries []HostEntry
+	// UdevRules are custom udev rules, keyed by file name, written to /etc/udev/rules.d/ on Linux nodes.
+	UdevRules map[string]string
+	// KernelModules are kernel modules loaded at boot on Linux nodes, in the given order.
+	KernelModules []string
+	// DisableSystemdUnits are systemd units the CSE stops and masks on Linux nodes, e.g. snapd. A name without
+	// a unit type suffix is a service.
+	DisableSystemdUnits []string
+	/* EnvironmentVariables are appended to /etc/environment on Linux nodes. Proxy variables are rejected, they must be
+	set through HTTPProxyConfig, which also writes them to /etc/environment and configures the node services. */
+	EnvironmentVariables map[string]string
+	// VMInstanceIndex is the index of the VM within its scale set or availability set.
+	// It is only used to compute the expected node name.
+	VMInstanceIndex int
+	// HostnamePattern overrides the hostname, and so the node name, of Linux nodes. It can contain the tokens
+	// {poolName}, {clusterID} and {instanceID}, the VM instance index. VMSS patterns must contain {instanceID}, which is
+	// resolved on the node since the instances share the custom data of the scale set.
+	HostnamePattern string
+	/* BootCmds are shell commands rendered into the cloud-init bootcmd section of Linux nodes, e.g. to partition a
+	disk. cloud-init runs them in the given order early on every boot, before write_files writes the AgentBaker files
+	and before the CSE provisions the node, so they must be idempotent and must not rely on AgentBaker's files. */
+	BootCmds []string
+	/* SandboxImage is the pause image containerd uses as the sandbox image of the pods of Linux nodes. It overrides
+	K8sComponents.PodInfraContainerImageURL. When neither is set, the pause image matching the Kubernetes version is
+	selected, see SandboxImageForVersion. */
+	SandboxImage string
 }
 
 type SSHStatus int
@@ -1720,6 +2154,69 @@ type NodeBootstrapping struct {
 	CSE            string
 	OSImageConfig  *AzureOSImageConfig
 	SigImageConfig *SigImageConfig
+	// FeatureGates are the effective kubelet feature gates of the node.
+	FeatureGates map[string]bool
+	// Warnings are problems with the configuration which did not prevent generating the node bootstrapping.
+	Warnings []string
+	// Manifest is the provisioning manifest resolved by GetNodeBootstrapping, see ProvisioningManifest.
+	Manifest *ProvisioningManifest `json:"provisioningManifest,omitempty"`
+}
+
+// ImageSource is where the node image of a node bootstrapping was resolved from.
+type ImageSource string
+
+const (
+	// ImageSourceSIG means the node image is an image of the shared image gallery.
+	ImageSourceSIG ImageSource = "SIG"
+	// ImageSourceOSImage means the node image is a marketplace OS image.
+	ImageSourceOSImage ImageSource = "OSImage"
+	// ImageSourceOverride means the node image is not resolved by AgentBaker, e.g. customized images and Arc-connected machines.
+	ImageSourceOverride ImageSource = "Override"
+)
+
+// ImageResolution explains how the node image of a node bootstrapping was resolved, to debug which image a node runs.
+type ImageResolution struct {
+	// Source is where the node image was resolved from. SIG images take precedence over OS images.
+	Source ImageSource
+	// Distro is the distro the node image was resolved for.
+	Distro Distro
+	// Version is the version of the resolved node image, empty for overrides.
+	Version string
+	// VersionOverridden is true if a node image version override replaced the version of the SIG image.
+	VersionOverridden bool
+}
+
+// CustomDataSize returns the size of the base64 encoded custom data, as limited by MaxLinuxCustomDataSize and
+// MaxWindowsCustomDataSize.
+func (n *NodeBootstrapping) CustomDataSize() int {
+	return len(n.CustomData)
+}
+
+// ProvisioningManifest returns the structured summary of the versions and configuration the node will be
+// provisioned with. An error is returned if the node bootstrapping was not produced by GetNodeBootstrapping.
+func (n *NodeBootstrapping) ProvisioningManifest() (*ProvisioningManifest, error) {
+	if n == nil || n.Manifest == nil {
+		return nil, fmt.Errorf("provisioning manifest is not available for this node bootstrapping")
+	}
+	return n.Manifest, nil
+}
+
+// ProvisioningManifest describes the resolved versions and configuration a node is provisioned with.
+type ProvisioningManifest struct {
+	// Distro is the distro of the node image.
+	Distro Distro `json:"distro"`
+	// KubernetesVersion is the orchestrator version of the cluster.
+	KubernetesVersion string `json:"kubernetesVersion"`
+	// ComponentVersions maps a component name, e.g. containerd, to the version requested for the node.
+	ComponentVersions map[string]string `json:"componentVersions,omitempty"`
+	// OSImageConfig is the resolved marketplace image, if any.
+	OSImageConfig *AzureOSImageConfig `json:"osImageConfig,omitempty"`
+	// SigImageConfig is the resolved shared image gallery image, if any.
+	SigImageConfig *SigImageConfig `json:"sigImageConfig,omitempty"`
+	// KubeletFlags are the kubelet flags the node is configured with.
+	KubeletFlags map[string]string `json:"kubeletFlags,omitempty"`
+	// PrePullImages are the images pulled during provisioning because they are not cached on the VHD.
+	PrePullImages []string `json:"prePullImages,omitempty"`
 }
 
 // HTTPProxyConfig represents configurations of http proxy.
@@ -1730,6 +2227,187 @@ type HTTPProxyConfig struct {
 	TrustedCA  *string   `json:"trustedCa,omitempty"`
 }
 
+// ArcConfig represents the Azure Arc connected cluster the node joins.
+type ArcConfig struct {
+	TenantID      string `json:"tenantId,omitempty"`
+	ResourceGroup string `json:"resourceGroup,omitempty"`
+	ClusterName   string `json:"clusterName,omitempty"`
+}
+
+// PrePullImage represents a container image pulled during provisioning.
+type PrePullImage struct {
+	// Image is the image reference to pull.
+	Image string `json:"image"`
+	// Auth is the optional base64 encoded docker config JSON used to pull the image.
+	Auth string `json:"auth,omitempty"`
+}
+
+// TaintEffect is the effect of a node taint on pods which do not tolerate it.
+type TaintEffect string
+
+const (
+	// TaintEffectNoSchedule does not schedule new pods which do not tolerate the taint.
+	TaintEffectNoSchedule TaintEffect = "NoSchedule"
+	// TaintEffectPreferNoSchedule tries to avoid scheduling new pods which do not tolerate the taint.
+	TaintEffectPreferNoSchedule TaintEffect = "PreferNoSchedule"
+	// TaintEffectNoExecute evicts running pods which do not tolerate the taint.
+	TaintEffectNoExecute TaintEffect = "NoExecute"
+)
+
+// Taint represents a node taint applied by kubelet at registration.
+type Taint struct {
+	Key    string      `json:"key"`
+	Value  string      `json:"value,omitempty"`
+	Effect TaintEffect `json:"effect"`
+}
+
+// String returns the taint in the key=value:effect format of the kubelet --register-with-taints flag.
+func (t Taint) String() string {
+	if t.Value == "" {
+		return fmt.Sprintf("%s:%s", t.Key, t.Effect)
+	}
+	return fmt.Sprintf("%s=%s:%s", t.Key, t.Value, t.Effect)
+}
+
+// HostEntry represents a static entry of the /etc/hosts file of Linux nodes.
+type HostEntry struct {
+	// IP is the IPv4 or IPv6 address the hostnames resolve to.
+	IP string `json:"ip"`
+	// Hostnames are the hostnames which resolve to the IP.
+	Hostnames []string `json:"hostnames"`
+}
+
+// APTSource represents an additional APT package repository of Ubuntu nodes.
+type APTSource struct {
+	// URI is the base URI of the repository.
+	URI string `json:"uri"`
+	// Suite is the distribution of the repository, for example "jammy". A suite ending with "/" is a flat repository.
+	Suite string `json:"suite"`
+	// Components are the components of the repository, for example "main", they must be empty for flat repositories.
+	Components []string `json:"components,omitempty"`
+	// SigningKey is the optional ASCII armored GPG public key the repository is signed with.
+	SigningKey string `json:"signingKey,omitempty"`
+}
+
+// ManagedFile represents a file AgentBaker writes to a Linux node, like an entry of the cloud-init write_files.
+type ManagedFile struct {
+	// Path is the absolute path of the file on the node.
+	Path string `json:"path"`
+	// Owner is the owner of the file, in the user:group form.
+	Owner string `json:"owner"`
+	// Mode is the octal permission mode of the file, e.g. "0644".
+	Mode string `json:"mode"`
+}
+
+// FileChange is how a file differs between two rendered node bootstrappings.
+type FileChange string
+
+const (
+	// FileAdded means the file is only written by the second node bootstrapping.
+	FileAdded FileChange = "Added"
+	// FileRemoved means the file is only written by the first node bootstrapping.
+	FileRemoved FileChange = "Removed"
+	// FileChanged means both node bootstrappings write the file, with different content or permissions.
+	FileChanged FileChange = "Changed"
+)
+
+// FileDiff represents a file which differs between two rendered node bootstrappings.
+type FileDiff struct {
+	// Path is the absolute path of the file on the node.
+	Path string `json:"path"`
+	// Change is how the file differs.
+	Change FileChange `json:"change"`
+	// Before is the decoded content of the file in the first node bootstrapping, empty when the file is added.
+	Before string `json:"before,omitempty"`
+	// After is the decoded content of the file in the second node bootstrapping, empty when the file is removed.
+	After string `json:"after,omitempty"`
+}
+
+// JournaldConfig represents the storage and retention settings of journald, the journald defaults are kept for unset fields.
+type JournaldConfig struct {
+	// StorageMode is where journald stores the journal, one of volatile, persistent, auto or none.
+	StorageMode string `json:"storageMode,omitempty"`
+	// MaxUseMB is the max disk space in MB the journal can use.
+	MaxUseMB int `json:"maxUseMB,omitempty"`
+	// MaxRetentionDays is the max number of days journal entries are kept.
+	MaxRetentionDays int `json:"maxRetentionDays,omitempty"`
+}
+
+// TrustedLaunch represents the trusted launch security features of a VM.
+type TrustedLaunch struct {
+	// SecureBoot only allows signed boot loaders, kernels and kernel modules, e.g. GPU drivers, to load.
+	SecureBoot bool `json:"secureBoot,omitempty"`
+	// VTPM enables the virtual trusted platform module of the VM.
+	VTPM bool `json:"vTPM,omitempty"`
+}
+
+// NodeProblemDetectorConfig represents the node-problem-detector service of Linux nodes.
+type NodeProblemDetectorConfig struct {
+	// Enabled runs node-problem-detector with the kernel monitor config installed with it.
+	Enabled bool `json:"enabled,omitempty"`
+	// CustomConfig is an additional system log monitor config JSON document, e.g. to detect problems from the
+	// logs of a custom service.
+	CustomConfig []byte `json:"customConfig,omitempty"`
+}
+
+// NvidiaRuntimeConfig represents the nvidia runtime handler of containerd on GPU nodes.
+type NvidiaRuntimeConfig struct {
+	// Enabled registers the nvidia containerd runtime handler, which runs containers with nvidia-container-runtime.
+	Enabled bool `json:"enabled,omitempty"`
+	// DefaultRuntime makes nvidia the default runtime of containerd, i.e. of pods without a runtime class.
+	DefaultRuntime bool `json:"defaultRuntime,omitempty"`
+}
+
+// GracefulNodeShutdown represents the graceful node shutdown of kubelet.
+type GracefulNodeShutdown struct {
+	// Enabled makes kubelet hold a systemd inhibitor lock to delay the node shutdown and terminate the pods first.
+	Enabled bool `json:"enabled,omitempty"`
+	// ShutdownGracePeriod is the total time the node shutdown is delayed by, e.g. "30s".
+	ShutdownGracePeriod Duration `json:"shutdownGracePeriod,omitempty"`
+	// CriticalPodGracePeriod is the part of ShutdownGracePeriod reserved to terminate critical pods, which are terminated
+	// after the regular pods. It must not be longer than ShutdownGracePeriod.
+	CriticalPodGracePeriod Duration `json:"criticalPodGracePeriod,omitempty"`
+}
+
+// CredentialProviderConfig represents a kubelet image credential provider plugin.
+type CredentialProviderConfig struct {
+	// Name is the name of the plugin binary in the kubelet credential provider bin dir, e.g. acr-credential-provider.
+	Name string `json:"name"`
+	// MatchImages are the image patterns the plugin provides credentials for, e.g. "*.azurecr.io".
+	MatchImages []string `json:"matchImages"`
+	// DefaultCacheDuration is how long kubelet caches credentials without a cache duration from the plugin,
+	// DefaultCredentialProviderCacheDuration when empty.
+	DefaultCacheDuration Duration `json:"defaultCacheDuration,omitempty"`
+	// APIVersion is the credential provider API version of the plugin, DefaultCredentialProviderAPIVersion when empty.
+	APIVersion string `json:"apiVersion,omitempty"`
+	// Args are the command line arguments kubelet runs the plugin with.
+	Args []string `json:"args,omitempty"`
+}
+
+// DownloadRetryConfig represents how the CSE retries failed component downloads.
+type DownloadRetryConfig struct {
+	// MaxRetries is the max number of times a failed download is retried.
+	MaxRetries int `json:"maxRetries,omitempty"`
+	// BackoffSeconds is the number of seconds to wait between retries.
+	BackoffSeconds int `json:"backoffSeconds,omitempty"`
+}
+
+// WorkloadIdentityConfig represents the settings of Microsoft Entra workload identity on the node.
+type WorkloadIdentityConfig struct {
+	// Issuer is the https URL of the OIDC issuer of the cluster's service account tokens.
+	Issuer string `json:"issuer"`
+	// Audience is the audience of the projected service account tokens, DefaultWorkloadIdentityAudience when empty.
+	Audience string `json:"audience,omitempty"`
+}
+
+// ContainerLogConfig represents the container log rotation settings.
+type ContainerLogConfig struct {
+	// MaxSizeMB is the max size in MB of a container log file before it is rotated.
+	MaxSizeMB int `json:"maxSizeMB,omitempty"`
+	// MaxFiles is the max number of container log files that can be present for a container.
+	MaxFiles int `json:"maxFiles,omitempty"`
+}
+
 type CustomCATrustConfig struct {
 	CustomCATrustCerts []string `json:"customCATrustCerts,omitempty"`
 }
@@ -1862,6 +2540,11 @@ type AKSKubeletConfiguration struct {
 	Default: "4h"
 	+optional. */
 	StreamingConnectionIdleTimeout Duration `json:"streamingConnectionIdleTimeout,omitempty"`
+	/* runtimeRequestTimeout is the timeout for all runtime requests except long running
+	requests - pull, logs, exec and attach.
+	Default: "2m"
+	+optional. */
+	RuntimeRequestTimeout Duration `json:"runtimeRequestTimeout,omitempty"`
 	/* nodeStatusUpdateFrequency is the frequency that kubelet computes node
 	status. If node lease feature is not enabled, it is also the frequency that
 	kubelet posts node status to master.
@@ -1974,6 +2657,16 @@ type AKSKubeletConfiguration struct {
 	  imagefs.available: "15%"
 	+optional. */
 	EvictionHard map[string]string `json:"evictionHard,omitempty"`
+	/* Map of signal names to quantities that defines soft eviction thresholds. For example: {"memory.available": "500Mi"}.
+	Every soft threshold needs a matching grace period in EvictionSoftGracePeriod.
+	Default: nil
+	+optional. */
+	EvictionSoft map[string]string `json:"evictionSoft,omitempty"`
+	/* Map of signal names to durations that defines grace periods for each soft eviction signal.
+	For example: {"memory.available": "30s"}.
+	Default: nil
+	+optional. */
+	EvictionSoftGracePeriod map[string]string `json:"evictionSoftGracePeriod,omitempty"`
 	/* protectKernelDefaults, if true, causes the Kubelet to error if kernel
 	flags are not as it expects. Otherwise the Kubelet will attempt to modify
 	kernel flags to match its expectation.
@@ -2013,6 +2706,26 @@ type AKSKubeletConfiguration struct {
 	Default: 5
 	+optional. */
 	ContainerLogMaxFiles *int32 `json:"containerLogMaxFiles,omitempty"`
+	/* serializeImagePulls when enabled, tells the Kubelet to pull images one
+	at a time.
+	Default: true
+	+optional. */
+	SerializeImagePulls *bool `json:"serializeImagePulls,omitempty"`
+	/* maxParallelImagePulls sets the maximum number of image pulls in parallel.
+	This field cannot be set if SerializeImagePulls is true.
+	Default: nil
+	+optional. */
+	MaxParallelImagePulls *int32 `json:"maxParallelImagePulls,omitempty"`
+	/* shutdownGracePeriod specifies the total duration that the node should delay the
+	shutdown and total grace period for pod termination during a node shutdown.
+	Default: "0s"
+	+optional. */
+	ShutdownGracePeriod Duration `json:"shutdownGracePeriod,omitempty"`
+	/* shutdownGracePeriodCriticalPods specifies the duration used to terminate critical
+	pods during a node shutdown. This should be less than shutdownGracePeriod.
+	Default: "0s"
+	+optional. */
+	ShutdownGracePeriodCriticalPods Duration `json:"shutdownGracePeriodCriticalPods,omitempty"`
 
 	/* the following fields are meant for Node Allocatable */
 
@@ -2171,6 +2884,35 @@ func (err *CSEStatusParsingError) Error() string {
 	return fmt.Sprintf("CSE has invalid message=%q, InstanceErrorCode=%s", err.Message, err.Code)
 }
 
+// CloudNotFoundError is returned when there are no settings for the configured cloud.
+type CloudNotFoundError struct {
+	CloudName string
+}
+
+func (err *CloudNotFoundError) Error() string {
+	return fmt.Sprintf("don't have settings for cloud %s", err.CloudName)
+}
+
+// ErrCustomDataTooLarge is matched by errors.Is when the custom data of a node exceeds the limit of its OS.
+//
+//nolint:gochecknoglobals
+var ErrCustomDataTooLarge = errors.New("custom data is too large")
+
+// CustomDataTooLargeError is returned when the custom data of a node exceeds the limit of its OS.
+type CustomDataTooLargeError struct {
+	OSType  OSType
+	Size    int
+	MaxSize int
+}
+
+func (err *CustomDataTooLargeError) Error() string {
+	return fmt.Sprintf("%s: %s custom data is %d characters (base64), the limit is %d", ErrCustomDataTooLarge, err.OSType, err.Size, err.MaxSize)
+}
+
+func (err *CustomDataTooLargeError) Unwrap() error {
+	return ErrCustomDataTooLarge
+}
+
 type AgentPoolWindowsProfile struct {
 	DisableOutboundNat *bool `json:"disableOutboundNat,omitempty"`
 }
@@ -5,10 +5,12 @@ package datamodel
 
 import (
 	"encoding/json"
+	"errors"
 	"strings"
 	"testing"
 
 	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/google/go-cmp/cmp"
 )
 
 const (
@@ -825,6 +827,180 @@ func TestGetPrimaryAvailabilitySetName(t *testing.T) {
 	}
 }
 
+func TestNodeBootstrappingConfigurationExpectedNodeName(t *testing.T) {
+	cases := []struct {
+		name            string
+		poolName        string
+		osType          OSType
+		availability    string
+		scaleSetName    string
+		vmInstanceIndex int
+		hostnamePattern string
+		expected        string
+		expectErr       bool
+	}{
+		{
+			name:            "VMSS node",
+			poolName:        "nodepool1",
+			osType:          Linux,
+			availability:    VirtualMachineScaleSets,
+			scaleSetName:    "aks-nodepool1-28513887-vmss",
+			vmInstanceIndex: 0,
+			expected:        "aks-nodepool1-28513887-vmss000000",
+		},
+		{
+			name:            "VMSS node with base36 instance index",
+			poolName:        "nodepool1",
+			osType:          Linux,
+			availability:    VirtualMachineScaleSets,
+			scaleSetName:    "AKS-nodepool1-28513887-vmss",
+			vmInstanceIndex: 71,
+			expected:        "aks-nodepool1-28513887-vmss00001z",
+		},
+		{
+			name:            "Windows VMSS node",
+			poolName:        "npwin",
+			osType:          Windows,
+			availability:    VirtualMachineScaleSets,
+			scaleSetName:    "aks-npwin-28513887-vmss",
+			vmInstanceIndex: 2,
+			expected:        "aksnpwin000002",
+		},
+		{
+			name:         "VMSS node without scale set name",
+			poolName:     "nodepool1",
+			osType:       Linux,
+			availability: VirtualMachineScaleSets,
+			expectErr:    true,
+		},
+		{
+			name:            "availability set node",
+			poolName:        "agentpool",
+			osType:          Linux,
+			availability:    AvailabilitySet,
+			vmInstanceIndex: 1,
+			expected:        "aks-agentpool-28513887-1",
+		},
+		{
+			name:         "Windows availability set node",
+			poolName:     "agentpool",
+			osType:       Windows,
+			availability: AvailabilitySet,
+			expectErr:    true,
+		},
+		{
+			name:            "negative instance index",
+			poolName:        "nodepool1",
+			osType:          Linux,
+			availability:    VirtualMachineScaleSets,
+			scaleSetName:    "aks-nodepool1-28513887-vmss",
+			vmInstanceIndex: -1,
+			expectErr:       true,
+		},
+		{
+			name:            "hostname pattern",
+			poolName:        "NodePool1",
+			osType:          Linux,
+			availability:    VirtualMachineScaleSets,
+			scaleSetName:    "aks-nodepool1-28513887-vmss",
+			vmInstanceIndex: 71,
+			hostnamePattern: "k8s-{poolName}-{clusterID}-{instanceID}",
+			expected:        "k8s-nodepool1-28513887-71",
+		},
+		{
+			name:            "hostname pattern with unknown token",
+			poolName:        "nodepool1",
+			osType:          Linux,
+			availability:    VirtualMachineScaleSets,
+			hostnamePattern: "{poolName}-{zone}",
+			expectErr:       true,
+		},
+		{
+			name:            "VMSS hostname pattern without the instance ID",
+			poolName:        "nodepool1",
+			osType:          Linux,
+			availability:    VirtualMachineScaleSets,
+			hostnamePattern: "{poolName}-{clusterID}",
+			expectErr:       true,
+		},
+		{
+			name:            "availability set hostname pattern",
+			poolName:        "agentpool",
+			osType:          Linux,
+			availability:    AvailabilitySet,
+			vmInstanceIndex: 2,
+			hostnamePattern: "K8s-{poolName}-{clusterID}",
+			expected:        "k8s-agentpool-28513887",
+		},
+		{
+			name:            "hostname pattern which is not a DNS label",
+			poolName:        "nodepool1",
+			osType:          Linux,
+			availability:    VirtualMachineScaleSets,
+			hostnamePattern: "{poolName}.{instanceID}",
+			expectErr:       true,
+		},
+		{
+			name:            "hostname pattern longer than a DNS label",
+			poolName:        "nodepool1",
+			osType:          Linux,
+			availability:    VirtualMachineScaleSets,
+			hostnamePattern: strings.Repeat("a", 62) + "-{instanceID}",
+			expectErr:       true,
+		},
+		{
+			name:            "hostname pattern on Windows",
+			poolName:        "npwin",
+			osType:          Windows,
+			availability:    VirtualMachineScaleSets,
+			hostnamePattern: "{poolName}-{instanceID}",
+			expectErr:       true,
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+			profile := &AgentPoolProfile{
+				Name:                c.poolName,
+				OSType:              c.osType,
+				AvailabilityProfile: c.availability,
+			}
+			config := &NodeBootstrappingConfiguration{
+				ContainerService: &ContainerService{
+					Properties: &Properties{
+						OrchestratorProfile: &OrchestratorProfile{
+							OrchestratorType: Kubernetes,
+						},
+						HostedMasterProfile: &HostedMasterProfile{
+							DNSPrefix: "foo",
+						},
+						AgentPoolProfiles: []*AgentPoolProfile{profile},
+					},
+				},
+				AgentPoolProfile:    profile,
+				PrimaryScaleSetName: c.scaleSetName,
+				VMInstanceIndex:     c.vmInstanceIndex,
+				HostnamePattern:     c.hostnamePattern,
+			}
+			got, err := config.ExpectedNodeName()
+			if c.expectErr {
+				if err == nil {
+					t.Errorf("expected an error, but got node name %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != c.expected {
+				t.Errorf("expected node name %s, but got %s", c.expected, got)
+			}
+		})
+	}
+}
+
 func TestAgentPoolProfileIsVHDDistro(t *testing.T) {
 	cases := []struct {
 		name     string
@@ -947,6 +1123,46 @@ func TestAgentPoolProfileIs2204VHDDistro(t *testing.T) {
 	}
 }
 
+func TestDistroDefaultKubeletFlags(t *testing.T) {
+	cases := []struct {
+		name     string
+		distro   Distro
+		expected map[string]string
+	}{
+		{
+			name:     "Ubuntu 22.04 runs kubelet in its systemd unit",
+			distro:   AKSUbuntuContainerd2204,
+			expected: map[string]string{"--kubelet-cgroups": "/system.slice/kubelet.service"},
+		},
+		{
+			name:     "Azure Linux V2 runs kubelet in its systemd unit",
+			distro:   AKSAzureLinuxV2,
+			expected: map[string]string{"--kubelet-cgroups": "/system.slice/kubelet.service"},
+		},
+		{
+			name:     "Windows has no defaults",
+			distro:   AKSWindows2019,
+			expected: map[string]string{},
+		},
+		{
+			name:     "customized image has no defaults",
+			distro:   CustomizedImage,
+			expected: map[string]string{},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+			got := c.distro.DefaultKubeletFlags()
+			if diff := cmp.Diff(c.expected, got); diff != "" {
+				t.Errorf("unexpected default kubelet flags (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
 func TestAgentPoolProfileIsAzureLinuxCgroupV2VHDDistro(t *testing.T) {
 	cases := []struct {
 		name     string
@@ -2641,3 +2857,260 @@ func TestSecurityProfileGetProxyAddress(t *testing.T) {
 		})
 	}
 }
+
+func TestNodeBootstrappingProvisioningManifest(t *testing.T) {
+	manifest := &ProvisioningManifest{Distro: AKSUbuntuContainerd2204, KubernetesVersion: "1.29.2"}
+	cases := []struct {
+		name              string
+		nodeBootstrapping *NodeBootstrapping
+		expected          *ProvisioningManifest
+		expectedErr       bool
+	}{
+		{
+			name:              "nil NodeBootstrapping",
+			nodeBootstrapping: nil,
+			expectedErr:       true,
+		},
+		{
+			name:              "manifest not generated",
+			nodeBootstrapping: &NodeBootstrapping{},
+			expectedErr:       true,
+		},
+		{
+			name:              "manifest generated",
+			nodeBootstrapping: &NodeBootstrapping{Manifest: manifest},
+			expected:          manifest,
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+			actual, err := c.nodeBootstrapping.ProvisioningManifest()
+			if c.expectedErr != (err != nil) {
+				t.Fatalf("test case: %s, expected error: %t. Got: %v.", c.name, c.expectedErr, err)
+			}
+			if c.expected != actual {
+				t.Fatalf("test case: %s, expected: %v. Got: %v.", c.name, c.expected, actual)
+			}
+		})
+	}
+}
+
+func TestNodeBootstrappingProvisioningManifestJSON(t *testing.T) {
+	nodeBootstrapping := &NodeBootstrapping{
+		CustomData: "customdata",
+		Manifest:   &ProvisioningManifest{Distro: AKSUbuntuContainerd2204, KubernetesVersion: "1.29.2"},
+	}
+	data, err := json.Marshal(nodeBootstrapping)
+	if err != nil {
+		t.Fatalf("failed to marshal the node bootstrapping: %v", err)
+	}
+	var decoded NodeBootstrapping
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("failed to unmarshal the node bootstrapping: %v", err)
+	}
+	manifest, err := decoded.ProvisioningManifest()
+	if err != nil {
+		t.Fatalf("expected the provisioning manifest to survive JSON, but got %v", err)
+	}
+	if diff := cmp.Diff(nodeBootstrapping.Manifest, manifest); diff != "" {
+		t.Errorf("unexpected provisioning manifest (-want +got):\n%s", diff)
+	}
+}
+
+func TestTaintString(t *testing.T) {
+	cases := []struct {
+		name     string
+		taint    Taint
+		expected string
+	}{
+		{
+			name:     "taint without value",
+			taint:    Taint{Key: "node.kubernetes.io/not-ready", Effect: TaintEffectNoSchedule},
+			expected: "node.kubernetes.io/not-ready:NoSchedule",
+		},
+		{
+			name:     "taint with value",
+			taint:    Taint{Key: "sku", Value: "gpu", Effect: TaintEffectNoExecute},
+			expected: "sku=gpu:NoExecute",
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+			actual := c.taint.String()
+			if c.expected != actual {
+				t.Fatalf("test case: %s, expected: %s. Got: %s.", c.name, c.expected, actual)
+			}
+		})
+	}
+}
+
+func TestIsGPUNode(t *testing.T) {
+	cases := []struct {
+		name         string
+		vmSize       string
+		enableNvidia bool
+		expected     bool
+	}{
+		{"GPU SKU", "Standard_NC24ads_A100_v4", false, true},
+		{"NVIDIA enabled", "Standard_D4s_v3", true, true},
+		{"non GPU SKU", "Standard_D4s_v3", false, false},
+		{"AMD GPU SKU", "Standard_NV8as_v4", false, false},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+			config := &NodeBootstrappingConfiguration{
+				AgentPoolProfile: &AgentPoolProfile{VMSize: c.vmSize},
+				EnableNvidia:     c.enableNvidia,
+			}
+			if ret := config.IsGPUNode(); ret != c.expected {
+				t.Fatalf("expected IsGPUNode() of %s to return %t, but instead got %t", c.vmSize, c.expected, ret)
+			}
+		})
+	}
+}
+
+func TestNodeBootstrappingConfigurationEffectiveSummary(t *testing.T) {
+	config := &NodeBootstrappingConfiguration{
+		ContainerService: &ContainerService{
+			Properties: &Properties{
+				OrchestratorProfile: &OrchestratorProfile{
+					OrchestratorVersion: "1.29.2",
+					KubernetesConfig:    &KubernetesConfig{NetworkPlugin: "azure", ContainerRuntime: "containerd"},
+				},
+			},
+		},
+		AgentPoolProfile: &AgentPoolProfile{Distro: AKSUbuntuContainerd2204Gen2, VMSize: "Standard_D4s_v3"},
+		KubeletConfig:    map[string]string{"--max-pods": "110"},
+		DualStack:        true,
+	}
+	expected := map[string]string{
+		"distro":                string(AKSUbuntuContainerd2204Gen2),
+		"vmSize":                "Standard_D4s_v3",
+		"kubernetesVersion":     "1.29.2",
+		"networkPlugin":         "azure",
+		"containerRuntime":      "containerd",
+		"maxPods":               "110",
+		"dualStack":             "true",
+		"gpu":                   "false",
+		"fips":                  "false",
+		"arm64":                 "false",
+		"arc":                   "false",
+		"kubeletConfigFile":     "false",
+		"secureTLSBootstrap":    "false",
+		"externalCloudProvider": "false",
+		"runtimeRequestTimeout": string(DefaultRuntimeRequestTimeout),
+		"containerdSnapshotter": ContainerdSnapshotterOverlayfs,
+	}
+	if diff := cmp.Diff(expected, config.EffectiveSummary()); diff != "" {
+		t.Fatalf("unexpected EffectiveSummary() (-want +got):\n%s", diff)
+	}
+
+	config.AgentPoolProfile = &AgentPoolProfile{OSType: Windows}
+	if summary := config.EffectiveSummary(); summary["containerdSnapshotter"] != "" || summary["distro"] != "" {
+		t.Fatalf("expected no containerd snapshotter and distro in the summary of a Windows node, got %v", summary)
+	}
+}
+
+func TestCustomDataTooLargeError(t *testing.T) {
+	nodeBootstrapping := &NodeBootstrapping{CustomData: strings.Repeat("A", MaxWindowsCustomDataSize+4)}
+	var err error = &CustomDataTooLargeError{OSType: Windows, Size: nodeBootstrapping.CustomDataSize(), MaxSize: MaxWindowsCustomDataSize}
+	if !errors.Is(err, ErrCustomDataTooLarge) {
+		t.Fatalf("expected %v to match ErrCustomDataTooLarge", err)
+	}
+	expected := "custom data is too large: Windows custom data is 87384 characters (base64), the limit is 87380"
+	if err.Error() != expected {
+		t.Fatalf("expected error %q, but instead got %q", expected, err.Error())
+	}
+}
+
+func TestNodeBootstrappingConfigurationExpectedProviderID(t *testing.T) {
+	cases := []struct {
+		name            string
+		availability    string
+		scaleSetName    string
+		resourceGroup   string
+		vmInstanceIndex int
+		expected        string
+		expectErr       bool
+	}{
+		{
+			name:            "VMSS node",
+			availability:    VirtualMachineScaleSets,
+			scaleSetName:    "aks-nodepool1-28513887-vmss",
+			resourceGroup:   "MC_rg_cluster_eastus",
+			vmInstanceIndex: 71,
+			expected: "azure:///subscriptions/subID/resourceGroups/mc_rg_cluster_eastus/providers/Microsoft.Compute/" +
+				"virtualMachineScaleSets/aks-nodepool1-28513887-vmss/virtualMachines/71",
+		},
+		{
+			name:            "availability set node",
+			availability:    AvailabilitySet,
+			resourceGroup:   "MC_rg_cluster_eastus",
+			vmInstanceIndex: 1,
+			expected:        "azure:///subscriptions/subID/resourceGroups/mc_rg_cluster_eastus/providers/Microsoft.Compute/virtualMachines/aks-nodepool1-28513887-1",
+		},
+		{
+			name:          "VMSS node without scale set name",
+			availability:  VirtualMachineScaleSets,
+			resourceGroup: "MC_rg_cluster_eastus",
+			expectErr:     true,
+		},
+		{
+			name:         "missing resource group",
+			availability: VirtualMachineScaleSets,
+			scaleSetName: "aks-nodepool1-28513887-vmss",
+			expectErr:    true,
+		},
+		{
+			name:            "negative instance index",
+			availability:    AvailabilitySet,
+			resourceGroup:   "MC_rg_cluster_eastus",
+			vmInstanceIndex: -1,
+			expectErr:       true,
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+			profile := &AgentPoolProfile{Name: "nodepool1", OSType: Linux, AvailabilityProfile: c.availability}
+			config := &NodeBootstrappingConfiguration{
+				ContainerService: &ContainerService{
+					Properties: &Properties{
+						OrchestratorProfile: &OrchestratorProfile{OrchestratorType: Kubernetes},
+						HostedMasterProfile: &HostedMasterProfile{DNSPrefix: "foo"},
+						AgentPoolProfiles:   []*AgentPoolProfile{profile},
+					},
+				},
+				AgentPoolProfile:    profile,
+				SubscriptionID:      "subID",
+				ResourceGroupName:   c.resourceGroup,
+				PrimaryScaleSetName: c.scaleSetName,
+				VMInstanceIndex:     c.vmInstanceIndex,
+			}
+			got, err := config.ExpectedProviderID()
+			if c.expectErr {
+				if err == nil {
+					t.Errorf("expected an error, but got provider ID %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != c.expected {
+				t.Errorf("expected provider ID %s, but got %s", c.expected, got)
+			}
+		})
+	}
+}
@@ -534,3 +534,168 @@ func IsSupportedKubernetesVersion(version string, isUpdate, hasWindows bool) boo
 	}
 	return false
 }
+
+// kubernetesVersionRange is an inclusive range of Kubernetes minor versions. An empty bound is open.
+type kubernetesVersionRange struct {
+	min string
+	max string
+}
+
+/*
+distroKubernetesVersionRanges is the matrix of Kubernetes minor versions each distro is validated against.
+Distros not listed here, such as customized images, are not restricted.
+*/
+//nolint:gochecknoglobals
+var distroKubernetesVersionRanges = map[Distro]kubernetesVersionRange{
+	AKSUbuntu1804:                       {max: "1.24"},
+	AKSUbuntu1804Gen2:                   {max: "1.24"},
+	AKSUbuntuGPU1804:                    {max: "1.24"},
+	AKSUbuntuGPU1804Gen2:                {max: "1.24"},
+	AKSUbuntuContainerd1804:             {max: "1.24"},
+	AKSUbuntuContainerd1804Gen2:         {max: "1.24"},
+	AKSUbuntuGPUContainerd1804:          {max: "1.24"},
+	AKSUbuntuGPUContainerd1804Gen2:      {max: "1.24"},
+	AKSUbuntuFipsContainerd1804:         {max: "1.24"},
+	AKSUbuntuFipsContainerd1804Gen2:     {max: "1.24"},
+	AKSUbuntuEdgeZoneContainerd1804:     {max: "1.24"},
+	AKSUbuntuEdgeZoneContainerd1804Gen2: {max: "1.24"},
+	AKSCBLMarinerV1:                     {max: "1.24"},
+	AKSCBLMarinerV2:                     {min: "1.23"},
+	AKSCBLMarinerV2Gen2:                 {min: "1.23"},
+	AKSCBLMarinerV2FIPS:                 {min: "1.23"},
+	AKSCBLMarinerV2Gen2FIPS:             {min: "1.23"},
+	AKSCBLMarinerV2Gen2Kata:             {min: "1.23"},
+	AKSCBLMarinerV2Gen2TL:               {min: "1.23"},
+	AKSCBLMarinerV2KataGen2TL:           {min: "1.23"},
+	AKSCBLMarinerV2Arm64Gen2:            {min: "1.23"},
+	AKSAzureLinuxV2:                     {min: "1.28"},
+	AKSAzureLinuxV2Gen2:                 {min: "1.28"},
+	AKSAzureLinuxV2FIPS:                 {min: "1.28"},
+	AKSAzureLinuxV2Gen2FIPS:             {min: "1.28"},
+	AKSAzureLinuxV2Gen2Kata:             {min: "1.28"},
+	AKSAzureLinuxV2Gen2TL:               {min: "1.28"},
+	AKSAzureLinuxV2Arm64Gen2:            {min: "1.28"},
+	AKSWindows2022Containerd:            {min: "1.23"},
+	AKSWindows2022ContainerdGen2:        {min: "1.23"},
+	AKSWindows23H2:                      {min: "1.25"},
+	AKSWindows23H2Gen2:                  {min: "1.25"},
+}
+
+/*
+SupportedKubernetesVersions returns the lowest and highest Kubernetes minor versions the distro supports.
+An empty string means the range is open on that side.
+*/
+func (d Distro) SupportedKubernetesVersions() (string, string) {
+	r := distroKubernetesVersionRanges[d]
+	return r.min, r.max
+}
+
+// SupportsKubernetesVersion returns true if the distro supports the minor version of the provided Kubernetes version.
+func (d Distro) SupportsKubernetesVersion(version string) bool {
+	r, ok := distroKubernetesVersionRanges[d]
+	if !ok {
+		return true
+	}
+	v, err := semver.ParseTolerant(version)
+	if err != nil {
+		return false
+	}
+	return r.containsMinorVersion(v)
+}
+
+// containsMinorVersion returns true if the minor version of v is in the range.
+func (r kubernetesVersionRange) containsMinorVersion(v semver.Version) bool {
+	// Only the minor version matters, patches and pre-releases follow their minor version.
+	v = semver.Version{Major: v.Major, Minor: v.Minor}
+	if r.min != "" && v.LT(semver.MustParse(r.min+".0")) {
+		return false
+	}
+	if r.max != "" && v.GT(semver.MustParse(r.max+".0")) {
+		return false
+	}
+	return true
+}
+
+// supportedKubernetesVersionRange is the window of Kubernetes minor versions AgentBaker can bootstrap nodes for, it is
+// open-ended like the distro ranges so new Kubernetes releases don't need an AgentBaker release.
+//
+//nolint:gochecknoglobals
+var supportedKubernetesVersionRange = kubernetesVersionRange{min: "1.15"}
+
+// SupportedKubernetesVersionRange returns the lowest and highest Kubernetes minor versions AgentBaker supports, an
+// empty highest version means there is no upper bound.
+func SupportedKubernetesVersionRange() (string, string) {
+	return supportedKubernetesVersionRange.min, supportedKubernetesVersionRange.max
+}
+
+/*
+ParseKubernetesVersion parses a Kubernetes version, which must be a full semantic version such as 1.28.3 or
+1.29.0-rc.1. Partial versions such as 1.28 and a "v" prefix are rejected.
+*/
+func ParseKubernetesVersion(version string) (int, int, int, error) {
+	v, err := semver.Parse(version)
+	if err != nil {
+		return 0, 0, 0, errors.Wrapf(err, "invalid Kubernetes version %q, must be a semantic version like 1.28.3", version)
+	}
+	return int(v.Major), int(v.Minor), int(v.Patch), nil
+}
+
+// ValidateKubernetesVersion validates that the Kubernetes version is a semantic version in the supported window.
+func ValidateKubernetesVersion(version string) error {
+	if _, _, _, err := ParseKubernetesVersion(version); err != nil {
+		return err
+	}
+	if !supportedKubernetesVersionRange.containsMinorVersion(semver.MustParse(version)) {
+		maxVersion := supportedKubernetesVersionRange.max
+		if maxVersion == "" {
+			maxVersion = "latest"
+		}
+		return errors.Errorf("Kubernetes version %s is not supported, supported versions are %s to %s",
+			version, supportedKubernetesVersionRange.min, maxVersion)
+	}
+	return nil
+}
+
+// sandboxImageRepository is the repository of the pause image used as the containerd sandbox image of Linux nodes.
+const sandboxImageRepository = "mcr.microsoft.com/oss/kubernetes/pause"
+
+// sandboxImageVersions are the pause image versions by the first Kubernetes minor version they are used from, in
+// ascending order, following the pause image kubeadm pins for each Kubernetes minor version.
+//
+//nolint:gochecknoglobals
+var sandboxImageVersions = []struct {
+	minMinorVersion uint64
+	version         string
+}{
+	{minMinorVersion: 15, version: "3.1"},
+	{minMinorVersion: 18, version: "3.2"},
+	{minMinorVersion: 21, version: "3.4.1"},
+	{minMinorVersion: 22, version: "3.5"},
+	{minMinorVersion: 23, version: "3.6"},
+	{minMinorVersion: 24, version: "3.7"},
+	{minMinorVersion: 25, version: "3.8"},
+	{minMinorVersion: 26, version: "3.9"},
+	{minMinorVersion: 31, version: "3.10"},
+}
+
+/*
+SandboxImageForVersion returns the pause image matching the Kubernetes version, to be used as the containerd sandbox
+image. A pause image which does not match the Kubernetes version is a common cause of provisioning failures. It
+returns an error for Kubernetes versions which are invalid or outside the supported window.
+*/
+func SandboxImageForVersion(k8sVersion string) (string, error) {
+	if err := ValidateKubernetesVersion(k8sVersion); err != nil {
+		return "", errors.Wrap(err, "failed to select the sandbox image")
+	}
+	minorVersion := semver.MustParse(k8sVersion).Minor
+	image := ""
+	for _, v := range sandboxImageVersions {
+		if minorVersion >= v.minMinorVersion {
+			image = fmt.Sprintf("%s:%s", sandboxImageRepository, v.version)
+		}
+	}
+	if image == "" {
+		return "", errors.Errorf("there is no sandbox image for Kubernetes version %s", k8sVersion)
+	}
+	return image, nil
+}
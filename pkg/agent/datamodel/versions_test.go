@@ -637,3 +637,166 @@ func Test_IsValidMinVersion(t *testing.T) {
 		}
 	})
 }
+
+func TestDistroSupportsKubernetesVersion(t *testing.T) {
+	cases := []struct {
+		name           string
+		distro         Distro
+		version        string
+		expectedResult bool
+	}{
+		{
+			name:           "distro without restrictions supports any version",
+			distro:         CustomizedImage,
+			version:        "1.30.0",
+			expectedResult: true,
+		},
+		{
+			name:           "ubuntu 18.04 supports its last minor version",
+			distro:         AKSUbuntuContainerd1804,
+			version:        "1.24.15",
+			expectedResult: true,
+		},
+		{
+			name:           "ubuntu 18.04 does not support newer minor versions",
+			distro:         AKSUbuntuContainerd1804,
+			version:        "1.25.0",
+			expectedResult: false,
+		},
+		{
+			name:           "azure linux supports its first minor version",
+			distro:         AKSAzureLinuxV2Gen2,
+			version:        "1.28.0",
+			expectedResult: true,
+		},
+		{
+			name:           "azure linux supports pre-releases of supported minor versions",
+			distro:         AKSAzureLinuxV2Gen2,
+			version:        "1.28.0-alpha.1",
+			expectedResult: true,
+		},
+		{
+			name:           "azure linux does not support older minor versions",
+			distro:         AKSAzureLinuxV2Gen2,
+			version:        "1.27.9",
+			expectedResult: false,
+		},
+		{
+			name:           "invalid version is not supported",
+			distro:         AKSAzureLinuxV2Gen2,
+			version:        "latest",
+			expectedResult: false,
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+			if actual := c.distro.SupportsKubernetesVersion(c.version); actual != c.expectedResult {
+				t.Errorf("expected SupportsKubernetesVersion(%s) for distro %s to be %t, got %t", c.version, c.distro, c.expectedResult, actual)
+			}
+		})
+	}
+}
+
+func TestDistroSupportedKubernetesVersions(t *testing.T) {
+	minVersion, maxVersion := AKSWindows23H2.SupportedKubernetesVersions()
+	if minVersion != "1.25" || maxVersion != "" {
+		t.Errorf("expected supported versions of %s to be 1.25 to unbounded, got %q to %q", AKSWindows23H2, minVersion, maxVersion)
+	}
+
+	minVersion, maxVersion = CustomizedImage.SupportedKubernetesVersions()
+	if minVersion != "" || maxVersion != "" {
+		t.Errorf("expected supported versions of %s to be unbounded, got %q to %q", CustomizedImage, minVersion, maxVersion)
+	}
+}
+
+func TestParseKubernetesVersion(t *testing.T) {
+	cases := []struct {
+		version   string
+		expected  [3]int
+		expectErr bool
+	}{
+		{version: "1.28.3", expected: [3]int{1, 28, 3}},
+		{version: "1.29.0-rc.1", expected: [3]int{1, 29, 0}},
+		{version: "1.28", expectErr: true},
+		{version: "v1.28.3", expectErr: true},
+		{version: "1.28.x", expectErr: true},
+		{version: "", expectErr: true},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.version, func(t *testing.T) {
+			t.Parallel()
+			major, minor, patch, err := ParseKubernetesVersion(c.version)
+			if c.expectErr {
+				if err == nil {
+					t.Errorf("expected an error for version %q, but got none", c.version)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := [3]int{major, minor, patch}; got != c.expected {
+				t.Errorf("expected version %q to parse to %v, got %v", c.version, c.expected, got)
+			}
+		})
+	}
+}
+
+func TestValidateKubernetesVersion(t *testing.T) {
+	minVersion, maxVersion := SupportedKubernetesVersionRange()
+	if minVersion != "1.15" || maxVersion != "" {
+		t.Errorf("expected the supported window to be 1.15 to unbounded, got %q to %q", minVersion, maxVersion)
+	}
+	for _, version := range []string{"1.15.0", "1.24.2", "1.31.1", "1.31.0-beta.0", "1.32.0", "1.33.1"} {
+		if err := ValidateKubernetesVersion(version); err != nil {
+			t.Errorf("expected version %s to be valid, but got %v", version, err)
+		}
+	}
+	for _, version := range []string{"1.28", "1.14.10", "v1.29.2"} {
+		if err := ValidateKubernetesVersion(version); err == nil {
+			t.Errorf("expected version %s to be invalid", version)
+		}
+	}
+}
+
+func TestSandboxImageForVersion(t *testing.T) {
+	cases := []struct {
+		version   string
+		expected  string
+		expectErr bool
+	}{
+		{version: "1.15.0", expected: "mcr.microsoft.com/oss/kubernetes/pause:3.1"},
+		{version: "1.21.14", expected: "mcr.microsoft.com/oss/kubernetes/pause:3.4.1"},
+		{version: "1.24.2", expected: "mcr.microsoft.com/oss/kubernetes/pause:3.7"},
+		{version: "1.28.3", expected: "mcr.microsoft.com/oss/kubernetes/pause:3.9"},
+		{version: "1.31.0-beta.0", expected: "mcr.microsoft.com/oss/kubernetes/pause:3.10"},
+		{version: "1.14.10", expectErr: true},
+		{version: "1.33.1", expected: "mcr.microsoft.com/oss/kubernetes/pause:3.10"},
+		{version: "1.28", expectErr: true},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.version, func(t *testing.T) {
+			t.Parallel()
+			image, err := SandboxImageForVersion(c.version)
+			if c.expectErr {
+				if err == nil {
+					t.Errorf("expected an error for version %q, but got sandbox image %q", c.version, image)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if image != c.expected {
+				t.Errorf("expected sandbox image %q for version %q, got %q", c.expected, c.version, image)
+			}
+		})
+	}
+}
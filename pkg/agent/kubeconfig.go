@@ -0,0 +1,126 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package agent
+
+import (
+	"fmt"
+
+	"github.com/Azure/agentbaker/pkg/agent/datamodel"
+	"gopkg.in/yaml.v3"
+)
+
+// names of the cluster, user and context of the bootstrap kubeconfig.
+const (
+	bootstrapKubeconfigClusterName = "localcluster"
+	bootstrapKubeconfigUserName    = "kubelet-bootstrap"
+	bootstrapKubeconfigContextName = "bootstrap-context"
+)
+
+// kubeconfig is the subset of the kubeconfig format the bootstrap kubeconfig of kubelet uses.
+type kubeconfig struct {
+	APIVersion     string              `yaml:"apiVersion"`
+	Kind           string              `yaml:"kind"`
+	Clusters       []kubeconfigCluster `yaml:"clusters"`
+	Users          []kubeconfigUser    `yaml:"users"`
+	Contexts       []kubeconfigContext `yaml:"contexts"`
+	CurrentContext string              `yaml:"current-context"`
+}
+
+type kubeconfigCluster struct {
+	Name    string `yaml:"name"`
+	Cluster struct {
+		CertificateAuthority string `yaml:"certificate-authority"`
+		Server               string `yaml:"server"`
+	} `yaml:"cluster"`
+}
+
+type kubeconfigUser struct {
+	Name string `yaml:"name"`
+	User struct {
+		Token string              `yaml:"token,omitempty"`
+		Exec  *kubeconfigExecUser `yaml:"exec,omitempty"`
+	} `yaml:"user"`
+}
+
+// kubeconfigExecUser configures a client-go credential plugin.
+type kubeconfigExecUser struct {
+	APIVersion         string   `yaml:"apiVersion"`
+	Command            string   `yaml:"command"`
+	Args               []string `yaml:"args"`
+	InteractiveMode    string   `yaml:"interactiveMode"`
+	ProvideClusterInfo bool     `yaml:"provideClusterInfo"`
+}
+
+type kubeconfigContext struct {
+	Name    string `yaml:"name"`
+	Context struct {
+		Cluster string `yaml:"cluster"`
+		User    string `yaml:"user"`
+	} `yaml:"context"`
+}
+
+/*
+RenderBootstrapKubeconfig returns the kubeconfig kubelet uses for TLS bootstrapping, as written to
+/var/lib/kubelet/bootstrap-kubeconfig. With a hard-coded bootstrap token it contains the token, so the result must be
+handled as a secret. With secure TLS bootstrapping it points to the credential plugin on the VHD instead.
+It returns an error when TLS bootstrapping is disabled.
+*/
+func RenderBootstrapKubeconfig(config *datamodel.NodeBootstrappingConfiguration) ([]byte, error) {
+	bootstrapKubeconfig, err := getBootstrapKubeconfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(bootstrapKubeconfig)
+}
+
+func getBootstrapKubeconfig(config *datamodel.NodeBootstrappingConfiguration) (*kubeconfig, error) {
+	if config.KubeletClientTLSBootstrapToken == nil && !config.EnableSecureTLSBootstrapping {
+		return nil, fmt.Errorf("TLS bootstrapping is disabled, there is no bootstrap kubeconfig")
+	}
+	if config.AgentPoolProfile != nil && (config.AgentPoolProfile.IsWindows() || config.AgentPoolProfile.Distro.IsWindowsDistro()) {
+		return nil, fmt.Errorf("the bootstrap kubeconfig is only rendered for Linux nodes")
+	}
+	if config.ContainerService == nil || config.ContainerService.Properties == nil {
+		return nil, fmt.Errorf("container service properties are required to render the bootstrap kubeconfig")
+	}
+	endpoint := getKubernetesEndpoint(config.ContainerService)
+	if endpoint == "" {
+		return nil, fmt.Errorf("the API server FQDN or IP address is required to render the bootstrap kubeconfig")
+	}
+
+	cluster := kubeconfigCluster{Name: bootstrapKubeconfigClusterName}
+	cluster.Cluster.CertificateAuthority = kubernetesCACertFilepath
+	cluster.Cluster.Server = fmt.Sprintf("https://%s:443", endpoint)
+
+	user := kubeconfigUser{Name: bootstrapKubeconfigUserName}
+	// secure TLS bootstrapping takes precedence over a hard-coded token, like in the node custom data.
+	if config.EnableSecureTLSBootstrapping {
+		aadResource := config.CustomSecureTLSBootstrapAADServerAppID
+		if aadResource == "" {
+			aadResource = defaultSecureTLSBootstrapAADResource
+		}
+		user.User.Exec = &kubeconfigExecUser{
+			APIVersion:         "client.authentication.k8s.io/v1",
+			Command:            secureTLSBootstrapClientFilepath,
+			Args:               []string{"bootstrap", "--next-proto=aks-tls-bootstrap", "--aad-resource=" + aadResource},
+			InteractiveMode:    "Never",
+			ProvideClusterInfo: true,
+		}
+	} else {
+		user.User.Token = GetTLSBootstrapTokenForKubeConfig(config.KubeletClientTLSBootstrapToken)
+	}
+
+	context := kubeconfigContext{Name: bootstrapKubeconfigContextName}
+	context.Context.Cluster = bootstrapKubeconfigClusterName
+	context.Context.User = bootstrapKubeconfigUserName
+
+	return &kubeconfig{
+		APIVersion:     "v1",
+		Kind:           "Config",
+		Clusters:       []kubeconfigCluster{cluster},
+		Users:          []kubeconfigUser{user},
+		Contexts:       []kubeconfigContext{context},
+		CurrentContext: bootstrapKubeconfigContextName,
+	}, nil
+}
@@ -0,0 +1,101 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package agent
+
+import (
+	"github.com/Azure/agentbaker/pkg/agent/datamodel"
+	"github.com/Azure/go-autorest/autorest/to"
+	"gopkg.in/yaml.v3"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Test RenderBootstrapKubeconfig", func() {
+	var config *datamodel.NodeBootstrappingConfiguration
+
+	BeforeEach(func() {
+		config = &datamodel.NodeBootstrappingConfiguration{
+			ContainerService: &datamodel.ContainerService{
+				Properties: &datamodel.Properties{
+					HostedMasterProfile: &datamodel.HostedMasterProfile{
+						FQDN:      "aks-12345678.hcp.southcentralus.azmk8s.io",
+						IPAddress: "10.0.0.4",
+					},
+				},
+			},
+			AgentPoolProfile: &datamodel.AgentPoolProfile{Distro: datamodel.AKSUbuntuContainerd2204},
+		}
+	})
+
+	It("should render the bootstrap token", func() {
+		config.KubeletClientTLSBootstrapToken = to.StringPtr("07401b.f395accd246ae52d")
+		content, err := RenderBootstrapKubeconfig(config)
+		Expect(err).NotTo(HaveOccurred())
+
+		var kubeconfig map[string]interface{}
+		Expect(yaml.Unmarshal(content, &kubeconfig)).To(Succeed())
+		Expect(kubeconfig).To(HaveKeyWithValue("current-context", "bootstrap-context"))
+		Expect(string(content)).To(ContainSubstring("server: https://10.0.0.4:443"))
+		Expect(string(content)).To(ContainSubstring("certificate-authority: /etc/kubernetes/certs/ca.crt"))
+		Expect(string(content)).To(ContainSubstring("token: 07401b.f395accd246ae52d"))
+		Expect(string(content)).NotTo(ContainSubstring("command: /opt/azure/tlsbootstrap/tls-bootstrap-client"))
+	})
+
+	It("should render the credential plugin with secure TLS bootstrapping", func() {
+		config.EnableSecureTLSBootstrapping = true
+		content, err := RenderBootstrapKubeconfig(config)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(ContainSubstring("apiVersion: client.authentication.k8s.io/v1"))
+		Expect(string(content)).To(ContainSubstring("command: /opt/azure/tlsbootstrap/tls-bootstrap-client"))
+		Expect(string(content)).To(ContainSubstring("--aad-resource=6dae42f8-4368-4678-94ff-3960e28e3630"))
+		Expect(string(content)).To(ContainSubstring("interactiveMode: Never"))
+		Expect(string(content)).To(ContainSubstring("provideClusterInfo: true"))
+		Expect(string(content)).NotTo(ContainSubstring("token:"))
+	})
+
+	It("should render a custom AAD server application ID", func() {
+		config.EnableSecureTLSBootstrapping = true
+		config.CustomSecureTLSBootstrapAADServerAppID = "appID"
+		content, err := RenderBootstrapKubeconfig(config)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(ContainSubstring("--aad-resource=appID"))
+	})
+
+	It("should fall back to the FQDN of the API server", func() {
+		config.KubeletClientTLSBootstrapToken = to.StringPtr("07401b.f395accd246ae52d")
+		config.ContainerService.Properties.HostedMasterProfile.IPAddress = ""
+		content, err := RenderBootstrapKubeconfig(config)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(ContainSubstring("server: https://aks-12345678.hcp.southcentralus.azmk8s.io:443"))
+	})
+
+	It("should be what the node custom data template renders", func() {
+		config.KubeletClientTLSBootstrapToken = to.StringPtr("07401b.f395accd246ae52d")
+		content, err := RenderBootstrapKubeconfig(config)
+		Expect(err).NotTo(HaveOccurred())
+		getBootstrapKubeconfig, ok := getContainerServiceFuncMap(config)["GetBootstrapKubeconfig"].(func() (string, error))
+		Expect(ok).To(BeTrue())
+		Expect(getBootstrapKubeconfig()).To(Equal(string(content)))
+	})
+
+	It("should return an error when TLS bootstrapping is disabled", func() {
+		_, err := RenderBootstrapKubeconfig(config)
+		Expect(err).To(MatchError(ContainSubstring("TLS bootstrapping is disabled")))
+	})
+
+	It("should return an error for Windows nodes", func() {
+		config.KubeletClientTLSBootstrapToken = to.StringPtr("07401b.f395accd246ae52d")
+		config.AgentPoolProfile = &datamodel.AgentPoolProfile{OSType: datamodel.Windows}
+		_, err := RenderBootstrapKubeconfig(config)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should return an error without the API server endpoint", func() {
+		config.KubeletClientTLSBootstrapToken = to.StringPtr("07401b.f395accd246ae52d")
+		config.ContainerService.Properties.HostedMasterProfile = nil
+		_, err := RenderBootstrapKubeconfig(config)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,37 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package agent
+
+import (
+	"github.com/Azure/agentbaker/pkg/agent/datamodel"
+	"github.com/barkimedes/go-deepcopy"
+)
+
+/*
+DefaultKubeletFlags returns the kubelet flags AgentBaker sets for the config before the user provided KubeletConfig
+and WindowsKubeletConfig flags are applied, e.g. the cgroup paths of the distro, the default feature gates and the
+flags rendered from typed settings like MaxPods. Any of them overridden by a user provided flag is managed by the
+user instead. The config is not modified. It returns nil if the config is invalid.
+*/
+func DefaultKubeletFlags(config *datamodel.NodeBootstrappingConfiguration) map[string]string {
+	copied, err := deepcopy.Anything(config)
+	if err != nil {
+		return nil
+	}
+	defaults, ok := copied.(*datamodel.NodeBootstrappingConfiguration)
+	if !ok || defaults.ContainerService == nil || defaults.ContainerService.Properties == nil {
+		return nil
+	}
+	defaults.KubeletConfig = map[string]string{}
+	defaults.WindowsKubeletConfig = nil
+	if defaults.AgentPoolProfile != nil && defaults.AgentPoolProfile.IsWindows() {
+		err = validateAndSetWindowsNodeBootstrappingConfiguration(defaults)
+	} else {
+		err = validateAndSetLinuxNodeBootstrappingConfiguration(defaults)
+	}
+	if err != nil {
+		return nil
+	}
+	return defaults.KubeletConfig
+}
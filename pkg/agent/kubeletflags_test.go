@@ -0,0 +1,61 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package agent
+
+import (
+	"github.com/Azure/agentbaker/pkg/agent/datamodel"
+	"github.com/Azure/go-autorest/autorest/to"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Test DefaultKubeletFlags", func() {
+	var config *datamodel.NodeBootstrappingConfiguration
+
+	BeforeEach(func() {
+		profile := &datamodel.AgentPoolProfile{
+			Name:                "nodepool1",
+			VMSize:              "Standard_D4s_v3",
+			OSType:              datamodel.Linux,
+			Distro:              datamodel.AKSUbuntuContainerd2204,
+			AvailabilityProfile: datamodel.VirtualMachineScaleSets,
+			KubernetesConfig:    &datamodel.KubernetesConfig{ContainerRuntime: datamodel.Containerd},
+		}
+		config = &datamodel.NodeBootstrappingConfiguration{
+			ContainerService: &datamodel.ContainerService{
+				Properties: &datamodel.Properties{
+					OrchestratorProfile: &datamodel.OrchestratorProfile{
+						OrchestratorType:    datamodel.Kubernetes,
+						OrchestratorVersion: "1.29.2",
+						KubernetesConfig:    &datamodel.KubernetesConfig{},
+					},
+					AgentPoolProfiles: []*datamodel.AgentPoolProfile{profile},
+				},
+			},
+			AgentPoolProfile: profile,
+			KubeletConfig:    map[string]string{"--cgroup-driver": "cgroupfs", "--max-pods": "250", "--node-labels": "team=a"},
+		}
+	})
+
+	It("should return the flags AgentBaker sets without the user provided flags", func() {
+		flags := DefaultKubeletFlags(config)
+		Expect(flags).To(HaveKeyWithValue("--kubelet-cgroups", "/system.slice/kubelet.service"))
+		Expect(flags).NotTo(HaveKey("--cgroup-driver"))
+		Expect(flags).NotTo(HaveKey("--runtime-request-timeout"))
+		Expect(flags).NotTo(HaveKey("--housekeeping-interval"))
+		Expect(flags).NotTo(HaveKey("--node-labels"))
+	})
+
+	It("should not modify the config", func() {
+		DefaultKubeletFlags(config)
+		Expect(config.KubeletConfig).To(Equal(map[string]string{"--cgroup-driver": "cgroupfs", "--max-pods": "250", "--node-labels": "team=a"}))
+		Expect(config.RuntimeRequestTimeout).To(BeEmpty())
+	})
+
+	It("should return nil for an invalid config", func() {
+		config.ContainerdMaxConcurrentDownloads = to.IntPtr(0)
+		Expect(DefaultKubeletFlags(config)).To(BeNil())
+	})
+})
@@ -0,0 +1,123 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package agent
+
+import (
+	"fmt"
+	"path"
+	"sort"
+
+	"github.com/Azure/agentbaker/pkg/agent/datamodel"
+)
+
+// permission modes of the files AgentBaker writes.
+const (
+	managedFileOwner      = "root:root"
+	managedFileModeScript = "0744"
+	managedFileModeConfig = "0644"
+	managedFileModeSecret = "0600"
+)
+
+/*
+ManagedFilePaths returns the files AgentBaker writes to a Linux node with the given config, sorted by path, e.g. for
+compliance scanning. Files which are only written for some configs, like the APT sources list, are only listed when
+the config enables them.
+*/
+func ManagedFilePaths(config *datamodel.NodeBootstrappingConfiguration) ([]datamodel.ManagedFile, error) {
+	if config.ContainerService == nil || config.ContainerService.Properties == nil {
+		return nil, fmt.Errorf("container service properties are required to list the managed files")
+	}
+	if config.AgentPoolProfile != nil && (config.AgentPoolProfile.IsWindows() || config.AgentPoolProfile.Distro.IsWindowsDistro()) {
+		return nil, fmt.Errorf("managed files are only listed for Linux nodes")
+	}
+	files := getManagedFiles(config)
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+	return files, nil
+}
+
+// getManagedFiles returns the write_files plan of the node, in the order the templates write the files.
+func getManagedFiles(config *datamodel.NodeBootstrappingConfiguration) []datamodel.ManagedFile {
+	cs := config.ContainerService
+	var files []datamodel.ManagedFile
+	add := func(path, mode string) {
+		files = append(files, datamodel.ManagedFile{Path: path, Owner: managedFileOwner, Mode: mode})
+	}
+	for _, script := range []string{
+		cseHelpersScriptFilepath, cseHelpersScriptDistroFilepath, cseInstallScriptFilepath,
+		cseInstallScriptDistroFilepath, cseConfigScriptFilepath,
+	} {
+		add(script, managedFileModeScript)
+	}
+	add(kubeletSystemdServiceFilepath, managedFileModeConfig)
+	add(azureCloudConfigFilepath, managedFileModeSecret)
+	add(kubernetesCACertFilepath, managedFileModeSecret)
+	if cs.Properties.LinuxProfile != nil && cs.Properties.LinuxProfile.HasSearchDomain() {
+		add(customSearchDomainsCSEScriptFilepath, managedFileModeScript)
+	}
+	if cs.Properties.FeatureFlags.IsFeatureEnabled(datamodel.EnableIPv6DualStack) {
+		add(dhcpV6ServiceCSEScriptFilepath, managedFileModeConfig)
+		add(dhcpV6ConfigCSEScriptFilepath, managedFileModeScript)
+	}
+	if cs.IsAKSCustomCloud() {
+		add(initAKSCustomCloudFilepath, managedFileModeScript)
+	}
+	if config.KubeletClientTLSBootstrapToken != nil || config.EnableSecureTLSBootstrapping {
+		add(bootstrapKubeconfigFilepath, managedFileModeConfig)
+	}
+	if config.ProvisionCompleteMarker != "" {
+		add(provisionCompleteMarkerFilepath, managedFileModeConfig)
+	}
+	if len(config.APTSources) > 0 {
+		add(aptSourcesListFilepath, managedFileModeConfig)
+		for i, source := range config.APTSources {
+			if source.SigningKey != "" {
+				add(fmt.Sprintf(aptSourceSigningKeyFilepathFormat, i), managedFileModeConfig)
+			}
+		}
+	}
+	udevRuleNames := make([]string, 0, len(config.UdevRules))
+	for name := range config.UdevRules {
+		udevRuleNames = append(udevRuleNames, name)
+	}
+	sort.Strings(udevRuleNames)
+	for _, name := range udevRuleNames {
+		add(path.Join(udevRulesDirectory, name), managedFileModeConfig)
+	}
+	if len(config.KernelModules) > 0 {
+		add(kernelModulesLoadFilepath, managedFileModeConfig)
+	}
+	if config.ContainerdOOMScore != nil {
+		add(containerdOOMScoreDropinFilepath, managedFileModeConfig)
+	}
+	if len(config.EnvironmentVariables) > 0 {
+		add(etcEnvironmentFilepath, managedFileModeConfig)
+	}
+	if config.JournaldConfig != nil {
+		add(journaldConfigDropinFilepath, managedFileModeConfig)
+	}
+	if len(config.ContainerdBaseRuntimeSpec) > 0 {
+		add(containerdBaseRuntimeSpecFilepath, managedFileModeConfig)
+	}
+	if config.ContainerdRootDir != "" || config.ContainerdStateDir != "" {
+		add(containerdDirsDropinFilepath, managedFileModeConfig)
+	}
+	if isGracefulNodeShutdownEnabled(config) {
+		add(gracefulNodeShutdownDropinFilepath, managedFileModeConfig)
+	}
+	if len(config.KubeletClientCACert) > 0 {
+		add(kubeletClientCACertFilepath, managedFileModeConfig)
+	}
+	if len(config.CredentialProviders) > 0 {
+		add(credentialProviderConfigFilepath, managedFileModeConfig)
+	}
+	if npdConfig := config.NodeProblemDetectorConfig; npdConfig != nil && npdConfig.Enabled {
+		add(npdSystemdServiceFilepath, managedFileModeConfig)
+		if len(npdConfig.CustomConfig) > 0 {
+			add(npdCustomConfigFilepath, managedFileModeConfig)
+		}
+	}
+	return files
+}
@@ -0,0 +1,66 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package agent
+
+import (
+	"sort"
+
+	"github.com/Azure/agentbaker/pkg/agent/datamodel"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Test ManagedFilePaths", func() {
+	var config *datamodel.NodeBootstrappingConfiguration
+
+	BeforeEach(func() {
+		config = &datamodel.NodeBootstrappingConfiguration{
+			ContainerService: &datamodel.ContainerService{
+				Properties: &datamodel.Properties{},
+			},
+			AgentPoolProfile: &datamodel.AgentPoolProfile{Distro: datamodel.AKSUbuntuContainerd2204},
+		}
+	})
+
+	It("should list the files written to every node sorted by path", func() {
+		files, err := ManagedFilePaths(config)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(ContainElements(
+			datamodel.ManagedFile{Path: "/opt/azure/containers/provision_configs.sh", Owner: "root:root", Mode: "0744"},
+			datamodel.ManagedFile{Path: "/etc/kubernetes/azure.json", Owner: "root:root", Mode: "0600"},
+			datamodel.ManagedFile{Path: "/etc/systemd/system/kubelet.service", Owner: "root:root", Mode: "0644"},
+		))
+		Expect(sort.SliceIsSorted(files, func(i, j int) bool { return files[i].Path < files[j].Path })).To(BeTrue())
+		for _, file := range files {
+			Expect(file.Path).NotTo(Equal("/etc/apt/sources.list.d/aks-custom.list"))
+		}
+	})
+
+	It("should list the files of the enabled features", func() {
+		config.APTSources = []datamodel.APTSource{
+			{URI: "https://example.com/ubuntu", Suite: "jammy", Components: []string{"main"}, SigningKey: "key"},
+		}
+		config.UdevRules = map[string]string{"99-aks.rules": "rule"}
+		config.JournaldConfig = &datamodel.JournaldConfig{StorageMode: datamodel.JournaldStoragePersistent}
+		files, err := ManagedFilePaths(config)
+		Expect(err).NotTo(HaveOccurred())
+		paths := make([]string, 0, len(files))
+		for _, file := range files {
+			paths = append(paths, file.Path)
+		}
+		Expect(paths).To(ContainElements(
+			"/etc/apt/sources.list.d/aks-custom.list",
+			"/etc/apt/keyrings/aks-custom-0.asc",
+			"/etc/udev/rules.d/99-aks.rules",
+			"/etc/systemd/journald.conf.d/aks-journald.conf",
+		))
+	})
+
+	It("should return an error for Windows nodes", func() {
+		config.AgentPoolProfile.Distro = datamodel.AKSWindows2022Containerd
+		_, err := ManagedFilePaths(config)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,144 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package agent
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/Azure/agentbaker/pkg/agent/datamodel"
+	"gopkg.in/yaml.v3"
+)
+
+// windowsCustomDataFilepath is where the Windows custom data, a single PowerShell script, is written to on the node.
+const windowsCustomDataFilepath = `c:\AzureData\CustomData.bin`
+
+// cloudInitConfig is the part of the cloud-init config of the Linux custom data the rendered files are read from.
+type cloudInitConfig struct {
+	WriteFiles []cloudInitFile `yaml:"write_files"`
+}
+
+// cloudInitFile is an entry of the cloud-init write_files.
+type cloudInitFile struct {
+	Path        string `yaml:"path"`
+	Permissions string `yaml:"permissions"`
+	Owner       string `yaml:"owner"`
+	Encoding    string `yaml:"encoding"`
+	Content     string `yaml:"content"`
+}
+
+// renderedFile is a file written by the custom data, with its decoded content.
+type renderedFile struct {
+	permissions string
+	owner       string
+	content     string
+}
+
+/*
+DiffRenderedFiles returns the files which differ between the custom data of two node bootstrappings, sorted by path,
+e.g. to review the effect of a template change on the node. The Linux custom data is decoded into the cloud-init
+write_files, the Windows custom data is compared as a single file.
+*/
+func DiffRenderedFiles(a, b *datamodel.NodeBootstrapping) ([]datamodel.FileDiff, error) {
+	if a == nil || b == nil {
+		return nil, fmt.Errorf("both node bootstrappings are required to diff the rendered files")
+	}
+	before, err := decodeRenderedFiles(a.CustomData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode the custom data of the first node bootstrapping: %w", err)
+	}
+	after, err := decodeRenderedFiles(b.CustomData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode the custom data of the second node bootstrapping: %w", err)
+	}
+	var diffs []datamodel.FileDiff
+	for filepath, beforeFile := range before {
+		afterFile, ok := after[filepath]
+		switch {
+		case !ok:
+			diffs = append(diffs, datamodel.FileDiff{Path: filepath, Change: datamodel.FileRemoved, Before: beforeFile.content})
+		case beforeFile != afterFile:
+			diffs = append(diffs, datamodel.FileDiff{
+				Path:   filepath,
+				Change: datamodel.FileChanged,
+				Before: beforeFile.content,
+				After:  afterFile.content,
+			})
+		}
+	}
+	for filepath, afterFile := range after {
+		if _, ok := before[filepath]; !ok {
+			diffs = append(diffs, datamodel.FileDiff{Path: filepath, Change: datamodel.FileAdded, After: afterFile.content})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Path < diffs[j].Path })
+	return diffs, nil
+}
+
+// decodeRenderedFiles decodes the base64 encoded custom data of a node bootstrapping into the files it writes, by path.
+func decodeRenderedFiles(customData string) (map[string]renderedFile, error) {
+	decoded, err := base64.StdEncoding.DecodeString(customData)
+	if err != nil {
+		return nil, fmt.Errorf("custom data is not base64 encoded: %w", err)
+	}
+	files := make(map[string]renderedFile)
+	if !bytes.HasPrefix(decoded, []byte("#cloud-config")) {
+		files[windowsCustomDataFilepath] = renderedFile{content: string(decoded)}
+		return files, nil
+	}
+	var config cloudInitConfig
+	if err = yaml.Unmarshal(decoded, &config); err != nil {
+		return nil, fmt.Errorf("custom data is not a valid cloud-init config: %w", err)
+	}
+	for _, file := range config.WriteFiles {
+		content, err := decodeCloudInitContent(file.Content, file.Encoding)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode the content of %s: %w", file.Path, err)
+		}
+		files[file.Path] = renderedFile{permissions: file.Permissions, owner: file.Owner, content: content}
+	}
+	return files, nil
+}
+
+/*
+decodeCloudInitContent decodes the content of a cloud-init write_files entry with the given encoding. Binary content,
+tagged with !!binary, is already base64 decoded by the YAML parser.
+*/
+func decodeCloudInitContent(content, encoding string) (string, error) {
+	switch strings.ToLower(encoding) {
+	case "", "text/plain":
+		return content, nil
+	case "b64", "base64":
+		decoded, err := base64.StdEncoding.DecodeString(content)
+		return string(decoded), err
+	case "gz", "gzip":
+		return gunzip([]byte(content))
+	case "gz+b64", "gz+base64", "gzip+b64", "gzip+base64":
+		decoded, err := base64.StdEncoding.DecodeString(content)
+		if err != nil {
+			return "", err
+		}
+		return gunzip(decoded)
+	default:
+		return "", fmt.Errorf("unsupported encoding %q", encoding)
+	}
+}
+
+// gunzip returns the decompressed gzip data.
+func gunzip(data []byte) (string, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	decompressed, err := io.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+	return string(decompressed), reader.Close()
+}
@@ -0,0 +1,76 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package agent
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/agentbaker/pkg/agent/datamodel"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Test DiffRenderedFiles", func() {
+	newLinuxNodeBootstrapping := func(files map[string]string) *datamodel.NodeBootstrapping {
+		var customData strings.Builder
+		customData.WriteString("#cloud-config\n\nwrite_files:\n")
+		for filepath, content := range files {
+			fmt.Fprintf(&customData, "- path: %s\n  permissions: \"0644\"\n  owner: root\n  encoding: gzip\n  content: !!binary |\n    %s\n",
+				filepath, getBase64EncodedGzippedCustomScriptFromStr(content))
+		}
+		return &datamodel.NodeBootstrapping{CustomData: base64.StdEncoding.EncodeToString([]byte(customData.String()))}
+	}
+
+	It("should report the added, removed and changed files sorted by path", func() {
+		a := newLinuxNodeBootstrapping(map[string]string{
+			"/etc/kubernetes/azure.json":          "{}",
+			"/opt/azure/containers/provision.sh":  "echo provision",
+			"/etc/systemd/system/kubelet.service": "[Unit]",
+		})
+		b := newLinuxNodeBootstrapping(map[string]string{
+			"/etc/kubernetes/azure.json":          "{}",
+			"/opt/azure/containers/provision.sh":  "echo provisioned",
+			"/etc/containerd/config.toml":         "version = 2",
+			"/etc/systemd/system/kubelet.service": "[Unit]",
+		})
+		diffs, err := DiffRenderedFiles(a, b)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(diffs).To(Equal([]datamodel.FileDiff{
+			{Path: "/etc/containerd/config.toml", Change: datamodel.FileAdded, After: "version = 2"},
+			{Path: "/opt/azure/containers/provision.sh", Change: datamodel.FileChanged, Before: "echo provision", After: "echo provisioned"},
+		}))
+
+		diffs, err = DiffRenderedFiles(b, a)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(diffs[0]).To(Equal(datamodel.FileDiff{Path: "/etc/containerd/config.toml", Change: datamodel.FileRemoved, Before: "version = 2"}))
+	})
+
+	It("should report no files for identical node bootstrappings", func() {
+		a := newLinuxNodeBootstrapping(map[string]string{"/etc/kubernetes/azure.json": "{}"})
+		diffs, err := DiffRenderedFiles(a, a)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(diffs).To(BeEmpty())
+	})
+
+	It("should compare the Windows custom data as a single file", func() {
+		a := &datamodel.NodeBootstrapping{CustomData: base64.StdEncoding.EncodeToString([]byte("$global:KubeletVersion = '1.28.3'"))}
+		b := &datamodel.NodeBootstrapping{CustomData: base64.StdEncoding.EncodeToString([]byte("$global:KubeletVersion = '1.29.2'"))}
+		diffs, err := DiffRenderedFiles(a, b)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(diffs).To(HaveLen(1))
+		Expect(diffs[0].Path).To(Equal(`c:\AzureData\CustomData.bin`))
+		Expect(diffs[0].Change).To(Equal(datamodel.FileChanged))
+	})
+
+	It("should return an error for custom data which is not base64 encoded", func() {
+		a := newLinuxNodeBootstrapping(nil)
+		_, err := DiffRenderedFiles(a, &datamodel.NodeBootstrapping{CustomData: "not base64!"})
+		Expect(err).To(MatchError(ContainSubstring("failed to decode the custom data of the second node bootstrapping")))
+		_, err = DiffRenderedFiles(a, nil)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -1,6 +1,106 @@
 package toggles
 
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/Azure/agentbaker/pkg/agent/datamodel"
+)
+
+const (
+	linuxNodeImageVersion           = "linux-node-image-version"
+	containerdConfigTemplateVersion = "containerd-config-template-version"
+	cniPluginVersion                = "cni-plugin-version"
+	packageVersionOverrides         = "package-version-overrides"
+)
+
+//nolint:gochecknoglobals
+var (
+	linuxNodeImageVersionRegex           = regexp.MustCompile(`^[0-9]{6}\.[0-9]{2}\.[0-9]+$`)
+	containerdConfigTemplateVersionRegex = regexp.MustCompile(`^v[0-9]+$`)
+	cniPluginVersionRegex                = regexp.MustCompile(`^[0-9]+\.[0-9]+\.[0-9]+$`)
+	packageNameRegex                     = regexp.MustCompile(`^[a-z0-9][-a-z0-9._]*$`)
+)
+
+// mapToggleValidators validates the resolved values of the known map toggles.
+//
+//nolint:gochecknoglobals
+var mapToggleValidators = map[string]func(value map[string]string) error{
+	linuxNodeImageVersion:   validateLinuxNodeImageVersion,
+	packageVersionOverrides: validatePackageVersionOverrides,
+}
+
+// stringToggleValidators validates the resolved values of the known string toggles.
+//
+//nolint:gochecknoglobals
+var stringToggleValidators = map[string]func(value string) error{
+	containerdConfigTemplateVersion: validateContainerdConfigTemplateVersion,
+	cniPluginVersion:                validateCNIPluginVersion,
+}
+
 // GetLinuxNodeImageVersion gets the value of the 'linux-node-image-version' map toggle.
 func (t *Toggles) GetLinuxNodeImageVersion(entity *Entity) map[string]string {
-	return t.getMap("linux-node-image-version", entity)
+	return t.getMap(linuxNodeImageVersion, entity)
+}
+
+// GetContainerdConfigTemplateVersion gets the value of the 'containerd-config-template-version' string toggle,
+// and whether a version is set for the specified Entity.
+func (t *Toggles) GetContainerdConfigTemplateVersion(entity *Entity) (string, bool) {
+	version := t.getString(containerdConfigTemplateVersion, entity)
+	return version, version != ""
+}
+
+// GetCNIPluginVersion gets the value of the 'cni-plugin-version' string toggle,
+// and whether a version is pinned for the specified Entity.
+func (t *Toggles) GetCNIPluginVersion(entity *Entity) (string, bool) {
+	version := t.getString(cniPluginVersion, entity)
+	return version, version != ""
+}
+
+// GetPackageVersionOverrides gets the value of the 'package-version-overrides' map toggle, which maps the names
+// of the packages the CSE installs, e.g. cni-plugins, to the staged versions to install instead of the defaults.
+func (t *Toggles) GetPackageVersionOverrides(entity *Entity) map[string]string {
+	return t.getMap(packageVersionOverrides, entity)
+}
+
+// validateLinuxNodeImageVersion checks that the overrides map known Linux distros to SIG image versions.
+func validateLinuxNodeImageVersion(value map[string]string) error {
+	for distro, version := range value {
+		if !datamodel.Distro(distro).IsLinuxSIGDistro() {
+			return fmt.Errorf("unknown Linux distro %q", distro)
+		}
+		if !linuxNodeImageVersionRegex.MatchString(version) {
+			return fmt.Errorf("invalid image version %q for distro %s, expected the format YYYYMM.DD.PATCH", version, distro)
+		}
+	}
+	return nil
+}
+
+// validatePackageVersionOverrides checks that the overrides map package names to non-empty versions.
+func validatePackageVersionOverrides(value map[string]string) error {
+	for name, version := range value {
+		if !packageNameRegex.MatchString(name) {
+			return fmt.Errorf("invalid package name %q", name)
+		}
+		if version == "" {
+			return fmt.Errorf("empty version for package %s", name)
+		}
+	}
+	return nil
+}
+
+// validateContainerdConfigTemplateVersion checks that the version, if set, looks like "v1".
+func validateContainerdConfigTemplateVersion(value string) error {
+	if value != "" && !containerdConfigTemplateVersionRegex.MatchString(value) {
+		return fmt.Errorf("invalid containerd config template version %q", value)
+	}
+	return nil
+}
+
+// validateCNIPluginVersion checks that the version, if set, looks like "1.4.1".
+func validateCNIPluginVersion(value string) error {
+	if value != "" && !cniPluginVersionRegex.MatchString(value) {
+		return fmt.Errorf("invalid CNI plugin version %q, expected the format MAJOR.MINOR.PATCH", value)
+	}
+	return nil
 }
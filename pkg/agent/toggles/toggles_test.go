@@ -82,6 +82,7 @@ var _ = Describe("tgls tests", func() {
 	Context("getString tests", func() {
 		When("toggles are nil", func() {
 			It("should return the empty default value", func() {
+				tgls = nil
 				s := tgls.getString("st", e)
 				Expect(s).To(BeEmpty())
 			})
@@ -89,6 +90,7 @@ var _ = Describe("tgls tests", func() {
 
 		When("string toggles are nil", func() {
 			It("should return the empty default value", func() {
+				tgls.Strings = nil
 				s := tgls.getString("st", e)
 				Expect(s).To(BeEmpty())
 			})
@@ -111,4 +113,167 @@ var _ = Describe("tgls tests", func() {
 			})
 		})
 	})
+	Context("GetContainerdConfigTemplateVersion tests", func() {
+		When("toggle does not exist", func() {
+			It("should return no version", func() {
+				version, ok := tgls.GetContainerdConfigTemplateVersion(e)
+				Expect(ok).To(BeFalse())
+				Expect(version).To(BeEmpty())
+			})
+		})
+
+		When("toggle exists", func() {
+			It("should return the version", func() {
+				tgls.Strings["containerd-config-template-version"] = func(entity *Entity) string {
+					return "v1"
+				}
+				version, ok := tgls.GetContainerdConfigTemplateVersion(e)
+				Expect(ok).To(BeTrue())
+				Expect(version).To(Equal("v1"))
+			})
+		})
+	})
+	Context("GetCNIPluginVersion tests", func() {
+		When("toggle does not exist", func() {
+			It("should return no version", func() {
+				version, ok := tgls.GetCNIPluginVersion(e)
+				Expect(ok).To(BeFalse())
+				Expect(version).To(BeEmpty())
+			})
+		})
+
+		When("toggle exists", func() {
+			It("should return the version pinned for the region", func() {
+				tgls.Strings["cni-plugin-version"] = func(entity *Entity) string {
+					if entity.Fields["region"] == "eastus" {
+						return "1.4.1"
+					}
+					return ""
+				}
+				version, ok := tgls.GetCNIPluginVersion(NewEntity(map[string]string{"region": "eastus"}))
+				Expect(ok).To(BeTrue())
+				Expect(version).To(Equal("1.4.1"))
+
+				version, ok = tgls.GetCNIPluginVersion(NewEntity(map[string]string{"region": "westus"}))
+				Expect(ok).To(BeFalse())
+				Expect(version).To(BeEmpty())
+			})
+		})
+	})
+	Context("GetPackageVersionOverrides tests", func() {
+		When("toggle does not exist", func() {
+			It("should return no overrides", func() {
+				Expect(tgls.GetPackageVersionOverrides(e)).To(BeEmpty())
+			})
+		})
+
+		When("toggle exists", func() {
+			It("should return the versions staged for the region", func() {
+				tgls.Maps["package-version-overrides"] = func(entity *Entity) map[string]string {
+					if entity.Fields["region"] == "eastus" {
+						return map[string]string{"cni-plugins": "1.4.1"}
+					}
+					return nil
+				}
+				overrides := tgls.GetPackageVersionOverrides(NewEntity(map[string]string{"region": "eastus"}))
+				Expect(overrides).To(Equal(map[string]string{"cni-plugins": "1.4.1"}))
+				Expect(tgls.GetPackageVersionOverrides(NewEntity(map[string]string{"region": "westus"}))).To(BeEmpty())
+			})
+		})
+	})
+	Context("Validate tests", func() {
+		BeforeEach(func() {
+			tgls = &Toggles{
+				Maps: map[string]MapToggle{
+					"linux-node-image-version": func(entity *Entity) map[string]string {
+						if entity.Fields["region"] == "eastus" {
+							return map[string]string{"aks-ubuntu-containerd-22.04-gen2": "202405.03.0"}
+						}
+						return map[string]string{"aks-azurelinux-v2-gen2": "202404.22.0"}
+					},
+				},
+				Strings: map[string]StringToggle{
+					"containerd-config-template-version": func(entity *Entity) string {
+						return "v1"
+					},
+				},
+			}
+		})
+
+		When("toggles are nil", func() {
+			It("should succeed", func() {
+				tgls = nil
+				Expect(tgls.Validate()).To(Succeed())
+			})
+		})
+
+		When("toggles are well-formed", func() {
+			It("should succeed", func() {
+				Expect(tgls.Validate()).To(Succeed())
+			})
+		})
+
+		When("a toggle is unknown", func() {
+			It("should return an error", func() {
+				tgls.Strings["containerd-config-version"] = func(entity *Entity) string {
+					return "v1"
+				}
+				Expect(tgls.Validate()).To(MatchError(ContainSubstring("unknown string toggle")))
+			})
+		})
+
+		When("a toggle is nil", func() {
+			It("should return an error", func() {
+				tgls.Maps["linux-node-image-version"] = nil
+				Expect(tgls.Validate()).NotTo(Succeed())
+			})
+		})
+
+		When("a toggle references an unknown distro", func() {
+			It("should return an error", func() {
+				tgls.Maps["linux-node-image-version"] = func(entity *Entity) map[string]string {
+					return map[string]string{"aks-ubuntu-containerd-22.04-gen3": "202405.03.0"}
+				}
+				Expect(tgls.Validate()).To(MatchError(ContainSubstring("unknown Linux distro")))
+			})
+		})
+
+		When("a toggle has a malformed value", func() {
+			It("should return an error", func() {
+				tgls.Strings["containerd-config-template-version"] = func(entity *Entity) string {
+					return "1"
+				}
+				Expect(tgls.Validate()).NotTo(Succeed())
+			})
+		})
+
+		When("the CNI plugin version toggle has a malformed value", func() {
+			It("should return an error", func() {
+				tgls.Strings["cni-plugin-version"] = func(entity *Entity) string {
+					return "v1.4.1"
+				}
+				Expect(tgls.Validate()).To(MatchError(ContainSubstring("invalid CNI plugin version")))
+			})
+		})
+
+		When("the package version overrides toggle has a malformed value", func() {
+			It("should return an error", func() {
+				tgls.Maps["package-version-overrides"] = func(entity *Entity) map[string]string {
+					return map[string]string{"cni-plugins": ""}
+				}
+				Expect(tgls.Validate()).To(MatchError(ContainSubstring("empty version for package cni-plugins")))
+			})
+		})
+
+		When("a toggle panics", func() {
+			It("should return an error", func() {
+				tgls.Maps["linux-node-image-version"] = func(entity *Entity) map[string]string {
+					var m map[string]string
+					m["distro"] = "version"
+					return m
+				}
+				Expect(tgls.Validate()).To(MatchError(ContainSubstring("failed to resolve toggle")))
+			})
+		})
+	})
 })
@@ -1,6 +1,7 @@
 package toggles
 
 import (
+	"fmt"
 	"log"
 
 	"github.com/Azure/agentbaker/pkg/agent/datamodel"
@@ -82,9 +83,64 @@ func (t *Toggles) getMap(name string, entity *Entity) map[string]string {
 func (t *Toggles) getString(name string, entity *Entity) string {
 	if t == nil || t.Strings == nil {
 		log.Printf("string toggles are nil, resolving to default empty string value for toggle: %q", name)
+		return ""
 	}
 	if toggle, ok := t.Strings[name]; ok {
 		return toggle(entity)
 	}
 	return ""
 }
+
+/*
+Validate checks that every toggle is a known, non-nil toggle and that the value it resolves to for an Entity without
+fields, which is what any rule not scoped to a subscription, tenant or region resolves to, is well-formed.
+*/
+func (t *Toggles) Validate() error {
+	if t == nil {
+		return nil
+	}
+	emptyEntity := NewEntity(map[string]string{})
+	for name, toggle := range t.Maps {
+		validate, ok := mapToggleValidators[name]
+		if !ok {
+			return fmt.Errorf("unknown map toggle %q", name)
+		}
+		if toggle == nil {
+			return fmt.Errorf("map toggle %q is nil", name)
+		}
+		value, err := resolve(name, func() map[string]string { return toggle(emptyEntity) })
+		if err != nil {
+			return err
+		}
+		if err = validate(value); err != nil {
+			return fmt.Errorf("invalid value of map toggle %q: %w", name, err)
+		}
+	}
+	for name, toggle := range t.Strings {
+		validate, ok := stringToggleValidators[name]
+		if !ok {
+			return fmt.Errorf("unknown string toggle %q", name)
+		}
+		if toggle == nil {
+			return fmt.Errorf("string toggle %q is nil", name)
+		}
+		value, err := resolve(name, func() string { return toggle(emptyEntity) })
+		if err != nil {
+			return err
+		}
+		if err = validate(value); err != nil {
+			return fmt.Errorf("invalid value of string toggle %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// resolve resolves a toggle, turning a panic of a malformed toggle into an error.
+func resolve[T any](name string, toggle func() T) (value T, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to resolve toggle %q: %v", name, r)
+		}
+	}()
+	return toggle(), nil
+}
@@ -9,6 +9,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"regexp"
 	"sort"
 	"strconv"
@@ -28,43 +29,50 @@ import (
 */
 //nolint:gochecknoglobals
 var TranslatedKubeletConfigFlags = map[string]bool{
-	"--address":                           true,
-	"--anonymous-auth":                    true,
-	"--client-ca-file":                    true,
-	"--authentication-token-webhook":      true,
-	"--authorization-mode":                true,
-	"--pod-manifest-path":                 true,
-	"--cluster-dns":                       true,
-	"--cgroups-per-qos":                   true,
-	"--tls-cert-file":                     true,
-	"--tls-private-key-file":              true,
-	"--tls-cipher-suites":                 true,
-	"--cluster-domain":                    true,
-	"--max-pods":                          true,
-	"--eviction-hard":                     true,
-	"--node-status-update-frequency":      true,
-	"--node-status-report-frequency":      true,
-	"--image-gc-high-threshold":           true,
-	"--image-gc-low-threshold":            true,
-	"--event-qps":                         true,
-	"--pod-max-pids":                      true,
-	"--enforce-node-allocatable":          true,
-	"--streaming-connection-idle-timeout": true,
-	"--rotate-certificates":               true,
-	"--read-only-port":                    true,
-	"--feature-gates":                     true,
-	"--protect-kernel-defaults":           true,
-	"--resolv-conf":                       true,
-	"--system-reserved":                   true,
-	"--kube-reserved":                     true,
-	"--cpu-manager-policy":                true,
-	"--cpu-cfs-quota":                     true,
-	"--cpu-cfs-quota-period":              true,
-	"--topology-manager-policy":           true,
-	"--allowed-unsafe-sysctls":            true,
-	"--fail-swap-on":                      true,
-	"--container-log-max-size":            true,
-	"--container-log-max-files":           true,
+	"--address":                             true,
+	"--anonymous-auth":                      true,
+	"--client-ca-file":                      true,
+	"--authentication-token-webhook":        true,
+	"--authorization-mode":                  true,
+	"--pod-manifest-path":                   true,
+	"--cluster-dns":                         true,
+	"--cgroups-per-qos":                     true,
+	"--tls-cert-file":                       true,
+	"--tls-private-key-file":                true,
+	"--tls-cipher-suites":                   true,
+	"--cluster-domain":                      true,
+	"--max-pods":                            true,
+	"--eviction-hard":                       true,
+	"--eviction-soft":                       true,
+	"--eviction-soft-grace-period":          true,
+	"--node-status-update-frequency":        true,
+	"--node-status-report-frequency":        true,
+	"--image-gc-high-threshold":             true,
+	"--image-gc-low-threshold":              true,
+	"--event-qps":                           true,
+	"--pod-max-pids":                        true,
+	"--enforce-node-allocatable":            true,
+	"--streaming-connection-idle-timeout":   true,
+	"--runtime-request-timeout":             true,
+	"--rotate-certificates":                 true,
+	"--read-only-port":                      true,
+	"--feature-gates":                       true,
+	"--protect-kernel-defaults":             true,
+	"--resolv-conf":                         true,
+	"--system-reserved":                     true,
+	"--kube-reserved":                       true,
+	"--cpu-manager-policy":                  true,
+	"--cpu-cfs-quota":                       true,
+	"--cpu-cfs-quota-period":                true,
+	"--topology-manager-policy":             true,
+	"--allowed-unsafe-sysctls":              true,
+	"--fail-swap-on":                        true,
+	"--container-log-max-size":              true,
+	"--container-log-max-files":             true,
+	"--serialize-image-pulls":               true,
+	"--max-parallel-image-pulls":            true,
+	"--shutdown-grace-period":               true,
+	"--shutdown-grace-period-critical-pods": true,
 }
 
 type paramsMap map[string]interface{}
@@ -286,6 +294,120 @@ func IsSgxEnabledSKU(vmSize string) bool {
 	return false
 }
 
+/*
+WindowsCSEPackageURL returns the URL of the CSE scripts package Windows nodes download during provisioning, so it can
+be mirrored ahead of time. The URL of the Windows profile takes precedence over the default of the configured cloud.
+A *datamodel.CloudNotFoundError is returned if there are no settings for the configured cloud.
+*/
+func WindowsCSEPackageURL(config *datamodel.NodeBootstrappingConfiguration) (string, error) {
+	if config.CloudSpecConfig == nil {
+		return "", fmt.Errorf("cloud spec config is required to resolve the Windows CSE package URL")
+	}
+	if _, ok := datamodel.AzureCloudToOSImageMap[config.CloudSpecConfig.CloudName]; !ok {
+		return "", &datamodel.CloudNotFoundError{CloudName: config.CloudSpecConfig.CloudName}
+	}
+	packageURL := config.CloudSpecConfig.KubernetesSpecConfig.CseScriptsPackageURL
+	if config.ContainerService != nil && config.ContainerService.Properties != nil &&
+		config.ContainerService.Properties.WindowsProfile != nil && config.ContainerService.Properties.WindowsProfile.CseScriptsPackageURL != "" {
+		packageURL = config.ContainerService.Properties.WindowsProfile.CseScriptsPackageURL
+	}
+	if packageURL == "" {
+		return "", fmt.Errorf("no Windows CSE package URL is configured for cloud %s", config.CloudSpecConfig.CloudName)
+	}
+	u, err := url.Parse(packageURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return "", fmt.Errorf("invalid Windows CSE package URL %q, must be an absolute https URL", packageURL)
+	}
+	return packageURL, nil
+}
+
+/*
+ComponentDownloadURLs returns the sorted, de-duplicated URLs of the components the node downloads during provisioning
+in the configured cloud, e.g. to warm a CDN or validate a mirror. Components the node assembles from a base URL and a
+version, like containerd, are not included.
+*/
+func ComponentDownloadURLs(config *datamodel.NodeBootstrappingConfiguration) ([]string, error) {
+	if config.ContainerService == nil || config.ContainerService.Properties == nil ||
+		config.ContainerService.Properties.OrchestratorProfile == nil || config.CloudSpecConfig == nil {
+		return nil, fmt.Errorf("container service orchestrator profile and cloud spec config are required to resolve the download URLs")
+	}
+	properties := config.ContainerService.Properties
+	kubernetesConfig := properties.OrchestratorProfile.KubernetesConfig
+	if kubernetesConfig == nil {
+		kubernetesConfig = &datamodel.KubernetesConfig{}
+	}
+	k8sComponents := config.K8sComponents
+	if k8sComponents == nil {
+		k8sComponents = &datamodel.K8sComponents{}
+	}
+	cloudSpecConfig := config.CloudSpecConfig
+
+	urls := map[string]bool{}
+	add := func(downloadURLs ...string) {
+		for _, downloadURL := range downloadURLs {
+			if downloadURL != "" {
+				urls[downloadURL] = true
+			}
+		}
+	}
+
+	profile := config.AgentPoolProfile
+	if profile != nil && (profile.IsWindows() || profile.Distro.IsWindowsDistro()) {
+		cseURL, err := WindowsCSEPackageURL(config)
+		if err != nil {
+			return nil, err
+		}
+		add(cseURL, k8sComponents.WindowsPackageURL, k8sComponents.WindowsCredentialProviderURL,
+			kubernetesConfig.WindowsContainerdURL, kubernetesConfig.WindowsSdnPluginURL)
+		if kubernetesConfig.NetworkPlugin == datamodel.NetworkPluginAzure {
+			add(kubernetesConfig.GetAzureCNIURLWindows(cloudSpecConfig))
+		}
+		if windowsProfile := properties.WindowsProfile; windowsProfile != nil {
+			add(windowsProfile.ProvisioningScriptsPackageURL, windowsProfile.WindowsCalicoPackageURL)
+			if windowsProfile.IsCSIProxyEnabled() {
+				add(windowsProfile.CSIProxyURL)
+			}
+			if config.ConfigGPUDriverIfNeeded {
+				add(windowsProfile.GpuDriverURL)
+			}
+		}
+	} else {
+		// a custom kube binary replaces the private package.
+		if kubernetesConfig.CustomKubeBinaryURL != "" {
+			add(kubernetesConfig.CustomKubeBinaryURL)
+		} else {
+			add(k8sComponents.LinuxPrivatePackageURL)
+		}
+		if properties.CustomConfiguration != nil {
+			if kubelet, ok := properties.CustomConfiguration.KubernetesConfigurations["kubelet"]; ok && kubelet.DownloadURL != nil {
+				add(*kubelet.DownloadURL)
+			}
+		}
+		if config.IsARM64 {
+			add(cloudSpecConfig.KubernetesSpecConfig.CNIARM64PluginsDownloadURL)
+			if kubernetesConfig.NetworkPlugin == datamodel.NetworkPluginAzure {
+				add(kubernetesConfig.GetAzureCNIURLARM64Linux(cloudSpecConfig))
+			}
+		} else {
+			add(cloudSpecConfig.KubernetesSpecConfig.CNIPluginsDownloadURL)
+			if kubernetesConfig.NetworkPlugin == datamodel.NetworkPluginAzure {
+				add(kubernetesConfig.GetAzureCNIURLLinux(cloudSpecConfig))
+			}
+		}
+		add(k8sComponents.LinuxCredentialProviderURL, config.ContainerdPackageURL, config.RuncPackageURL)
+		if config.EnableACRTeleportPlugin {
+			add(config.TeleportdPluginURL)
+		}
+	}
+
+	sortedURLs := make([]string, 0, len(urls))
+	for downloadURL := range urls {
+		sortedURLs = append(sortedURLs, downloadURL)
+	}
+	sort.Strings(sortedURLs)
+	return sortedURLs, nil
+}
+
 /* GetCloudTargetEnv determines and returns whether the region is a sovereign cloud which
 have their own data compliance regulations (China/Germany/USGov) or standard.  */
 // Azure public cloud.
@@ -432,26 +554,32 @@ func getAKSKubeletConfiguration(kc map[string]string) *datamodel.AKSKubeletConfi
 		Authorization: datamodel.KubeletAuthorization{
 			Mode: datamodel.KubeletAuthorizationMode(kc["--authorization-mode"]),
 		},
-		ClusterDNS:                     strings.Split(kc["--cluster-dns"], ","),
-		CgroupsPerQOS:                  strToBoolPtr(kc["--cgroups-per-qos"]),
-		TLSCertFile:                    kc["--tls-cert-file"],
-		TLSPrivateKeyFile:              kc["--tls-private-key-file"],
-		TLSCipherSuites:                strings.Split(kc["--tls-cipher-suites"], ","),
-		ClusterDomain:                  kc["--cluster-domain"],
-		MaxPods:                        strToInt32(kc["--max-pods"]),
-		NodeStatusUpdateFrequency:      datamodel.Duration(kc["--node-status-update-frequency"]),
-		NodeStatusReportFrequency:      datamodel.Duration(kc["--node-status-report-frequency"]),
-		ImageGCHighThresholdPercent:    strToInt32Ptr(kc["--image-gc-high-threshold"]),
-		ImageGCLowThresholdPercent:     strToInt32Ptr(kc["--image-gc-low-threshold"]),
-		EventRecordQPS:                 strToInt32Ptr(kc["--event-qps"]),
-		PodPidsLimit:                   strToInt64Ptr(kc["--pod-max-pids"]),
-		EnforceNodeAllocatable:         strings.Split(kc["--enforce-node-allocatable"], ","),
-		StreamingConnectionIdleTimeout: datamodel.Duration(kc["--streaming-connection-idle-timeout"]),
-		RotateCertificates:             strToBool(kc["--rotate-certificates"]),
-		ReadOnlyPort:                   strToInt32(kc["--read-only-port"]),
-		ProtectKernelDefaults:          strToBool(kc["--protect-kernel-defaults"]),
-		ResolverConfig:                 kc["--resolv-conf"],
-		ContainerLogMaxSize:            kc["--container-log-max-size"],
+		ClusterDNS:                      strings.Split(kc["--cluster-dns"], ","),
+		CgroupsPerQOS:                   strToBoolPtr(kc["--cgroups-per-qos"]),
+		TLSCertFile:                     kc["--tls-cert-file"],
+		TLSPrivateKeyFile:               kc["--tls-private-key-file"],
+		TLSCipherSuites:                 strings.Split(kc["--tls-cipher-suites"], ","),
+		ClusterDomain:                   kc["--cluster-domain"],
+		MaxPods:                         strToInt32(kc["--max-pods"]),
+		NodeStatusUpdateFrequency:       datamodel.Duration(kc["--node-status-update-frequency"]),
+		NodeStatusReportFrequency:       datamodel.Duration(kc["--node-status-report-frequency"]),
+		ImageGCHighThresholdPercent:     strToInt32Ptr(kc["--image-gc-high-threshold"]),
+		ImageGCLowThresholdPercent:      strToInt32Ptr(kc["--image-gc-low-threshold"]),
+		EventRecordQPS:                  strToInt32Ptr(kc["--event-qps"]),
+		PodPidsLimit:                    strToInt64Ptr(kc["--pod-max-pids"]),
+		EnforceNodeAllocatable:          strings.Split(kc["--enforce-node-allocatable"], ","),
+		StreamingConnectionIdleTimeout:  datamodel.Duration(kc["--streaming-connection-idle-timeout"]),
+		RuntimeRequestTimeout:           datamodel.Duration(kc["--runtime-request-timeout"]),
+		RotateCertificates:              strToBool(kc["--rotate-certificates"]),
+		ReadOnlyPort:                    strToInt32(kc["--read-only-port"]),
+		ProtectKernelDefaults:           strToBool(kc["--protect-kernel-defaults"]),
+		ResolverConfig:                  kc["--resolv-conf"],
+		ContainerLogMaxSize:             kc["--container-log-max-size"],
+		ContainerLogMaxFiles:            strToInt32Ptr(kc["--container-log-max-files"]),
+		SerializeImagePulls:             strToBoolPtr(kc["--serialize-image-pulls"]),
+		MaxParallelImagePulls:           strToInt32Ptr(kc["--max-parallel-image-pulls"]),
+		ShutdownGracePeriod:             datamodel.Duration(kc["--shutdown-grace-period"]),
+		ShutdownGracePeriodCriticalPods: datamodel.Duration(kc["--shutdown-grace-period-critical-pods"]),
 	}
 	return kubeletConfig
 }
@@ -532,6 +660,15 @@ func GetKubeletConfigFileContent(kc map[string]string, customKc *datamodel.Custo
 		kubeletConfig.EvictionHard = strKeyValToMap(eh, ",", "<")
 	}
 
+	// EvictionSoft and the grace periods of its signals.
+	// looks like "memory.available<1Gi" and "memory.available=30s".
+	if es, ok := kc["--eviction-soft"]; ok && es != "" {
+		kubeletConfig.EvictionSoft = strKeyValToMap(es, ",", "<")
+	}
+	if esgp, ok := kc["--eviction-soft-grace-period"]; ok && esgp != "" {
+		kubeletConfig.EvictionSoftGracePeriod = strKeyValToMap(esgp, ",", "=")
+	}
+
 	// feature gates.
 	// look like "f1=true,f2=true".
 	kubeletConfig.FeatureGates = strKeyValToMapBool(kc["--feature-gates"], ",", "=")
@@ -644,3 +781,142 @@ func addFeatureGateString(featureGates string, key string, value bool) string {
 	}
 	return strings.Join(pairs, ",")
 }
+
+// cseArgKeyRegex matches the names of the shell variables the Linux CSE command assigns.
+var cseArgKeyRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+/*
+ParseCSEArgs parses the key=value assignments embedded in a Linux CSE command into a map. Values are unquoted
+following shell quoting rules, words which are not assignments are skipped and the last assignment of a key wins.
+*/
+func ParseCSEArgs(cse string) (map[string]string, error) {
+	if strings.TrimSpace(cse) == "" {
+		return nil, fmt.Errorf("CSE command is empty")
+	}
+	words, err := splitCSEWords(cse)
+	if err != nil {
+		return nil, err
+	}
+	args := map[string]string{}
+	for _, w := range words {
+		if w.assignAt < 0 {
+			continue
+		}
+		key := w.value[:w.assignAt]
+		if !cseArgKeyRegex.MatchString(key) {
+			continue
+		}
+		args[key] = w.value[w.assignAt+1:]
+	}
+	return args, nil
+}
+
+// cseWord is a shell word of the CSE command. assignAt is the index of its first unquoted '=' or -1.
+type cseWord struct {
+	value    string
+	assignAt int
+}
+
+// splitCSEWords splits a CSE command into unquoted shell words, treating unquoted whitespace and ';' as separators.
+func splitCSEWords(cse string) ([]cseWord, error) {
+	words := []cseWord{}
+	var current strings.Builder
+	inWord := false
+	assignAt := -1
+	var quote rune
+	escaped := false
+	flush := func() {
+		if inWord {
+			words = append(words, cseWord{value: current.String(), assignAt: assignAt})
+		}
+		current.Reset()
+		inWord = false
+		assignAt = -1
+	}
+	for _, r := range cse {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case quote == '"':
+			switch r {
+			case '"':
+				quote = 0
+			case '\\':
+				escaped = true
+			default:
+				current.WriteRune(r)
+			}
+		case r == '\\':
+			inWord = true
+			escaped = true
+		case r == '\'' || r == '"':
+			inWord = true
+			quote = r
+		case r == ' ' || r == '\t' || r == '\n' || r == ';':
+			flush()
+		default:
+			if r == '=' && assignAt < 0 {
+				assignAt = current.Len()
+			}
+			inWord = true
+			current.WriteRune(r)
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("CSE command has an unterminated %c quote", quote)
+	}
+	if escaped {
+		return nil, fmt.Errorf("CSE command ends with an unterminated escape")
+	}
+	flush()
+	return words, nil
+}
+
+/*
+ResolveNodeLabels merges the node labels of all sources into the final set of labels of the node. The precedence,
+from highest to lowest, is: the labels managed by AgentBaker, the labels passed by the cloud provider through the
+--node-labels kubelet flag and the user's custom node labels. A warning is returned for every label overridden by a
+source with higher precedence.
+*/
+func ResolveNodeLabels(config *datamodel.NodeBootstrappingConfiguration) (map[string]string, []string) {
+	profile := config.AgentPoolProfile
+	// sources are listed from lowest to highest precedence.
+	sources := []struct {
+		name   string
+		labels map[string]string
+	}{
+		{"custom node labels", profile.CustomNodeLabels},
+		{"--node-labels kubelet flag", strKeyValToMap(config.KubeletConfig["--node-labels"], ",", "=")},
+		{"AgentBaker", map[string]string{
+			"agentpool":                      profile.Name,
+			"kubernetes.azure.com/agentpool": profile.Name,
+		}},
+	}
+	labels := map[string]string{}
+	origins := map[string]string{}
+	warnings := []string{}
+	for _, source := range sources {
+		keys := make([]string, 0, len(source.labels))
+		for key := range source.labels {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value := source.labels[key]
+			if existing, ok := labels[key]; ok && existing != value {
+				warnings = append(warnings, fmt.Sprintf("node label %s=%s from %s is overridden by %s=%s from %s",
+					key, existing, origins[key], key, value, source.name))
+			}
+			labels[key] = value
+			origins[key] = source.name
+		}
+	}
+	return labels, warnings
+}
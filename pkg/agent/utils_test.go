@@ -441,6 +441,23 @@ func TestGetKubeletConfigFileCustomKCShouldOverrideValuesPassedInKc(t *testing.T
 	}
 }
 
+func TestGetKubeletConfigFileContentWithParallelImagePulls(t *testing.T) {
+	kc := map[string]string{
+		"--serialize-image-pulls":    "false",
+		"--max-parallel-image-pulls": "5",
+	}
+	var kubeletConfig datamodel.AKSKubeletConfiguration
+	if err := json.Unmarshal([]byte(GetKubeletConfigFileContent(kc, nil)), &kubeletConfig); err != nil {
+		t.Fatalf("failed to unmarshal the kubelet config file: %v", err)
+	}
+	if kubeletConfig.SerializeImagePulls == nil || *kubeletConfig.SerializeImagePulls {
+		t.Errorf("expected serializeImagePulls to be false, got %v", kubeletConfig.SerializeImagePulls)
+	}
+	if kubeletConfig.MaxParallelImagePulls == nil || *kubeletConfig.MaxParallelImagePulls != 5 {
+		t.Errorf("expected maxParallelImagePulls to be 5, got %v", kubeletConfig.MaxParallelImagePulls)
+	}
+}
+
 func TestIsTLSBootstrappingEnabledWithHardCodedToken(t *testing.T) {
 	cases := []struct {
 		tlsBootstrapToken *string
@@ -727,3 +744,220 @@ var _ = Describe("Test removeComments", func() {
 	})
 
 })
+
+var _ = Describe("Test ParseCSEArgs", func() {
+	It("should parse unquoted and quoted assignments", func() {
+		cse := `PROVISION_OUTPUT="/var/log/azure/cluster-provision-cse-output.log"; echo $(date),$(hostname) > ${PROVISION_OUTPUT}; ` +
+			`ADMINUSER=azureuser MOBY_VERSION= KUBELET_FLAGS="--node-labels=a=b --max-pods=110" SUBNET='10.0.0.0/24' ` +
+			`/usr/bin/nohup /bin/bash -c "/bin/bash /opt/azure/containers/provision_start.sh"`
+		args, err := ParseCSEArgs(cse)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(args).To(Equal(map[string]string{
+			"PROVISION_OUTPUT": "/var/log/azure/cluster-provision-cse-output.log",
+			"ADMINUSER":        "azureuser",
+			"MOBY_VERSION":     "",
+			"KUBELET_FLAGS":    "--node-labels=a=b --max-pods=110",
+			"SUBNET":           "10.0.0.0/24",
+		}))
+	})
+
+	It("should keep the last assignment of a key", func() {
+		args, err := ParseCSEArgs(`A=1 A=2`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(args).To(HaveKeyWithValue("A", "2"))
+	})
+
+	It("should not treat a quoted equal sign as an assignment", func() {
+		args, err := ParseCSEArgs(`echo "A=1" B=\"2\"`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(args).To(Equal(map[string]string{"B": `"2"`}))
+	})
+
+	It("should return an error for an empty command", func() {
+		_, err := ParseCSEArgs("  ")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should return an error for an unterminated quote", func() {
+		_, err := ParseCSEArgs(`A="1 B=2`)
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("Test ResolveNodeLabels", func() {
+	var config *datamodel.NodeBootstrappingConfiguration
+
+	BeforeEach(func() {
+		config = &datamodel.NodeBootstrappingConfiguration{
+			AgentPoolProfile: &datamodel.AgentPoolProfile{Name: "nodepool1"},
+		}
+	})
+
+	It("should return the AgentBaker labels without other sources", func() {
+		labels, warnings := ResolveNodeLabels(config)
+		Expect(labels).To(Equal(map[string]string{
+			"agentpool":                      "nodepool1",
+			"kubernetes.azure.com/agentpool": "nodepool1",
+		}))
+		Expect(warnings).To(BeEmpty())
+	})
+
+	It("should merge the labels of all sources", func() {
+		config.KubeletConfig = map[string]string{"--node-labels": "topology.kubernetes.io/region=southcentralus"}
+		config.AgentPoolProfile.CustomNodeLabels = map[string]string{"team": "payments"}
+		labels, warnings := ResolveNodeLabels(config)
+		Expect(labels).To(Equal(map[string]string{
+			"agentpool":                      "nodepool1",
+			"kubernetes.azure.com/agentpool": "nodepool1",
+			"topology.kubernetes.io/region":  "southcentralus",
+			"team":                           "payments",
+		}))
+		Expect(warnings).To(BeEmpty())
+	})
+
+	It("should apply the precedence and warn about the overridden labels", func() {
+		config.KubeletConfig = map[string]string{"--node-labels": "topology.kubernetes.io/region=southcentralus,agentpool=other"}
+		config.AgentPoolProfile.CustomNodeLabels = map[string]string{
+			"topology.kubernetes.io/region": "westus",
+			"team":                          "payments",
+		}
+		labels, warnings := ResolveNodeLabels(config)
+		Expect(labels).To(HaveKeyWithValue("topology.kubernetes.io/region", "southcentralus"))
+		Expect(labels).To(HaveKeyWithValue("agentpool", "nodepool1"))
+		Expect(labels).To(HaveKeyWithValue("team", "payments"))
+		Expect(warnings).To(HaveLen(2))
+	})
+
+	It("should not warn when sources agree on a label", func() {
+		config.AgentPoolProfile.CustomNodeLabels = map[string]string{"agentpool": "nodepool1"}
+		_, warnings := ResolveNodeLabels(config)
+		Expect(warnings).To(BeEmpty())
+	})
+})
+
+var _ = Describe("Test WindowsCSEPackageURL", func() {
+	var config *datamodel.NodeBootstrappingConfiguration
+
+	BeforeEach(func() {
+		config = &datamodel.NodeBootstrappingConfiguration{
+			ContainerService: &datamodel.ContainerService{
+				Properties: &datamodel.Properties{WindowsProfile: &datamodel.WindowsProfile{}},
+			},
+			CloudSpecConfig: &datamodel.AzureEnvironmentSpecConfig{
+				CloudName: datamodel.AzurePublicCloud,
+				KubernetesSpecConfig: datamodel.KubernetesSpecConfig{
+					CseScriptsPackageURL: "https://acs-mirror.azureedge.net/aks/windows/cse/csescripts-v0.0.1.zip",
+				},
+			},
+		}
+	})
+
+	It("should return the default URL of the cloud", func() {
+		packageURL, err := WindowsCSEPackageURL(config)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(packageURL).To(Equal("https://acs-mirror.azureedge.net/aks/windows/cse/csescripts-v0.0.1.zip"))
+	})
+
+	It("should prefer the URL of the Windows profile", func() {
+		config.ContainerService.Properties.WindowsProfile.CseScriptsPackageURL = "https://mirror.contoso.com/csescripts-v0.0.2.zip"
+		packageURL, err := WindowsCSEPackageURL(config)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(packageURL).To(Equal("https://mirror.contoso.com/csescripts-v0.0.2.zip"))
+	})
+
+	It("should return a CloudNotFoundError for an unknown cloud", func() {
+		config.CloudSpecConfig.CloudName = "UnknownCloud"
+		_, err := WindowsCSEPackageURL(config)
+		Expect(err).To(Equal(&datamodel.CloudNotFoundError{CloudName: "UnknownCloud"}))
+	})
+
+	It("should return an error for a URL which is not https", func() {
+		config.ContainerService.Properties.WindowsProfile.CseScriptsPackageURL = "http://mirror.contoso.com/csescripts-v0.0.2.zip"
+		_, err := WindowsCSEPackageURL(config)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should return an error when no URL is configured", func() {
+		config.CloudSpecConfig.KubernetesSpecConfig.CseScriptsPackageURL = ""
+		_, err := WindowsCSEPackageURL(config)
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("Test ComponentDownloadURLs", func() {
+	var config *datamodel.NodeBootstrappingConfiguration
+
+	BeforeEach(func() {
+		config = &datamodel.NodeBootstrappingConfiguration{
+			ContainerService: &datamodel.ContainerService{
+				Properties: &datamodel.Properties{
+					OrchestratorProfile: &datamodel.OrchestratorProfile{
+						KubernetesConfig: &datamodel.KubernetesConfig{NetworkPlugin: datamodel.NetworkPluginAzure},
+					},
+					WindowsProfile: &datamodel.WindowsProfile{
+						CSIProxyURL:    "https://acs-mirror.azureedge.net/csi-proxy/v1.1.2/binaries/csi-proxy-v1.1.2.tar.gz",
+						EnableCSIProxy: to.BoolPtr(true),
+					},
+				},
+			},
+			CloudSpecConfig: &datamodel.AzureEnvironmentSpecConfig{
+				CloudName: datamodel.AzurePublicCloud,
+				KubernetesSpecConfig: datamodel.KubernetesSpecConfig{
+					CNIPluginsDownloadURL:            "https://acs-mirror.azureedge.net/cni/cni-plugins-amd64-v0.7.6.tgz",
+					VnetCNILinuxPluginsDownloadURL:   "https://acs-mirror.azureedge.net/azure-cni/v1.1.3/binaries/azure-vnet-cni-linux-amd64-v1.1.3.tgz",
+					VnetCNIWindowsPluginsDownloadURL: "https://acs-mirror.azureedge.net/azure-cni/v1.1.3/binaries/azure-vnet-cni-windows-amd64-v1.1.3.zip",
+					CseScriptsPackageURL:             "https://acs-mirror.azureedge.net/aks/windows/cse/csescripts-v0.0.1.zip",
+				},
+			},
+			K8sComponents: &datamodel.K8sComponents{
+				LinuxPrivatePackageURL:     "https://acs-mirror.azureedge.net/kubernetes/v1.29.2/binaries/kubernetes-node-linux-amd64.tar.gz",
+				LinuxCredentialProviderURL: "https://acs-mirror.azureedge.net/cloud-provider-azure/v1.29.4/binaries/azure-acr-credential-provider-linux-amd64-v1.29.4.tar.gz",
+				WindowsPackageURL:          "https://acs-mirror.azureedge.net/kubernetes/v1.29.2/windowszip/v1.29.2-1int.zip",
+			},
+			AgentPoolProfile: &datamodel.AgentPoolProfile{OSType: datamodel.Linux},
+		}
+	})
+
+	It("should return the sorted URLs of a Linux node", func() {
+		urls, err := ComponentDownloadURLs(config)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(urls).To(Equal([]string{
+			"https://acs-mirror.azureedge.net/azure-cni/v1.1.3/binaries/azure-vnet-cni-linux-amd64-v1.1.3.tgz",
+			"https://acs-mirror.azureedge.net/cloud-provider-azure/v1.29.4/binaries/azure-acr-credential-provider-linux-amd64-v1.29.4.tar.gz",
+			"https://acs-mirror.azureedge.net/cni/cni-plugins-amd64-v0.7.6.tgz",
+			"https://acs-mirror.azureedge.net/kubernetes/v1.29.2/binaries/kubernetes-node-linux-amd64.tar.gz",
+		}))
+	})
+
+	It("should prefer a custom kube binary and de-duplicate the URLs", func() {
+		customKubeBinaryURL := "https://mirror.contoso.com/kubernetes-node-linux-amd64.tar.gz"
+		config.ContainerService.Properties.OrchestratorProfile.KubernetesConfig.CustomKubeBinaryURL = customKubeBinaryURL
+		config.ContainerService.Properties.OrchestratorProfile.KubernetesConfig.NetworkPlugin = "kubenet"
+		config.ContainerdPackageURL = customKubeBinaryURL
+		urls, err := ComponentDownloadURLs(config)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(urls).To(Equal([]string{
+			"https://acs-mirror.azureedge.net/cloud-provider-azure/v1.29.4/binaries/azure-acr-credential-provider-linux-amd64-v1.29.4.tar.gz",
+			"https://acs-mirror.azureedge.net/cni/cni-plugins-amd64-v0.7.6.tgz",
+			customKubeBinaryURL,
+		}))
+	})
+
+	It("should return the URLs of a Windows node", func() {
+		config.AgentPoolProfile.OSType = datamodel.Windows
+		urls, err := ComponentDownloadURLs(config)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(urls).To(Equal([]string{
+			"https://acs-mirror.azureedge.net/aks/windows/cse/csescripts-v0.0.1.zip",
+			"https://acs-mirror.azureedge.net/azure-cni/v1.1.3/binaries/azure-vnet-cni-windows-amd64-v1.1.3.zip",
+			"https://acs-mirror.azureedge.net/csi-proxy/v1.1.2/binaries/csi-proxy-v1.1.2.tar.gz",
+			"https://acs-mirror.azureedge.net/kubernetes/v1.29.2/windowszip/v1.29.2-1int.zip",
+		}))
+	})
+
+	It("should return an error without a cloud spec config", func() {
+		config.CloudSpecConfig = nil
+		_, err := ComponentDownloadURLs(config)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -15,63 +15,71 @@ import (
 
 // getCustomDataVariables returns cloudinit data used by Linux.
 func getCustomDataVariables(config *datamodel.NodeBootstrappingConfiguration) paramsMap {
+	cloudInitData := paramsMap{}
+	for name, file := range getLinuxCloudInitFiles(config) {
+		cloudInitData[name] = getBase64EncodedGzippedCustomScript(file, config)
+	}
+	return map[string]interface{}{
+		"cloudInitData": cloudInitData,
+	}
+}
+
+// getLinuxCloudInitFiles returns the template files embedded into Linux custom data, keyed by their cloudinit variable name.
+func getLinuxCloudInitFiles(config *datamodel.NodeBootstrappingConfiguration) map[string]string {
 	cs := config.ContainerService
-	cloudInitFiles := map[string]interface{}{
-		"cloudInitData": paramsMap{
-			"provisionStartScript":         getBase64EncodedGzippedCustomScript(kubernetesCSEStartScript, config),
-			"provisionScript":              getBase64EncodedGzippedCustomScript(kubernetesCSEMainScript, config),
-			"provisionSource":              getBase64EncodedGzippedCustomScript(kubernetesCSEHelpersScript, config),
-			"provisionSourceUbuntu":        getBase64EncodedGzippedCustomScript(kubernetesCSEHelpersScriptUbuntu, config),
-			"provisionSourceMariner":       getBase64EncodedGzippedCustomScript(kubernetesCSEHelpersScriptMariner, config),
-			"provisionInstalls":            getBase64EncodedGzippedCustomScript(kubernetesCSEInstall, config),
-			"provisionInstallsUbuntu":      getBase64EncodedGzippedCustomScript(kubernetesCSEInstallUbuntu, config),
-			"provisionInstallsMariner":     getBase64EncodedGzippedCustomScript(kubernetesCSEInstallMariner, config),
-			"provisionConfigs":             getBase64EncodedGzippedCustomScript(kubernetesCSEConfig, config),
-			"provisionSendLogs":            getBase64EncodedGzippedCustomScript(kubernetesCSESendLogs, config),
-			"provisionRedactCloudConfig":   getBase64EncodedGzippedCustomScript(kubernetesCSERedactCloudConfig, config),
-			"customSearchDomainsScript":    getBase64EncodedGzippedCustomScript(kubernetesCustomSearchDomainsScript, config),
-			"dhcpv6SystemdService":         getBase64EncodedGzippedCustomScript(dhcpv6SystemdService, config),
-			"dhcpv6ConfigurationScript":    getBase64EncodedGzippedCustomScript(dhcpv6ConfigurationScript, config),
-			"kubeletSystemdService":        getBase64EncodedGzippedCustomScript(kubeletSystemdService, config),
-			"reconcilePrivateHostsScript":  getBase64EncodedGzippedCustomScript(reconcilePrivateHostsScript, config),
-			"reconcilePrivateHostsService": getBase64EncodedGzippedCustomScript(reconcilePrivateHostsService, config),
-			"ensureNoDupEbtablesScript":    getBase64EncodedGzippedCustomScript(ensureNoDupEbtablesScript, config),
-			"ensureNoDupEbtablesService":   getBase64EncodedGzippedCustomScript(ensureNoDupEbtablesService, config),
-			"bindMountScript":              getBase64EncodedGzippedCustomScript(bindMountScript, config),
-			"bindMountSystemdService":      getBase64EncodedGzippedCustomScript(bindMountSystemdService, config),
-			"migPartitionSystemdService":   getBase64EncodedGzippedCustomScript(migPartitionSystemdService, config),
-			"migPartitionScript":           getBase64EncodedGzippedCustomScript(migPartitionScript, config),
-			"containerdKubeletDropin":      getBase64EncodedGzippedCustomScript(containerdKubeletDropin, config),
-			"cgroupv2KubeletDropin":        getBase64EncodedGzippedCustomScript(cgroupv2KubeletDropin, config),
-			"componentConfigDropin":        getBase64EncodedGzippedCustomScript(componentConfigDropin, config),
-			"tlsBootstrapDropin":           getBase64EncodedGzippedCustomScript(tlsBootstrapDropin, config),
-			"bindMountDropin":              getBase64EncodedGzippedCustomScript(bindMountDropin, config),
-			"httpProxyDropin":              getBase64EncodedGzippedCustomScript(httpProxyDropin, config),
-			"snapshotUpdateScript":         getBase64EncodedGzippedCustomScript(snapshotUpdateScript, config),
-			"snapshotUpdateService":        getBase64EncodedGzippedCustomScript(snapshotUpdateSystemdService, config),
-			"snapshotUpdateTimer":          getBase64EncodedGzippedCustomScript(snapshotUpdateSystemdTimer, config),
-			"packageUpdateScriptMariner":   getBase64EncodedGzippedCustomScript(packageUpdateScriptMariner, config),
-			"packageUpdateServiceMariner":  getBase64EncodedGzippedCustomScript(packageUpdateSystemdServiceMariner, config),
-			"packageUpdateTimerMariner":    getBase64EncodedGzippedCustomScript(packageUpdateSystemdTimerMariner, config),
-			"componentManifestFile":        getBase64EncodedGzippedCustomScript(componentManifestFile, config),
-		},
+	cloudInitFiles := map[string]string{
+		"provisionStartScript":         kubernetesCSEStartScript,
+		"provisionScript":              kubernetesCSEMainScript,
+		"provisionSource":              kubernetesCSEHelpersScript,
+		"provisionSourceUbuntu":        kubernetesCSEHelpersScriptUbuntu,
+		"provisionSourceMariner":       kubernetesCSEHelpersScriptMariner,
+		"provisionInstalls":            kubernetesCSEInstall,
+		"provisionInstallsUbuntu":      kubernetesCSEInstallUbuntu,
+		"provisionInstallsMariner":     kubernetesCSEInstallMariner,
+		"provisionConfigs":             kubernetesCSEConfig,
+		"provisionSendLogs":            kubernetesCSESendLogs,
+		"provisionRedactCloudConfig":   kubernetesCSERedactCloudConfig,
+		"customSearchDomainsScript":    kubernetesCustomSearchDomainsScript,
+		"dhcpv6SystemdService":         dhcpv6SystemdService,
+		"dhcpv6ConfigurationScript":    dhcpv6ConfigurationScript,
+		"kubeletSystemdService":        kubeletSystemdService,
+		"reconcilePrivateHostsScript":  reconcilePrivateHostsScript,
+		"reconcilePrivateHostsService": reconcilePrivateHostsService,
+		"ensureNoDupEbtablesScript":    ensureNoDupEbtablesScript,
+		"ensureNoDupEbtablesService":   ensureNoDupEbtablesService,
+		"bindMountScript":              bindMountScript,
+		"bindMountSystemdService":      bindMountSystemdService,
+		"migPartitionSystemdService":   migPartitionSystemdService,
+		"migPartitionScript":           migPartitionScript,
+		"containerdKubeletDropin":      containerdKubeletDropin,
+		"cgroupv2KubeletDropin":        cgroupv2KubeletDropin,
+		"componentConfigDropin":        componentConfigDropin,
+		"tlsBootstrapDropin":           tlsBootstrapDropin,
+		"bindMountDropin":              bindMountDropin,
+		"httpProxyDropin":              httpProxyDropin,
+		"snapshotUpdateScript":         snapshotUpdateScript,
+		"snapshotUpdateService":        snapshotUpdateSystemdService,
+		"snapshotUpdateTimer":          snapshotUpdateSystemdTimer,
+		"packageUpdateScriptMariner":   packageUpdateScriptMariner,
+		"packageUpdateServiceMariner":  packageUpdateSystemdServiceMariner,
+		"packageUpdateTimerMariner":    packageUpdateSystemdTimerMariner,
+		"componentManifestFile":        componentManifestFile,
 	}
 
-	cloudInitData := cloudInitFiles["cloudInitData"].(paramsMap) //nolint:errcheck // no error is actually here
 	if cs.IsAKSCustomCloud() {
 		// TODO(ace): do we care about both? 2nd one should be more general and catch custom VHD for mariner.
 		if config.AgentPoolProfile.Distro.IsAzureLinuxDistro() || isMariner(config.OSSKU) {
-			cloudInitData["initAKSCustomCloud"] = getBase64EncodedGzippedCustomScript(initAKSCustomCloudMarinerScript, config)
+			cloudInitFiles["initAKSCustomCloud"] = initAKSCustomCloudMarinerScript
 		} else {
-			cloudInitData["initAKSCustomCloud"] = getBase64EncodedGzippedCustomScript(initAKSCustomCloudScript, config)
+			cloudInitFiles["initAKSCustomCloud"] = initAKSCustomCloudScript
 		}
 	}
 
 	if !cs.Properties.IsVHDDistroForAllNodes() {
-		cloudInitData["provisionCIS"] = getBase64EncodedGzippedCustomScript(kubernetesCISScript, config)
-		cloudInitData["kmsSystemdService"] = getBase64EncodedGzippedCustomScript(kmsSystemdService, config)
-		cloudInitData["aptPreferences"] = getBase64EncodedGzippedCustomScript(aptPreferences, config)
-		cloudInitData["dockerClearMountPropagationFlags"] = getBase64EncodedGzippedCustomScript(dockerClearMountPropagationFlags, config)
+		cloudInitFiles["provisionCIS"] = kubernetesCISScript
+		cloudInitFiles["kmsSystemdService"] = kmsSystemdService
+		cloudInitFiles["aptPreferences"] = aptPreferences
+		cloudInitFiles["dockerClearMountPropagationFlags"] = dockerClearMountPropagationFlags
 	}
 
 	return cloudInitFiles
@@ -146,6 +154,7 @@ func getCSECommandVariables(config *datamodel.NodeBootstrappingConfiguration) pa
 		"enableGPUDevicePluginIfNeeded":   config.EnableGPUDevicePluginIfNeeded,
 		"migNode":                         strconv.FormatBool(common.IsMIGNode(config.GPUInstanceProfile)),
 		"gpuInstanceProfile":              config.GPUInstanceProfile,
+		"secureBootEnabled":               strconv.FormatBool(config.IsSecureBootEnabled()),
 	}
 }
 
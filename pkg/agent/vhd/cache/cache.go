@@ -47,6 +47,55 @@ func GetOnVHD() *OnVHD {
 	return onVHD
 }
 
+// HasContainerImage returns true if the specified container image reference is cached on the VHD.
+func (o *OnVHD) HasContainerImage(image string) bool {
+	if o == nil {
+		return false
+	}
+	for _, containerImage := range o.FromComponentContainerImages {
+		versions := append(append([]string{}, containerImage.MultiArchVersions...), containerImage.Amd64OnlyVersions...)
+		for _, version := range versions {
+			if strings.Replace(containerImage.DownloadURL, "*", version, 1) == image {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasDownloadedFile returns true if any version of the named downloaded file component is cached on the VHD.
+func (o *OnVHD) HasDownloadedFile(name string) bool {
+	return o != nil && len(o.FromComponentDownloadedFiles[name].Versions) > 0
+}
+
+// HasDownloadedFileVersion returns true if the specified version of the named downloaded file component is cached on the VHD.
+func (o *OnVHD) HasDownloadedFileVersion(name, version string) bool {
+	if o == nil {
+		return false
+	}
+	for _, cached := range o.FromComponentDownloadedFiles[name].Versions {
+		if cached == version {
+			return true
+		}
+	}
+	return false
+}
+
+// HasManifestDependency returns true if any version of the named manifest dependency, e.g. runc, is cached on the VHD.
+func (o *OnVHD) HasManifestDependency(name string) bool {
+	return len(o.manifestVersions()[name]) > 0
+}
+
+// HasManifestDependencyVersion returns true if the specified version of the named manifest dependency is cached on the VHD.
+func (o *OnVHD) HasManifestDependencyVersion(name, version string) bool {
+	for _, cached := range o.manifestVersions()[name] {
+		if cached == version {
+			return true
+		}
+	}
+	return false
+}
+
 func loadOnVHD() (*OnVHD, error) {
 	// init manifest content
 	manifest, err := getManifest()
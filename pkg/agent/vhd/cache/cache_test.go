@@ -36,6 +36,233 @@ var _ = Describe("cache suite", func() {
 		})
 	})
 
+	Context("HasContainerImage", func() {
+		var o *OnVHD
+
+		BeforeEach(func() {
+			o = &OnVHD{
+				FromComponentContainerImages: map[string]ContainerImage{
+					"pause": {
+						DownloadURL:       "mcr.microsoft.com/oss/kubernetes/pause:*",
+						MultiArchVersions: []string{"3.6"},
+						Amd64OnlyVersions: []string{"3.5"},
+					},
+				},
+			}
+		})
+
+		It("should return true for cached images", func() {
+			Expect(o.HasContainerImage("mcr.microsoft.com/oss/kubernetes/pause:3.6")).To(BeTrue())
+			Expect(o.HasContainerImage("mcr.microsoft.com/oss/kubernetes/pause:3.5")).To(BeTrue())
+		})
+
+		It("should return false for images which are not cached", func() {
+			Expect(o.HasContainerImage("mcr.microsoft.com/oss/kubernetes/pause:3.7")).To(BeFalse())
+			Expect(o.HasContainerImage("myregistry.azurecr.io/pause:3.6")).To(BeFalse())
+		})
+
+		It("should return false when nothing is cached", func() {
+			o = nil
+			Expect(o.HasContainerImage("mcr.microsoft.com/oss/kubernetes/pause:3.6")).To(BeFalse())
+		})
+	})
+
+	Context("HasDownloadedFileVersion", func() {
+		var o *OnVHD
+
+		BeforeEach(func() {
+			o = &OnVHD{
+				FromComponentDownloadedFiles: map[string]DownloadFile{
+					"cni-plugins": {Versions: []string{"1.4.0", "1.4.1"}},
+				},
+			}
+		})
+
+		It("should return true for cached versions", func() {
+			Expect(o.HasDownloadedFileVersion("cni-plugins", "1.4.1")).To(BeTrue())
+		})
+
+		It("should return false for versions or components which are not cached", func() {
+			Expect(o.HasDownloadedFileVersion("cni-plugins", "1.5.0")).To(BeFalse())
+			Expect(o.HasDownloadedFileVersion("azure-cni", "1.4.1")).To(BeFalse())
+		})
+
+		It("should return false when nothing is cached", func() {
+			o = nil
+			Expect(o.HasDownloadedFileVersion("cni-plugins", "1.4.1")).To(BeFalse())
+		})
+	})
+
+	Context("HasDownloadedFile", func() {
+		It("should return true only for components with cached versions", func() {
+			o := &OnVHD{
+				FromComponentDownloadedFiles: map[string]DownloadFile{
+					"stargz-snapshotter": {Versions: []string{"0.15.1"}},
+					"cni-plugins":        {},
+				},
+			}
+			Expect(o.HasDownloadedFile("stargz-snapshotter")).To(BeTrue())
+			Expect(o.HasDownloadedFile("cni-plugins")).To(BeFalse())
+			Expect(o.HasDownloadedFile("azure-cni")).To(BeFalse())
+		})
+
+		It("should return false when nothing is cached", func() {
+			var o *OnVHD
+			Expect(o.HasDownloadedFile("stargz-snapshotter")).To(BeFalse())
+		})
+	})
+
+	Context("HasManifestDependency", func() {
+		It("should return true only for dependencies with cached versions", func() {
+			o := &OnVHD{
+				FromManifest: &Manifest{
+					NvidiaContainerRuntime: Dependency{Versions: []string{"1.14.1"}},
+					Runc:                   Dependency{Installed: map[string]string{"default": "1.1.12"}},
+				},
+			}
+			Expect(o.HasManifestDependency("nvidia-container-runtime")).To(BeTrue())
+			Expect(o.HasManifestDependency("runc")).To(BeTrue())
+			Expect(o.HasManifestDependency("containerd")).To(BeFalse())
+		})
+
+		It("should return false when nothing is cached", func() {
+			var o *OnVHD
+			Expect(o.HasManifestDependency("nvidia-container-runtime")).To(BeFalse())
+		})
+	})
+
+	Context("HasManifestDependencyVersion", func() {
+		It("should return true only for cached versions", func() {
+			o := &OnVHD{
+				FromManifest: &Manifest{
+					Containerd: Dependency{Versions: []string{"1.6.26"}, Edge: "1.7.7"},
+					Runc:       Dependency{Pinned: map[string]string{"1804": "1.1.12"}},
+				},
+			}
+			Expect(o.HasManifestDependencyVersion("containerd", "1.6.26")).To(BeTrue())
+			Expect(o.HasManifestDependencyVersion("containerd", "1.7.7")).To(BeTrue())
+			Expect(o.HasManifestDependencyVersion("runc", "1.1.12")).To(BeTrue())
+			Expect(o.HasManifestDependencyVersion("containerd", "1.7.15")).To(BeFalse())
+			Expect(o.HasManifestDependencyVersion("kubernetes", "1.29.2")).To(BeFalse())
+		})
+	})
+
+	Context("DiffAgainst", func() {
+		var current, desired *OnVHD
+
+		BeforeEach(func() {
+			current = &OnVHD{
+				FromManifest: &Manifest{
+					Containerd: Dependency{Versions: []string{"1.6.26"}, Edge: "1.7.7"},
+					Runc:       Dependency{Installed: map[string]string{"default": "1.1.12"}},
+				},
+				FromComponentContainerImages: map[string]ContainerImage{
+					"pause":         {MultiArchVersions: []string{"3.6"}},
+					"addon-resizer": {Amd64OnlyVersions: []string{"1.8.20"}},
+				},
+				FromComponentDownloadedFiles: map[string]DownloadFile{
+					"cni-plugins": {Versions: []string{"1.4.0"}},
+				},
+			}
+			desired = &OnVHD{
+				FromManifest: &Manifest{
+					Containerd: Dependency{Versions: []string{"1.6.26"}, Edge: "1.7.15"},
+					Runc:       Dependency{Installed: map[string]string{"default": "1.1.12"}},
+				},
+				FromComponentContainerImages: map[string]ContainerImage{
+					"pause":     {MultiArchVersions: []string{"3.6"}},
+					"azure-cns": {MultiArchVersions: []string{"v1.5.26"}},
+				},
+				FromComponentDownloadedFiles: map[string]DownloadFile{
+					"cni-plugins": {Versions: []string{"1.4.0"}},
+				},
+			}
+		})
+
+		It("should return the added, removed and upgraded components per category", func() {
+			diff := current.DiffAgainst(desired)
+			Expect(diff.IsEmpty()).To(BeFalse())
+			Expect(diff.Manifest).To(Equal(CategoryDiff{
+				Upgraded: map[string]ComponentVersions{
+					"containerd": {From: []string{"1.6.26", "1.7.7"}, To: []string{"1.6.26", "1.7.15"}},
+				},
+			}))
+			Expect(diff.ContainerImages).To(Equal(CategoryDiff{
+				Added:   map[string][]string{"azure-cns": {"v1.5.26"}},
+				Removed: map[string][]string{"addon-resizer": {"1.8.20"}},
+			}))
+			Expect(diff.DownloadedFiles).To(Equal(CategoryDiff{}))
+		})
+
+		It("should return an empty diff for the same cache", func() {
+			Expect(current.DiffAgainst(current).IsEmpty()).To(BeTrue())
+		})
+
+		It("should treat a nil cache as empty", func() {
+			diff := (*OnVHD)(nil).DiffAgainst(desired)
+			Expect(diff.ContainerImages.Added).To(HaveLen(2))
+			Expect(diff.Manifest.Added).To(HaveKeyWithValue("runc", []string{"1.1.12"}))
+		})
+	})
+
+	Context("DetectRegressions", func() {
+		var previous *OnVHD
+
+		BeforeEach(func() {
+			previous = &OnVHD{
+				FromManifest: &Manifest{
+					Containerd: Dependency{Versions: []string{"1.6.26"}, Edge: "1.7.15-1"},
+					Runc:       Dependency{Installed: map[string]string{"default": "1.1.12"}},
+				},
+				FromComponentContainerImages: map[string]ContainerImage{
+					"azure-cns": {MultiArchVersions: []string{"v1.5.26", "v1.5.28"}},
+					"pause":     {MultiArchVersions: []string{"3.6"}},
+				},
+				FromComponentDownloadedFiles: map[string]DownloadFile{
+					"cni-plugins": {Versions: []string{"1.4.0"}},
+					"azure-cni":   {Versions: []string{"1.5.28"}},
+				},
+			}
+		})
+
+		It("should return the components whose latest version went backwards", func() {
+			current := &OnVHD{
+				FromManifest: &Manifest{
+					Containerd: Dependency{Versions: []string{"1.6.26"}, Edge: "1.7.7-1"},
+					Runc:       Dependency{Installed: map[string]string{"default": "1.1.14"}},
+				},
+				FromComponentContainerImages: map[string]ContainerImage{
+					"azure-cns": {MultiArchVersions: []string{"v1.5.26"}},
+				},
+				FromComponentDownloadedFiles: map[string]DownloadFile{
+					"cni-plugins": {Versions: []string{"1.4.0"}},
+					"azure-cni":   {Versions: []string{"1.4.54", "1.5.28"}},
+				},
+			}
+			Expect(DetectRegressions(previous, current)).To(Equal([]ComponentRegression{
+				{Category: "containerImages", Name: "azure-cns", From: "v1.5.28", To: "v1.5.26"},
+				{Category: "manifest", Name: "containerd", From: "1.7.15-1", To: "1.7.7-1"},
+			}))
+		})
+
+		It("should not return regressions for the same cache", func() {
+			Expect(DetectRegressions(previous, previous)).To(BeEmpty())
+		})
+
+		It("should not treat removed components as regressions", func() {
+			Expect(DetectRegressions(previous, nil)).To(BeEmpty())
+		})
+
+		It("should ignore versions which are not semantic versions", func() {
+			current := &OnVHD{
+				FromComponentDownloadedFiles: map[string]DownloadFile{
+					"azure-cni": {Versions: []string{"latest"}},
+				},
+			}
+			Expect(DetectRegressions(previous, current)).To(BeEmpty())
+		})
+	})
+
 	Context("getContainerImageNameFromURL", func() {
 		When("URL is empty", func() {
 			It("should return an error", func() {
@@ -0,0 +1,178 @@
+package cache
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// categories of the VHD cache, as named in the Diff.
+const (
+	categoryManifest        = "manifest"
+	categoryContainerImages = "containerImages"
+	categoryDownloadedFiles = "downloadedFiles"
+)
+
+// DiffAgainst returns the components which are added, removed or upgraded in the desired VHD cache
+// compared to this one, per category. A nil cache is treated as an empty one.
+func (o *OnVHD) DiffAgainst(desired *OnVHD) *Diff {
+	return &Diff{
+		Manifest:        diffComponentVersions(o.manifestVersions(), desired.manifestVersions()),
+		ContainerImages: diffComponentVersions(o.containerImageVersions(), desired.containerImageVersions()),
+		DownloadedFiles: diffComponentVersions(o.downloadedFileVersions(), desired.downloadedFileVersions()),
+	}
+}
+
+/*
+DetectRegressions returns the components whose latest cached version is older in the current VHD cache than in the
+previous one, sorted by category and name, e.g. to stop a VHD from being published with a downgraded component by
+mistake. Versions are compared as semantic versions, versions which are not semantic versions are ignored. Components
+which are removed are not regressions, DiffAgainst reports them. A nil cache is treated as an empty one.
+*/
+func DetectRegressions(previous, current *OnVHD) []ComponentRegression {
+	var regressions []ComponentRegression
+	for _, category := range []struct {
+		name              string
+		previous, current map[string][]string
+	}{
+		{categoryManifest, previous.manifestVersions(), current.manifestVersions()},
+		{categoryContainerImages, previous.containerImageVersions(), current.containerImageVersions()},
+		{categoryDownloadedFiles, previous.downloadedFileVersions(), current.downloadedFileVersions()},
+	} {
+		for name, previousVersions := range category.previous {
+			currentVersions, ok := category.current[name]
+			if !ok {
+				continue
+			}
+			from, to := latestSemver(previousVersions), latestSemver(currentVersions)
+			if from != nil && to != nil && to.LessThan(from) {
+				regressions = append(regressions, ComponentRegression{
+					Category: category.name, Name: name, From: from.Original(), To: to.Original(),
+				})
+			}
+		}
+	}
+	sort.Slice(regressions, func(i, j int) bool {
+		if regressions[i].Category != regressions[j].Category {
+			return regressions[i].Category < regressions[j].Category
+		}
+		return regressions[i].Name < regressions[j].Name
+	})
+	return regressions
+}
+
+// latestSemver returns the latest of the versions which are semantic versions, or nil if there is none.
+func latestSemver(versions []string) *semver.Version {
+	var latest *semver.Version
+	for _, version := range versions {
+		v, err := semver.NewVersion(version)
+		if err != nil {
+			continue
+		}
+		if latest == nil || v.GreaterThan(latest) {
+			latest = v
+		}
+	}
+	return latest
+}
+
+// IsEmpty returns true if the diff has no changes in any category.
+func (d *Diff) IsEmpty() bool {
+	return d == nil || (d.Manifest.isEmpty() && d.ContainerImages.isEmpty() && d.DownloadedFiles.isEmpty())
+}
+
+func (c CategoryDiff) isEmpty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Upgraded) == 0
+}
+
+func (o *OnVHD) manifestVersions() map[string][]string {
+	versions := map[string][]string{}
+	if o == nil || o.FromManifest == nil {
+		return versions
+	}
+	for name, dependency := range map[string]Dependency{
+		"containerd":               o.FromManifest.Containerd,
+		"runc":                     o.FromManifest.Runc,
+		"nvidia-container-runtime": o.FromManifest.NvidiaContainerRuntime,
+		"nvidia-drivers":           o.FromManifest.NvidiaDrivers,
+		"kubernetes":               o.FromManifest.Kubernetes,
+	} {
+		// installed, pinned and edge versions are cached as well, even if not listed in versions.
+		all := append([]string{dependency.Edge}, dependency.Versions...)
+		for _, version := range dependency.Installed {
+			all = append(all, version)
+		}
+		for _, version := range dependency.Pinned {
+			all = append(all, version)
+		}
+		if sorted := sortedUniqueVersions(all); len(sorted) > 0 {
+			versions[name] = sorted
+		}
+	}
+	return versions
+}
+
+func (o *OnVHD) containerImageVersions() map[string][]string {
+	versions := map[string][]string{}
+	if o == nil {
+		return versions
+	}
+	for name, image := range o.FromComponentContainerImages {
+		versions[name] = sortedUniqueVersions(append(append([]string{}, image.MultiArchVersions...), image.Amd64OnlyVersions...))
+	}
+	return versions
+}
+
+func (o *OnVHD) downloadedFileVersions() map[string][]string {
+	versions := map[string][]string{}
+	if o == nil {
+		return versions
+	}
+	for name, file := range o.FromComponentDownloadedFiles {
+		versions[name] = sortedUniqueVersions(file.Versions)
+	}
+	return versions
+}
+
+func diffComponentVersions(current, desired map[string][]string) CategoryDiff {
+	diff := CategoryDiff{}
+	for name, desiredVersions := range desired {
+		currentVersions, ok := current[name]
+		switch {
+		case !ok:
+			if diff.Added == nil {
+				diff.Added = map[string][]string{}
+			}
+			diff.Added[name] = desiredVersions
+		case !reflect.DeepEqual(currentVersions, desiredVersions):
+			if diff.Upgraded == nil {
+				diff.Upgraded = map[string]ComponentVersions{}
+			}
+			diff.Upgraded[name] = ComponentVersions{From: currentVersions, To: desiredVersions}
+		}
+	}
+	for name, currentVersions := range current {
+		if _, ok := desired[name]; !ok {
+			if diff.Removed == nil {
+				diff.Removed = map[string][]string{}
+			}
+			diff.Removed[name] = currentVersions
+		}
+	}
+	return diff
+}
+
+func sortedUniqueVersions(versions []string) []string {
+	seen := map[string]bool{}
+	unique := []string{}
+	for _, version := range versions {
+		if version == "" || seen[version] {
+			continue
+		}
+		seen[version] = true
+		unique = append(unique, version)
+	}
+	sort.Strings(unique)
+	return unique
+}
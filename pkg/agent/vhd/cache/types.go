@@ -56,3 +56,31 @@ type DownloadFile struct {
 	DownloadURL      string   `json:"downloadURL"`
 	Versions         []string `json:"versions"`
 }
+
+// Diff represents the components added, removed and upgraded between two VHD cache snapshots.
+type Diff struct {
+	Manifest        CategoryDiff `json:"manifest"`
+	ContainerImages CategoryDiff `json:"containerImages"`
+	DownloadedFiles CategoryDiff `json:"downloadedFiles"`
+}
+
+// CategoryDiff represents the changes of the components within one category of the VHD cache, keyed by component name.
+type CategoryDiff struct {
+	Added    map[string][]string          `json:"added,omitempty"`
+	Removed  map[string][]string          `json:"removed,omitempty"`
+	Upgraded map[string]ComponentVersions `json:"upgraded,omitempty"`
+}
+
+// ComponentRegression represents a component whose latest cached version went backwards between two VHD cache snapshots.
+type ComponentRegression struct {
+	Category string `json:"category"`
+	Name     string `json:"name"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+}
+
+// ComponentVersions represents the cached versions of a component before and after a change.
+type ComponentVersions struct {
+	From []string `json:"from"`
+	To   []string `json:"to"`
+}
@@ -0,0 +1,82 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package agent
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Azure/agentbaker/pkg/agent/datamodel"
+	"github.com/Azure/agentbaker/pkg/agent/vhd/cache"
+)
+
+// names of the manifest dependencies on the VHD.
+const (
+	kubernetesManifestDependencyName = "kubernetes"
+	containerdManifestDependencyName = "containerd"
+	runcManifestDependencyName       = "runc"
+)
+
+/*
+ValidateConfigForVHD validates that the Kubernetes, containerd, runc and CNI plugin versions, and the package version
+overrides, the config of a Linux node requires are all cached on the VHD. Unlike node bootstrapping, which only checks
+the versions explicitly set, it requires the cluster's Kubernetes version and the default containerd and CNI plugins
+to be cached as well, so it is meant as a pre-flight check of configs targeting a specific VHD. The returned error
+lists every missing dependency.
+*/
+func ValidateConfigForVHD(config *datamodel.NodeBootstrappingConfiguration, cached *cache.OnVHD) error {
+	if cached == nil {
+		return fmt.Errorf("the cached components of the VHD are required")
+	}
+	if config.ContainerService == nil || config.ContainerService.Properties == nil {
+		return fmt.Errorf("container service properties are required to validate the config against the VHD")
+	}
+	if config.AgentPoolProfile != nil && (config.AgentPoolProfile.IsWindows() || config.AgentPoolProfile.Distro.IsWindowsDistro()) {
+		return fmt.Errorf("configs are only validated against the VHD for Linux nodes")
+	}
+	var missing []string
+	if orchestratorProfile := config.ContainerService.Properties.OrchestratorProfile; orchestratorProfile != nil &&
+		orchestratorProfile.OrchestratorVersion != "" {
+		// a custom kube binary is downloaded instead of using the cached binaries.
+		customKubeBinary := orchestratorProfile.KubernetesConfig != nil && orchestratorProfile.KubernetesConfig.CustomKubeBinaryURL != ""
+		if !customKubeBinary && !cached.HasManifestDependencyVersion(kubernetesManifestDependencyName, orchestratorProfile.OrchestratorVersion) {
+			missing = append(missing, fmt.Sprintf("%s %s", kubernetesManifestDependencyName, orchestratorProfile.OrchestratorVersion))
+		}
+	}
+	switch {
+	case config.ContainerdVersion != "":
+		if !cached.HasManifestDependencyVersion(containerdManifestDependencyName, config.ContainerdVersion) {
+			missing = append(missing, fmt.Sprintf("%s %s", containerdManifestDependencyName, config.ContainerdVersion))
+		}
+	case config.ContainerdPackageURL == "" && !cached.HasManifestDependency(containerdManifestDependencyName):
+		missing = append(missing, containerdManifestDependencyName)
+	}
+	if config.RuncVersion != "" && config.RuncPackageURL == "" &&
+		!cached.HasManifestDependencyVersion(runcManifestDependencyName, config.RuncVersion) {
+		missing = append(missing, fmt.Sprintf("%s %s", runcManifestDependencyName, config.RuncVersion))
+	}
+	switch {
+	case config.CNIPluginVersion != "":
+		if !cached.HasDownloadedFileVersion(cniPluginsComponentName, config.CNIPluginVersion) {
+			missing = append(missing, fmt.Sprintf("%s %s", cniPluginsComponentName, config.CNIPluginVersion))
+		}
+	case !cached.HasDownloadedFile(cniPluginsComponentName):
+		missing = append(missing, cniPluginsComponentName)
+	}
+	names := make([]string, 0, len(config.PackageVersionOverrides))
+	for name := range config.PackageVersionOverrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if version := config.PackageVersionOverrides[name]; !cached.HasDownloadedFileVersion(name, version) {
+			missing = append(missing, fmt.Sprintf("%s %s", name, version))
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("the VHD does not cache the dependencies of the config: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package agent
+
+import (
+	"github.com/Azure/agentbaker/pkg/agent/datamodel"
+	"github.com/Azure/agentbaker/pkg/agent/vhd/cache"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Test ValidateConfigForVHD", func() {
+	var (
+		config *datamodel.NodeBootstrappingConfiguration
+		cached *cache.OnVHD
+	)
+
+	BeforeEach(func() {
+		config = &datamodel.NodeBootstrappingConfiguration{
+			ContainerService: &datamodel.ContainerService{
+				Properties: &datamodel.Properties{
+					OrchestratorProfile: &datamodel.OrchestratorProfile{
+						OrchestratorType:    datamodel.Kubernetes,
+						OrchestratorVersion: "1.29.2",
+					},
+				},
+			},
+			AgentPoolProfile: &datamodel.AgentPoolProfile{Distro: datamodel.AKSUbuntuContainerd2204},
+		}
+		cached = &cache.OnVHD{
+			FromManifest: &cache.Manifest{
+				Kubernetes: cache.Dependency{Versions: []string{"1.28.5", "1.29.2"}},
+				Containerd: cache.Dependency{Versions: []string{"1.7.15-1"}},
+				Runc:       cache.Dependency{Versions: []string{"1.1.12"}},
+			},
+			FromComponentDownloadedFiles: map[string]cache.DownloadFile{
+				"cni-plugins": {Versions: []string{"1.4.0"}},
+				"azure-cni":   {Versions: []string{"1.5.28"}},
+			},
+		}
+	})
+
+	It("should succeed when every dependency is cached", func() {
+		config.ContainerdVersion = "1.7.15-1"
+		config.RuncVersion = "1.1.12"
+		config.CNIPluginVersion = "1.4.0"
+		config.PackageVersionOverrides = map[string]string{"azure-cni": "1.5.28"}
+		Expect(ValidateConfigForVHD(config, cached)).To(Succeed())
+	})
+
+	It("should succeed with the default containerd and CNI plugins", func() {
+		Expect(ValidateConfigForVHD(config, cached)).To(Succeed())
+	})
+
+	It("should list every missing dependency", func() {
+		config.ContainerService.Properties.OrchestratorProfile.OrchestratorVersion = "1.30.0"
+		config.ContainerdVersion = "1.6.26"
+		config.RuncVersion = "1.1.9"
+		config.CNIPluginVersion = "1.3.0"
+		config.PackageVersionOverrides = map[string]string{"azure-cni": "1.4.54"}
+		Expect(ValidateConfigForVHD(config, cached)).To(MatchError("the VHD does not cache the dependencies of the config: " +
+			"kubernetes 1.30.0, containerd 1.6.26, runc 1.1.9, cni-plugins 1.3.0, azure-cni 1.4.54"))
+	})
+
+	It("should require the default containerd and CNI plugins to be cached", func() {
+		cached.FromManifest.Containerd = cache.Dependency{}
+		cached.FromComponentDownloadedFiles = nil
+		Expect(ValidateConfigForVHD(config, cached)).To(MatchError(ContainSubstring("containerd, cni-plugins")))
+	})
+
+	It("should not require the kubernetes version with a custom kube binary", func() {
+		config.ContainerService.Properties.OrchestratorProfile.OrchestratorVersion = "1.30.0"
+		config.ContainerService.Properties.OrchestratorProfile.KubernetesConfig = &datamodel.KubernetesConfig{
+			CustomKubeBinaryURL: "https://acs-mirror.azureedge.net/kubernetes/v1.30.0/binaries/kubernetes-node-linux-amd64.tar.gz",
+		}
+		Expect(ValidateConfigForVHD(config, cached)).To(Succeed())
+	})
+
+	It("should return an error for Windows nodes or without the VHD cache", func() {
+		Expect(ValidateConfigForVHD(config, nil)).NotTo(Succeed())
+		config.AgentPoolProfile = &datamodel.AgentPoolProfile{OSType: datamodel.Windows}
+		Expect(ValidateConfigForVHD(config, cached)).NotTo(Succeed())
+	})
+})